@@ -116,7 +116,8 @@ func TestStation12(t *testing.T) {
 				CreatedAt:   time.Now(),
 				UpdatedAt:   time.Now(),
 			}
-			if diff := cmp.Diff(got, want, cmpopts.EquateApproxTime(time.Second)); diff != "" {
+			// サブテストは同じ TODO を更新するため、version は実行順によって変わる
+			if diff := cmp.Diff(got, want, cmpopts.EquateApproxTime(time.Second), cmpopts.IgnoreFields(model.TODO{}, "Version")); diff != "" {
 				t.Error("期待していない値です\n", diff)
 			}
 		})
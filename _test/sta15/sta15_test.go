@@ -20,14 +20,17 @@ func TestStation15(t *testing.T) {
 		{
 			ID:      3,
 			Subject: "todo subject 3",
+			Version: 1,
 		},
 		{
 			ID:      2,
 			Subject: "todo subject 2",
+			Version: 1,
 		},
 		{
 			ID:      1,
 			Subject: "todo subject 1",
+			Version: 1,
 		},
 	}
 
@@ -75,6 +75,7 @@ func TestStation8(t *testing.T) {
 			want := &model.TODO{
 				Subject:     tc.Subject,
 				Description: tc.Description,
+				Version:     1,
 				CreatedAt:   time.Now(),
 				UpdatedAt:   time.Now(),
 			}
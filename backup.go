@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/TechBowl-japan/go-stations/backup"
+	"github.com/TechBowl-japan/go-stations/db"
+)
+
+// backupUsage は backup, restore サブコマンドの使い方です。
+const backupUsage = `usage: stations backup [-o file]
+       stations restore [-check] file
+
+backup   write a snapshot of the running database to BACKUP_DIR (or to file with -o)
+restore  replace the database with the backup file after checking that its schema is compatible;
+         stop the server before restoring. -check only checks the file
+`
+
+// runBackup は backup サブコマンドを実行します。args は "backup" より後ろの引数です。
+// manager は SQLite 以外のドライバーの場合 nil です。
+func runBackup(ctx context.Context, manager *backup.Manager, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	flags.SetOutput(out)
+	output := flags.String("o", "", "write the snapshot to this file instead of BACKUP_DIR")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprint(out, backupUsage)
+		return fmt.Errorf("backup: unexpected arguments %v", flags.Args())
+	}
+	if manager == nil {
+		return fmt.Errorf("backup: only DB_DRIVER=%s is supported", db.DriverSQLite)
+	}
+
+	if *output != "" {
+		if err := manager.Snapshot(ctx, *output); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "wrote %s\n", *output)
+		return nil
+	}
+
+	b, err := manager.Create(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "created %s (%d bytes)\n", b.Name, b.Size)
+	return nil
+}
+
+// runRestore は restore サブコマンドを実行します。args は "restore" より後ろの引数です。
+func runRestore(ctx context.Context, driver, dsn string, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	flags.SetOutput(out)
+	check := flags.Bool("check", false, "only check that the backup can be restored")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprint(out, backupUsage)
+		return fmt.Errorf("restore: backup file is required")
+	}
+	if driver != db.DriverSQLite {
+		return fmt.Errorf("restore: only DB_DRIVER=%s is supported", db.DriverSQLite)
+	}
+
+	path := flags.Arg(0)
+	if *check {
+		if err := db.CheckBackup(ctx, path); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s can be restored\n", path)
+		return nil
+	}
+
+	if err := db.Restore(ctx, path, dsn); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "restored %s from %s\n", dsn, path)
+	return nil
+}
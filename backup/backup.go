@@ -0,0 +1,201 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/periodic"
+)
+
+const (
+	// filePrefix, fileSuffix は、Manager が作成するバックアップのファイル名の前後です。
+	// ローテーションではこの形式のファイルだけを削除します。
+	filePrefix = "todo-"
+	fileSuffix = ".db"
+	// timeLayout は、ファイル名に含める作成日時 (UTC) の形式です。名前の順に並べると作成日時の順になります。
+	timeLayout = "20060102T150405Z"
+)
+
+// A Manager creates snapshots of the SQLite database in a directory and keeps only the newest ones.
+type Manager struct {
+	dbPath string
+	dir    string
+	keep   int
+
+	// mu は、同時に作成したバックアップのローテーションが重ならないようにします。
+	mu sync.Mutex
+}
+
+// NewManager returns new Manager that backs up the SQLite database at dbPath into dir.
+// keep 件より古いバックアップは削除します。keep が 0 以下の場合は削除しません。
+func NewManager(dbPath, dir string, keep int) *Manager {
+	return &Manager{
+		dbPath: dbPath,
+		dir:    dir,
+		keep:   keep,
+	}
+}
+
+// Create creates a new backup in the directory and deletes backups beyond the number to keep.
+func (m *Manager) Create(ctx context.Context) (*model.Backup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	// 同じ秒に作成した場合は前のバックアップを上書きする
+	name := filePrefix + time.Now().UTC().Format(timeLayout) + fileSuffix
+	path := filepath.Join(m.dir, name)
+	if err := db.Backup(ctx, m.dbPath, path); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.rotate(); err != nil {
+		return nil, err
+	}
+	return newBackup(info), nil
+}
+
+// List returns the backups in the directory, newest first.
+func (m *Manager) List() ([]*model.Backup, error) {
+	infos, err := m.files()
+	if err != nil {
+		return nil, err
+	}
+	backups := make([]*model.Backup, len(infos))
+	for i, info := range infos {
+		backups[i] = newBackup(info)
+	}
+	return backups, nil
+}
+
+// Snapshot writes a consistent snapshot of the database to path. It is not rotated nor listed
+// unless path is in the backup directory.
+func (m *Manager) Snapshot(ctx context.Context, path string) error {
+	return db.Backup(ctx, m.dbPath, path)
+}
+
+// WriteSnapshot writes a consistent snapshot of the database to w, and returns the number of bytes written.
+// スナップショットは一時ファイルに作成し、書き込んだ後に削除します。バックアップの一覧には含まれません。
+func (m *Manager) WriteSnapshot(ctx context.Context, w io.Writer) (int64, error) {
+	dir, err := ioutil.TempDir("", "todo-snapshot")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot"+fileSuffix)
+	if err := m.Snapshot(ctx, path); err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.Copy(w, f)
+}
+
+// files は、ディレクトリにあるバックアップのファイルを新しい順に返します。ディレクトリがない場合は空です。
+func (m *Manager) files() ([]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(m.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []os.FileInfo{}, nil
+		}
+		return nil, err
+	}
+
+	files := []os.FileInfo{}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		files = append(files, info)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() > files[j].Name()
+	})
+	return files, nil
+}
+
+// rotate は、新しい順に keep 件を残して古いバックアップを削除します。
+func (m *Manager) rotate() error {
+	if m.keep <= 0 {
+		return nil
+	}
+	files, err := m.files()
+	if err != nil {
+		return err
+	}
+	for i := m.keep; i < len(files); i++ {
+		if err := os.Remove(filepath.Join(m.dir, files[i].Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newBackup は info のファイルを model.Backup に変換します。作成日時はファイル名から求めます。
+func newBackup(info os.FileInfo) *model.Backup {
+	createdAt := info.ModTime().UTC()
+	stamp := strings.TrimSuffix(strings.TrimPrefix(info.Name(), filePrefix), fileSuffix)
+	if t, err := time.Parse(timeLayout, stamp); err == nil {
+		createdAt = t
+	}
+	return &model.Backup{
+		Name:      info.Name(),
+		Size:      info.Size(),
+		CreatedAt: createdAt,
+	}
+}
+
+// A Scheduler periodically creates backups.
+type Scheduler struct {
+	manager  *Manager
+	interval time.Duration
+}
+
+// NewScheduler returns new Scheduler.
+func NewScheduler(manager *Manager, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		manager:  manager,
+		interval: interval,
+	}
+}
+
+// Run は ctx がキャンセルされるまで interval ごとにバックアップを作成します。
+// 起動直後ではなく、最初の interval が経過してから作成します。
+func (s *Scheduler) Run(ctx context.Context) {
+	periodic.Run(ctx, s.interval, s.tick)
+}
+
+// tick はバックアップを 1 つ作成します。
+func (s *Scheduler) tick(ctx context.Context) {
+	b, err := s.manager.Create(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Println("backup: failed to create backup:", err)
+		}
+		return
+	}
+	log.Printf("backup: created %s (%d bytes)", b.Name, b.Size)
+}
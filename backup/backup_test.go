@@ -0,0 +1,65 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/backup"
+	"github.com/TechBowl-japan/go-stations/db"
+)
+
+func TestManager(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "todo.db")
+	d, err := db.NewDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// 古いバックアップを用意し、作成時にローテーションで削除されることを確認する
+	backupDir := filepath.Join(dir, "backups")
+	m := backup.NewManager(path, backupDir, 2)
+	if err := os.Mkdir(backupDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"todo-20000101T000000Z.db", "todo-20000102T000000Z.db", "other.db"} {
+		if err := m.Snapshot(ctx, filepath.Join(backupDir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	created, err := m.Create(ctx)
+	if err != nil {
+		t.Fatal("failed to create backup, err =", err)
+	}
+
+	backups, err := m.List()
+	if err != nil {
+		t.Fatal("failed to list backups, err =", err)
+	}
+	if len(backups) != 2 || backups[0].Name != created.Name || backups[1].Name != "todo-20000102T000000Z.db" {
+		t.Errorf("unexpected backups, given = %+v", backups)
+	}
+	if backups[1].CreatedAt.Year() != 2000 {
+		t.Errorf("created_at should be parsed from the name, given = %v", backups[1].CreatedAt)
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(backupDir, "other.db")); err != nil {
+		t.Error("files not created by Manager should be kept, err =", err)
+	}
+
+	var buf bytes.Buffer
+	n, err := m.WriteSnapshot(ctx, &buf)
+	if err != nil {
+		t.Fatal("failed to write snapshot, err =", err)
+	}
+	if n == 0 || !bytes.HasPrefix(buf.Bytes(), []byte("SQLite format 3\x00")) {
+		t.Errorf("unexpected snapshot, size = %d", n)
+	}
+}
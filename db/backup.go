@@ -0,0 +1,198 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrIncompatibleBackup は、バックアップのスキーマをこのサーバーで扱えない場合のエラーを表します。
+type ErrIncompatibleBackup struct {
+	Reason string
+}
+
+// Error メソッドを実装し、error インターフェースを満たします。
+func (e *ErrIncompatibleBackup) Error() string {
+	return "incompatible backup: " + e.Reason
+}
+
+// IsErrIncompatibleBackup は err が *ErrIncompatibleBackup を含むかを返します。
+func IsErrIncompatibleBackup(err error) bool {
+	var incompatible *ErrIncompatibleBackup
+	return errors.As(err, &incompatible)
+}
+
+// Backup writes a consistent snapshot of the SQLite database at srcPath to destPath
+// using the online backup API, without stopping writers to the database.
+//
+// 書き込み中のファイルが見えないよう、destPath と同じディレクトリの一時ファイルに書き込んでから名前を変えます。
+func Backup(ctx context.Context, srcPath, destPath string) error {
+	tmp := destPath + ".tmp"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := copyDatabase(ctx, srcPath, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, destPath)
+}
+
+// Restore replaces the contents of the SQLite database at dbPath with the backup at backupPath,
+// and applies the migrations that the backup is missing.
+//
+// バックアップがこのサーバーのマイグレーションと互換でない場合は、何も変更せずに *ErrIncompatibleBackup を返します。
+// 復元は online backup API でデータベースを丸ごと置き換えるため、復元中の他の接続の書き込みは失われます。
+// サーバーを停止してから実行してください。
+func Restore(ctx context.Context, backupPath, dbPath string) error {
+	if err := CheckBackup(ctx, backupPath); err != nil {
+		return err
+	}
+	if err := copyDatabase(ctx, backupPath, dbPath); err != nil {
+		return err
+	}
+
+	d, err := Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	migrator, err := NewMigrator(d)
+	if err != nil {
+		return err
+	}
+	_, err = migrator.Up(ctx, 0)
+	return err
+}
+
+// CheckBackup checks that the SQLite database at path is intact and that its schema can be
+// migrated to the schema of this server. It doesn't modify the file.
+//
+// バックアップに適用済みのマイグレーションは、すべてこのサーバーが持つものと同じである必要があります。
+// バックアップの方が古い場合は互換とみなし、復元後に残りのマイグレーションを適用します。
+func CheckBackup(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	dsn, err := fileURI(path, "mode=ro")
+	if err != nil {
+		return err
+	}
+	d, err := Open(dsn)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	var integrity string
+	if err := d.QueryRowContext(ctx, `PRAGMA quick_check`).Scan(&integrity); err != nil {
+		return &ErrIncompatibleBackup{Reason: fmt.Sprintf("%s is not a SQLite database: %v", path, err)}
+	}
+	if integrity != "ok" {
+		return &ErrIncompatibleBackup{Reason: fmt.Sprintf("%s is corrupted: %s", path, integrity)}
+	}
+
+	var hasTable bool
+	if err := d.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`).Scan(&hasTable); err != nil {
+		return err
+	}
+	if !hasTable {
+		return &ErrIncompatibleBackup{Reason: fmt.Sprintf("%s has no schema_migrations table", path)}
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	known := make(map[int]*Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	rows, err := d.QueryContext(ctx, `SELECT version, checksum FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			version  int
+			checksum string
+		)
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return err
+		}
+		m, ok := known[version]
+		if !ok {
+			return &ErrIncompatibleBackup{Reason: fmt.Sprintf("migration %04d of the backup is newer than this server", version)}
+		}
+		if checksum != m.Checksum() {
+			return &ErrIncompatibleBackup{Reason: fmt.Sprintf("migration %04d_%s of the backup differs from this server", m.Version, m.Name)}
+		}
+	}
+	return rows.Err()
+}
+
+// copyDatabase は、srcPath のデータベースの内容を online backup API で destPath にコピーします。
+// destPath が存在しない場合は作成します。
+//
+// 1 回のステップですべてのページをコピーするため、コピー中は srcPath への書き込みを待たせますが、
+// 他の接続の書き込みでコピーがやり直しになることはありません。
+func copyDatabase(ctx context.Context, srcPath, destPath string) error {
+	src, err := openSQLiteConn(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := openSQLiteConn(destPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b, err := dest.Backup("main", src, "main")
+	if err != nil {
+		return err
+	}
+	if _, err := b.Step(-1); err != nil {
+		b.Finish()
+		return err
+	}
+	return b.Finish()
+}
+
+// openSQLiteConn は、online backup API を呼ぶために *sql.DB を介さずに SQLite の接続を開きます。
+func openSQLiteConn(path string) (*sqlite3.SQLiteConn, error) {
+	dsn, err := fileURI(path, "")
+	if err != nil {
+		return nil, err
+	}
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*sqlite3.SQLiteConn), nil
+}
+
+// fileURI は、path のデータベースを query のパラメーター (mode=ro など) で開く URI 形式の DSN を返します。
+// ? や # を含むパスも開けるよう、パスはエスケープします。URI では相対パスを使えないため、絶対パスにします。
+func fileURI(path, query string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(abs), RawQuery: query}
+	return u.String(), nil
+}
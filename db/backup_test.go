@@ -0,0 +1,156 @@
+package db_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+)
+
+// countTODOs は path のデータベースの TODO の件数を返します。
+func countTODOs(t *testing.T, path string) int {
+	t.Helper()
+
+	d, err := db.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	var count int
+	if err := d.QueryRow(`SELECT COUNT(*) FROM todos`).Scan(&count); err != nil {
+		t.Fatal("failed to count TODOs, err =", err)
+	}
+	return count
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "todo.db")
+	backupPath := filepath.Join(dir, "backup.db")
+
+	d, err := db.NewDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := d.Exec(`INSERT INTO todos(subject) VALUES ('before')`); err != nil {
+		t.Fatal(err)
+	}
+
+	// 接続を開いたままバックアップできること
+	if err := db.Backup(ctx, path, backupPath); err != nil {
+		t.Fatal("failed to back up, err =", err)
+	}
+	if _, err := d.Exec(`INSERT INTO todos(subject) VALUES ('after')`); err != nil {
+		t.Fatal(err)
+	}
+	if got := countTODOs(t, backupPath); got != 1 {
+		t.Errorf("unexpected TODOs in the backup, given = %d, expected = %d", got, 1)
+	}
+
+	if err := db.Restore(ctx, backupPath, path); err != nil {
+		t.Fatal("failed to restore, err =", err)
+	}
+	if got := countTODOs(t, path); got != 1 {
+		t.Errorf("unexpected TODOs after restore, given = %d, expected = %d", got, 1)
+	}
+}
+
+func TestCheckBackup(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// 古いスキーマのバックアップは、復元時に残りのマイグレーションを適用する
+	older := filepath.Join(dir, "older.db")
+	d, err := db.Open(older)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := db.NewMigrator(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+	if err := db.CheckBackup(ctx, older); err != nil {
+		t.Errorf("older backup should be compatible, err = %v", err)
+	}
+	target := filepath.Join(dir, "target.db")
+	if err := db.Restore(ctx, older, target); err != nil {
+		t.Fatal("failed to restore the older backup, err =", err)
+	}
+	d, err = db.Open(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if m, err = db.NewMigrator(d); err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := db.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := countApplied(t, m); got != len(migrations) {
+		t.Errorf("unexpected applied count after restore, given = %d, expected = %d", got, len(migrations))
+	}
+
+	cases := map[string]string{
+		"Newer":    `INSERT INTO schema_migrations(version, name, checksum) VALUES (9999, 'future', '')`,
+		"Modified": `UPDATE schema_migrations SET checksum = 'modified' WHERE version = 1`,
+	}
+	for name, query := range cases {
+		path := filepath.Join(dir, name+".db")
+		d, err := db.NewDB(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+		d.Close()
+
+		if err := db.CheckBackup(ctx, path); !db.IsErrIncompatibleBackup(err) {
+			t.Errorf("%s: unexpected error, given = %v, expected = incompatible backup", name, err)
+		}
+		if err := db.Restore(ctx, path, target); !db.IsErrIncompatibleBackup(err) {
+			t.Errorf("%s: restore should fail, err = %v", name, err)
+		}
+	}
+
+	// URI で特別な意味を持つ文字を含むパスのバックアップも確認・復元できる
+	special := filepath.Join(dir, "back?up #1 %41.db")
+	if err := db.Backup(ctx, target, special); err != nil {
+		t.Fatal("failed to back up to a path with special characters, err =", err)
+	}
+	if err := db.CheckBackup(ctx, special); err != nil {
+		t.Errorf("unexpected error for a path with special characters, err = %v", err)
+	}
+	if err := db.Restore(ctx, special, filepath.Join(dir, "restored.db")); err != nil {
+		t.Errorf("failed to restore from a path with special characters, err = %v", err)
+	}
+	if files, err := filepath.Glob(filepath.Join(dir, "back*")); err != nil || len(files) != 1 {
+		t.Errorf("unexpected files, given = %v, expected = [%s]", files, special)
+	}
+
+	notDB := filepath.Join(dir, "not.db")
+	if err := ioutil.WriteFile(notDB, []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CheckBackup(ctx, notDB); !db.IsErrIncompatibleBackup(err) {
+		t.Errorf("unexpected error for a file that is not a database, given = %v", err)
+	}
+	if err := db.CheckBackup(ctx, filepath.Join(dir, "missing.db")); err == nil {
+		t.Error("checking a missing file should fail")
+	}
+}
@@ -1,25 +1,143 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	_ "embed"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-//go:embed schema.sql
-var schema string
+//go:embed fts.sql
+var ftsSchema string
 
-// NewDB returns go-sqlite3 driver based *sql.DB.
+// ftsTriggers は、todos_fts を todos と同期するトリガーです。
+var ftsTriggers = []string{
+	"trigger_todos_fts_insert",
+	"trigger_todos_fts_delete",
+	"trigger_todos_fts_update",
+}
+
+// NewDB returns go-sqlite3 driver based *sql.DB, applying all pending migrations.
+// It opens the database with DefaultOptions.
 func NewDB(path string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", path)
+	return NewDBWithOptions(DriverSQLite, path, DefaultOptions())
+}
+
+// NewDBWithDriver returns *sql.DB of driver (sqlite3 or postgres) connected to dsn,
+// applying all pending migrations. For sqlite3, dsn is the path of the database file.
+func NewDBWithDriver(driver, dsn string) (*sql.DB, error) {
+	return NewDBWithOptions(driver, dsn, DefaultOptions())
+}
+
+// NewDBWithOptions is like NewDBWithDriver, but opens the database with opts.
+func NewDBWithOptions(driver, dsn string, opts Options) (*sql.DB, error) {
+	db, err := OpenWithOptions(driver, dsn, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err := db.Exec(schema); err != nil {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := migrator.Up(context.Background(), 0); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// 全文検索は SQLite の FTS5 でのみ提供する。PostgreSQL では LIKE で検索する
+	if driver == DriverSQLite {
+		if err := setupFTS(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// Open は path の SQLite データベースを、マイグレーションを適用せずに開きます。
+func Open(path string) (*sql.DB, error) {
+	return OpenWithDriver(DriverSQLite, path)
+}
+
+// OpenWithDriver は dsn のデータベースを driver で、マイグレーションを適用せずに開きます。
+// 接続の設定は変更しません。
+func OpenWithDriver(driver, dsn string) (*sql.DB, error) {
+	if err := checkDriver(driver); err != nil {
+		return nil, err
+	}
+	return sql.Open(driver, dsn)
+}
+
+// OpenWithOptions は dsn のデータベースを driver と opts の設定で、マイグレーションを適用せずに開きます。
+func OpenWithOptions(driver, dsn string, opts Options) (*sql.DB, error) {
+	if err := checkDriver(driver); err != nil {
 		return nil, err
 	}
+	if driver == DriverSQLite {
+		if err := opts.validate(); err != nil {
+			return nil, err
+		}
+		dsn = opts.sqliteDSN(dsn)
+	}
 
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	opts.configurePool(db)
 	return db, nil
 }
+
+// HasFTS5 は、go-sqlite3 が FTS5 を有効にしてビルドされているか (-tags sqlite_fts5) を返します。
+func HasFTS5(db *sql.DB) (bool, error) {
+	var used bool
+	if err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&used); err != nil {
+		return false, err
+	}
+	return used, nil
+}
+
+// setupFTS は、FTS5 が使える場合に全文検索用の todos_fts とトリガーを作成します。
+//
+// FTS5 が使えない場合は、todos への書き込みが失敗しないようトリガーを削除します。
+// その間の変更は todos_fts に反映されないため、次に FTS5 が使えるようになったときに
+// トリガーが欠けていれば索引を作り直します。
+func setupFTS(db *sql.DB) error {
+	available, err := HasFTS5(db)
+	if err != nil {
+		return err
+	}
+
+	if !available {
+		for _, name := range ftsTriggers {
+			if _, err := db.Exec(`DROP TRIGGER IF EXISTS ` + name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var existing int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name IN (?, ?, ?)`,
+		ftsTriggers[0], ftsTriggers[1], ftsTriggers[2]).Scan(&existing)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(ftsSchema); err != nil {
+		return err
+	}
+
+	if existing < len(ftsTriggers) {
+		if _, err := db.Exec(`INSERT INTO todos_fts(todos_fts) VALUES ('rebuild')`); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
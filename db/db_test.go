@@ -35,7 +35,11 @@ func TestNewDB(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			_, err := db.NewDB(c.path)
+			d, err := db.NewDB(c.path)
+			if err == nil {
+				// WAL モードの -wal, -shm ファイルを残さないよう閉じる
+				d.Close()
+			}
 			if err != nil {
 				if errors.Is(c.err, err) {
 					t.Errorf("unexpected value, given = %s, expected = %s\n", err, c.err)
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// ドライバー名です。database/sql に登録されている名前と同じです。
+const (
+	DriverSQLite   = "sqlite3"
+	DriverPostgres = "postgres"
+)
+
+// postgresNow は、PostgreSQL で DATETIME('now') と同じく UTC の現在時刻を秒単位で返す式です。
+const postgresNow = `DATE_TRUNC('second', STATEMENT_TIMESTAMP() AT TIME ZONE 'UTC')`
+
+// DriverName returns the name of the driver that conn was opened with.
+// It returns an empty string for drivers other than SQLite and PostgreSQL.
+func DriverName(conn *sql.DB) string {
+	switch conn.Driver().(type) {
+	case *sqlite3.SQLiteDriver:
+		return DriverSQLite
+	case *pq.Driver:
+		return DriverPostgres
+	default:
+		return ""
+	}
+}
+
+// Rebind rewrites query written for SQLite so that it runs on driver.
+//
+// PostgreSQL の場合は、プレースホルダの ? を $1, $2, ... に、DATETIME('now') を同じ値を返す式に置き換えます。
+// 文字列リテラルの中の ? は置き換えません。
+// SQLite の場合は、SQLite にない FOR UPDATE を取り除きます。SQLite ではトランザクションが
+// データベース全体の書き込みのロックを取るため、行のロックは不要です。
+func Rebind(driver, query string) string {
+	if driver != DriverPostgres {
+		return strings.ReplaceAll(query, " FOR UPDATE", "")
+	}
+
+	query = strings.ReplaceAll(query, `DATETIME('now')`, postgresNow)
+
+	var (
+		b        strings.Builder
+		n        int
+		inString bool
+	)
+	b.Grow(len(query) + 8)
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+		case r == '?' && !inString:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// checkDriver は driver が対応しているドライバーかを確認します。
+func checkDriver(driver string) error {
+	switch driver {
+	case DriverSQLite, DriverPostgres:
+		return nil
+	default:
+		return fmt.Errorf("db: unsupported driver %q, must be %s or %s", driver, DriverSQLite, DriverPostgres)
+	}
+}
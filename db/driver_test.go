@@ -0,0 +1,74 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+)
+
+func TestRebind(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		driver, query, expected string
+	}{
+		"SQLite": {
+			driver:   db.DriverSQLite,
+			query:    `UPDATE todos SET deleted_at = DATETIME('now') WHERE id = ?`,
+			expected: `UPDATE todos SET deleted_at = DATETIME('now') WHERE id = ?`,
+		},
+		"SQLite FOR UPDATE": {
+			driver:   db.DriverSQLite,
+			query:    `SELECT version FROM todos WHERE id = ? FOR UPDATE`,
+			expected: `SELECT version FROM todos WHERE id = ?`,
+		},
+		"Postgres FOR UPDATE": {
+			driver:   db.DriverPostgres,
+			query:    `SELECT version FROM todos WHERE id = ? FOR UPDATE`,
+			expected: `SELECT version FROM todos WHERE id = $1 FOR UPDATE`,
+		},
+		"Postgres": {
+			driver:   db.DriverPostgres,
+			query:    `UPDATE todos SET deleted_at = DATETIME('now') WHERE id IN (?,?)`,
+			expected: `UPDATE todos SET deleted_at = DATE_TRUNC('second', STATEMENT_TIMESTAMP() AT TIME ZONE 'UTC') WHERE id IN ($1,$2)`,
+		},
+		"Postgres string literal": {
+			driver:   db.DriverPostgres,
+			query:    `SELECT 'what?', 'it''s ?' FROM todos WHERE subject LIKE ? ESCAPE '\'`,
+			expected: `SELECT 'what?', 'it''s ?' FROM todos WHERE subject LIKE $1 ESCAPE '\'`,
+		},
+	}
+
+	for name, c := range cases {
+		if given := db.Rebind(c.driver, c.query); given != c.expected {
+			t.Errorf("%s: unexpected query, given = %s, expected = %s", name, given, c.expected)
+		}
+	}
+}
+
+// PostgreSQL のマイグレーションは、SQLite のマイグレーションとバージョンと名前が揃っていること
+func TestMigrationsForDriver_Postgres(t *testing.T) {
+	t.Parallel()
+
+	sqlite, err := db.MigrationsForDriver(db.DriverSQLite)
+	if err != nil {
+		t.Fatal("failed to load sqlite migrations, err =", err)
+	}
+	postgres, err := db.MigrationsForDriver(db.DriverPostgres)
+	if err != nil {
+		t.Fatal("failed to load postgres migrations, err =", err)
+	}
+
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("unexpected number of postgres migrations, given = %d, expected = %d", len(postgres), len(sqlite))
+	}
+	for i, m := range postgres {
+		if m.Version != sqlite[i].Version || m.Name != sqlite[i].Name {
+			t.Errorf("unexpected postgres migration, given = %04d_%s, expected = %04d_%s", m.Version, m.Name, sqlite[i].Version, sqlite[i].Name)
+		}
+	}
+
+	if _, err := db.MigrationsForDriver("mysql"); err == nil {
+		t.Error("loading migrations of unsupported driver should fail")
+	}
+}
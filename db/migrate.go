@@ -0,0 +1,434 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// SQLite のマイグレーションは migrations に、PostgreSQL のマイグレーションは migrations/postgres に置きます。
+// 両者のバージョンと名前は揃えてください。
+//
+//go:embed migrations/*.sql migrations/postgres/*.sql
+var migrationFS embed.FS
+
+// migrationDirs は、ドライバーごとのマイグレーションのディレクトリです。
+var migrationDirs = map[string]string{
+	DriverSQLite:   "migrations",
+	DriverPostgres: "migrations/postgres",
+}
+
+// migrationFile は、マイグレーションのファイル名 (0001_create_todos.up.sql など) の形式です。
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// legacyProbes は、マイグレーションを導入する前の schema.sql で作成されたデータベースに、各バージョンの
+// マイグレーションの変更が含まれているかを確認するクエリです。schema.sql はカラムやテーブルを追加するたびに
+// CREATE TABLE を書き換えていたため、途中の版で作成されたデータベースには一部のマイグレーションの変更だけが含まれます。
+var legacyProbes = []struct {
+	version int
+	probe   string
+}{
+	{1, `SELECT id, subject, description, created_at, updated_at FROM todos`},
+	{2, `SELECT completed_at FROM todos`},
+	{3, `SELECT due_at, reminded_at FROM todos`},
+	{4, `SELECT priority FROM todos`},
+	{5, `SELECT tags.id, todo_tags.todo_id FROM tags, todo_tags`},
+	{6, `SELECT parent_id FROM todos`},
+	{7, `SELECT recurrence, occurrence, previous_id FROM todos`},
+	{8, `SELECT lists.id, todos.list_id FROM lists, todos`},
+	{9, `SELECT deleted_at FROM todos`},
+	{10, `SELECT id FROM todo_revisions`},
+	{11, `SELECT version FROM todos`},
+}
+
+// A Migration is a schema change with the SQL to apply it and to roll it back.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum は Up と Down の SQL の SHA-256 です。適用後にファイルが編集されたことを検出するために記録します。
+func (m *Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up + "\x00" + m.Down))
+	return hex.EncodeToString(sum[:])
+}
+
+// A MigrationStatus expresses whether a migration has been applied to the database.
+type MigrationStatus struct {
+	*Migration
+	Applied   bool
+	AppliedAt *time.Time
+	// Modified は、適用後にマイグレーションのファイルが編集されたことを表します。
+	Modified bool
+}
+
+// ErrChecksumMismatch は、適用済みのマイグレーションのファイルが編集されている場合のエラーを表します。
+type ErrChecksumMismatch struct {
+	Version int
+	Name    string
+}
+
+// Error メソッドを実装し、error インターフェースを満たします。
+func (e *ErrChecksumMismatch) Error() string {
+	return fmt.Sprintf("migration %04d_%s has been modified after it was applied", e.Version, e.Name)
+}
+
+// Migrations は埋め込まれた SQLite のマイグレーションをバージョンの昇順で返します。
+func Migrations() ([]*Migration, error) {
+	return MigrationsForDriver(DriverSQLite)
+}
+
+// MigrationsForDriver は埋め込まれた driver のマイグレーションをバージョンの昇順で返します。
+func MigrationsForDriver(driver string) ([]*Migration, error) {
+	if err := checkDriver(driver); err != nil {
+		return nil, err
+	}
+	return loadMigrations(migrationFS, migrationDirs[driver])
+}
+
+// loadMigrations は dir にある up と down の SQL を組にして、バージョンの昇順で返します。
+func loadMigrations(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		// 他のドライバーのマイグレーションのディレクトリは読まない
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration: invalid file name %q", entry.Name())
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, err
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration: version %d has two names, %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration: %04d_%s needs both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// A Migrator applies and rolls back migrations, recording them in the schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	driver     string
+	migrations []*Migration
+
+	// DryRun が true の場合、マイグレーションをトランザクション内で実行した後にロールバックし、
+	// データベースを変更しません。
+	DryRun bool
+	// Log には、実行したマイグレーションとその SQL を書き込みます。nil の場合は書き込みません。
+	Log io.Writer
+}
+
+// NewMigrator returns new Migrator for the embedded migrations of the driver that db was opened with.
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	driver := DriverName(db)
+	migrations, err := MigrationsForDriver(driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		driver:     driver,
+		migrations: migrations,
+	}, nil
+}
+
+// ensureTable は schema_migrations がなければ作成します。
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	const create = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER  NOT NULL PRIMARY KEY,
+		name       TEXT     NOT NULL,
+		checksum   TEXT     NOT NULL,
+		applied_at DATETIME NOT NULL DEFAULT (DATETIME('now'))
+	)`
+	// PostgreSQL には DATETIME 型がないため、TIMESTAMP で作成する
+	const createPostgres = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER   NOT NULL PRIMARY KEY,
+		name       TEXT      NOT NULL,
+		checksum   TEXT      NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT (DATETIME('now'))
+	)`
+
+	query := create
+	if m.driver == DriverPostgres {
+		query = createPostgres
+	}
+	_, err := m.db.ExecContext(ctx, Rebind(m.driver, query))
+	return err
+}
+
+// Status returns the status of every migration in ascending order of version.
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	const read = `SELECT version, checksum, applied_at FROM schema_migrations`
+
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, read)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type applied struct {
+		checksum string
+		at       time.Time
+	}
+	done := map[int]applied{}
+	for rows.Next() {
+		var (
+			version int
+			a       applied
+		)
+		if err := rows.Scan(&version, &a.checksum, &a.at); err != nil {
+			return nil, err
+		}
+		done[version] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		status := &MigrationStatus{Migration: migration}
+		if a, ok := done[migration.Version]; ok {
+			at := a.at
+			status.Applied = true
+			status.AppliedAt = &at
+			status.Modified = a.checksum != migration.Checksum()
+		}
+		statuses[i] = status
+	}
+	return statuses, nil
+}
+
+// Up applies pending migrations up to and including target in ascending order of version,
+// and returns the applied ones. A target of 0 applies all of them.
+//
+// 適用済みのマイグレーションが編集されている場合は、何も適用せずに *ErrChecksumMismatch を返します。
+// マイグレーションを導入する前の schema.sql で作成されたデータベースは、含まれている変更を実行せずに適用済みとして記録します。
+// 各マイグレーションはそれぞれのトランザクションで適用します。DryRun の場合は 1 つのトランザクションで
+// すべて実行してからロールバックします。
+func (m *Migrator) Up(ctx context.Context, target int) ([]*Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := m.legacyVersion(ctx, statuses)
+	if err != nil {
+		return nil, err
+	}
+
+	var adopted, pending []*Migration
+	for _, status := range statuses {
+		if status.Modified {
+			return nil, &ErrChecksumMismatch{Version: status.Version, Name: status.Name}
+		}
+		if status.Applied || (target != 0 && status.Version > target) {
+			continue
+		}
+		if status.Version <= legacy {
+			adopted = append(adopted, status.Migration)
+		} else {
+			pending = append(pending, status.Migration)
+		}
+	}
+
+	record := func(ctx context.Context, tx *sql.Tx, migration *Migration) error {
+		_, err := tx.ExecContext(ctx, Rebind(m.driver, `INSERT INTO schema_migrations(version, name, checksum) VALUES(?, ?, ?)`),
+			migration.Version, migration.Name, migration.Checksum())
+		return err
+	}
+	// schema.sql で作成済みの変更は実行せず、適用済みとして記録だけする
+	if err := m.run(ctx, "adopt", adopted, record); err != nil {
+		return nil, err
+	}
+	err = m.run(ctx, "up", pending, func(ctx context.Context, tx *sql.Tx, migration *Migration) error {
+		if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+			return err
+		}
+		return record(ctx, tx, migration)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(adopted, pending...), nil
+}
+
+// legacyVersion は、マイグレーションを導入する前の schema.sql で作成されたデータベースが、どのバージョンまでの
+// マイグレーションの変更を含んでいるかを返します。マイグレーションを 1 つでも適用済みのデータベースや、
+// 空のデータベースの場合は 0 を返します。
+func (m *Migrator) legacyVersion(ctx context.Context, statuses []*MigrationStatus) (int, error) {
+	// schema.sql は SQLite でしか使っていない
+	if m.driver != DriverSQLite {
+		return 0, nil
+	}
+	for _, status := range statuses {
+		if status.Applied {
+			return 0, nil
+		}
+	}
+
+	// schema.sql の各版は、それまでのマイグレーションの変更をすべて含むため、最初に見つからない変更の手前までを返す
+	version := 0
+	for _, p := range legacyProbes {
+		rows, err := m.db.QueryContext(ctx, p.probe+` LIMIT 0`)
+		if err != nil {
+			// no such table や no such column の場合は、その変更を含まない
+			break
+		}
+		if err := rows.Close(); err != nil {
+			return 0, err
+		}
+		version = p.version
+	}
+	return version, nil
+}
+
+// Down rolls back the last steps applied migrations in descending order of version,
+// and returns the rolled back ones.
+//
+// ロールバックするマイグレーションが編集されている場合は、何もせずに *ErrChecksumMismatch を返します。
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []*Migration
+	for i := len(statuses) - 1; i >= 0 && len(targets) < steps; i-- {
+		status := statuses[i]
+		if !status.Applied {
+			continue
+		}
+		if status.Modified {
+			return nil, &ErrChecksumMismatch{Version: status.Version, Name: status.Name}
+		}
+		targets = append(targets, status.Migration)
+	}
+
+	err = m.run(ctx, "down", targets, func(ctx context.Context, tx *sql.Tx, migration *Migration) error {
+		if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, Rebind(m.driver, `DELETE FROM schema_migrations WHERE version = ?`), migration.Version)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// run は migrations を順に exec で実行します。direction (up, down, adopt) はログに使います。
+// DryRun の場合は最後にロールバックします。
+func (m *Migrator) run(ctx context.Context, direction string, migrations []*Migration, exec func(context.Context, *sql.Tx, *Migration) error) error {
+	if len(migrations) == 0 {
+		return nil
+	}
+
+	// DryRun の場合は、後のマイグレーションが前のマイグレーションの結果を使えるよう 1 つのトランザクションで実行する
+	var dryRunTx *sql.Tx
+	if m.DryRun {
+		tx, err := m.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		dryRunTx = tx
+	}
+
+	for _, migration := range migrations {
+		m.logf("%s %04d_%s\n", direction, migration.Version, migration.Name)
+		if m.DryRun && direction != "adopt" {
+			query := migration.Up
+			if direction == "down" {
+				query = migration.Down
+			}
+			m.logf("%s\n", query)
+		}
+
+		tx := dryRunTx
+		if tx == nil {
+			var err error
+			tx, err = m.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+		}
+		if err := exec(ctx, tx, migration); err != nil {
+			if !m.DryRun {
+				tx.Rollback()
+			}
+			return fmt.Errorf("migration %04d_%s %s: %w", migration.Version, migration.Name, direction, err)
+		}
+		if !m.DryRun {
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// logf は Log が設定されている場合に書き込みます。
+func (m *Migrator) logf(format string, args ...interface{}) {
+	if m.Log != nil {
+		fmt.Fprintf(m.Log, format, args...)
+	}
+}
+
+// IsErrChecksumMismatch は err が *ErrChecksumMismatch を含むかを返します。
+func IsErrChecksumMismatch(err error) bool {
+	var mismatch *ErrChecksumMismatch
+	return errors.As(err, &mismatch)
+}
@@ -0,0 +1,282 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+)
+
+// openMigrator は一時ディレクトリの空のデータベースと、その Migrator を返します。
+func openMigrator(t *testing.T) (*sql.DB, *db.Migrator) {
+	t.Helper()
+
+	d, err := db.Open(filepath.Join(t.TempDir(), "migrate_test.db"))
+	if err != nil {
+		t.Fatal("failed to open db, err =", err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	m, err := db.NewMigrator(d)
+	if err != nil {
+		t.Fatal("failed to create migrator, err =", err)
+	}
+	return d, m
+}
+
+// countApplied は適用済みのマイグレーションの数を返します。
+func countApplied(t *testing.T, m *db.Migrator) int {
+	t.Helper()
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal("failed to read status, err =", err)
+	}
+	applied := 0
+	for _, s := range statuses {
+		if s.Applied {
+			applied++
+		}
+	}
+	return applied
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	_, m := openMigrator(t)
+	migrations, err := db.Migrations()
+	if err != nil {
+		t.Fatal("failed to load migrations, err =", err)
+	}
+
+	if _, err := m.Up(ctx, 2); err != nil {
+		t.Fatal("failed to migrate up to 2, err =", err)
+	}
+	if got := countApplied(t, m); got != 2 {
+		t.Errorf("unexpected applied count after up to 2, given = %d, expected = %d", got, 2)
+	}
+
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatal("failed to migrate up, err =", err)
+	}
+	if got := countApplied(t, m); got != len(migrations) {
+		t.Errorf("unexpected applied count after up, given = %d, expected = %d", got, len(migrations))
+	}
+
+	// すべてロールバックしてから、もう一度適用できること
+	rolledBack, err := m.Down(ctx, len(migrations))
+	if err != nil {
+		t.Fatal("failed to migrate down, err =", err)
+	}
+	if len(rolledBack) != len(migrations) || rolledBack[0].Version != migrations[len(migrations)-1].Version {
+		t.Errorf("unexpected rolled back migrations, given = %d starting at %d", len(rolledBack), rolledBack[0].Version)
+	}
+	if got := countApplied(t, m); got != 0 {
+		t.Errorf("unexpected applied count after down, given = %d, expected = %d", got, 0)
+	}
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatal("failed to migrate up again, err =", err)
+	}
+}
+
+func TestMigrator_DryRun(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	d, m := openMigrator(t)
+	m.DryRun = true
+
+	applied, err := m.Up(ctx, 0)
+	if err != nil {
+		t.Fatal("failed to dry run, err =", err)
+	}
+	if len(applied) == 0 {
+		t.Error("dry run reported no migrations")
+	}
+	if got := countApplied(t, m); got != 0 {
+		t.Errorf("dry run recorded migrations, given = %d, expected = %d", got, 0)
+	}
+	var tables int
+	if err := d.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'todos'`).Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Error("dry run created the todos table")
+	}
+}
+
+func TestMigrator_ChecksumMismatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	d, m := openMigrator(t)
+
+	if _, err := m.Up(ctx, 1); err != nil {
+		t.Fatal("failed to migrate up, err =", err)
+	}
+	// 適用後にファイルが編集された状態を再現する
+	if _, err := d.Exec(`UPDATE schema_migrations SET checksum = 'edited' WHERE version = 1`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Up(ctx, 0); !db.IsErrChecksumMismatch(err) {
+		t.Errorf("unexpected error on up, given = %v, expected = checksum mismatch", err)
+	}
+	if _, err := m.Down(ctx, 1); !db.IsErrChecksumMismatch(err) {
+		t.Errorf("unexpected error on down, given = %v, expected = checksum mismatch", err)
+	}
+	if got := countApplied(t, m); got != 1 {
+		t.Errorf("unexpected applied count, given = %d, expected = %d", got, 1)
+	}
+}
+
+func TestNewDB_ExistingDatabase(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "existing.db")
+
+	// マイグレーション導入前の schema.sql で作成されたデータベース
+	d, err := db.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.Exec(`CREATE TABLE todos (
+		id          INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+		subject     TEXT     NOT NULL,
+		description TEXT     NOT NULL DEFAULT '',
+		created_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+		updated_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+		CHECK(subject <> '')
+	);
+	INSERT INTO todos(subject) VALUES ('existing');`)
+	d.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = db.NewDB(path)
+	if err != nil {
+		t.Fatal("failed to migrate the existing database, err =", err)
+	}
+	defer d.Close()
+
+	var (
+		subject    string
+		priority   int
+		occurrence int
+	)
+	if err := d.QueryRow(`SELECT subject, priority, occurrence FROM todos`).Scan(&subject, &priority, &occurrence); err != nil {
+		t.Fatal("failed to read the migrated TODO, err =", err)
+	}
+	if subject != "existing" || priority != 0 || occurrence != 0 {
+		t.Errorf("unexpected TODO, given = (%q, %d, %d), expected = (%q, %d, %d)", subject, priority, occurrence, "existing", 0, 0)
+	}
+
+	// occurrence を指定せずに作成した繰り返さない TODO も 0 回目になる
+	if err := d.QueryRow(`INSERT INTO todos(subject) VALUES ('inserted') RETURNING id`).Scan(new(int64)); err != nil {
+		t.Fatal("failed to insert, err =", err)
+	}
+	if err := d.QueryRow(`SELECT occurrence FROM todos WHERE subject = 'inserted'`).Scan(&occurrence); err != nil || occurrence != 0 {
+		t.Errorf("unexpected occurrence of the inserted TODO, given = %d, err = %v", occurrence, err)
+	}
+}
+
+// schemaOf は、データベースのテーブルのカラムとインデックス・トリガーの名前を返します。
+// 全文検索の todos_fts と schema_migrations は比較しません。
+func schemaOf(t *testing.T, d *sql.DB) []string {
+	t.Helper()
+
+	const read = `SELECT m.type, m.name, COALESCE(c.name, '') FROM sqlite_master m LEFT JOIN pragma_table_info(m.name) c
+		WHERE m.name NOT LIKE 'sqlite_%' AND m.name NOT LIKE 'todos_fts%' AND m.name <> 'schema_migrations'
+		ORDER BY m.type, m.name, c.name`
+	rows, err := d.Query(read)
+	if err != nil {
+		t.Fatal("failed to read schema, err =", err)
+	}
+	defer rows.Close()
+
+	var schema []string
+	for rows.Next() {
+		var typ, name, column string
+		if err := rows.Scan(&typ, &name, &column); err != nil {
+			t.Fatal("failed to scan schema, err =", err)
+		}
+		schema = append(schema, typ+" "+name+" "+column)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal("failed to read schema, err =", err)
+	}
+	return schema
+}
+
+// testdata/legacy には、マイグレーションを導入する前の各版の schema.sql を、その版が含むマイグレーションの名前で置いています。
+// どの版で作成されたデータベースも、最新のマイグレーションを適用したデータベースと同じスキーマに移行できること
+func TestNewDB_LegacySchema(t *testing.T) {
+	t.Parallel()
+
+	fresh, err := db.NewDB(filepath.Join(t.TempDir(), "fresh.db"))
+	if err != nil {
+		t.Fatal("failed to open db, err =", err)
+	}
+	defer fresh.Close()
+	expected := schemaOf(t, fresh)
+
+	files, err := filepath.Glob(filepath.Join("testdata", "legacy", "*.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("failed to find legacy schemas, files = %v, err = %v", files, err)
+	}
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			t.Parallel()
+
+			schema, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "legacy.db")
+			d, err := db.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = d.Exec(string(schema) + `;INSERT INTO todos(subject) VALUES ('legacy')`)
+			d.Close()
+			if err != nil {
+				t.Fatal("failed to create the legacy database, err =", err)
+			}
+
+			d, err = db.NewDB(path)
+			if err != nil {
+				t.Fatal("failed to migrate the legacy database, err =", err)
+			}
+			defer d.Close()
+
+			if given := schemaOf(t, d); strings.Join(given, "\n") != strings.Join(expected, "\n") {
+				t.Errorf("unexpected schema\ngiven:\n%s\nexpected:\n%s", strings.Join(given, "\n"), strings.Join(expected, "\n"))
+			}
+			var subject string
+			if err := d.QueryRow(`SELECT subject FROM todos`).Scan(&subject); err != nil || subject != "legacy" {
+				t.Errorf("unexpected TODO, given = %q, err = %v", subject, err)
+			}
+
+			// schema.sql で作成済みの変更も、適用済みとして記録される
+			m, err := db.NewMigrator(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			migrations, err := db.Migrations()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if given := countApplied(t, m); given != len(migrations) {
+				t.Errorf("unexpected applied count, given = %d, expected = %d", given, len(migrations))
+			}
+		})
+	}
+}
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS trigger_todos_updated_at;
+DROP TABLE IF EXISTS todos;
@@ -0,0 +1,4 @@
+DROP INDEX index_todos_due_at;
+
+ALTER TABLE todos DROP COLUMN reminded_at;
+ALTER TABLE todos DROP COLUMN due_at;
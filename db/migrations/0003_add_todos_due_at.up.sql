@@ -0,0 +1,4 @@
+ALTER TABLE todos ADD COLUMN due_at DATETIME;
+ALTER TABLE todos ADD COLUMN reminded_at DATETIME;
+
+CREATE INDEX index_todos_due_at ON todos(due_at);
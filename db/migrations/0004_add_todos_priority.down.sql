@@ -0,0 +1,3 @@
+DROP INDEX index_todos_priority;
+
+ALTER TABLE todos DROP COLUMN priority;
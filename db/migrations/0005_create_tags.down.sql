@@ -0,0 +1,5 @@
+DROP TRIGGER trigger_tags_delete_todos;
+DROP TRIGGER trigger_todos_delete_tags;
+
+DROP TABLE todo_tags;
+DROP TABLE tags;
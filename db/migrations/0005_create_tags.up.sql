@@ -0,0 +1,25 @@
+CREATE TABLE tags (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  name       TEXT     NOT NULL UNIQUE,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+CREATE TABLE todo_tags (
+  todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  tag_id  INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+  PRIMARY KEY (todo_id, tag_id)
+);
+
+CREATE INDEX index_todo_tags_tag_id ON todo_tags(tag_id);
+
+-- foreign_keys が無効な接続でも関連が残らないよう、トリガーでも削除する
+CREATE TRIGGER trigger_todos_delete_tags AFTER DELETE ON todos
+BEGIN
+  DELETE FROM todo_tags WHERE todo_id == OLD.id;
+END;
+
+CREATE TRIGGER trigger_tags_delete_todos AFTER DELETE ON tags
+BEGIN
+  DELETE FROM todo_tags WHERE tag_id == OLD.id;
+END;
@@ -0,0 +1,6 @@
+DROP TRIGGER trigger_todos_delete_previous;
+DROP INDEX index_todos_previous_id;
+
+ALTER TABLE todos DROP COLUMN previous_id;
+ALTER TABLE todos DROP COLUMN occurrence;
+ALTER TABLE todos DROP COLUMN recurrence;
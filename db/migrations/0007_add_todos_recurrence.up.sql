@@ -0,0 +1,10 @@
+ALTER TABLE todos ADD COLUMN recurrence TEXT NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN occurrence INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE todos ADD COLUMN previous_id INTEGER REFERENCES todos(id);
+
+CREATE INDEX index_todos_previous_id ON todos(previous_id);
+
+CREATE TRIGGER trigger_todos_delete_previous AFTER DELETE ON todos
+BEGIN
+  UPDATE todos SET previous_id = NULL WHERE previous_id == OLD.id;
+END;
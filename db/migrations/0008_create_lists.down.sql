@@ -0,0 +1,7 @@
+DROP TRIGGER trigger_lists_delete_todos;
+DROP INDEX index_todos_list_id;
+
+ALTER TABLE todos DROP COLUMN list_id;
+
+DROP TRIGGER trigger_lists_updated_at;
+DROP TABLE lists;
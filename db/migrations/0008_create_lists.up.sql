@@ -0,0 +1,24 @@
+CREATE TABLE lists (
+  id          INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  name        TEXT     NOT NULL,
+  description TEXT     NOT NULL DEFAULT '',
+  archived_at DATETIME,
+  created_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+CREATE TRIGGER trigger_lists_updated_at AFTER UPDATE ON lists
+BEGIN
+  UPDATE lists SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+ALTER TABLE todos ADD COLUMN list_id INTEGER REFERENCES lists(id);
+
+CREATE INDEX index_todos_list_id ON todos(list_id);
+
+-- リストを削除しても TODO は残し、どのリストにも属さない状態にする
+CREATE TRIGGER trigger_lists_delete_todos AFTER DELETE ON lists
+BEGIN
+  UPDATE todos SET list_id = NULL WHERE list_id == OLD.id;
+END;
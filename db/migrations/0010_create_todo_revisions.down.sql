@@ -0,0 +1,3 @@
+DROP TRIGGER trigger_todos_delete_revisions;
+
+DROP TABLE todo_revisions;
@@ -0,0 +1,20 @@
+-- TODO を変更するたびに、変更後の状態を 1 行追加する
+CREATE TABLE todo_revisions (
+  id           INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  todo_id      INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  revision     INTEGER  NOT NULL,
+  action       TEXT     NOT NULL,
+  actor        TEXT     NOT NULL DEFAULT '',
+  subject      TEXT     NOT NULL,
+  description  TEXT     NOT NULL,
+  completed_at DATETIME,
+  due_at       DATETIME,
+  priority     INTEGER  NOT NULL,
+  created_at   DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  UNIQUE(todo_id, revision)
+);
+
+CREATE TRIGGER trigger_todos_delete_revisions AFTER DELETE ON todos
+BEGIN
+  DELETE FROM todo_revisions WHERE todo_id == OLD.id;
+END;
@@ -0,0 +1,3 @@
+DROP TRIGGER trigger_todos_version;
+
+ALTER TABLE todos DROP COLUMN version;
@@ -0,0 +1,8 @@
+ALTER TABLE todos ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- TODO の属性を変更するたびに version を上げる。reminded_at の更新では上げない
+CREATE TRIGGER trigger_todos_version
+AFTER UPDATE OF subject, description, completed_at, due_at, priority, parent_id, recurrence, list_id, deleted_at ON todos
+BEGIN
+  UPDATE todos SET version = version + 1 WHERE id == NEW.id;
+END;
@@ -0,0 +1,18 @@
+CREATE TABLE users (
+  id            INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  name          TEXT     NOT NULL UNIQUE,
+  password_hash TEXT     NOT NULL,
+  created_at    DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at    DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+CREATE TRIGGER trigger_users_updated_at AFTER UPDATE ON users
+BEGIN
+  UPDATE users SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+-- 既存の TODO は所有者のない状態で残し、BASIC_AUTH_USER_ID の利用者が起動時に引き継ぐ
+ALTER TABLE todos ADD COLUMN owner_id INTEGER REFERENCES users(id);
+
+CREATE INDEX index_todos_owner_id ON todos(owner_id);
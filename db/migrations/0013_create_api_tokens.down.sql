@@ -0,0 +1,2 @@
+DROP INDEX index_api_tokens_user_id;
+DROP TABLE api_tokens;
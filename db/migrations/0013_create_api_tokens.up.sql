@@ -0,0 +1,15 @@
+-- トークンそのものは保存せず、SHA-256 のハッシュで照合する
+CREATE TABLE api_tokens (
+  id           INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  user_id      INTEGER  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name         TEXT     NOT NULL,
+  token_hash   TEXT     NOT NULL UNIQUE,
+  scopes       TEXT     NOT NULL,
+  expires_at   DATETIME,
+  last_used_at DATETIME,
+  revoked_at   DATETIME,
+  created_at   DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+CREATE INDEX index_api_tokens_user_id ON api_tokens(user_id);
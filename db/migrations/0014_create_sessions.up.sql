@@ -0,0 +1,20 @@
+CREATE TABLE sessions (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  user_id    INTEGER  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  expires_at DATETIME NOT NULL,
+  revoked_at DATETIME,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now'))
+);
+
+CREATE INDEX index_sessions_user_id ON sessions(user_id);
+
+-- 使用済みのリフレッシュトークンも残し、再び使われた場合はセッションごと取り消す
+CREATE TABLE refresh_tokens (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  session_id INTEGER  NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+  token_hash TEXT     NOT NULL UNIQUE,
+  used_at    DATETIME,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now'))
+);
+
+CREATE INDEX index_refresh_tokens_session_id ON refresh_tokens(session_id);
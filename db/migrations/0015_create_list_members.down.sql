@@ -0,0 +1,6 @@
+DROP INDEX index_list_members_user_id;
+DROP TABLE list_members;
+
+DROP INDEX index_lists_owner_id;
+
+ALTER TABLE lists DROP COLUMN owner_id;
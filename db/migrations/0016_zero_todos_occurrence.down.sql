@@ -0,0 +1,3 @@
+DROP TRIGGER trigger_todos_occurrence;
+
+UPDATE todos SET occurrence = 1 WHERE recurrence = '';
@@ -0,0 +1,9 @@
+-- 繰り返さない TODO の occurrence は 0 とする
+UPDATE todos SET occurrence = 0 WHERE recurrence = '';
+
+-- SQLite ではカラムの既定値を変更できないため、occurrence を指定せずに作成された繰り返さない TODO をトリガーで 0 にする
+CREATE TRIGGER trigger_todos_occurrence AFTER INSERT ON todos
+WHEN NEW.recurrence = '' AND NEW.occurrence <> 0
+BEGIN
+  UPDATE todos SET occurrence = 0 WHERE id == NEW.id;
+END;
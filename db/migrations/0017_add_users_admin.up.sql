@@ -0,0 +1,3 @@
+-- 管理者だけがバックアップとスナップショットのエンドポイントを使い、admin スコープの API トークンを発行できる
+-- BASIC_AUTH_USER_ID の利用者は起動時に、htpasswd ファイルの運用者は認証時に管理者になる
+ALTER TABLE users ADD COLUMN admin BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS trigger_todos_updated_at ON todos;
+DROP TABLE IF EXISTS todos;
+DROP FUNCTION IF EXISTS set_updated_at();
@@ -0,0 +1,21 @@
+-- SQLite と同じく、時刻は UTC の TIMESTAMP で秒単位に保持する。
+-- subject と description は、SQLite の BINARY と同じく並び順をバイト順に、LOWER を ASCII の文字のみに
+-- 適用するよう、照合順序を "C" にする
+CREATE TABLE todos (
+  id          BIGSERIAL NOT NULL PRIMARY KEY,
+  subject     TEXT      COLLATE "C" NOT NULL,
+  description TEXT      COLLATE "C" NOT NULL DEFAULT '',
+  created_at  TIMESTAMP NOT NULL DEFAULT (DATE_TRUNC('second', STATEMENT_TIMESTAMP() AT TIME ZONE 'UTC')),
+  updated_at  TIMESTAMP NOT NULL DEFAULT (DATE_TRUNC('second', STATEMENT_TIMESTAMP() AT TIME ZONE 'UTC')),
+  CHECK(subject <> '')
+);
+
+CREATE FUNCTION set_updated_at() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at := DATE_TRUNC('second', STATEMENT_TIMESTAMP() AT TIME ZONE 'UTC');
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_todos_updated_at BEFORE UPDATE ON todos
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1 @@
+ALTER TABLE todos ADD COLUMN completed_at TIMESTAMP;
@@ -0,0 +1,4 @@
+ALTER TABLE todos ADD COLUMN due_at TIMESTAMP;
+ALTER TABLE todos ADD COLUMN reminded_at TIMESTAMP;
+
+CREATE INDEX index_todos_due_at ON todos(due_at);
@@ -0,0 +1,15 @@
+CREATE TABLE tags (
+  id         BIGSERIAL NOT NULL PRIMARY KEY,
+  name       TEXT      COLLATE "C" NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL DEFAULT (DATE_TRUNC('second', STATEMENT_TIMESTAMP() AT TIME ZONE 'UTC')),
+  CHECK(name <> '')
+);
+
+-- SQLite のトリガーの代わりに、外部キーで TODO やタグと一緒に削除する
+CREATE TABLE todo_tags (
+  todo_id BIGINT NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  tag_id  BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+  PRIMARY KEY (todo_id, tag_id)
+);
+
+CREATE INDEX index_todo_tags_tag_id ON todo_tags(tag_id);
@@ -0,0 +1,3 @@
+ALTER TABLE todos ADD COLUMN parent_id BIGINT REFERENCES todos(id) ON DELETE SET NULL;
+
+CREATE INDEX index_todos_parent_id ON todos(parent_id);
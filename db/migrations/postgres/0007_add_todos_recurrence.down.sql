@@ -0,0 +1,5 @@
+DROP INDEX index_todos_previous_id;
+
+ALTER TABLE todos DROP COLUMN previous_id;
+ALTER TABLE todos DROP COLUMN occurrence;
+ALTER TABLE todos DROP COLUMN recurrence;
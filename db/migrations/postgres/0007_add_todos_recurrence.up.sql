@@ -0,0 +1,6 @@
+ALTER TABLE todos ADD COLUMN recurrence TEXT NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN occurrence INTEGER NOT NULL DEFAULT 1;
+-- 前の回を削除した場合は、SQLite のトリガーと同じく previous_id を NULL にする
+ALTER TABLE todos ADD COLUMN previous_id BIGINT REFERENCES todos(id) ON DELETE SET NULL;
+
+CREATE INDEX index_todos_previous_id ON todos(previous_id);
@@ -0,0 +1,6 @@
+DROP INDEX index_todos_list_id;
+
+ALTER TABLE todos DROP COLUMN list_id;
+
+DROP TRIGGER trigger_lists_updated_at ON lists;
+DROP TABLE lists;
@@ -0,0 +1,17 @@
+CREATE TABLE lists (
+  id          BIGSERIAL NOT NULL PRIMARY KEY,
+  name        TEXT      NOT NULL,
+  description TEXT      NOT NULL DEFAULT '',
+  archived_at TIMESTAMP,
+  created_at  TIMESTAMP NOT NULL DEFAULT (DATE_TRUNC('second', STATEMENT_TIMESTAMP() AT TIME ZONE 'UTC')),
+  updated_at  TIMESTAMP NOT NULL DEFAULT (DATE_TRUNC('second', STATEMENT_TIMESTAMP() AT TIME ZONE 'UTC')),
+  CHECK(name <> '')
+);
+
+CREATE TRIGGER trigger_lists_updated_at BEFORE UPDATE ON lists
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- リストを削除しても TODO は残し、どのリストにも属さない状態にする
+ALTER TABLE todos ADD COLUMN list_id BIGINT REFERENCES lists(id) ON DELETE SET NULL;
+
+CREATE INDEX index_todos_list_id ON todos(list_id);
@@ -0,0 +1,15 @@
+-- TODO を変更するたびに、変更後の状態を 1 行追加する
+CREATE TABLE todo_revisions (
+  id           BIGSERIAL NOT NULL PRIMARY KEY,
+  todo_id      BIGINT    NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  revision     BIGINT    NOT NULL,
+  action       TEXT      NOT NULL,
+  actor        TEXT      NOT NULL DEFAULT '',
+  subject      TEXT      NOT NULL,
+  description  TEXT      NOT NULL,
+  completed_at TIMESTAMP,
+  due_at       TIMESTAMP,
+  priority     INTEGER   NOT NULL,
+  created_at   TIMESTAMP NOT NULL DEFAULT (DATE_TRUNC('second', STATEMENT_TIMESTAMP() AT TIME ZONE 'UTC')),
+  UNIQUE(todo_id, revision)
+);
@@ -0,0 +1,4 @@
+DROP TRIGGER trigger_todos_version ON todos;
+DROP FUNCTION increment_todos_version();
+
+ALTER TABLE todos DROP COLUMN version;
@@ -0,0 +1,13 @@
+ALTER TABLE todos ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+-- TODO の属性を変更するたびに version を上げる。reminded_at の更新では上げない
+CREATE FUNCTION increment_todos_version() RETURNS trigger AS $$
+BEGIN
+  NEW.version := OLD.version + 1;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_todos_version
+BEFORE UPDATE OF subject, description, completed_at, due_at, priority, parent_id, recurrence, list_id, deleted_at ON todos
+FOR EACH ROW EXECUTE FUNCTION increment_todos_version();
@@ -0,0 +1,6 @@
+DROP INDEX index_todos_owner_id;
+
+ALTER TABLE todos DROP COLUMN owner_id;
+
+DROP TRIGGER trigger_users_updated_at ON users;
+DROP TABLE users;
@@ -0,0 +1,16 @@
+CREATE TABLE users (
+  id            BIGSERIAL NOT NULL PRIMARY KEY,
+  name          TEXT      NOT NULL UNIQUE,
+  password_hash TEXT      NOT NULL,
+  created_at    TIMESTAMP NOT NULL DEFAULT (DATE_TRUNC('second', STATEMENT_TIMESTAMP() AT TIME ZONE 'UTC')),
+  updated_at    TIMESTAMP NOT NULL DEFAULT (DATE_TRUNC('second', STATEMENT_TIMESTAMP() AT TIME ZONE 'UTC')),
+  CHECK(name <> '')
+);
+
+CREATE TRIGGER trigger_users_updated_at BEFORE UPDATE ON users
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- 既存の TODO は所有者のない状態で残し、BASIC_AUTH_USER_ID の利用者が起動時に引き継ぐ
+ALTER TABLE todos ADD COLUMN owner_id BIGINT REFERENCES users(id);
+
+CREATE INDEX index_todos_owner_id ON todos(owner_id);
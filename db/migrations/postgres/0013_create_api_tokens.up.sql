@@ -0,0 +1,15 @@
+-- トークンそのものは保存せず、SHA-256 のハッシュで照合する
+CREATE TABLE api_tokens (
+  id           BIGSERIAL NOT NULL PRIMARY KEY,
+  user_id      BIGINT    NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name         TEXT      NOT NULL,
+  token_hash   TEXT      NOT NULL UNIQUE,
+  scopes       TEXT      NOT NULL,
+  expires_at   TIMESTAMP,
+  last_used_at TIMESTAMP,
+  revoked_at   TIMESTAMP,
+  created_at   TIMESTAMP NOT NULL DEFAULT (DATE_TRUNC('second', STATEMENT_TIMESTAMP() AT TIME ZONE 'UTC')),
+  CHECK(name <> '')
+);
+
+CREATE INDEX index_api_tokens_user_id ON api_tokens(user_id);
@@ -0,0 +1,5 @@
+DROP INDEX index_refresh_tokens_session_id;
+DROP TABLE refresh_tokens;
+
+DROP INDEX index_sessions_user_id;
+DROP TABLE sessions;
@@ -0,0 +1,20 @@
+CREATE TABLE sessions (
+  id         BIGSERIAL NOT NULL PRIMARY KEY,
+  user_id    BIGINT    NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (DATE_TRUNC('second', STATEMENT_TIMESTAMP() AT TIME ZONE 'UTC'))
+);
+
+CREATE INDEX index_sessions_user_id ON sessions(user_id);
+
+-- 使用済みのリフレッシュトークンも残し、再び使われた場合はセッションごと取り消す
+CREATE TABLE refresh_tokens (
+  id         BIGSERIAL NOT NULL PRIMARY KEY,
+  session_id BIGINT    NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+  token_hash TEXT      NOT NULL UNIQUE,
+  used_at    TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (DATE_TRUNC('second', STATEMENT_TIMESTAMP() AT TIME ZONE 'UTC'))
+);
+
+CREATE INDEX index_refresh_tokens_session_id ON refresh_tokens(session_id);
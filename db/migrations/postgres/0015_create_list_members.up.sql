@@ -0,0 +1,16 @@
+-- 既存のリストは所有者のない状態で残し、BASIC_AUTH_USER_ID の利用者が起動時に引き継ぐ
+ALTER TABLE lists ADD COLUMN owner_id BIGINT REFERENCES users(id);
+
+CREATE INDEX index_lists_owner_id ON lists(owner_id);
+
+-- 所有者以外の利用者とのリストの共有。所有者は lists.owner_id で表し、ここには含めない
+CREATE TABLE list_members (
+  list_id    BIGINT    NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+  user_id    BIGINT    NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  role       TEXT      NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT (DATE_TRUNC('second', STATEMENT_TIMESTAMP() AT TIME ZONE 'UTC')),
+  PRIMARY KEY(list_id, user_id),
+  CHECK(role IN ('editor', 'viewer'))
+);
+
+CREATE INDEX index_list_members_user_id ON list_members(user_id);
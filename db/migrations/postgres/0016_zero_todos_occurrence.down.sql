@@ -0,0 +1,2 @@
+ALTER TABLE todos ALTER COLUMN occurrence SET DEFAULT 1;
+UPDATE todos SET occurrence = 1 WHERE recurrence = '';
@@ -0,0 +1,3 @@
+-- 繰り返さない TODO の occurrence は 0 とする
+ALTER TABLE todos ALTER COLUMN occurrence SET DEFAULT 0;
+UPDATE todos SET occurrence = 0 WHERE recurrence = '';
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options は、データベースの接続とコネクションプールの設定です。
+// JournalMode から TxLock までは SQLite の接続ごとの設定で、PostgreSQL では使いません。
+type Options struct {
+	// JournalMode は journal_mode (WAL, DELETE, TRUNCATE, PERSIST, MEMORY, OFF) です。空の場合は変更しません。
+	JournalMode string
+	// BusyTimeout は、他の接続がロックしている場合に待つ時間 (busy_timeout) です。
+	BusyTimeout time.Duration
+	// Synchronous は synchronous (OFF, NORMAL, FULL, EXTRA) です。空の場合は変更しません。
+	Synchronous string
+	// ForeignKeys は外部キー制約を有効にするか (foreign_keys) です。
+	ForeignKeys bool
+	// TxLock はトランザクションを開始するときのロック (deferred, immediate, exclusive) です。空の場合は deferred です。
+	TxLock string
+
+	// MaxOpenConns は同時に開く接続の最大数です。0 以下の場合は無制限です。
+	MaxOpenConns int
+	// MaxIdleConns はプールに残すアイドル状態の接続の最大数です。0 以下の場合はアイドル状態の接続を残しません。
+	MaxIdleConns int
+	// ConnMaxLifetime は接続を再利用する最大の期間です。0 以下の場合は期限なく再利用します。
+	ConnMaxLifetime time.Duration
+}
+
+// DefaultOptions returns the options that NewDB and NewDBWithDriver use.
+//
+// SQLite は WAL モードで開き、書き込みが重なった場合は最大 5 秒待ちます。
+// トランザクションは読み込んでから書き込むものが多いため、BEGIN IMMEDIATE で開始して
+// 途中で書き込みのロックを取れずに失敗しないようにします。
+func DefaultOptions() Options {
+	return Options{
+		JournalMode:  "WAL",
+		BusyTimeout:  5 * time.Second,
+		Synchronous:  "NORMAL",
+		ForeignKeys:  true,
+		TxLock:       "immediate",
+		MaxOpenConns: 0,
+		MaxIdleConns: 2,
+	}
+}
+
+// validate は o の値が SQLite に渡せるものかを確認します。
+func (o Options) validate() error {
+	if err := oneOf("journal mode", o.JournalMode, "WAL", "DELETE", "TRUNCATE", "PERSIST", "MEMORY", "OFF"); err != nil {
+		return err
+	}
+	if err := oneOf("synchronous", o.Synchronous, "OFF", "NORMAL", "FULL", "EXTRA"); err != nil {
+		return err
+	}
+	if err := oneOf("tx lock", o.TxLock, "DEFERRED", "IMMEDIATE", "EXCLUSIVE"); err != nil {
+		return err
+	}
+	if o.BusyTimeout < 0 {
+		return fmt.Errorf("db: busy timeout must not be negative, got %s", o.BusyTimeout)
+	}
+	return nil
+}
+
+// oneOf は、空でない value が大文字・小文字を区別せずに values のいずれかと等しいかを確認します。
+func oneOf(name, value string, values ...string) error {
+	if value == "" {
+		return nil
+	}
+	for _, v := range values {
+		if strings.EqualFold(value, v) {
+			return nil
+		}
+	}
+	return fmt.Errorf("db: unsupported %s %q, must be one of %s", name, value, strings.Join(values, ", "))
+}
+
+// sqliteDSN は、o の接続ごとの設定を go-sqlite3 の DSN のパラメータとして path に加えます。
+// go-sqlite3 は、プールが新しい接続を開くたびにこれらの PRAGMA を実行します。
+func (o Options) sqliteDSN(path string) string {
+	params := url.Values{}
+	if o.JournalMode != "" {
+		params.Set("_journal_mode", strings.ToUpper(o.JournalMode))
+	}
+	params.Set("_busy_timeout", strconv.FormatInt(o.BusyTimeout.Milliseconds(), 10))
+	if o.Synchronous != "" {
+		params.Set("_synchronous", strings.ToUpper(o.Synchronous))
+	}
+	params.Set("_foreign_keys", strconv.FormatBool(o.ForeignKeys))
+	if o.TxLock != "" {
+		params.Set("_txlock", strings.ToLower(o.TxLock))
+	}
+
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + params.Encode()
+}
+
+// configurePool は o のコネクションプールの設定を d に反映します。
+func (o Options) configurePool(d *sql.DB) {
+	d.SetMaxOpenConns(o.MaxOpenConns)
+	d.SetMaxIdleConns(o.MaxIdleConns)
+	d.SetConnMaxLifetime(o.ConnMaxLifetime)
+}
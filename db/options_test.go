@@ -0,0 +1,67 @@
+package db_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/db"
+)
+
+func TestNewDBWithOptions(t *testing.T) {
+	t.Parallel()
+
+	opts := db.DefaultOptions()
+	opts.BusyTimeout = 1500 * time.Millisecond
+	opts.MaxOpenConns = 4
+
+	d, err := db.NewDBWithOptions(db.DriverSQLite, filepath.Join(t.TempDir(), "options_test.db"), opts)
+	if err != nil {
+		t.Fatal("failed to open db, err =", err)
+	}
+	defer d.Close()
+
+	// PRAGMA は接続ごとの設定のため、プールのどの接続でも同じ値になること
+	cases := map[string]struct {
+		pragma   string
+		expected string
+	}{
+		"JournalMode": {pragma: "journal_mode", expected: "wal"},
+		"BusyTimeout": {pragma: "busy_timeout", expected: "1500"},
+		"Synchronous": {pragma: "synchronous", expected: "1"},
+		"ForeignKeys": {pragma: "foreign_keys", expected: "1"},
+	}
+	for name, c := range cases {
+		var given string
+		if err := d.QueryRow(`PRAGMA ` + c.pragma).Scan(&given); err != nil {
+			t.Fatalf("%s: failed to read pragma, err = %v", name, err)
+		}
+		if given != c.expected {
+			t.Errorf("%s: unexpected value, given = %s, expected = %s", name, given, c.expected)
+		}
+	}
+
+	if given := d.Stats().MaxOpenConnections; given != opts.MaxOpenConns {
+		t.Errorf("unexpected max open connections, given = %d, expected = %d", given, opts.MaxOpenConns)
+	}
+}
+
+func TestNewDBWithOptions_Invalid(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]func(o *db.Options){
+		"JournalMode": func(o *db.Options) { o.JournalMode = "fast" },
+		"Synchronous": func(o *db.Options) { o.Synchronous = "sometimes" },
+		"TxLock":      func(o *db.Options) { o.TxLock = "optimistic" },
+		"BusyTimeout": func(o *db.Options) { o.BusyTimeout = -time.Second },
+	}
+	for name, modify := range cases {
+		opts := db.DefaultOptions()
+		modify(&opts)
+		d, err := db.NewDBWithOptions(db.DriverSQLite, filepath.Join(t.TempDir(), "invalid.db"), opts)
+		if err == nil {
+			d.Close()
+			t.Errorf("%s: expected an error for invalid options", name)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+CREATE TABLE IF NOT EXISTS todos (
+  id           INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  subject      TEXT     NOT NULL,
+  description  TEXT     NOT NULL DEFAULT '',
+  completed_at DATETIME,
+  due_at       DATETIME,
+  reminded_at  DATETIME,
+  priority     INTEGER  NOT NULL DEFAULT 0,
+  parent_id    INTEGER  REFERENCES todos(id),
+  created_at   DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at   DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(subject <> ''),
+  CHECK(priority BETWEEN 0 AND 3)
+);
+
+CREATE TRIGGER IF NOT EXISTS trigger_todos_updated_at AFTER UPDATE ON todos
+BEGIN
+  UPDATE todos SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+CREATE INDEX IF NOT EXISTS index_todos_due_at ON todos(due_at);
+CREATE INDEX IF NOT EXISTS index_todos_priority ON todos(priority);
+
+CREATE INDEX IF NOT EXISTS index_todos_parent_id ON todos(parent_id);
+
+CREATE TABLE IF NOT EXISTS tags (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  name       TEXT     NOT NULL UNIQUE,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+CREATE TABLE IF NOT EXISTS todo_tags (
+  todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  tag_id  INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+  PRIMARY KEY (todo_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS index_todo_tags_tag_id ON todo_tags(tag_id);
+
+-- foreign_keys が無効な接続でも関連が残らないよう、トリガーでも削除する
+CREATE TRIGGER IF NOT EXISTS trigger_todos_delete_tags AFTER DELETE ON todos
+BEGIN
+  DELETE FROM todo_tags WHERE todo_id == OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trigger_tags_delete_todos AFTER DELETE ON tags
+BEGIN
+  DELETE FROM todo_tags WHERE tag_id == OLD.id;
+END;
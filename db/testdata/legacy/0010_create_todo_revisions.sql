@@ -0,0 +1,104 @@
+CREATE TABLE IF NOT EXISTS todos (
+  id           INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  subject      TEXT     NOT NULL,
+  description  TEXT     NOT NULL DEFAULT '',
+  completed_at DATETIME,
+  due_at       DATETIME,
+  reminded_at  DATETIME,
+  priority     INTEGER  NOT NULL DEFAULT 0,
+  parent_id    INTEGER  REFERENCES todos(id),
+  recurrence   TEXT     NOT NULL DEFAULT '',
+  occurrence   INTEGER  NOT NULL DEFAULT 1,
+  previous_id  INTEGER  REFERENCES todos(id),
+  list_id      INTEGER  REFERENCES lists(id),
+  deleted_at   DATETIME,
+  created_at   DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at   DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(subject <> ''),
+  CHECK(priority BETWEEN 0 AND 3)
+);
+
+CREATE TRIGGER IF NOT EXISTS trigger_todos_updated_at AFTER UPDATE ON todos
+BEGIN
+  UPDATE todos SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+CREATE INDEX IF NOT EXISTS index_todos_due_at ON todos(due_at);
+CREATE INDEX IF NOT EXISTS index_todos_priority ON todos(priority);
+CREATE INDEX IF NOT EXISTS index_todos_parent_id ON todos(parent_id);
+CREATE INDEX IF NOT EXISTS index_todos_previous_id ON todos(previous_id);
+CREATE INDEX IF NOT EXISTS index_todos_list_id ON todos(list_id);
+CREATE INDEX IF NOT EXISTS index_todos_deleted_at ON todos(deleted_at);
+
+CREATE TRIGGER IF NOT EXISTS trigger_todos_delete_previous AFTER DELETE ON todos
+BEGIN
+  UPDATE todos SET previous_id = NULL WHERE previous_id == OLD.id;
+END;
+
+CREATE TABLE IF NOT EXISTS tags (
+  id         INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  name       TEXT     NOT NULL UNIQUE,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+CREATE TABLE IF NOT EXISTS todo_tags (
+  todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  tag_id  INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+  PRIMARY KEY (todo_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS index_todo_tags_tag_id ON todo_tags(tag_id);
+
+-- foreign_keys が無効な接続でも関連が残らないよう、トリガーでも削除する
+CREATE TRIGGER IF NOT EXISTS trigger_todos_delete_tags AFTER DELETE ON todos
+BEGIN
+  DELETE FROM todo_tags WHERE todo_id == OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trigger_tags_delete_todos AFTER DELETE ON tags
+BEGIN
+  DELETE FROM todo_tags WHERE tag_id == OLD.id;
+END;
+
+-- TODO を変更するたびに、変更後の状態を 1 行追加する
+CREATE TABLE IF NOT EXISTS todo_revisions (
+  id           INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  todo_id      INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  revision     INTEGER  NOT NULL,
+  action       TEXT     NOT NULL,
+  actor        TEXT     NOT NULL DEFAULT '',
+  subject      TEXT     NOT NULL,
+  description  TEXT     NOT NULL,
+  completed_at DATETIME,
+  due_at       DATETIME,
+  priority     INTEGER  NOT NULL,
+  created_at   DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  UNIQUE(todo_id, revision)
+);
+
+CREATE TRIGGER IF NOT EXISTS trigger_todos_delete_revisions AFTER DELETE ON todos
+BEGIN
+  DELETE FROM todo_revisions WHERE todo_id == OLD.id;
+END;
+
+CREATE TABLE IF NOT EXISTS lists (
+  id          INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  name        TEXT     NOT NULL,
+  description TEXT     NOT NULL DEFAULT '',
+  archived_at DATETIME,
+  created_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+CREATE TRIGGER IF NOT EXISTS trigger_lists_updated_at AFTER UPDATE ON lists
+BEGIN
+  UPDATE lists SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+-- リストを削除しても TODO は残し、どのリストにも属さない状態にする
+CREATE TRIGGER IF NOT EXISTS trigger_lists_delete_todos AFTER DELETE ON lists
+BEGIN
+  UPDATE todos SET list_id = NULL WHERE list_id == OLD.id;
+END;
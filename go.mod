@@ -5,6 +5,8 @@ go 1.16
 require (
 	github.com/google/go-cmp v0.5.9 // indirect
 	github.com/jstemmer/go-junit-report v0.9.1
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.7
-	github.com/mileusna/useragent v1.3.5 // indirect
+	github.com/mileusna/useragent v1.3.5
+	golang.org/x/crypto v0.0.0-20220214200702-86341886e292
 )
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// BackupStore creates and lists backups. *backup.Manager implements it.
+type BackupStore interface {
+	List() ([]*model.Backup, error)
+	Create(ctx context.Context) (*model.Backup, error)
+}
+
+// BackupHandler handles HTTP requests for creating and listing backups of the database.
+type BackupHandler struct {
+	manager BackupStore
+}
+
+// NewBackupHandler creates a new BackupHandler with the provided BackupStore.
+func NewBackupHandler(manager BackupStore) *BackupHandler {
+	return &BackupHandler{
+		manager: manager,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for BackupHandler.
+func (h *BackupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		backups, err := h.manager.List()
+		if err != nil {
+			log.Println("Error listing backups:", err)
+			writeProblem(w, http.StatusInternalServerError, "")
+			return
+		}
+		writeJSON(w, model.ReadBackupResponse{Backups: backups})
+	case http.MethodPost:
+		b, err := h.manager.Create(r.Context())
+		if err != nil {
+			log.Println("Error creating backup:", err)
+			writeProblem(w, http.StatusInternalServerError, "")
+			return
+		}
+		writeJSONStatus(w, http.StatusCreated, model.CreateBackupResponse{Backup: b})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+	}
+}
+
+// SnapshotWriter writes a snapshot of the database. *backup.Manager implements it.
+type SnapshotWriter interface {
+	WriteSnapshot(ctx context.Context, w io.Writer) (int64, error)
+}
+
+// SnapshotHandler handles HTTP requests for downloading a snapshot of the database.
+type SnapshotHandler struct {
+	manager SnapshotWriter
+}
+
+// NewSnapshotHandler creates a new SnapshotHandler with the provided SnapshotWriter.
+func NewSnapshotHandler(manager SnapshotWriter) *SnapshotHandler {
+	return &SnapshotHandler{
+		manager: manager,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for SnapshotHandler.
+func (h *SnapshotHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	// スナップショットの作成に失敗した場合にエラーを返せるよう、ヘッダーはまだ書き込まない
+	name := "todo-" + time.Now().UTC().Format("20060102T150405Z") + ".db"
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	if _, err := h.manager.WriteSnapshot(r.Context(), w); err != nil {
+		log.Println("Error writing snapshot:", err)
+		w.Header().Del("Content-Disposition")
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+}
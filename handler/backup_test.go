@@ -0,0 +1,75 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/backup"
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+func TestBackupHandler(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "todo.db")
+	d, err := db.NewDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	manager := backup.NewManager(path, filepath.Join(dir, "backups"), 3)
+
+	backups := handler.NewBackupHandler(manager)
+	serve := func(h http.Handler, method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/admin/backups", nil))
+		return w
+	}
+
+	w := serve(backups, http.MethodPost)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status of create, given = %d, body = %s", w.Code, w.Body)
+	}
+	var created model.CreateBackupResponse
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal("failed to decode response, err =", err)
+	}
+	if !strings.HasPrefix(created.Backup.Name, "todo-") || created.Backup.Size == 0 {
+		t.Errorf("unexpected created backup, given = %+v", created.Backup)
+	}
+
+	w = serve(backups, http.MethodGet)
+	var read model.ReadBackupResponse
+	if err := json.NewDecoder(w.Body).Decode(&read); err != nil {
+		t.Fatal("failed to decode response, err =", err)
+	}
+	if w.Code != http.StatusOK || len(read.Backups) != 1 || read.Backups[0].Name != created.Backup.Name {
+		t.Errorf("unexpected response of list, given = %d %+v", w.Code, read.Backups)
+	}
+	if w := serve(backups, http.MethodDelete); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, POST" {
+		t.Errorf("unexpected response of delete, given = %d, Allow = %s", w.Code, w.Header().Get("Allow"))
+	}
+
+	snapshot := handler.NewSnapshotHandler(manager)
+	w = serve(snapshot, http.MethodGet)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/vnd.sqlite3" {
+		t.Fatalf("unexpected response of snapshot, given = %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Disposition"), `attachment; filename="todo-`) {
+		t.Errorf("unexpected Content-Disposition, given = %s", w.Header().Get("Content-Disposition"))
+	}
+	if !bytes.HasPrefix(w.Body.Bytes(), []byte("SQLite format 3\x00")) {
+		t.Error("the snapshot should be a SQLite database")
+	}
+	if w := serve(snapshot, http.MethodPost); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status of post, given = %d, expected = %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,87 @@
+package handler_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
+	"github.com/TechBowl-japan/go-stations/htpasswd"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestBasicAuthMiddleware_Credentials(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewMemoryStore()
+	users := service.NewUserServiceWithRepository(store)
+	for _, name := range []string{"alice", "bob"} {
+		if _, err := users.Signup(context.Background(), name, "password1"); err != nil {
+			t.Fatal("failed to sign up, err =", err)
+		}
+	}
+
+	// alice は htpasswd ファイルにも別のパスワードで記載する。ファイルに記載する前に登録された alice は運用者とは別の利用者のため、
+	// ファイルのパスワードで認証しても alice の TODO は扱えない
+	var content string
+	for _, c := range []struct{ name, password string }{{"operator", "secret1"}, {"alice", "secret2"}} {
+		sum := sha1.Sum([]byte(c.password))
+		content += c.name + ":{SHA}" + base64.StdEncoding.EncodeToString(sum[:]) + "\n"
+	}
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal("failed to write the file, err =", err)
+	}
+	credentials, err := htpasswd.Open(path)
+	if err != nil {
+		t.Fatal("failed to open, err =", err)
+	}
+
+	auth := middleware.NewBasicAuthMiddleware(users)
+	auth.Credentials = credentials
+	// ファイルに記載された名前は、新たに登録できない
+	users.Reserved = credentials.Has
+	if _, err := users.Signup(context.Background(), "operator", "password1"); !model.IsErrConflict(err) {
+		t.Errorf("unexpected error for the name of an operator, given = %v, expected = ErrConflict", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/todos", auth.Handler(handler.NewTODOHandler(service.NewTODOServiceWithRepository(store))))
+	mux.Handle("/todos/", auth.Handler(handler.NewTODOItemHandler(service.NewTODOServiceWithRepository(store))))
+
+	// ケースは前のケースで作成した TODO を使うため、順に実行する
+	cases := []struct {
+		name     string
+		user     string
+		password string
+		method   string
+		path     string
+		body     string
+		code     int
+	}{
+		{name: "Operator", user: "operator", password: "secret1", method: http.MethodPost, path: "/todos", body: `{"subject":"by operator"}`, code: http.StatusCreated},
+		{name: "OperatorOwnsTODO", user: "operator", password: "secret1", method: http.MethodGet, path: "/todos/1", code: http.StatusOK},
+		{name: "OperatorWrongPassword", user: "operator", password: "secret2", method: http.MethodGet, path: "/todos", code: http.StatusUnauthorized},
+		{name: "FileUserRegisteredWithPassword", user: "alice", password: "secret2", method: http.MethodGet, path: "/todos", code: http.StatusForbidden},
+		{name: "UserPasswordOfListedName", user: "alice", password: "password1", method: http.MethodGet, path: "/todos", code: http.StatusUnauthorized},
+		{name: "UnlistedUser", user: "bob", password: "password1", method: http.MethodGet, path: "/todos", code: http.StatusOK},
+		{name: "OtherUsersTODO", user: "bob", password: "password1", method: http.MethodGet, path: "/todos/1", code: http.StatusNotFound},
+		{name: "Unknown", user: "carol", password: "secret1", method: http.MethodGet, path: "/todos", code: http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		r.SetBasicAuth(c.user, c.password)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("%s: unexpected status, given = %d, expected = %d, body = %s", c.name, w.Code, c.code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// maxBulkTODOs は、1 回のリクエストで作成・更新できる TODO の最大数です。
+const maxBulkTODOs = 1000
+
+// TODOBulkWriter creates and updates many TODOs at once. *service.TODOService implements it.
+type TODOBulkWriter interface {
+	CreateTODOs(ctx context.Context, items []*service.BulkTODO, atomic bool) ([]*service.BulkResult, error)
+	UpdateTODOs(ctx context.Context, items []*service.BulkTODO, atomic bool) ([]*service.BulkResult, error)
+}
+
+// TODOBulkHandler handles HTTP requests for creating and updating many TODOs at once.
+type TODOBulkHandler struct {
+	service TODOBulkWriter
+}
+
+// NewTODOBulkHandler creates a new TODOBulkHandler with the provided TODOBulkWriter.
+func NewTODOBulkHandler(svc TODOBulkWriter) *TODOBulkHandler {
+	return &TODOBulkHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for TODOBulkHandler.
+//
+// POST で一括作成、PUT で一括更新します。項目ごとの結果をリクエストと同じ順に返し、
+// すべて成功した場合は 200 OK、失敗した項目がある場合は 207 Multi-Status を返します。
+func (h *TODOBulkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.createTODOs(w, r)
+	case http.MethodPut:
+		h.updateTODOs(w, r)
+	default:
+		w.Header().Set("Allow", "POST, PUT")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+	}
+}
+
+// createTODOs handles POST requests to create TODOs.
+func (h *TODOBulkHandler) createTODOs(w http.ResponseWriter, r *http.Request) {
+	var req model.BulkCreateTODORequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+	atomic, err := checkBulkRequest(len(req.TODOs), req.Mode)
+	if err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// 各項目を POST /todos と同じく検証する
+	items := make([]*service.BulkTODO, len(req.TODOs))
+	invalid := make([]error, len(req.TODOs))
+	for i, t := range req.TODOs {
+		if t == nil {
+			invalid[i] = &model.ErrValidation{Message: "todo must be an object"}
+			continue
+		}
+		opts, err := createOptions(t)
+		if err != nil {
+			invalid[i] = err
+			continue
+		}
+		items[i] = &service.BulkTODO{Subject: t.Subject, Description: t.Description, Options: opts}
+	}
+
+	h.writeResults(w, r, "creating", items, invalid, atomic, h.service.CreateTODOs)
+}
+
+// updateTODOs handles PUT requests to update TODOs.
+func (h *TODOBulkHandler) updateTODOs(w http.ResponseWriter, r *http.Request) {
+	var req model.BulkUpdateTODORequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+	atomic, err := checkBulkRequest(len(req.TODOs), req.Mode)
+	if err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// 各項目を PUT /todos と同じく検証する。If-Match の代わりに項目ごとの version を使う
+	items := make([]*service.BulkTODO, len(req.TODOs))
+	invalid := make([]error, len(req.TODOs))
+	for i, t := range req.TODOs {
+		if t == nil {
+			invalid[i] = &model.ErrValidation{Message: "todo must be an object"}
+			continue
+		}
+		opts, err := updateOptions(&t.UpdateTODORequest)
+		if err != nil {
+			invalid[i] = err
+			continue
+		}
+		if t.Version != nil {
+			opts = append(opts, service.WithExpectedVersion(*t.Version))
+		}
+		items[i] = &service.BulkTODO{ID: t.ID, Subject: t.Subject, Description: t.Description, Options: opts}
+	}
+
+	h.writeResults(w, r, "updating", items, invalid, atomic, h.service.UpdateTODOs)
+}
+
+// checkBulkRequest は項目の数と mode を検証し、アトミックに適用するかを返します。mode の省略時は atomic です。
+func checkBulkRequest(n int, mode string) (bool, error) {
+	if n == 0 {
+		return false, &model.ErrValidation{Field: "todos", Message: "is required"}
+	}
+	if n > maxBulkTODOs {
+		return false, &model.ErrValidation{Field: "todos", Message: fmt.Sprintf("must not contain more than %d items", maxBulkTODOs)}
+	}
+	switch mode {
+	case "", model.BulkModeAtomic:
+		return true, nil
+	case model.BulkModeBestEffort:
+		return false, nil
+	default:
+		return false, &model.ErrValidation{Field: "mode", Message: "must be atomic or best_effort"}
+	}
+}
+
+// writeResults は、検証に成功した items を apply で適用し、検証に失敗した invalid と合わせた項目ごとの結果を書き込みます。
+// アトミックな場合、検証に失敗した項目があれば何も適用しません。
+func (h *TODOBulkHandler) writeResults(w http.ResponseWriter, r *http.Request, action string, items []*service.BulkTODO, invalid []error, atomic bool,
+	apply func(ctx context.Context, items []*service.BulkTODO, atomic bool) ([]*service.BulkResult, error)) {
+	results := make([]*model.BulkTODOResult, len(items))
+	var (
+		valid   []*service.BulkTODO
+		indexes []int
+	)
+	for i, err := range invalid {
+		if err != nil {
+			results[i] = &model.BulkTODOResult{Index: i, Status: http.StatusBadRequest, Error: err.Error()}
+			continue
+		}
+		valid = append(valid, items[i])
+		indexes = append(indexes, i)
+	}
+
+	var applied []*service.BulkResult
+	switch {
+	case atomic && len(valid) < len(items):
+		applied = make([]*service.BulkResult, len(valid))
+		for j := range applied {
+			applied[j] = &service.BulkResult{Err: service.ErrBulkAborted}
+		}
+	case len(valid) > 0:
+		var err error
+		applied, err = apply(r.Context(), valid, atomic)
+		if err != nil {
+			log.Printf("Error %s TODOs: %v", action, err)
+			writeProblem(w, http.StatusInternalServerError, "")
+			return
+		}
+	}
+	// 適用した結果を、検証に成功した項目の位置に戻す
+	for j, result := range applied {
+		results[indexes[j]] = bulkTODOResult(indexes[j], result, action)
+	}
+
+	status := http.StatusOK
+	for _, result := range results {
+		if result.Status != http.StatusOK {
+			status = http.StatusMultiStatus
+		}
+	}
+	writeJSONStatus(w, status, model.BulkTODOResponse{Results: results})
+}
+
+// bulkTODOResult は 1 件の結果を、単体の POST /todos, PUT /todos と同じステータスコードで表します。
+func bulkTODOResult(index int, result *service.BulkResult, action string) *model.BulkTODOResult {
+	err := result.Err
+	if err == nil {
+		return &model.BulkTODOResult{Index: index, Status: http.StatusOK, TODO: result.TODO}
+	}
+
+	var precondition *model.ErrPreconditionFailed
+	switch {
+	case errors.Is(err, service.ErrBulkAborted):
+		return &model.BulkTODOResult{Index: index, Status: http.StatusFailedDependency, Error: err.Error()}
+	case errors.As(err, &precondition):
+		return &model.BulkTODOResult{Index: index, Status: http.StatusPreconditionFailed, TODO: precondition.Current, Error: err.Error()}
+	case model.IsErrValidation(err):
+		return &model.BulkTODOResult{Index: index, Status: http.StatusBadRequest, Error: err.Error()}
+	case model.IsErrNotFound(err):
+		return &model.BulkTODOResult{Index: index, Status: http.StatusNotFound, Error: err.Error()}
+	case model.IsErrConflict(err):
+		return &model.BulkTODOResult{Index: index, Status: http.StatusConflict, Error: err.Error()}
+	case model.IsErrForbidden(err):
+		return &model.BulkTODOResult{Index: index, Status: http.StatusForbidden, Error: err.Error()}
+	default:
+		log.Printf("Error %s TODO at %d: %v", action, index, err)
+		return &model.BulkTODOResult{Index: index, Status: http.StatusInternalServerError, Error: "Internal Server Error"}
+	}
+}
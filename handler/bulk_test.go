@@ -0,0 +1,106 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestTODOBulkHandler(t *testing.T) {
+	t.Parallel()
+
+	h := handler.NewTODOBulkHandler(service.NewTODOServiceWithRepository(service.NewMemoryStore()))
+	serve := func(method, body string) (int, []*model.BulkTODOResult) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/todos/bulk", strings.NewReader(body)))
+		if w.Code != http.StatusOK && w.Code != http.StatusMultiStatus {
+			return w.Code, nil
+		}
+		var resp model.BulkTODOResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal("failed to decode response, err =", err)
+		}
+		return w.Code, resp.Results
+	}
+	statuses := func(results []*model.BulkTODOResult) []int {
+		s := make([]int, len(results))
+		for i, r := range results {
+			s[i] = r.Status
+		}
+		return s
+	}
+	equalStatuses := func(a, b []int) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	cases := []struct {
+		name     string
+		method   string
+		body     string
+		code     int
+		statuses []int
+	}{
+		{
+			name:   "Empty",
+			method: http.MethodPost, body: `{"todos":[]}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			name:   "UnknownMode",
+			method: http.MethodPost, body: `{"todos":[{"subject":"a"}],"mode":"maybe"}`,
+			code: http.StatusBadRequest,
+		},
+		{
+			// 検証に失敗した項目があると、アトミックな場合は何も作成しない
+			name:   "AtomicInvalid",
+			method: http.MethodPost, body: `{"todos":[{"subject":"a"},{"subject":""}]}`,
+			code: http.StatusMultiStatus, statuses: []int{http.StatusFailedDependency, http.StatusBadRequest},
+		},
+		{
+			name:   "BestEffortCreate",
+			method: http.MethodPost, body: `{"todos":[{"subject":""},{"subject":"a"},{"subject":"b","parent_id":1000}],"mode":"best_effort"}`,
+			code: http.StatusMultiStatus, statuses: []int{http.StatusBadRequest, http.StatusOK, http.StatusBadRequest},
+		},
+		{
+			name:   "Create",
+			method: http.MethodPost, body: `{"todos":[{"subject":"b"},{"subject":"c"}]}`,
+			code: http.StatusOK, statuses: []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name:   "BestEffortUpdate",
+			method: http.MethodPut, body: `{"todos":[{"id":1,"subject":"a2","version":1},{"id":2,"subject":"b2","version":5},{"id":1000,"subject":"x"}],"mode":"best_effort"}`,
+			code: http.StatusMultiStatus, statuses: []int{http.StatusOK, http.StatusPreconditionFailed, http.StatusNotFound},
+		},
+		{
+			name:   "Update",
+			method: http.MethodPut, body: `{"todos":[{"id":2,"subject":"b2","done":true},{"id":3,"subject":"c2"}]}`,
+			code: http.StatusOK, statuses: []int{http.StatusOK, http.StatusOK},
+		},
+	}
+	// ケースは前のケースで作成した TODO を使うため、順に実行する
+	for _, c := range cases {
+		code, results := serve(c.method, c.body)
+		if code != c.code {
+			t.Errorf("%s: unexpected status, given = %d, expected = %d", c.name, code, c.code)
+			continue
+		}
+		if given := statuses(results); !equalStatuses(given, c.statuses) {
+			t.Errorf("%s: unexpected statuses, given = %v, expected = %v", c.name, given, c.statuses)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// todoETag は TODO の id と version から強い ETag を作ります。
+func todoETag(todo *model.TODO) string {
+	return fmt.Sprintf(`"%d-%d"`, todo.ID, todo.Version)
+}
+
+// setETag は TODO の ETag をレスポンスヘッダーに設定します。
+func setETag(w http.ResponseWriter, todo *model.TODO) {
+	w.Header().Set("ETag", todoETag(todo))
+}
+
+// parseIfMatch は If-Match ヘッダーから id の TODO が持つべき version を取得します。
+// ヘッダーがない場合や * の場合は false を返します。
+// id の TODO の ETag が含まれない場合は、どの TODO とも一致しない version 0 を返します。
+func parseIfMatch(r *http.Request, id int64) (int64, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		var tagID, version int64
+		if _, err := fmt.Sscanf(strings.TrimSpace(tag), `"%d-%d"`, &tagID, &version); err != nil {
+			continue
+		}
+		if tagID == id {
+			return version, true
+		}
+	}
+	return 0, true
+}
+
+// writePreconditionFailed は err が ErrPreconditionFailed の場合に、現在の TODO を含む 412 Precondition Failed の problem を書き込み true を返します。
+func writePreconditionFailed(w http.ResponseWriter, err error) bool {
+	var precondition *model.ErrPreconditionFailed
+	if !errors.As(err, &precondition) {
+		return false
+	}
+	setETag(w, precondition.Current)
+	writeErrorProblem(w, http.StatusPreconditionFailed, precondition)
+	return true
+}
@@ -0,0 +1,353 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// ListStore is the set of list operations ListHandler uses. *service.ListService implements it.
+type ListStore interface {
+	ReadLists(ctx context.Context, includeArchived bool) ([]*model.List, error)
+	CreateList(ctx context.Context, name, description string) (*model.List, error)
+	UpdateList(ctx context.Context, id int64, name, description string, archived *bool) (*model.List, error)
+	DeleteLists(ctx context.Context, ids []int64) error
+}
+
+// ListHandler handles HTTP requests for list operations.
+type ListHandler struct {
+	service ListStore
+}
+
+// NewListHandler creates a new ListHandler with the provided ListStore.
+func NewListHandler(svc ListStore) *ListHandler {
+	return &ListHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for ListHandler.
+func (h *ListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.readLists(w, r)
+	case http.MethodPost:
+		h.createList(w, r)
+	case http.MethodPut:
+		h.updateList(w, r)
+	case http.MethodDelete:
+		h.deleteLists(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, PUT, DELETE")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+	}
+}
+
+// readLists handles GET requests to list lists.
+func (h *ListHandler) readLists(w http.ResponseWriter, r *http.Request) {
+	// include_archived が true の場合のみ、アーカイブされたリストも返す
+	var includeArchived bool
+	if s := r.URL.Query().Get("include_archived"); s != "" {
+		var err error
+		includeArchived, err = strconv.ParseBool(s)
+		if err != nil {
+			writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "include_archived", Message: "must be a boolean"})
+			return
+		}
+	}
+
+	lists, err := h.service.ReadLists(r.Context(), includeArchived)
+	if err != nil {
+		log.Println("Error reading lists:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.ReadListResponse{Lists: lists})
+}
+
+// createList handles POST requests to create a new list.
+func (h *ListHandler) createList(w http.ResponseWriter, r *http.Request) {
+	var req model.CreateListRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "name", Message: "is required"})
+		return
+	}
+
+	list, err := h.service.CreateList(r.Context(), req.Name, req.Description)
+	if err != nil {
+		log.Println("Error creating list:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.CreateListResponse{List: list})
+}
+
+// updateList handles PUT requests to update or archive a list.
+func (h *ListHandler) updateList(w http.ResponseWriter, r *http.Request) {
+	var req model.UpdateListRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.ID == 0 {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "id", Message: "is required and must be greater than 0"})
+		return
+	}
+	if req.Name == "" {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "name", Message: "is required"})
+		return
+	}
+
+	list, err := h.service.UpdateList(r.Context(), req.ID, req.Name, req.Description, req.Archived)
+	if err != nil {
+		if model.IsErrNotFound(err) {
+			writeErrorProblem(w, http.StatusNotFound, err)
+			return
+		}
+		if model.IsErrForbidden(err) {
+			writeErrorProblem(w, http.StatusForbidden, err)
+			return
+		}
+		log.Println("Error updating list:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.UpdateListResponse{List: list})
+}
+
+// deleteLists handles DELETE requests to delete lists. TODOs in the lists are kept.
+func (h *ListHandler) deleteLists(w http.ResponseWriter, r *http.Request) {
+	var req model.DeleteListRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "ids", Message: "are required"})
+		return
+	}
+
+	if err := h.service.DeleteLists(r.Context(), req.IDs); err != nil {
+		if model.IsErrNotFound(err) {
+			writeErrorProblem(w, http.StatusNotFound, err)
+			return
+		}
+		if model.IsErrForbidden(err) {
+			writeErrorProblem(w, http.StatusForbidden, err)
+			return
+		}
+		log.Println("Error deleting lists:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.DeleteListResponse{})
+}
+
+// ListMemberStore reads and changes the members of a list. *service.ListService implements it.
+type ListMemberStore interface {
+	ReadListMembers(ctx context.Context, listID int64) ([]*model.ListMember, error)
+	ShareList(ctx context.Context, listID int64, name, role string) (*model.ListMember, error)
+	UnshareList(ctx context.Context, listID int64, name string) error
+}
+
+// ListMemberHandler handles HTTP requests for the users a list is shared with at /lists/{id}/members.
+//
+// 共有やその役割の変更ができるのは所有者だけです。共有をやめられるのは所有者と、リストから抜けるメンバー自身です。
+type ListMemberHandler struct {
+	service ListMemberStore
+}
+
+// NewListMemberHandler creates a new ListMemberHandler with the provided ListMemberStore.
+func NewListMemberHandler(svc ListMemberStore) *ListMemberHandler {
+	return &ListMemberHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for ListMemberHandler.
+func (h *ListMemberHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// /lists/{id}/members の id は正の整数のみ。それ以外のパスは存在しない
+	path := strings.TrimPrefix(r.URL.Path, "/lists/")
+	if !strings.HasSuffix(path, "/members") {
+		writeProblem(w, http.StatusNotFound, "")
+		return
+	}
+	id, err := strconv.ParseInt(strings.TrimSuffix(path, "/members"), 10, 64)
+	if err != nil || id <= 0 {
+		writeProblem(w, http.StatusNotFound, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.readMembers(w, r, id)
+	case http.MethodPut:
+		h.shareList(w, r, id)
+	case http.MethodDelete:
+		h.unshareList(w, r, id)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+	}
+}
+
+// readMembers handles GET requests to list the owner and the members of the list.
+func (h *ListMemberHandler) readMembers(w http.ResponseWriter, r *http.Request, id int64) {
+	members, err := h.service.ReadListMembers(r.Context(), id)
+	if err != nil {
+		if model.IsErrNotFound(err) {
+			writeErrorProblem(w, http.StatusNotFound, err)
+			return
+		}
+		log.Println("Error reading list members:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.ReadListMembersResponse{Members: members})
+}
+
+// shareList handles PUT requests to share the list with a user or to change the role of the user.
+func (h *ListMemberHandler) shareList(w http.ResponseWriter, r *http.Request, id int64) {
+	var req model.ShareListRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	member, err := h.service.ShareList(r.Context(), id, strings.TrimSpace(req.Name), req.Role)
+	if err != nil {
+		switch {
+		case model.IsErrValidation(err):
+			writeErrorProblem(w, http.StatusBadRequest, err)
+		case model.IsErrNotFound(err):
+			writeErrorProblem(w, http.StatusNotFound, err)
+		case model.IsErrForbidden(err):
+			writeErrorProblem(w, http.StatusForbidden, err)
+		default:
+			log.Println("Error sharing list:", err)
+			writeProblem(w, http.StatusInternalServerError, "")
+		}
+		return
+	}
+
+	writeJSON(w, model.ShareListResponse{Member: member})
+}
+
+// unshareList handles DELETE requests to stop sharing the list with a user.
+func (h *ListMemberHandler) unshareList(w http.ResponseWriter, r *http.Request, id int64) {
+	var req model.UnshareListRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.service.UnshareList(r.Context(), id, strings.TrimSpace(req.Name)); err != nil {
+		switch {
+		case model.IsErrValidation(err):
+			writeErrorProblem(w, http.StatusBadRequest, err)
+		case model.IsErrNotFound(err):
+			writeErrorProblem(w, http.StatusNotFound, err)
+		case model.IsErrForbidden(err):
+			writeErrorProblem(w, http.StatusForbidden, err)
+		default:
+			log.Println("Error unsharing list:", err)
+			writeProblem(w, http.StatusInternalServerError, "")
+		}
+		return
+	}
+
+	writeJSON(w, model.UnshareListResponse{})
+}
+
+// TODOMover moves TODOs between lists. *service.TODOService implements it.
+type TODOMover interface {
+	MoveTODOs(ctx context.Context, ids []int64, listID *int64) ([]*model.TODO, error)
+}
+
+// TODOMoveHandler handles HTTP requests for moving TODOs between lists.
+type TODOMoveHandler struct {
+	service TODOMover
+}
+
+// NewTODOMoveHandler creates a new TODOMoveHandler with the provided TODOMover.
+func NewTODOMoveHandler(svc TODOMover) *TODOMoveHandler {
+	return &TODOMoveHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for TODOMoveHandler.
+func (h *TODOMoveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	var req model.MoveTODORequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "ids", Message: "are required"})
+		return
+	}
+	// どのリストにも属さない状態にするには null を明示する
+	if !req.ListID.Set {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "list_id", Message: "is required, use null to move out of any list"})
+		return
+	}
+
+	todos, err := h.service.MoveTODOs(r.Context(), req.IDs, req.ListID.Ptr())
+	if err != nil {
+		switch {
+		case model.IsErrNotFound(err):
+			writeErrorProblem(w, http.StatusNotFound, err)
+		case model.IsErrValidation(err):
+			writeErrorProblem(w, http.StatusBadRequest, err)
+		case model.IsErrConflict(err):
+			writeErrorProblem(w, http.StatusConflict, err)
+		case model.IsErrForbidden(err):
+			writeErrorProblem(w, http.StatusForbidden, err)
+		default:
+			log.Println("Error moving TODOs:", err)
+			writeProblem(w, http.StatusInternalServerError, "")
+		}
+		return
+	}
+
+	writeJSON(w, model.MoveTODOResponse{TODOs: todos})
+}
@@ -0,0 +1,70 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestListMemberHandler(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewMemoryStore()
+	users := service.NewUserServiceWithRepository(store)
+	for _, name := range []string{"alice", "bob", "carol"} {
+		if _, err := users.Signup(context.Background(), name, "password1"); err != nil {
+			t.Fatal("failed to sign up, err =", err)
+		}
+	}
+
+	auth := middleware.NewBasicAuthMiddleware(users)
+	lists := service.NewListServiceWithRepository(store)
+	todos := service.NewTODOServiceWithRepository(store)
+	mux := http.NewServeMux()
+	mux.Handle("/lists", auth.Handler(handler.NewListHandler(lists)))
+	mux.Handle("/lists/", auth.Handler(handler.NewListMemberHandler(lists)))
+	mux.Handle("/todos", auth.Handler(handler.NewTODOHandler(todos)))
+	mux.Handle("/todos/", auth.Handler(handler.NewTODOItemHandler(todos)))
+
+	// ケースは前のケースで作成したリストと共有を使うため、順に実行する
+	cases := []struct {
+		name   string
+		user   string
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{name: "CreateList", user: "alice", method: http.MethodPost, path: "/lists", body: `{"name":"shared"}`, code: http.StatusOK},
+		{name: "CreateTODO", user: "alice", method: http.MethodPost, path: "/todos", body: `{"subject":"in the list","list_id":1}`, code: http.StatusCreated},
+		{name: "Share", user: "alice", method: http.MethodPut, path: "/lists/1/members", body: `{"name":"bob","role":"viewer"}`, code: http.StatusOK},
+		{name: "ShareAsOwner", user: "alice", method: http.MethodPut, path: "/lists/1/members", body: `{"name":"carol","role":"owner"}`, code: http.StatusBadRequest},
+		{name: "ShareByViewer", user: "bob", method: http.MethodPut, path: "/lists/1/members", body: `{"name":"bob","role":"editor"}`, code: http.StatusForbidden},
+		{name: "ReadMembers", user: "bob", method: http.MethodGet, path: "/lists/1/members", code: http.StatusOK},
+		{name: "ReadMembersOutsider", user: "carol", method: http.MethodGet, path: "/lists/1/members", code: http.StatusNotFound},
+		{name: "ReadTODOByViewer", user: "bob", method: http.MethodGet, path: "/todos/1", code: http.StatusOK},
+		{name: "UpdateTODOByViewer", user: "bob", method: http.MethodPut, path: "/todos/1", body: `{"subject":"changed"}`, code: http.StatusForbidden},
+		{name: "DeleteTODOByViewer", user: "bob", method: http.MethodDelete, path: "/todos/1", code: http.StatusForbidden},
+		{name: "ReadTODOByOutsider", user: "carol", method: http.MethodGet, path: "/todos/1", code: http.StatusNotFound},
+		{name: "UpdateListByViewer", user: "bob", method: http.MethodPut, path: "/lists", body: `{"id":1,"name":"renamed"}`, code: http.StatusForbidden},
+		{name: "Unshare", user: "alice", method: http.MethodDelete, path: "/lists/1/members", body: `{"name":"bob"}`, code: http.StatusOK},
+		{name: "ReadTODOAfterUnshare", user: "bob", method: http.MethodGet, path: "/todos/1", code: http.StatusNotFound},
+		{name: "InvalidPath", user: "alice", method: http.MethodGet, path: "/lists/x/members", code: http.StatusNotFound},
+		{name: "MethodNotAllowed", user: "alice", method: http.MethodPost, path: "/lists/1/members", code: http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		r.SetBasicAuth(c.user, "password1")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != c.code {
+			t.Errorf("%s: unexpected status, given = %d, expected = %d, body = %s", c.name, w.Code, c.code, w.Body)
+		}
+	}
+}
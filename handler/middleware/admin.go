@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/handler/problem"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// RequireAdmin は、認証された利用者が管理者でない場合に 403 Forbidden を返すミドルウェアです。
+// 認証ミドルウェアの後に適用してください。
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := service.UserFromContext(r.Context())
+		if !ok || !user.Admin {
+			problem.Write(w, problem.FromError(http.StatusForbidden, &model.ErrForbidden{Message: "the user is not an administrator"}))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,19 +2,35 @@
 package middleware
 
 import (
+	"context"
+	"log"
 	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/handler/problem"
+	"github.com/TechBowl-japan/go-stations/htpasswd"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
 )
 
+// UserAuthenticator は BasicAuthMiddleware が利用者の照合に使う操作です。*service.UserService が実装します。
+type UserAuthenticator interface {
+	Authenticate(ctx context.Context, name, password string) (*model.User, error)
+	ProvisionUser(ctx context.Context, name string) (*model.User, error)
+}
+
 type BasicAuthMiddleware struct {
-	UserID   string
-	Password string
+	Users UserAuthenticator
+	// Credentials は、Users より先に照合する htpasswd ファイルです。nil の場合は Users だけで認証します。
+	// ファイルに記載された名前はファイルのパスワードだけで認証し、同じ名前のパスワードのない利用者として TODO を扱います。
+	// 同じ名前の利用者がパスワードで登録されている場合は、その利用者の TODO を渡さず 403 Forbidden を返します。
+	// 運用者が管理者になるかは Users が決めます (service.UserService.Administrators)。
+	Credentials *htpasswd.File
 }
 
-// NewBasicAuthMiddleware は Basic 認証ミドルウェアを作成します。
-func NewBasicAuthMiddleware(userID, password string) *BasicAuthMiddleware {
+// NewBasicAuthMiddleware は Basic 認証ミドルウェアを作成します。名前とパスワードは users に登録された利用者と照合します。
+func NewBasicAuthMiddleware(users UserAuthenticator) *BasicAuthMiddleware {
 	return &BasicAuthMiddleware{
-		UserID:   userID,
-		Password: password,
+		Users: users,
 	}
 }
 
@@ -25,20 +41,59 @@ func (bam *BasicAuthMiddleware) Handler(next http.Handler) http.Handler {
 		userID, password, ok := r.BasicAuth()
 		if !ok {
 			// 認証情報がない場合
-			w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			writeUnauthorized(w, "authentication is required")
 			return
 		}
 
-		// 環境変数から取得したユーザー名とパスワードと比較
-		if userID != bam.UserID || password != bam.Password {
-			// 認証失敗
-			w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		// htpasswd ファイルまたは登録された利用者のパスワードと比較
+		user, err := bam.authenticate(r.Context(), userID, password)
+		if err != nil {
+			if model.IsErrUnauthorized(err) {
+				// 認証失敗
+				writeUnauthorized(w, "the user ID or the password is incorrect")
+				return
+			}
+			if model.IsErrValidation(err) {
+				// htpasswd ファイルに利用者の名前として使えない名前が記載されている
+				log.Printf("htpasswd: refused to authenticate %s: %v", userID, err)
+				writeUnauthorized(w, "the user ID or the password is incorrect")
+				return
+			}
+			if model.IsErrConflict(err) {
+				// htpasswd ファイルの運用者と同じ名前の利用者がパスワードで登録されている
+				log.Printf("htpasswd: refused to authenticate %s as an operator: %v", userID, err)
+				problem.Write(w, problem.FromError(http.StatusForbidden, &model.ErrForbidden{Message: "the user is registered with a password"}))
+				return
+			}
+			log.Println("Error authenticating:", err)
+			problem.Write(w, problem.New(http.StatusInternalServerError, ""))
 			return
 		}
 
-		// 認証成功、次のハンドラーを呼び出す
-		next.ServeHTTP(w, r)
+		// 認証成功、TODO の所有者と変更履歴に記録するユーザー名を context に設定して次のハンドラーを呼び出す
+		ctx := service.ContextWithUser(r.Context(), user)
+		ctx = service.ContextWithActor(ctx, user.Name)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// authenticate は、name が htpasswd ファイルに記載されていればファイルのパスワードと、
+// そうでなければ登録された利用者のパスワードと照合します。
+func (bam *BasicAuthMiddleware) authenticate(ctx context.Context, name, password string) (*model.User, error) {
+	if bam.Credentials != nil {
+		ok, err := bam.Credentials.Authenticate(name, password)
+		if err == nil {
+			if !ok {
+				return nil, &model.ErrUnauthorized{Message: "the name or the password is incorrect"}
+			}
+			return bam.Users.ProvisionUser(ctx, name)
+		}
+	}
+	return bam.Users.Authenticate(ctx, name, password)
+}
+
+// writeUnauthorized は、Basic 認証を求める 401 Unauthorized の problem を書き込みます。
+func writeUnauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
+	problem.Write(w, problem.FromError(http.StatusUnauthorized, &model.ErrUnauthorized{Message: message}))
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/handler/problem"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// TokenAuthenticator は BearerAuthMiddleware が API トークンの照合に使う操作です。*service.TokenService が実装します。
+type TokenAuthenticator interface {
+	Authenticate(ctx context.Context, secret string) (*model.User, *model.APIToken, error)
+}
+
+// BearerAuthMiddleware は API トークンによる Bearer 認証を行います。
+// Authorization ヘッダーが Bearer でないリクエストは Fallback の認証に任せます。
+type BearerAuthMiddleware struct {
+	Tokens   TokenAuthenticator
+	Fallback func(http.Handler) http.Handler
+}
+
+// NewBearerAuthMiddleware は Bearer 認証ミドルウェアを作成します。
+// fallback には、トークンを使わないリクエストを認証する BasicAuthMiddleware の Handler などを渡します。
+func NewBearerAuthMiddleware(tokens TokenAuthenticator, fallback func(http.Handler) http.Handler) *BearerAuthMiddleware {
+	return &BearerAuthMiddleware{
+		Tokens:   tokens,
+		Fallback: fallback,
+	}
+}
+
+// Handler は Bearer 認証を行うハンドラーを返します。
+func (bam *BearerAuthMiddleware) Handler(next http.Handler) http.Handler {
+	fallback := bam.Fallback(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := bearerToken(r)
+		if !ok {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+
+		user, token, err := bam.Tokens.Authenticate(r.Context(), secret)
+		if err != nil {
+			if model.IsErrUnauthorized(err) {
+				// RFC 6750 に従い、トークンが使えない理由を WWW-Authenticate でも返す
+				w.Header().Set("WWW-Authenticate", `Bearer realm="restricted", error="invalid_token"`)
+				problem.Write(w, problem.FromError(http.StatusUnauthorized, err))
+				return
+			}
+			log.Println("Error authenticating the token:", err)
+			problem.Write(w, problem.New(http.StatusInternalServerError, ""))
+			return
+		}
+
+		// 認証成功、TODO の所有者と変更履歴に記録するユーザー名に加え、スコープを確認するためのトークンを context に設定する
+		ctx := service.ContextWithUser(r.Context(), user)
+		ctx = service.ContextWithActor(ctx, user.Name)
+		ctx = service.ContextWithToken(ctx, token)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// bearerToken は Authorization ヘッダーの Bearer トークンを返します。スキームの大文字と小文字は区別しません。
+func bearerToken(r *http.Request) (string, bool) {
+	const scheme = "bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= len(scheme) || !strings.EqualFold(auth[:len(scheme)], scheme) {
+		return "", false
+	}
+	return strings.TrimSpace(auth[len(scheme):]), true
+}
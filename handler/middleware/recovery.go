@@ -4,6 +4,8 @@ package middleware
 import (
     "log"
     "net/http"
+
+    "github.com/TechBowl-japan/go-stations/handler/problem"
 )
 
 // Recovery はパニックをキャッチしてアプリケーションのクラッシュを防ぐミドルウェアです。
@@ -14,7 +16,7 @@ func Recovery(h http.Handler) http.Handler {
                 // エラーログを出力
                 log.Printf("panic recovered: %v", err)
                 // HTTP 500 Internal Server Error を返す
-                http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+                problem.Write(w, problem.New(http.StatusInternalServerError, ""))
             }
         }()
 
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/handler/problem"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// RequireScope は、認証されたリクエストが scope を持たない場合に 403 Forbidden を返すミドルウェアを返します。
+// パスワードやセッションで認証されたリクエストのスコープは service.HasScope のとおりで、admin は管理者だけが持ちます。
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return requireScope(func(*http.Request) string { return scope })
+}
+
+// RequireTokenScope は、API トークンで認証されたリクエストにだけ scope を求めるミドルウェアを返します。
+// パスワードやセッションで認証された利用者は、管理者でなくても自分の API トークンを管理できます。
+func RequireTokenScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		scoped := RequireScope(scope)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := service.TokenFromContext(r.Context()); !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			scoped.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireTODOScope は、読み込みのリクエスト (GET, HEAD) には todos:read を、その他のリクエストには todos:write を求めるミドルウェアです。
+func RequireTODOScope(next http.Handler) http.Handler {
+	return requireScope(func(r *http.Request) string {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			return model.ScopeTODOsRead
+		}
+		return model.ScopeTODOsWrite
+	})(next)
+}
+
+// requireScope は、リクエストに必要なスコープを scope で決めて確認するミドルウェアを返します。
+func requireScope(scope func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			want := scope(r)
+			if !service.HasScope(r.Context(), want) {
+				message := "the user does not have the scope " + want
+				if _, ok := service.TokenFromContext(r.Context()); ok {
+					w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="restricted", error="insufficient_scope", scope=%q`, want))
+					message = "the token does not have the scope " + want
+				}
+				problem.Write(w, problem.FromError(http.StatusForbidden, &model.ErrForbidden{Message: message}))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/handler/problem"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// SessionAuthenticator は SessionAuthMiddleware がアクセストークンの照合に使う操作です。*service.SessionService が実装します。
+type SessionAuthenticator interface {
+	Authenticate(ctx context.Context, accessToken string) (*model.User, *model.Session, error)
+}
+
+// SessionAuthMiddleware は /login で発行した JWT のアクセストークンによる Bearer 認証を行います。
+// Bearer トークンが JWT でないリクエストは Fallback の認証に任せます。
+type SessionAuthMiddleware struct {
+	Sessions SessionAuthenticator
+	Fallback func(http.Handler) http.Handler
+}
+
+// NewSessionAuthMiddleware はアクセストークンで認証するミドルウェアを作成します。
+// fallback には、API トークンを認証する BearerAuthMiddleware の Handler などを渡します。
+func NewSessionAuthMiddleware(sessions SessionAuthenticator, fallback func(http.Handler) http.Handler) *SessionAuthMiddleware {
+	return &SessionAuthMiddleware{
+		Sessions: sessions,
+		Fallback: fallback,
+	}
+}
+
+// Handler はアクセストークンで認証するハンドラーを返します。
+func (sam *SessionAuthMiddleware) Handler(next http.Handler) http.Handler {
+	fallback := sam.Fallback(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accessToken, ok := bearerToken(r)
+		// API トークンには "." が含まれないため、header.payload.signature の形のトークンだけを JWT として扱う
+		if !ok || strings.Count(accessToken, ".") != 2 {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+
+		user, session, err := sam.Sessions.Authenticate(r.Context(), accessToken)
+		if err != nil {
+			if model.IsErrUnauthorized(err) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="restricted", error="invalid_token"`)
+				problem.Write(w, problem.FromError(http.StatusUnauthorized, err))
+				return
+			}
+			log.Println("Error authenticating the access token:", err)
+			problem.Write(w, problem.New(http.StatusInternalServerError, ""))
+			return
+		}
+
+		// 認証成功、パスワードで認証した場合と同じく todos:read と todos:write を持ち、admin は管理者だけが持つ (service.HasScope)。
+		// スコープはトークンではなく利用者で決まるため、トークンは設定しない
+		ctx := service.ContextWithUser(r.Context(), user)
+		ctx = service.ContextWithActor(ctx, user.Name)
+		ctx = service.ContextWithSession(ctx, session)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
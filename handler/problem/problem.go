@@ -0,0 +1,64 @@
+// Package problem writes error responses in RFC 7807 Problem Details for HTTP APIs.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// ContentType は problem のレスポンスの Content-Type です。
+const ContentType = "application/problem+json"
+
+// New は status の problem を作成します。detail が空の場合は省略します。
+func New(status int, detail string) *model.Problem {
+	return &model.Problem{
+		Type:   model.ProblemTypeBlank,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// FromError は err を detail とする status の problem を作成します。
+// err がドメインのエラーの場合は、その種類を type に設定します。
+// ErrValidation の場合はフィールドの詳細を、ErrPreconditionFailed の場合は現在の TODO を含めます。
+func FromError(status int, err error) *model.Problem {
+	p := New(status, err.Error())
+	switch {
+	case model.IsErrValidation(err):
+		p.Type = model.ProblemTypeValidation
+		if validation, ok := model.AsErrValidation(err); ok && validation.Field != "" {
+			p.Errors = []*model.FieldError{{Field: validation.Field, Message: validation.Message}}
+		}
+	case model.IsErrNotFound(err):
+		p.Type = model.ProblemTypeNotFound
+	case model.IsErrConflict(err):
+		p.Type = model.ProblemTypeConflict
+	case model.IsErrUnauthorized(err):
+		p.Type = model.ProblemTypeUnauthorized
+	case model.IsErrForbidden(err):
+		p.Type = model.ProblemTypeForbidden
+	case model.IsErrPreconditionFailed(err):
+		p.Type = model.ProblemTypePreconditionFailed
+		var precondition *model.ErrPreconditionFailed
+		if errors.As(err, &precondition) {
+			p.TODO = precondition.Current
+		}
+	}
+	return p
+}
+
+// Write は p を application/problem+json のレスポンスとして書き込みます。
+func Write(w http.ResponseWriter, p *model.Problem) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Println("Error encoding problem response:", err)
+	}
+}
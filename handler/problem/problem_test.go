@@ -0,0 +1,86 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/handler/problem"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+func TestFromError(t *testing.T) {
+	t.Parallel()
+
+	current := &model.TODO{ID: 1, Version: 2}
+	cases := map[string]struct {
+		err    error
+		typ    string
+		fields []string
+		todo   bool
+	}{
+		"Validation":        {err: &model.ErrValidation{Field: "subject", Message: "is required"}, typ: model.ProblemTypeValidation, fields: []string{"subject"}},
+		"ValidationValue":   {err: model.ErrValidation{Field: "size", Message: "must be a positive integer"}, typ: model.ProblemTypeValidation, fields: []string{"size"}},
+		"ValidationNoField": {err: &model.ErrValidation{Message: "the patched TODO must be an object"}, typ: model.ProblemTypeValidation},
+		"Wrapped":           {err: fmt.Errorf("wrapped: %w", &model.ErrValidation{Field: "list_id", Message: "list 1 does not exist"}), typ: model.ProblemTypeValidation, fields: []string{"list_id"}},
+		"NotFound":          {err: &model.ErrNotFound{}, typ: model.ProblemTypeNotFound},
+		"Conflict":          {err: &model.ErrConflict{Message: "the list is archived"}, typ: model.ProblemTypeConflict},
+		"Unauthorized":      {err: &model.ErrUnauthorized{}, typ: model.ProblemTypeUnauthorized},
+		"Forbidden":         {err: &model.ErrForbidden{}, typ: model.ProblemTypeForbidden},
+		"Precondition":      {err: &model.ErrPreconditionFailed{Current: current}, typ: model.ProblemTypePreconditionFailed, todo: true},
+		"Other":             {err: errors.New("unexpected EOF"), typ: model.ProblemTypeBlank},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := problem.FromError(http.StatusBadRequest, c.err)
+			if p.Type != c.typ {
+				t.Errorf("unexpected type, given = %s, expected = %s", p.Type, c.typ)
+			}
+			if p.Status != http.StatusBadRequest || p.Title != "Bad Request" || p.Detail != c.err.Error() {
+				t.Errorf("unexpected problem, given = %+v", p)
+			}
+			if len(p.Errors) != len(c.fields) {
+				t.Fatalf("unexpected errors, given = %+v, expected fields = %v", p.Errors, c.fields)
+			}
+			for i, field := range c.fields {
+				if p.Errors[i].Field != field {
+					t.Errorf("unexpected field, given = %s, expected = %s", p.Errors[i].Field, field)
+				}
+			}
+			if (p.TODO != nil) != c.todo {
+				t.Errorf("unexpected TODO, given = %+v", p.TODO)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	w := httptest.NewRecorder()
+	problem.Write(w, problem.New(http.StatusMethodNotAllowed, ""))
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status, given = %d, expected = %d", w.Code, http.StatusMethodNotAllowed)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != problem.ContentType {
+		t.Errorf("unexpected Content-Type, given = %s, expected = %s", contentType, problem.ContentType)
+	}
+	var body map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal("failed to decode response, err =", err)
+	}
+	if body["type"] != "about:blank" || body["title"] != "Method Not Allowed" || body["status"] != float64(http.StatusMethodNotAllowed) {
+		t.Errorf("unexpected problem, given = %v", body)
+	}
+	if _, ok := body["detail"]; ok {
+		t.Errorf("empty detail must be omitted, given = %v", body)
+	}
+}
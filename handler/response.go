@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/handler/problem"
+)
+
+// writeJSON は v を JSON エンコードし、200 OK のレスポンスとして書き込みます。
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	writeJSONStatus(w, http.StatusOK, v)
+}
+
+// writeJSONStatus は v を JSON エンコードし、status のレスポンスとして書き込みます。
+func writeJSONStatus(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("Error encoding JSON response:", err)
+	}
+}
+
+// writeProblem は status の application/problem+json のレスポンスを書き込みます。detail が空の場合は省略します。
+func writeProblem(w http.ResponseWriter, status int, detail string) {
+	problem.Write(w, problem.New(status, detail))
+}
+
+// writeErrorProblem は err を status の application/problem+json のレスポンスとして書き込みます。
+func writeErrorProblem(w http.ResponseWriter, status int, err error) {
+	problem.Write(w, problem.FromError(status, err))
+}
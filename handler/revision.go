@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// RevisionReader reads the revisions of a TODO. *service.TODOService implements it.
+type RevisionReader interface {
+	ReadRevisions(ctx context.Context, id int64) ([]*model.Revision, error)
+}
+
+// TODORevisionHandler handles HTTP requests for listing the revisions of a TODO.
+type TODORevisionHandler struct {
+	service RevisionReader
+}
+
+// NewTODORevisionHandler creates a new TODORevisionHandler with the provided RevisionReader.
+func NewTODORevisionHandler(svc RevisionReader) *TODORevisionHandler {
+	return &TODORevisionHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for TODORevisionHandler.
+func (h *TODORevisionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	id, ok := parseIDQuery(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.service.ReadRevisions(r.Context(), id)
+	if err != nil {
+		if model.IsErrNotFound(err) {
+			writeErrorProblem(w, http.StatusNotFound, err)
+			return
+		}
+		log.Println("Error reading revisions:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.ReadRevisionResponse{Revisions: revisions})
+}
+
+// RevisionDiffer compares two revisions of a TODO. *service.TODOService implements it.
+type RevisionDiffer interface {
+	DiffRevisions(ctx context.Context, id, from, to int64) ([]*model.RevisionChange, error)
+}
+
+// TODORevisionDiffHandler handles HTTP requests for comparing two revisions of a TODO.
+type TODORevisionDiffHandler struct {
+	service RevisionDiffer
+}
+
+// NewTODORevisionDiffHandler creates a new TODORevisionDiffHandler with the provided RevisionDiffer.
+func NewTODORevisionDiffHandler(svc RevisionDiffer) *TODORevisionDiffHandler {
+	return &TODORevisionDiffHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for TODORevisionDiffHandler.
+func (h *TODORevisionDiffHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	id, ok := parseIDQuery(w, r)
+	if !ok {
+		return
+	}
+	from, err := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+	if err != nil || from <= 0 {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "from", Message: "is required and must be greater than 0"})
+		return
+	}
+	to, err := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+	if err != nil || to <= 0 {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "to", Message: "is required and must be greater than 0"})
+		return
+	}
+
+	changes, err := h.service.DiffRevisions(r.Context(), id, from, to)
+	if err != nil {
+		if model.IsErrNotFound(err) {
+			writeErrorProblem(w, http.StatusNotFound, err)
+			return
+		}
+		log.Println("Error comparing revisions:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.DiffRevisionResponse{From: from, To: to, Changes: changes})
+}
+
+// TODOReverter reverts a TODO to a past revision. *service.TODOService implements it.
+type TODOReverter interface {
+	RevertTODO(ctx context.Context, id, revision int64) (*model.TODO, error)
+}
+
+// TODORevertHandler handles HTTP requests for reverting a TODO to a past revision.
+type TODORevertHandler struct {
+	service TODOReverter
+}
+
+// NewTODORevertHandler creates a new TODORevertHandler with the provided TODOReverter.
+func NewTODORevertHandler(svc TODOReverter) *TODORevertHandler {
+	return &TODORevertHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for TODORevertHandler.
+func (h *TODORevertHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	var req model.RevertTODORequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.ID == 0 {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "id", Message: "is required and must be greater than 0"})
+		return
+	}
+	if req.Revision <= 0 {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "revision", Message: "is required and must be greater than 0"})
+		return
+	}
+
+	todo, err := h.service.RevertTODO(r.Context(), req.ID, req.Revision)
+	if err != nil {
+		switch {
+		case model.IsErrNotFound(err):
+			writeErrorProblem(w, http.StatusNotFound, err)
+		case model.IsErrValidation(err):
+			writeErrorProblem(w, http.StatusBadRequest, err)
+		case model.IsErrForbidden(err):
+			writeErrorProblem(w, http.StatusForbidden, err)
+		default:
+			log.Println("Error reverting TODO:", err)
+			writeProblem(w, http.StatusInternalServerError, "")
+		}
+		return
+	}
+
+	setETag(w, todo)
+	writeJSON(w, model.RevertTODOResponse{TODO: todo})
+}
@@ -1,47 +1,189 @@
 package router
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
+	"github.com/TechBowl-japan/go-stations/backup"
 	"github.com/TechBowl-japan/go-stations/handler"
 	"github.com/TechBowl-japan/go-stations/handler/middleware" //station1
+	"github.com/TechBowl-japan/go-stations/htpasswd"
+	"github.com/TechBowl-japan/go-stations/jwt"
+	"github.com/TechBowl-japan/go-stations/model"
 	"github.com/TechBowl-japan/go-stations/service"
 )
 
-// NewRouter sets up the HTTP router with all necessary endpoints.
+// Services are the services that the router dispatches requests to.
+type Services struct {
+	TODO *service.TODOService
+	Tag  *service.TagService
+	List *service.ListService
+	// User は利用者の登録と認証を行います。
+	User *service.UserService
+	// Token は API トークンの発行と、トークンによる認証を行います。
+	Token *service.TokenService
+	// Session は /login で始めたセッションのアクセストークンとリフレッシュトークンを発行し、アクセストークンで認証します。
+	Session *service.SessionService
+	// Credentials は Basic 認証で、登録された利用者より先に照合する htpasswd ファイルです。nil の場合は使いません。
+	Credentials *htpasswd.File
+	// Backup は SQLite のデータベースのバックアップを作成します。nil の場合はバックアップのエンドポイントを登録しません。
+	Backup *backup.Manager
+}
+
+// NewRouter sets up the HTTP router with all necessary endpoints backed by the SQLite or PostgreSQL database.
+// userID が空でない場合は、その名前とパスワードの利用者を用意し、所有者のない TODO を引き継がせます。
+// アクセストークンには起動ごとに生成する鍵で署名するため、再起動するとそれまでのアクセストークンは使えなくなります。
+// 鍵の生成や利用者の用意に失敗した場合はエラーを返すため、終了するかは呼び出し元が決めてください。
 // station4
-func NewRouter(db *sql.DB, userID, password string) http.Handler {
+func NewRouter(db *sql.DB, userID, password string) (http.Handler, error) {
+	keys, err := jwt.GenerateKeySet()
+	if err != nil {
+		return nil, fmt.Errorf("router: failed to generate keys: %w", err)
+	}
+	services := &Services{
+		TODO:    service.NewTODOService(db),
+		Tag:     service.NewTagService(db),
+		List:    service.NewListService(db),
+		User:    service.NewUserService(db),
+		Token:   service.NewTokenService(db),
+		Session: service.NewSessionService(db, keys),
+	}
+	if userID != "" {
+		if err := Bootstrap(context.Background(), services, userID, password); err != nil {
+			return nil, fmt.Errorf("router: failed to bootstrap user %s: %w", userID, err)
+		}
+	}
+	return NewRouterWithServices(services), nil
+}
+
+// Bootstrap creates the user of name and password, or resets the password of the user,
+// makes the user an administrator and the owner of the TODOs that have no owner.
+//
+// 利用者ごとの TODO を導入する前の BASIC_AUTH_USER_ID と BASIC_AUTH_PASSWORD の設定を引き継ぐために、起動時に呼び出します。
+func Bootstrap(ctx context.Context, services *Services, name, password string) error {
+	user, err := services.User.EnsureUser(ctx, name, password)
+	if err != nil {
+		return err
+	}
+	claimed, err := services.TODO.ClaimTODOs(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if claimed > 0 {
+		log.Printf("Assigned %d TODOs without an owner to user %s", claimed, user.Name)
+	}
+	return nil
+}
+
+// NewRouterWithServices sets up the HTTP router with all necessary endpoints backed by services.
+func NewRouterWithServices(services *Services) http.Handler {
 	mux := http.NewServeMux()
 
 	// Register HealthzHandler
 	healthzHandler := handler.NewHealthzHandler()
 	mux.Handle("/healthz", healthzHandler)
 
-	// Create TODOService
-	todoService := service.NewTODOService(db)
+	todoService := services.TODO
 
 	// Create TODOHandler and register
 	todoHandler := handler.NewTODOHandler(todoService)
 
 	// station3, 4
 	// ミドルウェアを適用
-	// Order: Recovery -> OSExtractor -> BasicAuth -> LoggingMiddleware -> Handler
-	// Basic 認証ミドルウェア
-	basicAuthMiddleware := middleware.NewBasicAuthMiddleware(userID, password)
-	wrappedTODOHandler := middleware.Recovery(
-		middleware.OSExtractor(
-			basicAuthMiddleware.Handler(
-				middleware.LoggingMiddleware(todoHandler),
+	// Order: Recovery -> OSExtractor -> SessionAuth (BearerAuth (BasicAuth)) -> RequireScope -> LoggingMiddleware -> Handler
+	// JWT のアクセストークンはセッションで、それ以外の Bearer トークンは API トークンで、Bearer トークンのないリクエストは Basic 認証で認証する
+	basicAuthMiddleware := middleware.NewBasicAuthMiddleware(services.User)
+	basicAuthMiddleware.Credentials = services.Credentials
+	bearerAuthMiddleware := middleware.NewBearerAuthMiddleware(services.Token, basicAuthMiddleware.Handler)
+	authMiddleware := middleware.NewSessionAuthMiddleware(services.Session, bearerAuthMiddleware.Handler)
+	wrapScope := func(scope func(http.Handler) http.Handler, h http.Handler) http.Handler {
+		return middleware.Recovery(
+			middleware.OSExtractor(
+				authMiddleware.Handler(
+					scope(
+						middleware.LoggingMiddleware(h),
+					),
+				),
 			),
-		),
-	)
+		)
+	}
+	// TODO・タグ・リストは、API トークンの todos:read で読み込み、todos:write で変更できる
+	wrap := func(h http.Handler) http.Handler {
+		return wrapScope(middleware.RequireTODOScope, h)
+	}
+	// API トークンの管理には、API トークンで認証する場合だけ admin が必要
+	wrapTokens := func(h http.Handler) http.Handler {
+		return wrapScope(middleware.RequireTokenScope(model.ScopeAdmin), h)
+	}
+	// バックアップには、さらに管理者であることが必要
+	wrapAdmin := func(h http.Handler) http.Handler {
+		return wrapScope(func(next http.Handler) http.Handler {
+			return middleware.RequireAdmin(middleware.RequireScope(model.ScopeAdmin)(next))
+		}, h)
+	}
+
+	// 利用者の登録、ログイン、セッションの更新とログアウトは、認証なしで受け付ける
+	unauthenticated := func(h http.Handler) http.Handler {
+		return middleware.Recovery(middleware.LoggingMiddleware(h))
+	}
+	mux.Handle("/signup", unauthenticated(handler.NewSignupHandler(services.User)))
+	mux.Handle("/login", unauthenticated(handler.NewLoginHandler(services.User, services.Session)))
+	mux.Handle("/refresh", unauthenticated(handler.NewRefreshHandler(services.Session)))
+	mux.Handle("/logout", unauthenticated(handler.NewLogoutHandler(services.Session)))
+	// アクセストークンを検証する公開鍵
+	mux.Handle("/.well-known/jwks.json", unauthenticated(handler.NewJWKSHandler(services.Session.Keys)))
 
-	mux.Handle("/todos", wrappedTODOHandler)
+	mux.Handle("/todos", wrap(todoHandler))
 	// station3 end
 
+	// 1 件の TODO の取得・更新・部分的な更新・削除
+	mux.Handle("/todos/", wrap(handler.NewTODOItemHandler(todoService)))
+
+	// 一括作成・一括更新
+	mux.Handle("/todos/bulk", wrap(handler.NewTODOBulkHandler(todoService)))
+
+	// 全文検索
+	mux.Handle("/todos/search", wrap(handler.NewTODOSearchHandler(todoService)))
+
+	// サブタスク
+	mux.Handle("/todos/children", wrap(handler.NewTODOChildrenHandler(todoService)))
+	mux.Handle("/todos/tree", wrap(handler.NewTODOTreeHandler(todoService)))
+
+	// ゴミ箱の一覧と復元
+	mux.Handle("/todos/trash", wrap(handler.NewTrashHandler(todoService)))
+	mux.Handle("/todos/restore", wrap(handler.NewTODORestoreHandler(todoService)))
+
+	// 変更履歴の一覧・比較と、過去の版への差し戻し
+	mux.Handle("/todos/revisions", wrap(handler.NewTODORevisionHandler(todoService)))
+	mux.Handle("/todos/revisions/diff", wrap(handler.NewTODORevisionDiffHandler(todoService)))
+	mux.Handle("/todos/revert", wrap(handler.NewTODORevertHandler(todoService)))
+
+	// タグの一覧・名前の変更・統合
+	tagService := services.Tag
+	mux.Handle("/tags", wrap(handler.NewTagHandler(tagService)))
+	mux.Handle("/tags/merge", wrap(handler.NewTagMergeHandler(tagService)))
+
+	// リストの CRUD・アーカイブと TODO のリスト間の移動
+	mux.Handle("/lists", wrap(handler.NewListHandler(services.List)))
+	// リストの共有
+	mux.Handle("/lists/", wrap(handler.NewListMemberHandler(services.List)))
+	mux.Handle("/todos/move", wrap(handler.NewTODOMoveHandler(todoService)))
+
+	// データベースのバックアップの作成・一覧とスナップショットのダウンロード
+	if services.Backup != nil {
+		mux.Handle("/admin/backups", wrapAdmin(handler.NewBackupHandler(services.Backup)))
+		mux.Handle("/admin/snapshot", wrapAdmin(handler.NewSnapshotHandler(services.Backup)))
+	}
+
+	// API トークンの作成・一覧・取り消し
+	// admin スコープのトークンは管理者にしか発行しない
+	mux.Handle("/tokens", wrapTokens(handler.NewTokenHandler(services.Token)))
+	mux.Handle("/tokens/", wrapTokens(handler.NewTokenItemHandler(services.Token)))
+
 	// 他のエンドポイントの登録もここで行う
 	// station1
 	// PanicHandler をミドルウェアでラップして登録
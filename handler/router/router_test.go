@@ -0,0 +1,112 @@
+package router_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/backup"
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/handler/router"
+	"github.com/TechBowl-japan/go-stations/jwt"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestNewRouterWithServices_Admin(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "todo.db")
+	d, err := db.NewDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	keys, err := jwt.GenerateKeySet()
+	if err != nil {
+		t.Fatal("failed to generate keys, err =", err)
+	}
+	services := &router.Services{
+		TODO:    service.NewTODOService(d),
+		Tag:     service.NewTagService(d),
+		List:    service.NewListService(d),
+		User:    service.NewUserService(d),
+		Token:   service.NewTokenService(d),
+		Session: service.NewSessionService(d, keys),
+		Backup:  backup.NewManager(path, filepath.Join(dir, "backups"), 3),
+	}
+	// BASIC_AUTH_USER_ID の利用者は管理者になる
+	if err := router.Bootstrap(context.Background(), services, "operator", "operator-password"); err != nil {
+		t.Fatal("failed to bootstrap, err =", err)
+	}
+	mux := router.NewRouterWithServices(services)
+
+	serve := func(method, path, body, user, password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if user != "" {
+			r.SetBasicAuth(user, password)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	// 誰でも登録できる利用者は管理者にならない
+	if w := serve(http.MethodPost, "/signup", `{"name":"mallory","password":"mallory-password"}`, "", ""); w.Code != http.StatusCreated {
+		t.Fatalf("failed to sign up, status = %d, body = %s", w.Code, w.Body)
+	}
+
+	cases := []struct {
+		name     string
+		user     string
+		password string
+		method   string
+		path     string
+		body     string
+		code     int
+	}{
+		{name: "SnapshotByUser", user: "mallory", password: "mallory-password", method: http.MethodGet, path: "/admin/snapshot", code: http.StatusForbidden},
+		{name: "BackupsByUser", user: "mallory", password: "mallory-password", method: http.MethodGet, path: "/admin/backups", code: http.StatusForbidden},
+		{name: "CreateBackupByUser", user: "mallory", password: "mallory-password", method: http.MethodPost, path: "/admin/backups", code: http.StatusForbidden},
+		{name: "AdminTokenByUser", user: "mallory", password: "mallory-password", method: http.MethodPost, path: "/tokens", body: `{"name":"ci","scopes":["admin"]}`, code: http.StatusForbidden},
+		{name: "TokenByUser", user: "mallory", password: "mallory-password", method: http.MethodPost, path: "/tokens", body: `{"name":"ci","scopes":["todos:read"]}`, code: http.StatusCreated},
+		{name: "SnapshotByAdmin", user: "operator", password: "operator-password", method: http.MethodGet, path: "/admin/snapshot", code: http.StatusOK},
+		{name: "BackupsByAdmin", user: "operator", password: "operator-password", method: http.MethodGet, path: "/admin/backups", code: http.StatusOK},
+		{name: "AdminTokenByAdmin", user: "operator", password: "operator-password", method: http.MethodPost, path: "/tokens", body: `{"name":"ci","scopes":["admin"]}`, code: http.StatusCreated},
+		{name: "SnapshotWithoutAuth", method: http.MethodGet, path: "/admin/snapshot", code: http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		w := serve(c.method, c.path, c.body, c.user, c.password)
+		if w.Code != c.code {
+			t.Errorf("%s: unexpected status, given = %d, expected = %d, body = %s", c.name, w.Code, c.code, w.Body)
+		}
+	}
+}
+
+func TestNewRouter(t *testing.T) {
+	t.Parallel()
+
+	d, err := db.NewDB(filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux, err := router.NewRouter(d, "operator", "operator-password")
+	if err != nil {
+		t.Fatal("failed to create router, err =", err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/todos", nil)
+	r.SetBasicAuth("operator", "operator-password")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected status, given = %d, expected = %d, body = %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	// 利用者を用意できない場合は、終了せずにエラーを返す
+	d.Close()
+	if _, err := router.NewRouter(d, "operator", "operator-password"); err == nil {
+		t.Error("expected an error for a closed database")
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// TODOSearcher searches TODOs. *service.TODOService implements it.
+type TODOSearcher interface {
+	SearchTODO(ctx context.Context, query string, size, offset int64) ([]*model.SearchTODOResult, error)
+}
+
+// TODOSearchHandler handles HTTP requests for full-text search of TODOs.
+type TODOSearchHandler struct {
+	service TODOSearcher
+}
+
+// NewTODOSearchHandler creates a new TODOSearchHandler with the provided TODOSearcher.
+func NewTODOSearchHandler(svc TODOSearcher) *TODOSearchHandler {
+	return &TODOSearchHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for TODOSearchHandler.
+func (h *TODOSearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	// クエリパラメータから q と size と offset を取得
+	req := model.SearchTODORequest{
+		Query: strings.TrimSpace(r.URL.Query().Get("q")),
+		Size:  10,
+	}
+	if req.Query == "" {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "q", Message: "is required"})
+		return
+	}
+	if sizeStr := r.URL.Query().Get("size"); sizeStr != "" {
+		size, err := strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil || size <= 0 {
+			writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "size", Message: "must be a positive integer"})
+			return
+		}
+		req.Size = size
+	}
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		offset, err := strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil || offset < 0 {
+			writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "offset", Message: "must be a non-negative integer"})
+			return
+		}
+		req.Offset = offset
+	}
+
+	results, err := h.service.SearchTODO(r.Context(), req.Query, req.Size, req.Offset)
+	if err != nil {
+		log.Println("Error searching TODOs:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.SearchTODOResponse{Results: results})
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// SessionRefresher issues new tokens for a refresh token. *service.SessionService implements it.
+type SessionRefresher interface {
+	Refresh(ctx context.Context, refreshToken string) (*model.User, *model.SessionTokens, error)
+}
+
+// RefreshHandler handles HTTP requests to exchange a refresh token for new tokens of the session.
+//
+// リフレッシュトークンは 1 回しか使えません。使用済みのリフレッシュトークンが再び送られた場合は、
+// 盗まれたものとしてセッションを取り消します。
+type RefreshHandler struct {
+	service SessionRefresher
+}
+
+// NewRefreshHandler creates a new RefreshHandler with the provided SessionRefresher.
+func NewRefreshHandler(svc SessionRefresher) *RefreshHandler {
+	return &RefreshHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for RefreshHandler.
+func (h *RefreshHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	var req model.RefreshRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.RefreshToken == "" {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "refresh_token", Message: "is required"})
+		return
+	}
+
+	user, tokens, err := h.service.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		if model.IsErrUnauthorized(err) {
+			writeErrorProblem(w, http.StatusUnauthorized, err)
+			return
+		}
+		log.Println("Error refreshing session:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, model.RefreshResponse{User: user, SessionTokens: *tokens})
+}
+
+// SessionRevoker revokes the session of a refresh token. *service.SessionService implements it.
+type SessionRevoker interface {
+	Logout(ctx context.Context, refreshToken string) error
+}
+
+// LogoutHandler handles HTTP requests to revoke the session of a refresh token.
+// セッションのアクセストークンも、有効期間の途中でも使えなくなります。
+type LogoutHandler struct {
+	service SessionRevoker
+}
+
+// NewLogoutHandler creates a new LogoutHandler with the provided SessionRevoker.
+func NewLogoutHandler(svc SessionRevoker) *LogoutHandler {
+	return &LogoutHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for LogoutHandler.
+func (h *LogoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	var req model.LogoutRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.RefreshToken == "" {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "refresh_token", Message: "is required"})
+		return
+	}
+
+	if err := h.service.Logout(r.Context(), req.RefreshToken); err != nil {
+		if model.IsErrUnauthorized(err) {
+			writeErrorProblem(w, http.StatusUnauthorized, err)
+			return
+		}
+		log.Println("Error logging out:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.LogoutResponse{})
+}
+
+// PublicKeySet returns the public keys as a JWK Set. *jwt.KeySet implements it.
+type PublicKeySet interface {
+	PublicKeys() ([]byte, error)
+}
+
+// JWKSHandler handles HTTP requests to read the public keys that verify access tokens.
+// HS256 の鍵は共有の秘密のため公開しません。EdDSA の鍵を使う場合に、他のサービスがアクセストークンを検証できます。
+type JWKSHandler struct {
+	keys PublicKeySet
+}
+
+// NewJWKSHandler creates a new JWKSHandler with the provided PublicKeySet.
+func NewJWKSHandler(keys PublicKeySet) *JWKSHandler {
+	return &JWKSHandler{
+		keys: keys,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for JWKSHandler.
+func (h *JWKSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	body, err := h.keys.PublicKeys()
+	if err != nil {
+		log.Println("Error encoding public keys:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	if _, err := w.Write(append(body, '\n')); err != nil {
+		log.Println("Error writing public keys:", err)
+	}
+}
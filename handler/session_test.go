@@ -0,0 +1,97 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
+	"github.com/TechBowl-japan/go-stations/jwt"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestSessionHandler(t *testing.T) {
+	t.Parallel()
+
+	keys, err := jwt.GenerateKeySet()
+	if err != nil {
+		t.Fatal("failed to generate keys, err =", err)
+	}
+	store := service.NewMemoryStore()
+	users := service.NewUserServiceWithRepository(store)
+	sessions := service.NewSessionServiceWithRepository(store, keys)
+	if _, err := users.Signup(context.Background(), "alice", "password1"); err != nil {
+		t.Fatal("failed to sign up, err =", err)
+	}
+
+	// ルーターと同じ順に、アクセストークン、API トークン、Basic 認証の順で認証する
+	basic := middleware.NewBasicAuthMiddleware(users)
+	bearer := middleware.NewBearerAuthMiddleware(service.NewTokenServiceWithRepository(store), basic.Handler)
+	auth := middleware.NewSessionAuthMiddleware(sessions, bearer.Handler)
+	mux := http.NewServeMux()
+	mux.Handle("/login", handler.NewLoginHandler(users, sessions))
+	mux.Handle("/refresh", handler.NewRefreshHandler(sessions))
+	mux.Handle("/logout", handler.NewLogoutHandler(sessions))
+	mux.Handle("/.well-known/jwks.json", handler.NewJWKSHandler(keys))
+	mux.Handle("/todos", auth.Handler(handler.NewTODOHandler(service.NewTODOServiceWithRepository(store))))
+
+	serve := func(method, path, body, accessToken string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if accessToken != "" {
+			r.Header.Set("Authorization", "Bearer "+accessToken)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) *model.RefreshResponse {
+		t.Helper()
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status, given = %d, body = %s", w.Code, w.Body)
+		}
+		var resp model.RefreshResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal("failed to decode response, err =", err)
+		}
+		if resp.User == nil || resp.User.Name != "alice" || resp.AccessToken == "" || resp.RefreshToken == "" {
+			t.Fatalf("unexpected response, given = %+v", resp)
+		}
+		return &resp
+	}
+
+	login := decode(serve(http.MethodPost, "/login", `{"name":"alice","password":"password1"}`, ""))
+	refreshed := decode(serve(http.MethodPost, "/refresh", `{"refresh_token":"`+login.RefreshToken+`"}`, ""))
+
+	// ケースは前のケースでログアウトしたセッションを使うため、順に実行する
+	cases := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		accessToken string
+		code        int
+	}{
+		{name: "AccessToken", method: http.MethodPost, path: "/todos", body: `{"subject":"a"}`, accessToken: login.AccessToken, code: http.StatusCreated},
+		{name: "RefreshedAccessToken", method: http.MethodGet, path: "/todos", accessToken: refreshed.AccessToken, code: http.StatusOK},
+		{name: "TamperedAccessToken", method: http.MethodGet, path: "/todos", accessToken: refreshed.AccessToken + "x", code: http.StatusUnauthorized},
+		{name: "NoCredentials", method: http.MethodGet, path: "/todos", code: http.StatusUnauthorized},
+		{name: "RefreshWithoutToken", method: http.MethodPost, path: "/refresh", body: `{}`, code: http.StatusBadRequest},
+		{name: "RefreshUnknownToken", method: http.MethodPost, path: "/refresh", body: `{"refresh_token":"str_unknown"}`, code: http.StatusUnauthorized},
+		{name: "Logout", method: http.MethodPost, path: "/logout", body: `{"refresh_token":"` + refreshed.RefreshToken + `"}`, code: http.StatusOK},
+		{name: "AccessTokenAfterLogout", method: http.MethodGet, path: "/todos", accessToken: refreshed.AccessToken, code: http.StatusUnauthorized},
+		{name: "RefreshAfterLogout", method: http.MethodPost, path: "/refresh", body: `{"refresh_token":"` + refreshed.RefreshToken + `"}`, code: http.StatusUnauthorized},
+		{name: "LogoutMethodNotAllowed", method: http.MethodGet, path: "/logout", code: http.StatusMethodNotAllowed},
+		{name: "JWKS", method: http.MethodGet, path: "/.well-known/jwks.json", code: http.StatusOK},
+	}
+	for _, c := range cases {
+		w := serve(c.method, c.path, c.body, c.accessToken)
+		if w.Code != c.code {
+			t.Errorf("%s: unexpected status, given = %d, expected = %d, body = %s", c.name, w.Code, c.code, w.Body)
+		}
+	}
+}
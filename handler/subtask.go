@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// TODOChildrenReader reads the subtasks of a TODO. *service.TODOService implements it.
+type TODOChildrenReader interface {
+	ReadTODOChildren(ctx context.Context, id int64) ([]*model.TODO, error)
+}
+
+// TODOChildrenHandler handles HTTP requests for listing the subtasks of a TODO.
+type TODOChildrenHandler struct {
+	service TODOChildrenReader
+}
+
+// NewTODOChildrenHandler creates a new TODOChildrenHandler with the provided TODOChildrenReader.
+func NewTODOChildrenHandler(svc TODOChildrenReader) *TODOChildrenHandler {
+	return &TODOChildrenHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for TODOChildrenHandler.
+func (h *TODOChildrenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	id, ok := parseIDQuery(w, r)
+	if !ok {
+		return
+	}
+
+	todos, err := h.service.ReadTODOChildren(r.Context(), id)
+	if err != nil {
+		if model.IsErrNotFound(err) {
+			writeErrorProblem(w, http.StatusNotFound, err)
+			return
+		}
+		log.Println("Error reading children:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.ReadTODOChildrenResponse{TODOs: todos})
+}
+
+// TODOTreeReader reads a TODO with all of its descendants. *service.TODOService implements it.
+type TODOTreeReader interface {
+	ReadTODOTree(ctx context.Context, id int64) (*model.TODONode, error)
+}
+
+// TODOTreeHandler handles HTTP requests for reading a TODO with all of its descendants.
+type TODOTreeHandler struct {
+	service TODOTreeReader
+}
+
+// NewTODOTreeHandler creates a new TODOTreeHandler with the provided TODOTreeReader.
+func NewTODOTreeHandler(svc TODOTreeReader) *TODOTreeHandler {
+	return &TODOTreeHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for TODOTreeHandler.
+func (h *TODOTreeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	id, ok := parseIDQuery(w, r)
+	if !ok {
+		return
+	}
+
+	tree, err := h.service.ReadTODOTree(r.Context(), id)
+	if err != nil {
+		if model.IsErrNotFound(err) {
+			writeErrorProblem(w, http.StatusNotFound, err)
+			return
+		}
+		log.Println("Error reading tree:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.ReadTODOTreeResponse{Tree: tree})
+}
+
+// parseIDQuery はクエリパラメータの id を取得します。不正な場合は 400 Bad Request を書き込み false を返します。
+func parseIDQuery(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "id", Message: "is required and must be greater than 0"})
+		return 0, false
+	}
+	return id, true
+}
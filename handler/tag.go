@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// TagStore lists and renames tags. *service.TagService implements it.
+type TagStore interface {
+	ReadTags(ctx context.Context) ([]*model.Tag, error)
+	RenameTag(ctx context.Context, id int64, name string) (*model.Tag, error)
+}
+
+// TagHandler handles HTTP requests for listing and renaming tags.
+type TagHandler struct {
+	service TagStore
+}
+
+// NewTagHandler creates a new TagHandler with the provided TagStore.
+func NewTagHandler(svc TagStore) *TagHandler {
+	return &TagHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for TagHandler.
+func (h *TagHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.readTags(w, r)
+	case http.MethodPut:
+		h.renameTag(w, r)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+	}
+}
+
+// readTags handles GET requests to list all tags.
+func (h *TagHandler) readTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.service.ReadTags(r.Context())
+	if err != nil {
+		log.Println("Error reading tags:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.ReadTagResponse{Tags: tags})
+}
+
+// renameTag handles PUT requests to rename a tag.
+func (h *TagHandler) renameTag(w http.ResponseWriter, r *http.Request) {
+	var req model.UpdateTagRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.ID == 0 {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "id", Message: "is required and must be greater than 0"})
+		return
+	}
+	if req.Name == "" {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "name", Message: "is required"})
+		return
+	}
+
+	tag, err := h.service.RenameTag(r.Context(), req.ID, req.Name)
+	if err != nil {
+		switch {
+		case model.IsErrNotFound(err):
+			writeErrorProblem(w, http.StatusNotFound, err)
+		case model.IsErrConflict(err):
+			writeErrorProblem(w, http.StatusConflict, err)
+		case model.IsErrForbidden(err):
+			writeErrorProblem(w, http.StatusForbidden, err)
+		default:
+			log.Println("Error renaming tag:", err)
+			writeProblem(w, http.StatusInternalServerError, "")
+		}
+		return
+	}
+
+	writeJSON(w, model.UpdateTagResponse{Tag: tag})
+}
+
+// TagMerger merges tags. *service.TagService implements it.
+type TagMerger interface {
+	MergeTags(ctx context.Context, sourceIDs []int64, targetID int64) (*model.Tag, error)
+}
+
+// TagMergeHandler handles HTTP requests for merging tags.
+type TagMergeHandler struct {
+	service TagMerger
+}
+
+// NewTagMergeHandler creates a new TagMergeHandler with the provided TagMerger.
+func NewTagMergeHandler(svc TagMerger) *TagMergeHandler {
+	return &TagMergeHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for TagMergeHandler.
+func (h *TagMergeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	var req model.MergeTagRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.TargetID == 0 {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "target_id", Message: "is required and must be greater than 0"})
+		return
+	}
+	if len(req.SourceIDs) == 0 {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "source_ids", Message: "are required"})
+		return
+	}
+
+	tag, err := h.service.MergeTags(r.Context(), req.SourceIDs, req.TargetID)
+	if err != nil {
+		if model.IsErrNotFound(err) {
+			writeErrorProblem(w, http.StatusNotFound, err)
+			return
+		}
+		log.Println("Error merging tags:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.MergeTagResponse{Tag: tag})
+}
+
+// normalizeTags はタグ名の前後の空白を取り除きます。空のタグ名が含まれる場合は false を返します。
+func normalizeTags(tags []string) ([]string, bool) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return nil, false
+		}
+		normalized = append(normalized, tag)
+	}
+	return normalized, true
+}
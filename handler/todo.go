@@ -1,22 +1,38 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 	"github.com/TechBowl-japan/go-stations/model"
 	"github.com/TechBowl-japan/go-stations/service"
 	"github.com/TechBowl-japan/go-stations/handler/middleware" //station2
 )
 
+// TODOUpdater updates a TODO. *service.TODOService implements it.
+type TODOUpdater interface {
+	UpdateTODO(ctx context.Context, id int64, subject, description string, opts ...service.TODOOption) (*model.TODO, error)
+}
+
+// TODOStore is the set of TODO operations TODOHandler uses. *service.TODOService implements it.
+type TODOStore interface {
+	TODOUpdater
+	CreateTODO(ctx context.Context, subject, description string, opts ...service.TODOOption) (*model.TODO, error)
+	ReadTODO(ctx context.Context, prevID, size int64, opts ...service.ReadOption) ([]*model.TODO, error)
+	DeleteTODO(ctx context.Context, ids []int64, opts ...service.DeleteOption) error
+}
+
 // TODOHandler handles HTTP requests for TODO operations.
 type TODOHandler struct {
-	service *service.TODOService
+	service TODOStore
 }
 
-// NewTODOHandler creates a new TODOHandler with the provided TODOService.
-func NewTODOHandler(svc *service.TODOService) *TODOHandler {
+// NewTODOHandler creates a new TODOHandler with the provided TODOStore.
+func NewTODOHandler(svc TODOStore) *TODOHandler {
 	return &TODOHandler{
 		service: svc,
 	}
@@ -34,8 +50,8 @@ func (h *TODOHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case http.MethodDelete:
 		h.handleDelete(w, r)
 	default:
-		w.Header().Set("Allow", "POST, PUT, GET")
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		w.Header().Set("Allow", "POST, PUT, GET, DELETE")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
 	}
 }
 
@@ -46,21 +62,36 @@ func (h *TODOHandler) createTODO(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields() // Reject unknown fields
 	if err := decoder.Decode(&req); err != nil {
-		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		writeErrorProblem(w, http.StatusBadRequest, err)
 		return
 	}
 
-	// Validate that the Subject field is not empty
-	if req.Subject == "" {
-		http.Error(w, "Bad Request: subject is required", http.StatusBadRequest)
+	// Validate the request and build the options
+	opts, err := createOptions(&req)
+	if err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
 		return
 	}
 
 	// Call the service layer to create the TODO
-	todo, err := h.service.CreateTODO(r.Context(), req.Subject, req.Description)
+	todo, err := h.service.CreateTODO(r.Context(), req.Subject, req.Description, opts...)
 	if err != nil {
+		if model.IsErrValidation(err) {
+			writeErrorProblem(w, http.StatusBadRequest, err)
+			return
+		}
+		// アーカイブされたリストには追加できない
+		if model.IsErrConflict(err) {
+			writeErrorProblem(w, http.StatusConflict, err)
+			return
+		}
+		// 閲覧者として共有されたリストや、変更できない親には追加できない
+		if model.IsErrForbidden(err) {
+			writeErrorProblem(w, http.StatusForbidden, err)
+			return
+		}
 		log.Println("Error creating TODO:", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, "")
 		return
 	}
 
@@ -70,13 +101,16 @@ func (h *TODOHandler) createTODO(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Set the Content-Type header to application/json
+	// 作成した TODO の URL を Location で返す
+	setETag(w, todo)
+	w.Header().Set("Location", todoPath(todo.ID))
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusCreated)
 
 	// Encode the response as JSON and send it
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		log.Println("Error encoding JSON response:", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, "")
 		return
 	}
 }
@@ -88,31 +122,57 @@ func (h *TODOHandler) updateTODO(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields() // Reject unknown fields
 	if err := decoder.Decode(&req); err != nil {
-		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		writeErrorProblem(w, http.StatusBadRequest, err)
 		return
 	}
 
-	// Validate that ID is not zero and Subject is not empty
-	if req.ID == 0 {
-		http.Error(w, "Bad Request: id is required and must be greater than 0", http.StatusBadRequest)
+	serveUpdateTODO(w, r, h.service, &req)
+}
+
+// serveUpdateTODO は req の TODO を更新し、更新した TODO をレスポンスとして書き込みます。
+// PUT /todos と PUT /todos/{id} で共通です。
+func serveUpdateTODO(w http.ResponseWriter, r *http.Request, svc TODOUpdater, req *model.UpdateTODORequest) {
+	// Validate the request and build the options
+	opts, err := updateOptions(req)
+	if err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
 		return
 	}
-	if req.Subject == "" {
-		http.Error(w, "Bad Request: subject is required", http.StatusBadRequest)
-		return
+	// If-Match が指定された場合は、その version から変更されていない場合のみ更新する
+	if version, ok := parseIfMatch(r, req.ID); ok {
+		opts = append(opts, service.WithExpectedVersion(version))
 	}
 
 	// Call the service layer to update the TODO
-	updatedTODO, err := h.service.UpdateTODO(r.Context(), req.ID, req.Subject, req.Description)
+	updatedTODO, err := svc.UpdateTODO(r.Context(), req.ID, req.Subject, req.Description, opts...)
 	if err != nil {
 		// Check if the error is ErrNotFound
 		if model.IsErrNotFound(err) {
-			http.Error(w, "Not Found: "+err.Error(), http.StatusNotFound)
+			writeErrorProblem(w, http.StatusNotFound, err)
+			return
+		}
+		// 閲覧者として共有されたリストの TODO は変更できない
+		if model.IsErrForbidden(err) {
+			writeErrorProblem(w, http.StatusForbidden, err)
+			return
+		}
+		// 他の人が先に更新していた場合は、現在の TODO を返す
+		if writePreconditionFailed(w, err) {
+			return
+		}
+		// 親やリストが存在しない場合
+		if model.IsErrValidation(err) {
+			writeErrorProblem(w, http.StatusBadRequest, err)
+			return
+		}
+		// 親子関係が循環する場合や、アーカイブされたリストに移動する場合
+		if model.IsErrConflict(err) {
+			writeErrorProblem(w, http.StatusConflict, err)
 			return
 		}
 		// Handle other potential errors (e.g., database constraints)
 		log.Println("Error updating TODO:", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, "")
 		return
 	}
 
@@ -122,17 +182,98 @@ func (h *TODOHandler) updateTODO(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Set the Content-Type header to application/json
+	setETag(w, updatedTODO)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
 	// Encode the response as JSON and send it
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		log.Println("Error encoding JSON response:", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, "")
 		return
 	}
 }
 
+// createOptions は CreateTODORequest を検証し、CreateTODO に渡すオプションを返します。
+// 不正な場合は *model.ErrValidation を返します。
+func createOptions(req *model.CreateTODORequest) ([]service.TODOOption, error) {
+	// Validate that the Subject field is not empty
+	if req.Subject == "" {
+		return nil, &model.ErrValidation{Field: "subject", Message: "is required"}
+	}
+
+	// Validate that Priority is in range
+	if req.Priority < model.PriorityNone || req.Priority > model.PriorityHigh {
+		return nil, &model.ErrValidation{Field: "priority", Message: "must be between 0 and 3"}
+	}
+
+	opts := []service.TODOOption{service.WithPriority(req.Priority)}
+	if req.DueAt != nil {
+		opts = append(opts, service.WithDueAt(req.DueAt))
+	}
+	if req.Tags != nil {
+		tags, ok := normalizeTags(req.Tags)
+		if !ok {
+			return nil, &model.ErrValidation{Field: "tags", Message: "must not contain an empty name"}
+		}
+		opts = append(opts, service.WithTags(tags))
+	}
+	if req.ParentID != nil {
+		opts = append(opts, service.WithParentID(req.ParentID))
+	}
+	if req.Recurrence != "" {
+		opts = append(opts, service.WithRecurrence(req.Recurrence))
+	}
+	if req.ListID != nil {
+		opts = append(opts, service.WithListID(req.ListID))
+	}
+	return opts, nil
+}
+
+// updateOptions は UpdateTODORequest を検証し、UpdateTODO に渡すオプションを返します。
+// 不正な場合は *model.ErrValidation を返します。
+func updateOptions(req *model.UpdateTODORequest) ([]service.TODOOption, error) {
+	// Validate that ID is not zero and Subject is not empty
+	if req.ID == 0 {
+		return nil, &model.ErrValidation{Field: "id", Message: "is required and must be greater than 0"}
+	}
+	if req.Subject == "" {
+		return nil, &model.ErrValidation{Field: "subject", Message: "is required"}
+	}
+	if req.Priority != nil && (*req.Priority < model.PriorityNone || *req.Priority > model.PriorityHigh) {
+		return nil, &model.ErrValidation{Field: "priority", Message: "must be between 0 and 3"}
+	}
+
+	// done, due_at, priority, tags, parent_id, recurrence, list_id が指定された場合のみ変更する
+	var opts []service.TODOOption
+	if req.Priority != nil {
+		opts = append(opts, service.WithPriority(*req.Priority))
+	}
+	if req.Tags != nil {
+		tags, ok := normalizeTags(req.Tags)
+		if !ok {
+			return nil, &model.ErrValidation{Field: "tags", Message: "must not contain an empty name"}
+		}
+		opts = append(opts, service.WithTags(tags))
+	}
+	if req.ParentID.Set {
+		opts = append(opts, service.WithParentID(req.ParentID.Ptr()))
+	}
+	if req.Recurrence != nil {
+		opts = append(opts, service.WithRecurrence(*req.Recurrence))
+	}
+	if req.ListID.Set {
+		opts = append(opts, service.WithListID(req.ListID.Ptr()))
+	}
+	if req.Done != nil {
+		opts = append(opts, service.WithDone(*req.Done))
+	}
+	if req.DueAt.Set {
+		opts = append(opts, service.WithDueAt(req.DueAt.Ptr()))
+	}
+	return opts, nil
+}
+
 func (h *TODOHandler) readTODO(w http.ResponseWriter, r *http.Request) {
 	//station2
 	// Context から OS 情報を取得
@@ -143,9 +284,10 @@ func (h *TODOHandler) readTODO(w http.ResponseWriter, r *http.Request) {
     log.Printf("Accessed from OS: %s", osName)
 	//station2 end
 
-	// クエリパラメータから prev_id と size を取得
+	// クエリパラメータから prev_id と size と status を取得
 	prevIDStr := r.URL.Query().Get("prev_id")
 	sizeStr := r.URL.Query().Get("size")
+	status := r.URL.Query().Get("status")
 
 	var prevID int64
 	var size int64
@@ -155,7 +297,7 @@ func (h *TODOHandler) readTODO(w http.ResponseWriter, r *http.Request) {
 	if prevIDStr != "" {
 		prevID, err = strconv.ParseInt(prevIDStr, 10, 64)
 		if err != nil || prevID < 0 {
-			http.Error(w, "Bad Request: prev_id must be a non-negative integer", http.StatusBadRequest)
+			writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "prev_id", Message: "must be a non-negative integer"})
 			return
 		}
 	}
@@ -166,16 +308,140 @@ func (h *TODOHandler) readTODO(w http.ResponseWriter, r *http.Request) {
 	} else {
 		size, err = strconv.ParseInt(sizeStr, 10, 64)
 		if err != nil || size <= 0 {
-			http.Error(w, "Bad Request: size must be a positive integer", http.StatusBadRequest)
+			writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "size", Message: "must be a positive integer"})
+			return
+		}
+	}
+
+	// status を検証（省略時はすべて）
+	switch status {
+	case "", model.TODOStatusAll, model.TODOStatusDone, model.TODOStatusUndone:
+	default:
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "status", Message: "must be one of all, done, undone"})
+		return
+	}
+	opts := []service.ReadOption{service.WithStatus(status)}
+
+	// overdue を bool に変換（省略可能）
+	if overdueStr := r.URL.Query().Get("overdue"); overdueStr != "" {
+		overdue, err := strconv.ParseBool(overdueStr)
+		if err != nil {
+			writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "overdue", Message: "must be a boolean"})
+			return
+		}
+		if overdue {
+			opts = append(opts, service.WithOverdue())
+		}
+	}
+
+	// due_before, due_after を RFC 3339 の時刻に変換（省略可能）
+	if dueBeforeStr := r.URL.Query().Get("due_before"); dueBeforeStr != "" {
+		dueBefore, err := time.Parse(time.RFC3339, dueBeforeStr)
+		if err != nil {
+			writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "due_before", Message: "must be an RFC 3339 date-time"})
+			return
+		}
+		opts = append(opts, service.WithDueBefore(dueBefore))
+	}
+	if dueAfterStr := r.URL.Query().Get("due_after"); dueAfterStr != "" {
+		dueAfter, err := time.Parse(time.RFC3339, dueAfterStr)
+		if err != nil {
+			writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "due_after", Message: "must be an RFC 3339 date-time"})
+			return
+		}
+		opts = append(opts, service.WithDueAfter(dueAfter))
+	}
+
+	// tags をカンマ区切りで取得し、tag_mode (and, or) で結合方法を決める（省略時は and）
+	if tagsStr := r.URL.Query().Get("tags"); tagsStr != "" {
+		tags, ok := normalizeTags(strings.Split(tagsStr, ","))
+		if !ok {
+			writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "tags", Message: "must not contain an empty name"})
+			return
+		}
+		tagMode := r.URL.Query().Get("tag_mode")
+		switch tagMode {
+		case "", model.TagModeAnd, model.TagModeOr:
+		default:
+			writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "tag_mode", Message: "must be and or or"})
+			return
+		}
+		opts = append(opts, service.WithTagFilter(tags, tagMode))
+	}
+
+	// list_id でリストに絞り込む（none の場合はどのリストにも属さない TODO）
+	// 省略時は include_archived が true でない限り、アーカイブされたリストの TODO を含めない
+	if listIDStr := r.URL.Query().Get("list_id"); listIDStr != "" {
+		var listID *int64
+		if listIDStr != "none" {
+			id, err := strconv.ParseInt(listIDStr, 10, 64)
+			if err != nil || id <= 0 {
+				writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "list_id", Message: "must be a positive integer or none"})
+				return
+			}
+			listID = &id
+		}
+		opts = append(opts, service.WithListFilter(listID))
+	}
+	if includeArchivedStr := r.URL.Query().Get("include_archived"); includeArchivedStr != "" {
+		includeArchived, err := strconv.ParseBool(includeArchivedStr)
+		if err != nil {
+			writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "include_archived", Message: "must be a boolean"})
 			return
 		}
+		if includeArchived {
+			opts = append(opts, service.WithArchived())
+		}
 	}
 
+	// sort, order, cursor を取得（省略時は id の降順）
+	// cursor のみ指定された場合は、cursor が発行されたときのソート順を引き継ぐ
+	sortKey := r.URL.Query().Get("sort")
+	order := r.URL.Query().Get("order")
+	var cursor *service.Cursor
+	if cursorStr := r.URL.Query().Get("cursor"); cursorStr != "" {
+		cursor, err = service.ParseCursor(cursorStr)
+		if err != nil {
+			writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "cursor", Message: "is invalid"})
+			return
+		}
+		if sortKey == "" {
+			sortKey = cursor.Sort
+		}
+		if order == "" {
+			order = cursor.Order
+		}
+	}
+	if sortKey == "" {
+		sortKey = model.TODOSortID
+	}
+	if order == "" {
+		order = model.SortOrderDesc
+	}
+	if !service.IsValidSort(sortKey) {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "sort", Message: "must be one of id, priority, due_at, created_at, updated_at, subject"})
+		return
+	}
+	if order != model.SortOrderAsc && order != model.SortOrderDesc {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "order", Message: "must be asc or desc"})
+		return
+	}
+	if cursor != nil && (cursor.Sort != sortKey || cursor.Order != order) {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "cursor", Message: "was issued for a different sort"})
+		return
+	}
+	// prev_id は id の降順でのみ意味を持つ
+	if prevID > 0 && (sortKey != model.TODOSortID || order != model.SortOrderDesc) {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "prev_id", Message: "can only be used with the default sort, use cursor instead"})
+		return
+	}
+	opts = append(opts, service.WithSort(sortKey, order), service.WithCursor(cursor))
+
 	// サービス層の ReadTODO メソッドを呼び出し
-	todos, err := h.service.ReadTODO(r.Context(), prevID, size)
+	todos, err := h.service.ReadTODO(r.Context(), prevID, size, opts...)
 	if err != nil {
 		log.Println("Error reading TODOs:", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, "")
 		return
 	}
 
@@ -183,6 +449,10 @@ func (h *TODOHandler) readTODO(w http.ResponseWriter, r *http.Request) {
 	resp := model.ReadTODOResponse{
 		TODOs: todos, // []*model.TODO 型
 	}
+	// ページが埋まっている場合は、次のページを読むためのカーソルを返す
+	if int64(len(todos)) == size {
+		resp.NextCursor = service.NewCursor(sortKey, order, todos[len(todos)-1]).String()
+	}
 
 	// レスポンスヘッダーを設定
 	w.Header().Set("Content-Type", "application/json")
@@ -191,7 +461,7 @@ func (h *TODOHandler) readTODO(w http.ResponseWriter, r *http.Request) {
 	// JSON エンコードしてレスポンスを送信
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		log.Println("Error encoding JSON response:", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeProblem(w, http.StatusInternalServerError, "")
 		return
 	}
 }
@@ -208,28 +478,57 @@ func (h *TODOHandler) handleDelete(w http.ResponseWriter, r *http.Request) {
     var req model.DeleteTODORequest
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
         // デコードに失敗した場合は 400 Bad Request を返す
-        http.Error(w, "Bad Request", http.StatusBadRequest)
+        writeProblem(w, http.StatusBadRequest, "")
         return
     }
 
     // ids が空かどうかをチェック
     if len(req.IDs) == 0 {
         // ids が空の場合は 400 Bad Request を返す
-        http.Error(w, "IDs are required", http.StatusBadRequest)
+        writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "ids", Message: "are required"})
+        return
+    }
+
+    // children で子の扱いを指定する（省略時は親に付け替える）
+    var opts []service.DeleteOption
+    switch req.Children {
+    case "", model.DeleteChildrenReparent:
+    case model.DeleteChildrenCascade:
+        opts = append(opts, service.WithCascade())
+    default:
+        writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "children", Message: "must be reparent or cascade"})
+        return
+    }
+
+    // If-Match は 1 件の TODO を削除する場合のみ指定できる
+    if r.Header.Get("If-Match") != "" && len(req.IDs) > 1 {
+        writeProblem(w, http.StatusBadRequest, "If-Match can only be used when deleting a single TODO")
         return
     }
+    if version, ok := parseIfMatch(r, req.IDs[0]); ok {
+        opts = append(opts, service.WithDeleteExpectedVersion(version))
+    }
 
     // TODO を削除
-    err := h.service.DeleteTODO(r.Context(), req.IDs)
+    err := h.service.DeleteTODO(r.Context(), req.IDs, opts...)
     if err != nil {
         // ErrNotFound の場合は 404 Not Found を返す
         if _, ok := err.(*model.ErrNotFound); ok {
-            http.Error(w, "Not Found", http.StatusNotFound)
+            writeErrorProblem(w, http.StatusNotFound, err)
+            return
+        }
+        // 閲覧者として共有されたリストの TODO が含まれる場合は 403 Forbidden を返す
+        if model.IsErrForbidden(err) {
+            writeErrorProblem(w, http.StatusForbidden, err)
+            return
+        }
+        // 他の人が先に更新していた場合は、現在の TODO を返す
+        if writePreconditionFailed(w, err) {
             return
         }
         // その他のエラーは 500 Internal Server Error を返す
         log.Printf("DeleteTODO failed: %v", err)
-        http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+        writeProblem(w, http.StatusInternalServerError, "")
         return
     }
 
@@ -0,0 +1,449 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/jsonpatch"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// PATCH で受け付けるパッチの形式です。
+const (
+	contentTypeMergePatch = "application/merge-patch+json"
+	contentTypeJSONPatch  = "application/json-patch+json"
+)
+
+// maxPatchBytes は、PATCH で受け付けるパッチの最大のバイト数です。
+const maxPatchBytes = 1 << 20
+
+// patchableFields は PATCH で変更できる TODO のフィールドです。その他のフィールドは読み取り専用です。
+var patchableFields = map[string]bool{
+	"subject":     true,
+	"description": true,
+	"done":        true,
+	"due_at":      true,
+	"priority":    true,
+	"tags":        true,
+	"parent_id":   true,
+	"recurrence":  true,
+	"list_id":     true,
+}
+
+// TODOItemStore is the set of TODO operations TODOItemHandler uses. *service.TODOService implements it.
+type TODOItemStore interface {
+	TODOUpdater
+	ReadTODOByID(ctx context.Context, id int64) (*model.TODO, error)
+	DeleteTODO(ctx context.Context, ids []int64, opts ...service.DeleteOption) error
+}
+
+// TODOItemHandler handles HTTP requests for a single TODO at /todos/{id}.
+type TODOItemHandler struct {
+	service TODOItemStore
+}
+
+// NewTODOItemHandler creates a new TODOItemHandler with the provided TODOItemStore.
+func NewTODOItemHandler(svc TODOItemStore) *TODOItemHandler {
+	return &TODOItemHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for TODOItemHandler.
+func (h *TODOItemHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// /todos/{id} の id は正の整数のみ。それ以外のパスは存在しない
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/todos/"), 10, 64)
+	if err != nil || id <= 0 {
+		writeProblem(w, http.StatusNotFound, "")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.readTODO(w, r, id)
+	case http.MethodPut:
+		h.updateTODO(w, r, id)
+	case http.MethodPatch:
+		h.patchTODO(w, r, id)
+	case http.MethodDelete:
+		h.deleteTODO(w, r, id)
+	default:
+		w.Header().Set("Allow", "GET, PUT, PATCH, DELETE")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+	}
+}
+
+// todoPath は id の TODO の URL のパスを返します。
+func todoPath(id int64) string {
+	return "/todos/" + strconv.FormatInt(id, 10)
+}
+
+// readTODO handles GET requests to read the TODO.
+func (h *TODOItemHandler) readTODO(w http.ResponseWriter, r *http.Request, id int64) {
+	todo, err := h.service.ReadTODOByID(r.Context(), id)
+	if err != nil {
+		if model.IsErrNotFound(err) {
+			writeErrorProblem(w, http.StatusNotFound, err)
+			return
+		}
+		log.Println("Error reading TODO:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	setETag(w, todo)
+	writeJSON(w, model.ReadTODOByIDResponse{TODO: todo})
+}
+
+// updateTODO handles PUT requests to replace the TODO.
+// リクエストボディは PUT /todos と同じです。id は省略でき、指定する場合はパスの id と一致する必要があります。
+func (h *TODOItemHandler) updateTODO(w http.ResponseWriter, r *http.Request, id int64) {
+	var req model.UpdateTODORequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.ID != 0 && req.ID != id {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "id", Message: "does not match the path"})
+		return
+	}
+	req.ID = id
+
+	serveUpdateTODO(w, r, h.service, &req)
+}
+
+// deleteTODO handles DELETE requests to move the TODO to the trash.
+// 子の扱いは children クエリパラメータ（reparent または cascade）で指定します。
+func (h *TODOItemHandler) deleteTODO(w http.ResponseWriter, r *http.Request, id int64) {
+	var opts []service.DeleteOption
+	switch r.URL.Query().Get("children") {
+	case "", model.DeleteChildrenReparent:
+	case model.DeleteChildrenCascade:
+		opts = append(opts, service.WithCascade())
+	default:
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "children", Message: "must be reparent or cascade"})
+		return
+	}
+	// If-Match が指定された場合は、その version から変更されていない場合のみ削除する
+	if version, ok := parseIfMatch(r, id); ok {
+		opts = append(opts, service.WithDeleteExpectedVersion(version))
+	}
+
+	if err := h.service.DeleteTODO(r.Context(), []int64{id}, opts...); err != nil {
+		if model.IsErrNotFound(err) {
+			writeErrorProblem(w, http.StatusNotFound, err)
+			return
+		}
+		if model.IsErrForbidden(err) {
+			writeErrorProblem(w, http.StatusForbidden, err)
+			return
+		}
+		// 他の人が先に更新していた場合は、現在の TODO を返す
+		if writePreconditionFailed(w, err) {
+			return
+		}
+		log.Println("Error deleting TODO:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.DeleteTODOResponse{})
+}
+
+// patchTODO handles PATCH requests to update a part of the TODO.
+//
+// JSON Merge Patch (application/merge-patch+json) と JSON Patch (application/json-patch+json) を、
+// GET /todos が返す TODO の JSON に適用します。変更できるのは patchableFields のフィールドのみです。
+// パッチの文書が不正な場合は 400、適用できない場合は 409、適用した結果の TODO が不正な場合は 422 を返します。
+func (h *TODOItemHandler) patchTODO(w http.ResponseWriter, r *http.Request, id int64) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != contentTypeMergePatch && mediaType != contentTypeJSONPatch) {
+		w.Header().Set("Accept-Patch", contentTypeMergePatch+", "+contentTypeJSONPatch)
+		writeProblem(w, http.StatusUnsupportedMediaType, "Content-Type must be "+contentTypeMergePatch+" or "+contentTypeJSONPatch)
+		return
+	}
+
+	// MaxBytesReader は上限まで読んだ後にエラーを返すため、上限まで読めていれば大きすぎるパッチとする
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchBytes))
+	if err != nil {
+		if len(body) >= maxPatchBytes {
+			writeProblem(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("the patch must not be larger than %d bytes", maxPatchBytes))
+			return
+		}
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// パッチの文書を先に検証し、TODO を読む前に不正なリクエストを返す
+	var (
+		mergePatch interface{}
+		operations []*jsonpatch.Operation
+	)
+	if mediaType == contentTypeMergePatch {
+		mergePatch, err = jsonpatch.Decode(body)
+	} else {
+		operations, err = jsonpatch.ParsePatch(body)
+	}
+	if err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	current, err := h.service.ReadTODOByID(r.Context(), id)
+	if err != nil {
+		if model.IsErrNotFound(err) {
+			writeErrorProblem(w, http.StatusNotFound, err)
+			return
+		}
+		log.Println("Error reading TODO:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	// If-Match が指定された場合は、その version から変更されていない場合のみ更新する
+	ifMatch, hasIfMatch := parseIfMatch(r, id)
+	if hasIfMatch && ifMatch != current.Version {
+		writePreconditionFailed(w, &model.ErrPreconditionFailed{Current: current})
+		return
+	}
+
+	original, err := todoDocument(current)
+	if err != nil {
+		log.Println("Error encoding TODO:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+	var patched interface{}
+	if mediaType == contentTypeMergePatch {
+		patched = jsonpatch.MergePatch(original, mergePatch)
+	} else {
+		patched, err = jsonpatch.Apply(original, operations)
+		if err != nil {
+			writeErrorProblem(w, http.StatusConflict, err)
+			return
+		}
+	}
+
+	subject, description, opts, err := patchOptions(current, original, patched)
+	if err != nil {
+		writeErrorProblem(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	// パッチは読み込んだ TODO に対して適用したため、その間に他の人が更新していれば失敗させる
+	opts = append(opts, service.WithExpectedVersion(current.Version))
+
+	todo, err := h.service.UpdateTODO(r.Context(), id, subject, description, opts...)
+	if err != nil {
+		if model.IsErrNotFound(err) {
+			writeErrorProblem(w, http.StatusNotFound, err)
+			return
+		}
+		if model.IsErrForbidden(err) {
+			writeErrorProblem(w, http.StatusForbidden, err)
+			return
+		}
+		if model.IsErrPreconditionFailed(err) {
+			// If-Match を指定した場合は 412、指定しなかった場合は同時に更新されたことを 409 で返す
+			if hasIfMatch {
+				writePreconditionFailed(w, err)
+				return
+			}
+			writeErrorProblem(w, http.StatusConflict, &model.ErrConflict{Message: "the TODO was modified while applying the patch, retry the request"})
+			return
+		}
+		// 親やリストが存在しない場合や、繰り返しの規則が不正な場合
+		if model.IsErrValidation(err) {
+			writeErrorProblem(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		// 親子関係が循環する場合や、アーカイブされたリストに移動する場合
+		if model.IsErrConflict(err) {
+			writeErrorProblem(w, http.StatusConflict, err)
+			return
+		}
+		log.Println("Error patching TODO:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	setETag(w, todo)
+	writeJSON(w, model.UpdateTODOResponse{TODO: todo})
+}
+
+// todoDocument は、パッチを適用する対象として TODO を JSON の値に変換します。
+func todoDocument(todo *model.TODO) (interface{}, error) {
+	b, err := json.Marshal(todo)
+	if err != nil {
+		return nil, err
+	}
+	return jsonpatch.Decode(b)
+}
+
+// todoPatchDocument は、パッチを適用した文書のうち変更できるフィールドです。
+type todoPatchDocument struct {
+	Subject     *string    `json:"subject"`
+	Description *string    `json:"description"`
+	Done        *bool      `json:"done"`
+	DueAt       *time.Time `json:"due_at"`
+	Priority    *int       `json:"priority"`
+	Tags        []string   `json:"tags"`
+	ParentID    *int64     `json:"parent_id"`
+	Recurrence  *string    `json:"recurrence"`
+	ListID      *int64     `json:"list_id"`
+}
+
+// patchOptions は、current にパッチを適用した文書 patched を検証し、UpdateTODO に渡す値を返します。
+// original は current を JSON の値に変換したものです。変更されたフィールドのみオプションにします。
+//
+// 削除されたフィールドや null は、due_at, parent_id, list_id では解除、description, recurrence, tags では空を表します。
+// subject, done, priority は削除できません。
+func patchOptions(current *model.TODO, original, patched interface{}) (string, string, []service.TODOOption, error) {
+	fields, ok := patched.(map[string]interface{})
+	if !ok {
+		return "", "", nil, &model.ErrValidation{Message: "the patched TODO must be an object"}
+	}
+
+	// 読み取り専用のフィールドは変更も削除もできない
+	before := original.(map[string]interface{})
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if patchableFields[name] {
+			continue
+		}
+		value, ok := before[name]
+		if !ok {
+			return "", "", nil, &model.ErrValidation{Field: name, Message: "is not a field of TODO"}
+		}
+		if !jsonpatch.Equal(value, fields[name]) {
+			return "", "", nil, &model.ErrValidation{Field: name, Message: "is read-only"}
+		}
+	}
+	for name := range before {
+		if _, ok := fields[name]; !ok && !patchableFields[name] {
+			return "", "", nil, &model.ErrValidation{Field: name, Message: "is read-only"}
+		}
+	}
+
+	// 変更できるフィールドを型に合わせて読み込む
+	writable := map[string]interface{}{}
+	for name := range patchableFields {
+		if v, ok := fields[name]; ok {
+			writable[name] = v
+		}
+	}
+	b, err := json.Marshal(writable)
+	if err != nil {
+		return "", "", nil, err
+	}
+	var doc todoPatchDocument
+	if err := json.Unmarshal(b, &doc); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return "", "", nil, &model.ErrValidation{Field: typeErr.Field, Message: "must be " + typeErr.Type.String()}
+		}
+		return "", "", nil, err
+	}
+
+	if doc.Subject == nil || *doc.Subject == "" {
+		return "", "", nil, &model.ErrValidation{Field: "subject", Message: "is required"}
+	}
+	if doc.Done == nil {
+		return "", "", nil, &model.ErrValidation{Field: "done", Message: "must not be null"}
+	}
+	if doc.Priority == nil {
+		return "", "", nil, &model.ErrValidation{Field: "priority", Message: "must not be null"}
+	}
+	if *doc.Priority < model.PriorityNone || *doc.Priority > model.PriorityHigh {
+		return "", "", nil, &model.ErrValidation{Field: "priority", Message: "must be between 0 and 3"}
+	}
+	description := ""
+	if doc.Description != nil {
+		description = *doc.Description
+	}
+	tags, ok := normalizeTags(doc.Tags)
+	if !ok {
+		return "", "", nil, &model.ErrValidation{Field: "tags", Message: "must not contain an empty name"}
+	}
+
+	var opts []service.TODOOption
+	if *doc.Done != current.Done {
+		opts = append(opts, service.WithDone(*doc.Done))
+	}
+	if !equalTimes(doc.DueAt, current.DueAt) {
+		opts = append(opts, service.WithDueAt(doc.DueAt))
+	}
+	if *doc.Priority != current.Priority {
+		opts = append(opts, service.WithPriority(*doc.Priority))
+	}
+	if !sameTags(tags, current.Tags) {
+		opts = append(opts, service.WithTags(tags))
+	}
+	if !equalInt64s(doc.ParentID, current.ParentID) {
+		opts = append(opts, service.WithParentID(doc.ParentID))
+	}
+	recurrence := ""
+	if doc.Recurrence != nil {
+		recurrence = *doc.Recurrence
+	}
+	if recurrence != current.Recurrence {
+		opts = append(opts, service.WithRecurrence(recurrence))
+	}
+	if !equalInt64s(doc.ListID, current.ListID) {
+		opts = append(opts, service.WithListID(doc.ListID))
+	}
+	return *doc.Subject, description, opts, nil
+}
+
+// equalTimes は a と b が同じ時刻か、どちらも nil かを返します。
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// equalInt64s は a と b が同じ値か、どちらも nil かを返します。
+func equalInt64s(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// sameTags は、重複を除いた a と b が同じタグの集合かを返します。
+func sameTags(a, b []string) bool {
+	set := func(tags []string) map[string]bool {
+		m := make(map[string]bool, len(tags))
+		for _, tag := range tags {
+			m[tag] = true
+		}
+		return m
+	}
+	x, y := set(a), set(b)
+	if len(x) != len(y) {
+		return false
+	}
+	for tag := range x {
+		if !y[tag] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,221 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestTODOItemHandler_Patch(t *testing.T) {
+	t.Parallel()
+
+	svc := service.NewTODOServiceWithRepository(service.NewMemoryStore())
+	h := handler.NewTODOItemHandler(svc)
+	ctx := context.Background()
+	if _, err := svc.CreateTODO(ctx, "subject", "keep", service.WithTags([]string{"a"}), service.WithPriority(model.PriorityLow)); err != nil {
+		t.Fatal("failed to create, err =", err)
+	}
+
+	serve := func(path, contentType, body, ifMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	const (
+		merge = "application/merge-patch+json"
+		patch = "application/json-patch+json"
+	)
+	// ケースは前のケースで更新した TODO を使うため、順に実行する
+	cases := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		ifMatch     string
+		code        int
+		check       func(todo *model.TODO) bool
+	}{
+		{
+			name: "MergePatch", path: "/todos/1", contentType: merge,
+			body: `{"subject":"merged","due_at":"2030-01-02T03:04:05Z","tags":["a","b"]}`,
+			code: http.StatusOK,
+			check: func(todo *model.TODO) bool {
+				return todo.Subject == "merged" && todo.Description == "keep" && todo.DueAt != nil && len(todo.Tags) == 2 && todo.Priority == model.PriorityLow && todo.Version == 2
+			},
+		},
+		{
+			name: "MergePatchNull", path: "/todos/1", contentType: merge + "; charset=utf-8",
+			body: `{"due_at":null,"tags":null}`, ifMatch: `"1-2"`,
+			code: http.StatusOK,
+			check: func(todo *model.TODO) bool {
+				return todo.DueAt == nil && len(todo.Tags) == 0 && todo.Version == 3
+			},
+		},
+		{
+			name: "JSONPatch", path: "/todos/1", contentType: patch,
+			body: `[{"op":"test","path":"/version","value":3},{"op":"replace","path":"/done","value":true},{"op":"add","path":"/tags/-","value":"c"}]`,
+			code: http.StatusOK,
+			check: func(todo *model.TODO) bool {
+				return todo.Done && len(todo.Tags) == 1 && todo.Tags[0] == "c"
+			},
+		},
+		{name: "StaleIfMatch", path: "/todos/1", contentType: merge, body: `{"subject":"stale"}`, ifMatch: `"1-1"`, code: http.StatusPreconditionFailed},
+		{name: "TestFailed", path: "/todos/1", contentType: patch, body: `[{"op":"test","path":"/subject","value":"other"}]`, code: http.StatusConflict},
+		{name: "MissingPath", path: "/todos/1", contentType: patch, body: `[{"op":"remove","path":"/nothing"}]`, code: http.StatusConflict},
+		{name: "InvalidPatch", path: "/todos/1", contentType: patch, body: `[{"op":"merge","path":"/subject"}]`, code: http.StatusBadRequest},
+		{name: "InvalidJSON", path: "/todos/1", contentType: merge, body: `{"subject":`, code: http.StatusBadRequest},
+		{name: "TooLarge", path: "/todos/1", contentType: merge, body: `{"description":"` + strings.Repeat("a", 1<<20) + `"}`, code: http.StatusRequestEntityTooLarge},
+		{name: "ReadOnly", path: "/todos/1", contentType: merge, body: `{"version":10}`, code: http.StatusUnprocessableEntity},
+		{name: "UnknownField", path: "/todos/1", contentType: merge, body: `{"owner":"me"}`, code: http.StatusUnprocessableEntity},
+		{name: "EmptySubject", path: "/todos/1", contentType: patch, body: `[{"op":"remove","path":"/subject"}]`, code: http.StatusUnprocessableEntity},
+		{name: "WrongType", path: "/todos/1", contentType: merge, body: `{"priority":"high"}`, code: http.StatusUnprocessableEntity},
+		{name: "OutOfRange", path: "/todos/1", contentType: merge, body: `{"priority":4}`, code: http.StatusUnprocessableEntity},
+		{name: "MissingParent", path: "/todos/1", contentType: merge, body: `{"parent_id":1000}`, code: http.StatusUnprocessableEntity},
+		{name: "CyclicParent", path: "/todos/1", contentType: merge, body: `{"parent_id":1}`, code: http.StatusConflict},
+		{name: "NotFound", path: "/todos/1000", contentType: merge, body: `{"subject":"x"}`, code: http.StatusNotFound},
+		{name: "InvalidID", path: "/todos/abc", contentType: merge, body: `{"subject":"x"}`, code: http.StatusNotFound},
+		{name: "UnsupportedMediaType", path: "/todos/1", contentType: "application/json", body: `{"subject":"x"}`, code: http.StatusUnsupportedMediaType},
+	}
+	for _, c := range cases {
+		w := serve(c.path, c.contentType, c.body, c.ifMatch)
+		if w.Code != c.code {
+			t.Errorf("%s: unexpected status, given = %d, expected = %d, body = %s", c.name, w.Code, c.code, w.Body)
+			continue
+		}
+		if c.check == nil {
+			continue
+		}
+		var resp model.UpdateTODOResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: failed to decode response, err = %v", c.name, err)
+		}
+		if !c.check(resp.TODO) {
+			t.Errorf("%s: unexpected TODO, given = %+v", c.name, resp.TODO)
+		}
+	}
+}
+
+func TestTODOItemHandler(t *testing.T) {
+	t.Parallel()
+
+	svc := service.NewTODOServiceWithRepository(service.NewMemoryStore())
+	h := handler.NewTODOItemHandler(svc)
+	ctx := context.Background()
+	for _, subject := range []string{"parent", "child"} {
+		if _, err := svc.CreateTODO(ctx, subject, ""); err != nil {
+			t.Fatal("failed to create, err =", err)
+		}
+	}
+	parentID := int64(1)
+	if _, err := svc.UpdateTODO(ctx, 2, "child", "", service.WithParentID(&parentID)); err != nil {
+		t.Fatal("failed to update, err =", err)
+	}
+
+	serve := func(method, path, body, ifMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// ケースは前のケースで更新した TODO を使うため、順に実行する
+	cases := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		ifMatch string
+		code    int
+		etag    string
+	}{
+		{name: "Read", method: http.MethodGet, path: "/todos/1", code: http.StatusOK, etag: `"1-1"`},
+		{name: "ReadNotFound", method: http.MethodGet, path: "/todos/1000", code: http.StatusNotFound},
+		{name: "ReadInvalidID", method: http.MethodGet, path: "/todos/0", code: http.StatusNotFound},
+		{name: "Update", method: http.MethodPut, path: "/todos/1", body: `{"subject":"updated","priority":2}`, ifMatch: `"1-1"`, code: http.StatusOK, etag: `"1-2"`},
+		{name: "UpdateSameID", method: http.MethodPut, path: "/todos/1", body: `{"id":1,"subject":"updated again"}`, code: http.StatusOK, etag: `"1-3"`},
+		{name: "UpdateOtherID", method: http.MethodPut, path: "/todos/1", body: `{"id":2,"subject":"other"}`, code: http.StatusBadRequest},
+		{name: "UpdateStale", method: http.MethodPut, path: "/todos/1", body: `{"subject":"stale"}`, ifMatch: `"1-1"`, code: http.StatusPreconditionFailed, etag: `"1-3"`},
+		{name: "UpdateInvalid", method: http.MethodPut, path: "/todos/1", body: `{"subject":""}`, code: http.StatusBadRequest},
+		{name: "UpdateNotFound", method: http.MethodPut, path: "/todos/1000", body: `{"subject":"x"}`, code: http.StatusNotFound},
+		{name: "DeleteInvalidChildren", method: http.MethodDelete, path: "/todos/1?children=orphan", code: http.StatusBadRequest},
+		{name: "DeleteStale", method: http.MethodDelete, path: "/todos/1", ifMatch: `"1-1"`, code: http.StatusPreconditionFailed},
+		{name: "Delete", method: http.MethodDelete, path: "/todos/1?children=cascade", ifMatch: `"1-3"`, code: http.StatusOK},
+		{name: "ReadDeleted", method: http.MethodGet, path: "/todos/1", code: http.StatusNotFound},
+		{name: "ReadDeletedChild", method: http.MethodGet, path: "/todos/2", code: http.StatusNotFound},
+		{name: "DeleteNotFound", method: http.MethodDelete, path: "/todos/1", code: http.StatusNotFound},
+		{name: "MethodNotAllowed", method: http.MethodPost, path: "/todos/1", code: http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		w := serve(c.method, c.path, c.body, c.ifMatch)
+		if w.Code != c.code {
+			t.Errorf("%s: unexpected status, given = %d, expected = %d, body = %s", c.name, w.Code, c.code, w.Body)
+			continue
+		}
+		if etag := w.Header().Get("ETag"); c.etag != "" && etag != c.etag {
+			t.Errorf("%s: unexpected ETag, given = %s, expected = %s", c.name, etag, c.etag)
+		}
+	}
+
+	if allow := serve(http.MethodPost, "/todos/2", "", "").Header().Get("Allow"); allow != "GET, PUT, PATCH, DELETE" {
+		t.Errorf("unexpected Allow, given = %s", allow)
+	}
+}
+
+// タグのない TODO の tags は、null ではなく [] として返す
+func TestTODOItemHandler_EmptyTags(t *testing.T) {
+	t.Parallel()
+
+	svc := service.NewTODOServiceWithRepository(service.NewMemoryStore())
+	parent, err := svc.CreateTODO(context.Background(), "parent", "")
+	if err != nil {
+		t.Fatal("failed to create, err =", err)
+	}
+	if _, err := svc.CreateTODO(context.Background(), "child", "", service.WithParentID(&parent.ID)); err != nil {
+		t.Fatal("failed to create, err =", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/todos/tree", handler.NewTODOTreeHandler(svc))
+	mux.Handle("/todos/", handler.NewTODOItemHandler(svc))
+
+	cases := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		expected    string
+	}{
+		{name: "Read", method: http.MethodGet, path: "/todos/1", expected: `"tags":[]`},
+		{name: "Tree", method: http.MethodGet, path: "/todos/tree?id=1", expected: `"subject":"child","description":"","done":false,"completed_at":null,"due_at":null,"priority":0,"tags":[]`},
+		{name: "TreeChildren", method: http.MethodGet, path: "/todos/tree?id=1", expected: `"children":[]`},
+		// JSON Patch は [] に追加できる
+		{name: "PatchAdd", method: http.MethodPatch, path: "/todos/1", contentType: "application/json-patch+json", body: `[{"op":"add","path":"/tags/-","value":"a"}]`, expected: `"tags":["a"]`},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		if c.contentType != "" {
+			r.Header.Set("Content-Type", c.contentType)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), c.expected) {
+			t.Errorf("%s: unexpected response, status = %d, body = %s, expected to contain %s", c.name, w.Code, w.Body, c.expected)
+		}
+	}
+}
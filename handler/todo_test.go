@@ -0,0 +1,134 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// データベースを使わず、MemoryStore に対して TODOHandler を動かす
+func TestTODOHandler_MemoryStore(t *testing.T) {
+	t.Parallel()
+
+	h := handler.NewTODOHandler(service.NewTODOServiceWithRepository(service.NewMemoryStore()))
+	serve := func(method, body, ifMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/todos", strings.NewReader(body))
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(http.MethodPost, `{"subject":"subject","tags":["b","a"]}`, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status of create, given = %d, body = %s", w.Code, w.Body)
+	}
+	if location := w.Header().Get("Location"); location != "/todos/1" {
+		t.Errorf("unexpected Location, given = %s, expected = %s", location, "/todos/1")
+	}
+	var created model.CreateTODOResponse
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal("failed to decode response, err =", err)
+	}
+	if created.TODO.ID != 1 || len(created.TODO.Tags) != 2 || created.TODO.Tags[0] != "a" {
+		t.Errorf("unexpected created TODO, given = %+v", created.TODO)
+	}
+	if etag := w.Header().Get("ETag"); etag != `"1-1"` {
+		t.Errorf("unexpected ETag, given = %s, expected = %s", etag, `"1-1"`)
+	}
+
+	if w := serve(http.MethodPut, `{"id":1,"subject":"updated"}`, `"1-1"`); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status of update, given = %d, body = %s", w.Code, w.Body)
+	}
+	w = serve(http.MethodPut, `{"id":1,"subject":"stale"}`, `"1-1"`)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("unexpected status of stale update, given = %d, expected = %d", w.Code, http.StatusPreconditionFailed)
+	}
+	var stale model.Problem
+	if err := json.NewDecoder(w.Body).Decode(&stale); err != nil {
+		t.Fatal("failed to decode response, err =", err)
+	}
+	if stale.Type != model.ProblemTypePreconditionFailed || stale.TODO == nil || stale.TODO.Version != 2 {
+		t.Errorf("unexpected problem of stale update, given = %+v", stale)
+	}
+
+	// 不正な値はフィールドの詳細を持つ problem として返す
+	w = serve(http.MethodPost, `{"subject":"","priority":1}`, "")
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("unexpected response of invalid create, given = %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	var invalid model.Problem
+	if err := json.NewDecoder(w.Body).Decode(&invalid); err != nil {
+		t.Fatal("failed to decode response, err =", err)
+	}
+	if invalid.Type != model.ProblemTypeValidation || len(invalid.Errors) != 1 || invalid.Errors[0].Field != "subject" {
+		t.Errorf("unexpected problem of invalid create, given = %+v", invalid)
+	}
+
+	w = serve(http.MethodGet, "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status of read, given = %d, body = %s", w.Code, w.Body)
+	}
+	var read model.ReadTODOResponse
+	if err := json.NewDecoder(w.Body).Decode(&read); err != nil {
+		t.Fatal("failed to decode response, err =", err)
+	}
+	if len(read.TODOs) != 1 || read.TODOs[0].Subject != "updated" || read.TODOs[0].Version != 2 {
+		t.Errorf("unexpected TODOs, given = %+v", read.TODOs)
+	}
+}
+
+// fakeTODOStore は、呼び出された引数を記録し、決まった結果を返す TODOStore です。
+type fakeTODOStore struct {
+	handler.TODOStore
+	subject string
+	deleted []int64
+}
+
+func (f *fakeTODOStore) CreateTODO(ctx context.Context, subject, description string, opts ...service.TODOOption) (*model.TODO, error) {
+	f.subject = subject
+	return &model.TODO{ID: 7, Subject: subject, Version: 1}, nil
+}
+
+func (f *fakeTODOStore) ReadTODO(ctx context.Context, prevID, size int64, opts ...service.ReadOption) ([]*model.TODO, error) {
+	return nil, errors.New("the database is down")
+}
+
+func (f *fakeTODOStore) DeleteTODO(ctx context.Context, ids []int64, opts ...service.DeleteOption) error {
+	f.deleted = ids
+	return &model.ErrNotFound{}
+}
+
+// サービスの代わりに fake を渡し、エラーを HTTP のステータスに変換することを確かめる
+func TestTODOHandler_Fake(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeTODOStore{}
+	h := handler.NewTODOHandler(fake)
+	serve := func(method, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/todos", strings.NewReader(body)))
+		return w
+	}
+
+	w := serve(http.MethodPost, `{"subject":"fake"}`)
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/todos/7" || fake.subject != "fake" {
+		t.Errorf("unexpected response of create, given = %d %s, subject = %q", w.Code, w.Header().Get("Location"), fake.subject)
+	}
+	if w := serve(http.MethodGet, ""); w.Code != http.StatusInternalServerError {
+		t.Errorf("unexpected status of read, given = %d, expected = %d", w.Code, http.StatusInternalServerError)
+	}
+	if w := serve(http.MethodDelete, `{"ids":[3]}`); w.Code != http.StatusNotFound || len(fake.deleted) != 1 || fake.deleted[0] != 3 {
+		t.Errorf("unexpected response of delete, given = %d, deleted = %v", w.Code, fake.deleted)
+	}
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// TokenStore lists and issues API tokens. *service.TokenService implements it.
+type TokenStore interface {
+	ReadTokens(ctx context.Context) ([]*model.APIToken, error)
+	IssueToken(ctx context.Context, name string, scopes []string, expiresAt *time.Time) (*model.APIToken, string, error)
+}
+
+// TokenHandler handles HTTP requests to list and create personal API tokens of the authenticated user.
+type TokenHandler struct {
+	service TokenStore
+}
+
+// NewTokenHandler creates a new TokenHandler with the provided TokenStore.
+func NewTokenHandler(svc TokenStore) *TokenHandler {
+	return &TokenHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for TokenHandler.
+func (h *TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.readTokens(w, r)
+	case http.MethodPost:
+		h.createToken(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+	}
+}
+
+// readTokens handles GET requests to list tokens. 取り消されたトークンや期限切れのトークンも含みます。
+func (h *TokenHandler) readTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.service.ReadTokens(r.Context())
+	if err != nil {
+		log.Println("Error reading tokens:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.ReadTokensResponse{Tokens: tokens})
+}
+
+// createToken handles POST requests to create a new token.
+// トークンのシークレットはこのレスポンスでしか返しません。
+func (h *TokenHandler) createToken(w http.ResponseWriter, r *http.Request) {
+	var req model.CreateTokenRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	token, secret, err := h.service.IssueToken(r.Context(), strings.TrimSpace(req.Name), req.Scopes, req.ExpiresAt)
+	if err != nil {
+		switch {
+		case model.IsErrValidation(err):
+			writeErrorProblem(w, http.StatusBadRequest, err)
+		case model.IsErrUnauthorized(err):
+			writeErrorProblem(w, http.StatusUnauthorized, err)
+		case model.IsErrForbidden(err):
+			writeErrorProblem(w, http.StatusForbidden, err)
+		default:
+			log.Println("Error creating token:", err)
+			writeProblem(w, http.StatusInternalServerError, "")
+		}
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Location", tokenPath(token.ID))
+	writeJSONStatus(w, http.StatusCreated, model.CreateTokenResponse{Token: token, Secret: secret})
+}
+
+// TokenRevoker revokes an API token. *service.TokenService implements it.
+type TokenRevoker interface {
+	RevokeToken(ctx context.Context, id int64) (*model.APIToken, error)
+}
+
+// TokenItemHandler handles HTTP requests for a single token at /tokens/{id}.
+type TokenItemHandler struct {
+	service TokenRevoker
+}
+
+// NewTokenItemHandler creates a new TokenItemHandler with the provided TokenRevoker.
+func NewTokenItemHandler(svc TokenRevoker) *TokenItemHandler {
+	return &TokenItemHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for TokenItemHandler.
+func (h *TokenItemHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// /tokens/{id} の id は正の整数のみ。それ以外のパスは存在しない
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/tokens/"), 10, 64)
+	if err != nil || id <= 0 {
+		writeProblem(w, http.StatusNotFound, "")
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "DELETE")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	// 取り消したトークンは一覧に残り、revoked_at で取り消した日時がわかる
+	token, err := h.service.RevokeToken(r.Context(), id)
+	if err != nil {
+		if model.IsErrNotFound(err) {
+			writeErrorProblem(w, http.StatusNotFound, err)
+			return
+		}
+		log.Println("Error revoking token:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.RevokeTokenResponse{Token: token})
+}
+
+// tokenPath は id のトークンの URL のパスを返します。
+func tokenPath(id int64) string {
+	return "/tokens/" + strconv.FormatInt(id, 10)
+}
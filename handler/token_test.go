@@ -0,0 +1,135 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestTokenHandler(t *testing.T) {
+	t.Parallel()
+
+	store := service.NewMemoryStore()
+	users := service.NewUserServiceWithRepository(store)
+	tokens := service.NewTokenServiceWithRepository(store)
+	alice, err := users.Signup(context.Background(), "alice", "password1")
+	if err != nil {
+		t.Fatal("failed to sign up, err =", err)
+	}
+	// admin スコープのトークンを発行するため、alice を管理者にする
+	if _, err := users.UpdateUserAdmin(context.Background(), alice.ID, true); err != nil {
+		t.Fatal("failed to make alice an administrator, err =", err)
+	}
+
+	// ルーターと同じ順に、Bearer 認証 (Basic 認証) とスコープの確認を適用する
+	auth := middleware.NewBearerAuthMiddleware(tokens, middleware.NewBasicAuthMiddleware(users).Handler)
+	mux := http.NewServeMux()
+	mux.Handle("/tokens", auth.Handler(middleware.RequireTokenScope(model.ScopeAdmin)(handler.NewTokenHandler(tokens))))
+	mux.Handle("/tokens/", auth.Handler(middleware.RequireTokenScope(model.ScopeAdmin)(handler.NewTokenItemHandler(tokens))))
+	mux.Handle("/todos", auth.Handler(middleware.RequireTODOScope(handler.NewTODOHandler(service.NewTODOServiceWithRepository(store)))))
+	mux.Handle("/admin", auth.Handler(middleware.RequireScope(model.ScopeAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))))
+
+	serve := func(method, path, body, bearer string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if bearer != "" {
+			r.Header.Set("Authorization", "Bearer "+bearer)
+		} else {
+			r.SetBasicAuth("alice", "password1")
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	issue := func(scopes string) string {
+		t.Helper()
+		w := serve(http.MethodPost, "/tokens", `{"name":"ci","scopes":[`+scopes+`]}`, "")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("failed to create token, status = %d, body = %s", w.Code, w.Body)
+		}
+		var resp model.CreateTokenResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal("failed to decode response, err =", err)
+		}
+		return resp.Secret
+	}
+	read := issue(`"todos:read"`)
+	write := issue(`"todos:read","todos:write"`)
+	admin := issue(`"admin"`)
+
+	// ケースは前のケースで取り消したトークンを使うため、順に実行する
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		bearer string
+		code   int
+	}{
+		{name: "InvalidScope", method: http.MethodPost, path: "/tokens", body: `{"name":"ci","scopes":["root"]}`, code: http.StatusBadRequest},
+		{name: "ReadWithReadScope", method: http.MethodGet, path: "/todos", bearer: read, code: http.StatusOK},
+		{name: "WriteWithReadScope", method: http.MethodPost, path: "/todos", body: `{"subject":"a"}`, bearer: read, code: http.StatusForbidden},
+		{name: "WriteWithWriteScope", method: http.MethodPost, path: "/todos", body: `{"subject":"a"}`, bearer: write, code: http.StatusCreated},
+		{name: "TokensWithWriteScope", method: http.MethodGet, path: "/tokens", bearer: write, code: http.StatusForbidden},
+		{name: "TokensWithAdminScope", method: http.MethodGet, path: "/tokens", bearer: admin, code: http.StatusOK},
+		{name: "AdminWithWriteScope", method: http.MethodGet, path: "/admin", bearer: write, code: http.StatusForbidden},
+		{name: "AdminWithAdminScope", method: http.MethodGet, path: "/admin", bearer: admin, code: http.StatusOK},
+		{name: "AdminWithPassword", method: http.MethodGet, path: "/admin", code: http.StatusOK},
+		{name: "WriteWithAdminScope", method: http.MethodPost, path: "/todos", body: `{"subject":"b"}`, bearer: admin, code: http.StatusCreated},
+		{name: "UnknownToken", method: http.MethodGet, path: "/todos", bearer: "sta_unknown", code: http.StatusUnauthorized},
+		{name: "Revoke", method: http.MethodDelete, path: "/tokens/1", code: http.StatusOK},
+		{name: "RevokedToken", method: http.MethodGet, path: "/todos", bearer: read, code: http.StatusUnauthorized},
+		{name: "RevokeNotFound", method: http.MethodDelete, path: "/tokens/1000", code: http.StatusNotFound},
+		{name: "MethodNotAllowed", method: http.MethodGet, path: "/tokens/1", code: http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		w := serve(c.method, c.path, c.body, c.bearer)
+		if w.Code != c.code {
+			t.Errorf("%s: unexpected status, given = %d, expected = %d, body = %s", c.name, w.Code, c.code, w.Body)
+		}
+	}
+
+	// 管理者でなくなった利用者は、パスワードでも admin スコープのトークンでも admin を持たないが、
+	// TODO の読み書きと自分のトークンの管理はパスワードでできる
+	if _, err := users.UpdateUserAdmin(context.Background(), alice.ID, false); err != nil {
+		t.Fatal("failed to demote alice, err =", err)
+	}
+	for _, c := range []struct {
+		name   string
+		method string
+		path   string
+		bearer string
+		code   int
+	}{
+		{name: "AdminWithPasswordOfUser", method: http.MethodGet, path: "/admin", code: http.StatusForbidden},
+		{name: "AdminWithAdminScopeOfUser", method: http.MethodGet, path: "/admin", bearer: admin, code: http.StatusForbidden},
+		{name: "TokensWithAdminScopeOfUser", method: http.MethodGet, path: "/tokens", bearer: admin, code: http.StatusForbidden},
+		{name: "ReadWithAdminScopeOfUser", method: http.MethodGet, path: "/todos", bearer: admin, code: http.StatusOK},
+		{name: "TokensWithPasswordOfUser", method: http.MethodGet, path: "/tokens", code: http.StatusOK},
+	} {
+		w := serve(c.method, c.path, "", c.bearer)
+		if w.Code != c.code {
+			t.Errorf("%s: unexpected status, given = %d, expected = %d, body = %s", c.name, w.Code, c.code, w.Body)
+		}
+	}
+
+	// 一覧にはシークレットもハッシュも含めない
+	w := serve(http.MethodGet, "/tokens", "", "")
+	var resp model.ReadTokensResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal("failed to decode response, err =", err)
+	}
+	if len(resp.Tokens) != 3 || resp.Tokens[0].RevokedAt == nil || resp.Tokens[0].LastUsedAt == nil {
+		t.Errorf("unexpected tokens, given = %+v", resp.Tokens)
+	}
+	if strings.Contains(w.Body.String(), "sta_") {
+		t.Errorf("response should not contain secrets, body = %s", w.Body)
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// TrashReader lists TODOs in the trash. *service.TODOService implements it.
+type TrashReader interface {
+	ReadTrash(ctx context.Context, prevID, size int64) ([]*model.TODO, error)
+}
+
+// TrashHandler handles HTTP requests for listing TODOs in the trash.
+type TrashHandler struct {
+	service TrashReader
+}
+
+// NewTrashHandler creates a new TrashHandler with the provided TrashReader.
+func NewTrashHandler(svc TrashReader) *TrashHandler {
+	return &TrashHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for TrashHandler.
+func (h *TrashHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	// prev_id と size は GET /todos と同じ意味を持つ
+	var (
+		prevID int64
+		size   int64 = 10
+		err    error
+	)
+	if s := r.URL.Query().Get("prev_id"); s != "" {
+		prevID, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || prevID < 0 {
+			writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "prev_id", Message: "must be a non-negative integer"})
+			return
+		}
+	}
+	if s := r.URL.Query().Get("size"); s != "" {
+		size, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || size <= 0 {
+			writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "size", Message: "must be a positive integer"})
+			return
+		}
+	}
+
+	todos, err := h.service.ReadTrash(r.Context(), prevID, size)
+	if err != nil {
+		log.Println("Error reading trash:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.ReadTrashResponse{TODOs: todos})
+}
+
+// TODORestorer restores TODOs from the trash. *service.TODOService implements it.
+type TODORestorer interface {
+	RestoreTODOs(ctx context.Context, ids []int64) ([]*model.TODO, error)
+}
+
+// TODORestoreHandler handles HTTP requests for restoring TODOs from the trash.
+type TODORestoreHandler struct {
+	service TODORestorer
+}
+
+// NewTODORestoreHandler creates a new TODORestoreHandler with the provided TODORestorer.
+func NewTODORestoreHandler(svc TODORestorer) *TODORestoreHandler {
+	return &TODORestoreHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for TODORestoreHandler.
+func (h *TODORestoreHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	var req model.RestoreTODORequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		writeErrorProblem(w, http.StatusBadRequest, &model.ErrValidation{Field: "ids", Message: "are required"})
+		return
+	}
+
+	todos, err := h.service.RestoreTODOs(r.Context(), req.IDs)
+	if err != nil {
+		if model.IsErrNotFound(err) {
+			writeErrorProblem(w, http.StatusNotFound, err)
+			return
+		}
+		if model.IsErrForbidden(err) {
+			writeErrorProblem(w, http.StatusForbidden, err)
+			return
+		}
+		log.Println("Error restoring TODOs:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	writeJSON(w, model.RestoreTODOResponse{TODOs: todos})
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// UserRegistrar registers new users. *service.UserService implements it.
+type UserRegistrar interface {
+	Signup(ctx context.Context, name, password string) (*model.User, error)
+}
+
+// SignupHandler handles HTTP requests to create a user account.
+type SignupHandler struct {
+	service UserRegistrar
+}
+
+// NewSignupHandler creates a new SignupHandler with the provided UserRegistrar.
+func NewSignupHandler(svc UserRegistrar) *SignupHandler {
+	return &SignupHandler{
+		service: svc,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for SignupHandler.
+func (h *SignupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	var req model.SignupRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := h.service.Signup(r.Context(), req.Name, req.Password)
+	if err != nil {
+		switch {
+		case model.IsErrValidation(err):
+			writeErrorProblem(w, http.StatusBadRequest, err)
+		case model.IsErrConflict(err):
+			writeErrorProblem(w, http.StatusConflict, err)
+		default:
+			log.Println("Error signing up:", err)
+			writeProblem(w, http.StatusInternalServerError, "")
+		}
+		return
+	}
+
+	writeJSONStatus(w, http.StatusCreated, model.SignupResponse{User: user})
+}
+
+// Authenticator authenticates a user by the name and the password. *service.UserService implements it.
+type Authenticator interface {
+	Authenticate(ctx context.Context, name, password string) (*model.User, error)
+}
+
+// SessionStarter starts a session of an authenticated user. *service.SessionService implements it.
+type SessionStarter interface {
+	Login(ctx context.Context, user *model.User) (*model.SessionTokens, error)
+}
+
+// LoginHandler handles HTTP requests to log in with the name and the password of a user.
+//
+// 認証に成功すると、有効期間の短いアクセストークン (JWT) とリフレッシュトークンを発行します。
+// 以降のリクエストはアクセストークンを Bearer トークンとして送るか、同じ名前とパスワードで Basic 認証を行ってください。
+type LoginHandler struct {
+	service  Authenticator
+	sessions SessionStarter
+}
+
+// NewLoginHandler creates a new LoginHandler with the provided Authenticator and SessionStarter.
+func NewLoginHandler(svc Authenticator, sessions SessionStarter) *LoginHandler {
+	return &LoginHandler{
+		service:  svc,
+		sessions: sessions,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface for LoginHandler.
+func (h *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeProblem(w, http.StatusMethodNotAllowed, "")
+		return
+	}
+
+	var req model.LoginRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields() // Reject unknown fields
+	if err := decoder.Decode(&req); err != nil {
+		writeErrorProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := h.service.Authenticate(r.Context(), req.Name, req.Password)
+	if err != nil {
+		if model.IsErrUnauthorized(err) {
+			writeErrorProblem(w, http.StatusUnauthorized, err)
+			return
+		}
+		log.Println("Error logging in:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	tokens, err := h.sessions.Login(r.Context(), user)
+	if err != nil {
+		log.Println("Error starting session:", err)
+		writeProblem(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, model.LoginResponse{User: user, SessionTokens: *tokens})
+}
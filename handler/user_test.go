@@ -0,0 +1,58 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/jwt"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestSignupAndLoginHandler(t *testing.T) {
+	t.Parallel()
+
+	keys, err := jwt.GenerateKeySet()
+	if err != nil {
+		t.Fatal("failed to generate keys, err =", err)
+	}
+	store := service.NewMemoryStore()
+	svc := service.NewUserServiceWithRepository(store)
+	signup := handler.NewSignupHandler(svc)
+	login := handler.NewLoginHandler(svc, service.NewSessionServiceWithRepository(store, keys))
+	serve := func(h http.Handler, method, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/", strings.NewReader(body)))
+		return w
+	}
+
+	// ケースは前のケースで登録した利用者を使うため、順に実行する
+	cases := []struct {
+		name   string
+		h      http.Handler
+		method string
+		body   string
+		code   int
+	}{
+		{name: "Signup", h: signup, method: http.MethodPost, body: `{"name":"alice","password":"password1"}`, code: http.StatusCreated},
+		{name: "SignupConflict", h: signup, method: http.MethodPost, body: `{"name":"alice","password":"password2"}`, code: http.StatusConflict},
+		{name: "SignupShortPassword", h: signup, method: http.MethodPost, body: `{"name":"bob","password":"short"}`, code: http.StatusBadRequest},
+		{name: "SignupUnknownField", h: signup, method: http.MethodPost, body: `{"name":"bob","password":"password1","admin":true}`, code: http.StatusBadRequest},
+		{name: "SignupMethodNotAllowed", h: signup, method: http.MethodGet, code: http.StatusMethodNotAllowed},
+		{name: "Login", h: login, method: http.MethodPost, body: `{"name":"alice","password":"password1"}`, code: http.StatusOK},
+		{name: "LoginWrongPassword", h: login, method: http.MethodPost, body: `{"name":"alice","password":"password2"}`, code: http.StatusUnauthorized},
+		{name: "LoginUnknownUser", h: login, method: http.MethodPost, body: `{"name":"bob","password":"password1"}`, code: http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		w := serve(c.h, c.method, c.body)
+		if w.Code != c.code {
+			t.Errorf("%s: unexpected status, given = %d, expected = %d, body = %s", c.name, w.Code, c.code, w.Body)
+			continue
+		}
+		if strings.Contains(w.Body.String(), "password") && c.code < http.StatusBadRequest {
+			t.Errorf("%s: response should not contain the password, body = %s", c.name, w.Body)
+		}
+	}
+}
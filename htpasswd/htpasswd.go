@@ -0,0 +1,219 @@
+// Package htpasswd reads Basic authentication credentials from an Apache htpasswd file.
+//
+// 対応するハッシュは bcrypt (htpasswd -B) と SHA-1 (htpasswd -s) です。
+// MD5 (apr1) や crypt のハッシュは安全でないため、ファイルに含まれる場合は読み込みに失敗します。
+package htpasswd
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/TechBowl-japan/go-stations/periodic"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnknownUser is returned by Authenticate when the file has no entry of the user.
+var ErrUnknownUser = errors.New("htpasswd: unknown user")
+
+const (
+	// shaPrefix は SHA-1 のハッシュの接頭辞です。
+	shaPrefix = "{SHA}"
+	// maxNameLength は名前の最大の文字数で、登録できる利用者の名前と同じです。
+	maxNameLength = 64
+)
+
+// A File is an htpasswd file loaded in memory. It is safe for concurrent use.
+//
+// Reload や Watch で読み込み直すと、以降の Authenticate は新しい内容で照合します。
+// 読み込み直しに失敗した場合は、それまでの内容を使い続けます。
+type File struct {
+	path string
+
+	mu      sync.RWMutex
+	entries map[string]string
+	modTime time.Time
+	size    int64
+}
+
+// Open reads the htpasswd file at path.
+func Open(path string) (*File, error) {
+	f := &File{
+		path: path,
+	}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Path returns the path of the file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Len returns the number of users in the file.
+func (f *File) Len() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.entries)
+}
+
+// Has reports whether the file has an entry of the user name.
+func (f *File) Has(name string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	_, ok := f.entries[name]
+	return ok
+}
+
+// Authenticate reports whether password is the password of the user name.
+// name がファイルに記載されていない場合は ErrUnknownUser を返します。
+func (f *File) Authenticate(name, password string) (bool, error) {
+	f.mu.RLock()
+	hash, ok := f.entries[name]
+	f.mu.RUnlock()
+	if !ok {
+		return false, ErrUnknownUser
+	}
+	return verify(hash, password), nil
+}
+
+// Reload reads the file again. 失敗した場合は、それまでの内容を使い続けます。
+func (f *File) Reload() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	// 内容が不正な場合も更新日時とサイズは記録し、Watch が同じ内容を繰り返し読み込まないようにする
+	entries, err := parse(file)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	if err != nil {
+		return fmt.Errorf("htpasswd: %s: %w", f.path, err)
+	}
+	f.entries = entries
+	return nil
+}
+
+// Watch は ctx がキャンセルされるまで interval ごとにファイルの更新日時とサイズを確認し、変更されていれば読み込み直します。
+// reload に値を受け取った場合は、変更の有無にかかわらず読み込み直します。SIGHUP を受け取るチャネルを渡してください。
+func (f *File) Watch(ctx context.Context, interval time.Duration, reload <-chan os.Signal) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reload:
+				f.reload()
+			}
+		}
+	}()
+
+	periodic.Run(ctx, interval, func(context.Context) {
+		if f.changed() {
+			f.reload()
+		}
+	})
+}
+
+// changed は、読み込んだ後にファイルの更新日時かサイズが変わったかを返します。
+// ファイルを確認できない場合 (置き換えている途中など) は、それまでの内容を使い続けるため false を返します。
+func (f *File) changed() bool {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return !info.ModTime().Equal(f.modTime) || info.Size() != f.size
+}
+
+// reload は Watch からファイルを読み込み直し、結果を記録します。
+func (f *File) reload() {
+	if err := f.Reload(); err != nil {
+		log.Println("htpasswd: failed to reload, keeping the previous users:", err)
+		return
+	}
+	log.Printf("htpasswd: loaded %d users from %s", f.Len(), f.path)
+}
+
+// parse は htpasswd の形式で書かれた r を、利用者の名前からハッシュへの map に変換します。
+// 空行と # で始まる行は無視します。
+func parse(r io.Reader) (map[string]string, error) {
+	entries := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: must be name:hash", n)
+		}
+		name, hash := line[:i], line[i+1:]
+		// 利用者の名前として使えない名前は、運用者として認証できないため読み込まない
+		if utf8.RuneCountInString(name) > maxNameLength || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("line %d: user name must not contain a space or be longer than %d characters", n, maxNameLength)
+		}
+		if !supported(hash) {
+			return nil, fmt.Errorf("line %d: unsupported hash for user %s, use bcrypt (htpasswd -B) or SHA (htpasswd -s)", n, name)
+		}
+		if _, ok := entries[name]; ok {
+			return nil, fmt.Errorf("line %d: user %s is duplicated", n, name)
+		}
+		entries[name] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// supported は hash が bcrypt か SHA-1 のハッシュかを返します。
+func supported(hash string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		_, err := bcrypt.Cost([]byte(hash))
+		return err == nil
+	case strings.HasPrefix(hash, shaPrefix):
+		sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(hash, shaPrefix))
+		return err == nil && len(sum) == sha1.Size
+	}
+	return false
+}
+
+// verify は password を hash と照合します。どちらのハッシュも、一致した長さから推測されないよう一定時間で比較します。
+func verify(hash, password string) bool {
+	if strings.HasPrefix(hash, shaPrefix) {
+		expected, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(hash, shaPrefix))
+		if err != nil {
+			return false
+		}
+		sum := sha1.Sum([]byte(password))
+		return subtle.ConstantTimeCompare(sum[:], expected) == 1
+	}
+	// Apache の $2y$ は $2a$ と同じ形式で、bcrypt パッケージでそのまま比較できる
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
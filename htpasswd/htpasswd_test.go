@@ -0,0 +1,167 @@
+package htpasswd_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/htpasswd"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptEntry は、htpasswd -B と同じく $2y$ で始まる bcrypt のハッシュの行を返します。
+func bcryptEntry(t *testing.T, name, password string) string {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal("failed to hash, err =", err)
+	}
+	return name + ":$2y$" + strings.TrimPrefix(string(hash), "$2a$") + "\n"
+}
+
+// shaEntry は、htpasswd -s と同じく {SHA} で始まる SHA-1 のハッシュの行を返します。
+func shaEntry(name, password string) string {
+	sum := sha1.Sum([]byte(password))
+	return name + ":{SHA}" + base64.StdEncoding.EncodeToString(sum[:]) + "\n"
+}
+
+// writeFile は dir の htpasswd ファイルに content を書き込み、そのパスを返します。
+func writeFile(t *testing.T, dir, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, "htpasswd")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal("failed to write the file, err =", err)
+	}
+	return path
+}
+
+func TestFile_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	content := "# operators\n\n" + bcryptEntry(t, "alice", "password1") + shaEntry("bob", "password2")
+	f, err := htpasswd.Open(writeFile(t, t.TempDir(), content))
+	if err != nil {
+		t.Fatal("failed to open, err =", err)
+	}
+	if f.Len() != 2 {
+		t.Errorf("unexpected number of users, given = %d", f.Len())
+	}
+	if !f.Has("alice") || f.Has("carol") {
+		t.Errorf("unexpected result of Has, given = %v, %v, expected = true, false", f.Has("alice"), f.Has("carol"))
+	}
+
+	cases := map[string]struct {
+		name, password string
+		ok             bool
+		err            error
+	}{
+		"Bcrypt":          {name: "alice", password: "password1", ok: true},
+		"BcryptWrong":     {name: "alice", password: "password2"},
+		"SHA":             {name: "bob", password: "password2", ok: true},
+		"SHAWrong":        {name: "bob", password: "password1"},
+		"UnknownUser":     {name: "carol", password: "password1", err: htpasswd.ErrUnknownUser},
+		"CaseSensitivity": {name: "Alice", password: "password1", err: htpasswd.ErrUnknownUser},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ok, err := f.Authenticate(c.name, c.password)
+			if ok != c.ok || err != c.err {
+				t.Errorf("unexpected result, given = %v, %v, expected = %v, %v", ok, err, c.ok, c.err)
+			}
+		})
+	}
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"NoColon":   "alice\n",
+		"MD5":       "alice:$apr1$salt$hash\n",
+		"Plain":     "alice:password1\n",
+		"BadSHA":    "alice:{SHA}short\n",
+		"Duplicate": shaEntry("alice", "password1") + shaEntry("alice", "password2"),
+		"Space":     shaEntry("alice smith", "password1"),
+		"LongName":  shaEntry(strings.Repeat("a", 65), "password1"),
+	}
+	for name, content := range cases {
+		content := content
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := htpasswd.Open(writeFile(t, t.TempDir(), content)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+	if _, err := htpasswd.Open(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestFile_Watch(t *testing.T) {
+	t.Parallel()
+
+	path := writeFile(t, t.TempDir(), shaEntry("alice", "password1"))
+	f, err := htpasswd.Open(path)
+	if err != nil {
+		t.Fatal("failed to open, err =", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reload := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.Watch(ctx, 10*time.Millisecond, reload)
+	}()
+	// eventually は cond が満たされるまで待ちます。
+	eventually := func(cond func() bool) bool {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if cond() {
+				return true
+			}
+		}
+		return false
+	}
+	authenticated := func(name, password string) func() bool {
+		return func() bool {
+			ok, _ := f.Authenticate(name, password)
+			return ok
+		}
+	}
+
+	// ファイルが変更されると読み込み直す
+	writeFile(t, filepath.Dir(path), shaEntry("alice", "password1")+shaEntry("bob", "password2"))
+	if !eventually(authenticated("bob", "password2")) {
+		t.Error("the added user should be loaded")
+	}
+
+	// 不正な内容に変更された場合は、それまでの内容を使い続ける
+	writeFile(t, filepath.Dir(path), "broken\n")
+	time.Sleep(50 * time.Millisecond)
+	if ok, err := f.Authenticate("bob", "password2"); !ok || err != nil {
+		t.Errorf("the previous users should be kept, given = %v, %v", ok, err)
+	}
+
+	// reload を受け取ると、変更を検出できない場合も読み込み直す
+	writeFile(t, filepath.Dir(path), shaEntry("carol", "password3"))
+	reload <- os.Interrupt
+	if !eventually(authenticated("carol", "password3")) {
+		t.Error("the file should be reloaded by the signal")
+	}
+
+	cancel()
+	<-done
+}
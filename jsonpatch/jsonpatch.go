@@ -0,0 +1,481 @@
+// Package jsonpatch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902) documents
+// to JSON values decoded with encoding/json.
+//
+// 文書は interface{} (map[string]interface{}, []interface{}, string, json.Number, bool, nil) として扱います。
+// 数値を正確に比較できるよう、json.Decoder の UseNumber で読み込んだ値を渡してください。Decode はそのように読み込みます。
+// どちらの関数も引数の文書を変更せず、パッチを適用した新しい文書を返します。
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPatch は、パッチの文書自体が不正な場合のエラーです。
+type ErrInvalidPatch struct {
+	Message string
+}
+
+// Error メソッドを実装し、error インターフェースを満たします。
+func (e *ErrInvalidPatch) Error() string {
+	return "invalid patch: " + e.Message
+}
+
+// IsErrInvalidPatch は err が *ErrInvalidPatch を含むかを返します。
+func IsErrInvalidPatch(err error) bool {
+	var invalid *ErrInvalidPatch
+	return errors.As(err, &invalid)
+}
+
+// ErrNotApplicable は、パッチを文書に適用できない場合のエラーです。
+// path が存在しない場合や、test 操作の値が一致しない場合に返します。
+type ErrNotApplicable struct {
+	// Index は失敗した操作の位置です。
+	Index   int
+	Message string
+}
+
+// Error メソッドを実装し、error インターフェースを満たします。
+func (e *ErrNotApplicable) Error() string {
+	return fmt.Sprintf("operation %d cannot be applied: %s", e.Index, e.Message)
+}
+
+// IsErrNotApplicable は err が *ErrNotApplicable を含むかを返します。
+func IsErrNotApplicable(err error) bool {
+	var notApplicable *ErrNotApplicable
+	return errors.As(err, &notApplicable)
+}
+
+// Decode は data を 1 つの JSON の値として、数値を json.Number のまま読み込みます。
+func Decode(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return v, nil
+}
+
+// MergePatch applies the JSON Merge Patch patch to target (RFC 7396).
+//
+// patch がオブジェクトでない場合は、patch がそのまま新しい文書になります。
+// オブジェクトの null のメンバーは、target の同じ名前のメンバーを削除します。
+func MergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return deepCopy(patch)
+	}
+
+	result := map[string]interface{}{}
+	if t, ok := target.(map[string]interface{}); ok {
+		for k, v := range t {
+			result[k] = v
+		}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(result, k)
+			continue
+		}
+		result[k] = MergePatch(result[k], v)
+	}
+	return result
+}
+
+// JSON Patch の操作です。
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+	OpMove    = "move"
+	OpCopy    = "copy"
+	OpTest    = "test"
+)
+
+// An Operation is one operation of a JSON Patch document.
+type Operation struct {
+	Op   string
+	Path string
+	From string
+	// Value は add, replace, test の値です。null の場合も HasValue は true です。
+	Value    interface{}
+	HasValue bool
+}
+
+// ParsePatch parses a JSON Patch document, an array of operations (RFC 6902).
+// 未知の操作や、操作に必要なメンバーがない場合は *ErrInvalidPatch を返します。
+func ParsePatch(data []byte) ([]*Operation, error) {
+	doc, err := Decode(data)
+	if err != nil {
+		return nil, &ErrInvalidPatch{Message: err.Error()}
+	}
+	list, ok := doc.([]interface{})
+	if !ok {
+		return nil, &ErrInvalidPatch{Message: "the patch must be an array of operations"}
+	}
+
+	ops := make([]*Operation, len(list))
+	for i, item := range list {
+		members, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, &ErrInvalidPatch{Message: fmt.Sprintf("operation %d must be an object", i)}
+		}
+		op := &Operation{}
+		if op.Op, err = stringMember(members, "op", i); err != nil {
+			return nil, err
+		}
+		if op.Path, err = stringMember(members, "path", i); err != nil {
+			return nil, err
+		}
+		if _, err := parsePointer(op.Path); err != nil {
+			return nil, &ErrInvalidPatch{Message: fmt.Sprintf("operation %d: %v", i, err)}
+		}
+		switch op.Op {
+		case OpAdd, OpReplace, OpTest:
+			op.Value, op.HasValue = members["value"]
+			if !op.HasValue {
+				return nil, &ErrInvalidPatch{Message: fmt.Sprintf("operation %d: %s requires value", i, op.Op)}
+			}
+		case OpMove, OpCopy:
+			if op.From, err = stringMember(members, "from", i); err != nil {
+				return nil, err
+			}
+			if _, err := parsePointer(op.From); err != nil {
+				return nil, &ErrInvalidPatch{Message: fmt.Sprintf("operation %d: %v", i, err)}
+			}
+		case OpRemove:
+		default:
+			return nil, &ErrInvalidPatch{Message: fmt.Sprintf("operation %d: unknown op %q", i, op.Op)}
+		}
+		ops[i] = op
+	}
+	return ops, nil
+}
+
+// stringMember は、i 番目の操作の name メンバーを文字列として取得します。
+func stringMember(members map[string]interface{}, name string, i int) (string, error) {
+	v, ok := members[name]
+	if !ok {
+		return "", &ErrInvalidPatch{Message: fmt.Sprintf("operation %d: %s is required", i, name)}
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", &ErrInvalidPatch{Message: fmt.Sprintf("operation %d: %s must be a string", i, name)}
+	}
+	return s, nil
+}
+
+// Apply applies ops to doc in order (RFC 6902). 1 つでも失敗した場合は、どの操作も適用しない状態のままエラーを返します。
+func Apply(doc interface{}, ops []*Operation) (interface{}, error) {
+	doc = deepCopy(doc)
+	for i, op := range ops {
+		var err error
+		doc, err = apply(doc, op)
+		if err != nil {
+			return nil, &ErrNotApplicable{Index: i, Message: err.Error()}
+		}
+	}
+	return doc, nil
+}
+
+// apply は doc に 1 つの操作を適用した文書を返します。doc は変更されることがあります。
+func apply(doc interface{}, op *Operation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case OpAdd:
+		return add(doc, path, deepCopy(op.Value))
+	case OpRemove:
+		doc, _, err := remove(doc, path)
+		return doc, err
+	case OpReplace:
+		if len(path) == 0 {
+			return deepCopy(op.Value), nil
+		}
+		if _, err := get(doc, path); err != nil {
+			return nil, err
+		}
+		doc, _, err := remove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, deepCopy(op.Value))
+	case OpMove:
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if isProperPrefix(from, path) {
+			return nil, fmt.Errorf("cannot move %q into its own child %q", op.From, op.Path)
+		}
+		doc, value, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case OpCopy:
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, deepCopy(value))
+	case OpTest:
+		value, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !Equal(value, op.Value) {
+			return nil, fmt.Errorf("the value at %q does not match", op.Path)
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("unknown op %q", op.Op)
+	}
+}
+
+// parsePointer は JSON Pointer (RFC 6901) をトークンに分解します。"" は文書全体を表し、空のスライスを返します。
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		// ~ の後には 0 (~ を表す) か 1 (/ を表す) しか続けられない
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, fmt.Errorf("pointer %q has an invalid escape", pointer)
+			}
+		}
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// isProperPrefix は prefix が path の祖先を指すかを返します。
+func isProperPrefix(prefix, path []string) bool {
+	if len(prefix) >= len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex は配列のトークンを添字に変換します。end が true の場合は、末尾を表す "-" と長さと等しい添字を受け付けます。
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if end && token == "-" {
+		return length, nil
+	}
+	// 先頭の 0 や符号は RFC 6901 では添字として認めない
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.IndexFunc(token, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+	max := length - 1
+	if end {
+		max = length
+	}
+	if i > max {
+		return 0, fmt.Errorf("index %d is out of range", i)
+	}
+	return i, nil
+}
+
+// get は path が指す値を返します。
+func get(doc interface{}, path []string) (interface{}, error) {
+	current := doc
+	for _, token := range path {
+		switch c := current.(type) {
+		case map[string]interface{}:
+			v, ok := c[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			current = v
+		case []interface{}:
+			i, err := arrayIndex(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			current = c[i]
+		default:
+			return nil, fmt.Errorf("cannot refer to %q in a scalar value", token)
+		}
+	}
+	return current, nil
+}
+
+// add は path に value を加えた文書を返します。親は存在している必要があります。
+// オブジェクトの既存のメンバーは置き換え、配列には指定した位置に挿入します。
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = value
+		return doc, nil
+	case []interface{}:
+		i, err := arrayIndex(last, len(p), true)
+		if err != nil {
+			return nil, err
+		}
+		inserted := make([]interface{}, 0, len(p)+1)
+		inserted = append(inserted, p[:i]...)
+		inserted = append(inserted, value)
+		inserted = append(inserted, p[i:]...)
+		return set(doc, path[:len(path)-1], inserted)
+	default:
+		return nil, fmt.Errorf("cannot add %q to a scalar value", last)
+	}
+}
+
+// remove は path の値を取り除いた文書と、取り除いた値を返します。
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("cannot remove the whole document")
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		v, ok := p[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("member %q does not exist", last)
+		}
+		delete(p, last)
+		return doc, v, nil
+	case []interface{}:
+		i, err := arrayIndex(last, len(p), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		v := p[i]
+		removed := make([]interface{}, 0, len(p)-1)
+		removed = append(removed, p[:i]...)
+		removed = append(removed, p[i+1:]...)
+		doc, err := set(doc, path[:len(path)-1], removed)
+		return doc, v, err
+	default:
+		return nil, nil, fmt.Errorf("cannot remove %q from a scalar value", last)
+	}
+}
+
+// set は path の値を value で置き換えた文書を返します。配列の長さを変えた場合に、親から新しい配列を参照させるために使います。
+func set(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = value
+	case []interface{}:
+		i, err := arrayIndex(last, len(p), false)
+		if err != nil {
+			return nil, err
+		}
+		p[i] = value
+	}
+	return doc, nil
+}
+
+// Equal reports whether a and b are the same JSON value. 数値は値として比較します (1 と 1.0 は等しい)。
+func Equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !Equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !Equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	default:
+		return a == b
+	}
+}
+
+// deepCopy は、操作が引数の文書を変更しないよう、オブジェクトと配列を複製します。
+func deepCopy(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(x))
+		for k, w := range x {
+			c[k] = deepCopy(w)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(x))
+		for i, w := range x {
+			c[i] = deepCopy(w)
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,125 @@
+package jsonpatch_test
+
+import (
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/jsonpatch"
+)
+
+// mustDecode は s を JSON として読み込み、失敗した場合はテストを中断します。
+func mustDecode(t *testing.T, s string) interface{} {
+	t.Helper()
+
+	v, err := jsonpatch.Decode([]byte(s))
+	if err != nil {
+		t.Fatalf("failed to decode %s, err = %v", s, err)
+	}
+	return v
+}
+
+// RFC 7396 の Appendix A の例
+func TestMergePatch(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		target, patch, expected string
+	}{
+		"Replace":       {target: `{"a":"b"}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+		"Add":           {target: `{"a":"b"}`, patch: `{"b":"c"}`, expected: `{"a":"b","b":"c"}`},
+		"Remove":        {target: `{"a":"b","b":"c"}`, patch: `{"a":null}`, expected: `{"b":"c"}`},
+		"Array":         {target: `{"a":["b"]}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+		"Nested":        {target: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, expected: `{"a":{"b":"d"}}`},
+		"NotObject":     {target: `{"a":"foo"}`, patch: `"bar"`, expected: `"bar"`},
+		"TargetScalar":  {target: `["c"]`, patch: `{"a":"b"}`, expected: `{"a":"b"}`},
+		"NullInPatched": {target: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, expected: `{"a":{"bb":{}}}`},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			target := mustDecode(t, c.target)
+			given := jsonpatch.MergePatch(target, mustDecode(t, c.patch))
+			if !jsonpatch.Equal(given, mustDecode(t, c.expected)) {
+				t.Errorf("unexpected document, given = %v, expected = %s", given, c.expected)
+			}
+			if !jsonpatch.Equal(target, mustDecode(t, c.target)) {
+				t.Errorf("target was modified, given = %v, expected = %s", target, c.target)
+			}
+		})
+	}
+}
+
+// RFC 6902 の Appendix A の例
+func TestApply(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		doc, patch, expected string
+		invalid, conflict    bool
+	}{
+		"AddMember":      {doc: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/baz","value":"qux"}]`, expected: `{"baz":"qux","foo":"bar"}`},
+		"AddElement":     {doc: `{"foo":["bar","baz"]}`, patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`, expected: `{"foo":["bar","qux","baz"]}`},
+		"AddEnd":         {doc: `{"foo":["bar"]}`, patch: `[{"op":"add","path":"/foo/-","value":["abc"]}]`, expected: `{"foo":["bar",["abc"]]}`},
+		"RemoveMember":   {doc: `{"baz":"qux","foo":"bar"}`, patch: `[{"op":"remove","path":"/baz"}]`, expected: `{"foo":"bar"}`},
+		"RemoveElement":  {doc: `{"foo":["bar","qux","baz"]}`, patch: `[{"op":"remove","path":"/foo/1"}]`, expected: `{"foo":["bar","baz"]}`},
+		"Replace":        {doc: `{"baz":"qux","foo":"bar"}`, patch: `[{"op":"replace","path":"/baz","value":"boo"}]`, expected: `{"baz":"boo","foo":"bar"}`},
+		"Move":           {doc: `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, expected: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		"MoveElement":    {doc: `{"foo":["all","grass","cows","eat"]}`, patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, expected: `{"foo":["all","cows","eat","grass"]}`},
+		"Copy":           {doc: `{"foo":{"a":1}}`, patch: `[{"op":"copy","from":"/foo","path":"/bar"}]`, expected: `{"foo":{"a":1},"bar":{"a":1}}`},
+		"Test":           {doc: `{"baz":"qux","foo":["a",2,"c"]}`, patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`, expected: `{"baz":"qux","foo":["a",2,"c"]}`},
+		"TestFailed":     {doc: `{"baz":"qux"}`, patch: `[{"op":"test","path":"/baz","value":"bar"}]`, conflict: true},
+		"Escape":         {doc: `{"/":9,"~1":10}`, patch: `[{"op":"test","path":"/~01","value":10},{"op":"remove","path":"/~1"}]`, expected: `{"~1":10}`},
+		"AddNull":        {doc: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/foo","value":null}]`, expected: `{"foo":null}`},
+		"NoParent":       {doc: `{"foo":"bar"}`, patch: `[{"op":"add","path":"/baz/bat","value":"qux"}]`, conflict: true},
+		"RemoveMissing":  {doc: `{"foo":"bar"}`, patch: `[{"op":"remove","path":"/baz"}]`, conflict: true},
+		"OutOfRange":     {doc: `{"foo":["bar"]}`, patch: `[{"op":"add","path":"/foo/2","value":"x"}]`, conflict: true},
+		"LeadingZero":    {doc: `{"foo":["bar","baz"]}`, patch: `[{"op":"replace","path":"/foo/01","value":"x"}]`, conflict: true},
+		"MoveIntoChild":  {doc: `{"foo":{"bar":1}}`, patch: `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`, conflict: true},
+		"UnknownOp":      {doc: `{}`, patch: `[{"op":"merge","path":"/foo","value":1}]`, invalid: true},
+		"MissingValue":   {doc: `{}`, patch: `[{"op":"add","path":"/foo"}]`, invalid: true},
+		"BadPointer":     {doc: `{}`, patch: `[{"op":"remove","path":"foo"}]`, invalid: true},
+		"BadEscape":      {doc: `{}`, patch: `[{"op":"remove","path":"/~2"}]`, invalid: true},
+		"NotArray":       {doc: `{}`, patch: `{"op":"remove","path":"/foo"}`, invalid: true},
+		"ReplaceWhole":   {doc: `{"foo":1}`, patch: `[{"op":"replace","path":"","value":[1]}]`, expected: `[1]`},
+		"AllOrNothing":   {doc: `{"foo":1}`, patch: `[{"op":"remove","path":"/foo"},{"op":"remove","path":"/foo"}]`, conflict: true},
+		"MissingFromKey": {doc: `{}`, patch: `[{"op":"copy","path":"/foo"}]`, invalid: true},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ops, err := jsonpatch.ParsePatch([]byte(c.patch))
+			if c.invalid {
+				if !jsonpatch.IsErrInvalidPatch(err) {
+					t.Errorf("unexpected error, given = %v, expected = invalid patch", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("failed to parse the patch, err =", err)
+			}
+
+			doc := mustDecode(t, c.doc)
+			given, err := jsonpatch.Apply(doc, ops)
+			if c.conflict {
+				if !jsonpatch.IsErrNotApplicable(err) {
+					t.Errorf("unexpected error, given = %v, expected = not applicable", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("failed to apply the patch, err =", err)
+			}
+			if !jsonpatch.Equal(given, mustDecode(t, c.expected)) {
+				t.Errorf("unexpected document, given = %v, expected = %s", given, c.expected)
+			}
+			if !jsonpatch.Equal(doc, mustDecode(t, c.doc)) {
+				t.Errorf("document was modified, given = %v, expected = %s", doc, c.doc)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Package jwt signs and verifies JSON Web Tokens (RFC 7519) in the JWS compact serialization
+// with HS256 or EdDSA (Ed25519) keys.
+//
+// 鍵は JWKS (RFC 7517) 形式の KeySet で管理します。先頭の署名できる鍵で署名し、kid が一致するすべての鍵で検証するため、
+// 新しい鍵を先頭に追加し、古い鍵で署名したトークンの期限が切れてから古い鍵を削除すると、鍵を入れ替えられます。
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 対応している署名のアルゴリズムです。
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// ErrInvalidToken は、トークンの形式、署名、または有効期間が正しくない場合のエラーです。
+type ErrInvalidToken struct {
+	Message string
+}
+
+// Error メソッドを実装し、error インターフェースを満たします。
+func (e *ErrInvalidToken) Error() string {
+	return "invalid token: " + e.Message
+}
+
+// IsErrInvalidToken は err が *ErrInvalidToken を含むかを返します。
+func IsErrInvalidToken(err error) bool {
+	var invalid *ErrInvalidToken
+	return errors.As(err, &invalid)
+}
+
+// Claims は、このアプリケーションがトークンに含めるクレームです。
+type Claims struct {
+	// Subject は利用者の id です。
+	Subject string `json:"sub"`
+	// Name は利用者の名前です。
+	Name string `json:"name,omitempty"`
+	// SessionID はトークンを発行したセッションの id です。セッションを取り消すと、そのトークンも使えなくなります。
+	SessionID string `json:"sid,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// header は JOSE ヘッダーです。
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// encoding は、JWS で使う、パディングのない URL セーフな base64 です。
+var encoding = base64.RawURLEncoding
+
+// Sign は claims に先頭の署名できる鍵で署名したトークンを返します。
+func (s *KeySet) Sign(claims *Claims) (string, error) {
+	key := s.signingKey()
+	if key == nil {
+		return "", errors.New("the key set has no key to sign with")
+	}
+
+	h, err := json.Marshal(&header{Algorithm: key.Algorithm, Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := encoding.EncodeToString(h) + "." + encoding.EncodeToString(c)
+	return input + "." + encoding.EncodeToString(key.sign([]byte(input))), nil
+}
+
+// Verify は token の署名と有効期間を確認し、クレームを返します。
+//
+// ヘッダーの alg は kid の鍵のアルゴリズムと一致しなければなりません。none や、公開鍵を HMAC の鍵として使わせるような
+// アルゴリズムの取り違えは受け付けません。
+func (s *KeySet) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, &ErrInvalidToken{Message: "malformed token"}
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, err
+	}
+	key := s.key(h.KeyID)
+	if key == nil {
+		return nil, &ErrInvalidToken{Message: "unknown key " + h.KeyID}
+	}
+	if h.Algorithm != key.Algorithm {
+		return nil, &ErrInvalidToken{Message: "unexpected algorithm " + h.Algorithm}
+	}
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, &ErrInvalidToken{Message: "malformed signature"}
+	}
+	if !key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return nil, &ErrInvalidToken{Message: "signature mismatch"}
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt == 0 || now.Unix() >= claims.ExpiresAt {
+		return nil, &ErrInvalidToken{Message: "the token has expired"}
+	}
+	return &claims, nil
+}
+
+// decodeSegment は base64 で符号化された JSON の segment を v に読み込みます。
+func decodeSegment(segment string, v interface{}) error {
+	b, err := encoding.DecodeString(segment)
+	if err != nil {
+		return &ErrInvalidToken{Message: "malformed segment"}
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return &ErrInvalidToken{Message: "malformed segment"}
+	}
+	return nil
+}
+
+// sign は、鍵のアルゴリズムで input の署名を作成します。
+func (k *Key) sign(input []byte) []byte {
+	switch k.Algorithm {
+	case AlgorithmHS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return mac.Sum(nil)
+	case AlgorithmEdDSA:
+		return ed25519.Sign(k.private, input)
+	}
+	return nil
+}
+
+// verify は signature が input の正しい署名かを返します。
+func (k *Key) verify(input, signature []byte) bool {
+	switch k.Algorithm {
+	case AlgorithmHS256:
+		return hmac.Equal(k.sign(input), signature)
+	case AlgorithmEdDSA:
+		return len(signature) == ed25519.SignatureSize && ed25519.Verify(k.public, input, signature)
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package jwt_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/jwt"
+)
+
+// octKey は HS256 の JWK を返します。
+func octKey(kid string, secret byte) string {
+	k := make([]byte, 32)
+	for i := range k {
+		k[i] = secret
+	}
+	return fmt.Sprintf(`{"kty":"oct","kid":%q,"alg":"HS256","k":%q}`, kid, base64.RawURLEncoding.EncodeToString(k))
+}
+
+// okpKey は EdDSA の JWK を返します。private が false の場合は公開鍵だけを含めます。
+func okpKey(t *testing.T, kid string, key ed25519.PrivateKey, private bool) string {
+	t.Helper()
+
+	j := map[string]string{
+		"kty": "OKP", "kid": kid, "alg": "EdDSA", "crv": "Ed25519",
+		"x": base64.RawURLEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+	}
+	if private {
+		j["d"] = base64.RawURLEncoding.EncodeToString(key.Seed())
+	}
+	b, err := json.Marshal(j)
+	if err != nil {
+		t.Fatal("failed to marshal the key, err =", err)
+	}
+	return string(b)
+}
+
+// mustParse は keys からなる KeySet を返し、失敗した場合はテストを中断します。
+func mustParse(t *testing.T, keys ...string) *jwt.KeySet {
+	t.Helper()
+
+	s, err := jwt.ParseKeySet([]byte(`{"keys":[` + strings.Join(keys, ",") + `]}`))
+	if err != nil {
+		t.Fatal("failed to parse the key set, err =", err)
+	}
+	return s
+}
+
+func TestSignAndVerify(t *testing.T) {
+	t.Parallel()
+
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("failed to generate key, err =", err)
+	}
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("failed to generate key, err =", err)
+	}
+	now := time.Unix(1700000000, 0)
+	claims := &jwt.Claims{Subject: "1", Name: "alice", SessionID: "2", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+
+	cases := map[string]struct {
+		signer, verifier *jwt.KeySet
+		at               time.Time
+		tamper           func(token string) string
+		invalid          bool
+	}{
+		"HS256":       {signer: mustParse(t, octKey("a", 1)), verifier: mustParse(t, octKey("a", 1)), at: now},
+		"EdDSA":       {signer: mustParse(t, okpKey(t, "a", ed, true)), verifier: mustParse(t, okpKey(t, "b", other, true), okpKey(t, "a", ed, false)), at: now},
+		"Rotated":     {signer: mustParse(t, octKey("old", 1)), verifier: mustParse(t, octKey("new", 2), octKey("old", 1)), at: now},
+		"Removed":     {signer: mustParse(t, octKey("old", 1)), verifier: mustParse(t, octKey("new", 2)), at: now, invalid: true},
+		"WrongSecret": {signer: mustParse(t, octKey("a", 1)), verifier: mustParse(t, octKey("a", 2)), at: now, invalid: true},
+		"Expired":     {signer: mustParse(t, octKey("a", 1)), verifier: mustParse(t, octKey("a", 1)), at: now.Add(time.Minute), invalid: true},
+		"WrongEdDSA":  {signer: mustParse(t, okpKey(t, "a", ed, true)), verifier: mustParse(t, okpKey(t, "a", other, true)), at: now, invalid: true},
+		"Malformed":   {signer: mustParse(t, octKey("a", 1)), verifier: mustParse(t, octKey("a", 1)), at: now, tamper: func(string) string { return "a.b" }, invalid: true},
+		"TamperClaims": {signer: mustParse(t, octKey("a", 1)), verifier: mustParse(t, octKey("a", 1)), at: now, invalid: true,
+			tamper: func(token string) string {
+				parts := strings.Split(token, ".")
+				parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"2","exp":9999999999}`))
+				return strings.Join(parts, ".")
+			}},
+		"AlgNone": {signer: mustParse(t, octKey("a", 1)), verifier: mustParse(t, octKey("a", 1)), at: now, invalid: true,
+			tamper: func(token string) string {
+				parts := strings.Split(token, ".")
+				return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"a"}`)) + "." + parts[1] + "."
+			}},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			token, err := c.signer.Sign(claims)
+			if err != nil {
+				t.Fatal("failed to sign, err =", err)
+			}
+			if c.tamper != nil {
+				token = c.tamper(token)
+			}
+			given, err := c.verifier.Verify(token, c.at)
+			if c.invalid {
+				if !jwt.IsErrInvalidToken(err) {
+					t.Errorf("unexpected error, given = %v, expected = invalid token", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("failed to verify, err =", err)
+			}
+			if *given != *claims {
+				t.Errorf("unexpected claims, given = %+v, expected = %+v", given, claims)
+			}
+		})
+	}
+}
+
+func TestParseKeySet(t *testing.T) {
+	t.Parallel()
+
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("failed to generate key, err =", err)
+	}
+	cases := map[string]string{
+		"NoKeys":       `{"keys":[]}`,
+		"VerifyOnly":   `{"keys":[` + okpKey(t, "a", ed, false) + `]}`,
+		"ShortSecret":  `{"keys":[{"kty":"oct","kid":"a","k":"c2hvcnQ"}]}`,
+		"MissingKID":   `{"keys":[{"kty":"oct","k":"` + strings.Repeat("A", 43) + `"}]}`,
+		"DuplicateKID": `{"keys":[` + octKey("a", 1) + `,` + octKey("a", 2) + `]}`,
+		"UnknownType":  `{"keys":[{"kty":"RSA","kid":"a"}]}`,
+		"WrongAlg":     `{"keys":[{"kty":"oct","kid":"a","alg":"HS512","k":"` + strings.Repeat("A", 43) + `"}]}`,
+		"Encryption":   `{"keys":[{"kty":"oct","kid":"a","use":"enc","k":"` + strings.Repeat("A", 43) + `"}]}`,
+	}
+	for name, data := range cases {
+		if _, err := jwt.ParseKeySet([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// 公開する鍵には、HS256 の鍵と EdDSA の秘密鍵を含めない
+	s := mustParse(t, octKey("hs", 1), okpKey(t, "ed", ed, true))
+	public, err := s.PublicKeys()
+	if err != nil {
+		t.Fatal("failed to marshal public keys, err =", err)
+	}
+	if strings.Contains(string(public), `"hs"`) || strings.Contains(string(public), `"d"`) || !strings.Contains(string(public), `"ed"`) {
+		t.Errorf("unexpected public keys, given = %s", public)
+	}
+}
@@ -0,0 +1,187 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// minSecretBytes は HS256 の鍵の最小の長さです。RFC 7518 は、ハッシュの出力と同じ 256 ビット以上を求めています。
+const minSecretBytes = 32
+
+// A Key is a key to sign or verify tokens.
+// EdDSA の鍵のうち、秘密鍵 (d) のない鍵は検証にだけ使えます。
+type Key struct {
+	ID        string
+	Algorithm string
+
+	secret  []byte
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// A KeySet is an ordered set of keys. The first key that can sign is used to sign tokens.
+type KeySet struct {
+	keys []*Key
+}
+
+// jwk は JWK (RFC 7517, RFC 8037) の 1 つの鍵です。
+type jwk struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg,omitempty"`
+	Use       string `json:"use,omitempty"`
+	// K は oct の鍵です。
+	K string `json:"k,omitempty"`
+	// Curve、X と D は OKP の鍵の曲線、公開鍵、秘密鍵です。
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	D     string `json:"d,omitempty"`
+}
+
+// jwks は JWK Set です。
+type jwks struct {
+	Keys []*jwk `json:"keys"`
+}
+
+// ParseKeySet reads a JWK Set that contains oct keys for HS256 and OKP (Ed25519) keys for EdDSA.
+//
+// 鍵ごとに一意な kid が必要です。署名できる鍵が 1 つもない場合はエラーを返します。
+func ParseKeySet(data []byte) (*KeySet, error) {
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	s := &KeySet{}
+	seen := map[string]bool{}
+	for i, j := range set.Keys {
+		key, err := j.key()
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("key %d: duplicated kid %q", i, key.ID)
+		}
+		seen[key.ID] = true
+		s.keys = append(s.keys, key)
+	}
+	if s.signingKey() == nil {
+		return nil, fmt.Errorf("the key set has no key to sign with")
+	}
+	return s, nil
+}
+
+// ReadKeySetFile reads the JWK Set file at path with ParseKeySet.
+func ReadKeySetFile(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := ParseKeySet(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// GenerateKeySet returns a key set of a new random HS256 key.
+// 鍵は保存しないため、プロセスを再起動すると以前のトークンは検証できなくなります。
+func GenerateKeySet() (*KeySet, error) {
+	secret := make([]byte, minSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &KeySet{keys: []*Key{{ID: "generated", Algorithm: AlgorithmHS256, secret: secret}}}, nil
+}
+
+// PublicKeys returns a JWK Set of the public keys in s to publish for other services.
+// HS256 の鍵は共有の秘密のため含めません。
+func (s *KeySet) PublicKeys() ([]byte, error) {
+	set := jwks{Keys: []*jwk{}}
+	for _, k := range s.keys {
+		if k.Algorithm != AlgorithmEdDSA {
+			continue
+		}
+		set.Keys = append(set.Keys, &jwk{
+			KeyType:   "OKP",
+			KeyID:     k.ID,
+			Algorithm: AlgorithmEdDSA,
+			Use:       "sig",
+			Curve:     "Ed25519",
+			X:         encoding.EncodeToString(k.public),
+		})
+	}
+	return json.Marshal(&set)
+}
+
+// signingKey は先頭の署名できる鍵を返します。
+func (s *KeySet) signingKey() *Key {
+	for _, k := range s.keys {
+		if k.Algorithm == AlgorithmHS256 || k.private != nil {
+			return k
+		}
+	}
+	return nil
+}
+
+// key は kid が id の鍵を返します。
+func (s *KeySet) key(id string) *Key {
+	for _, k := range s.keys {
+		if k.ID == id {
+			return k
+		}
+	}
+	return nil
+}
+
+// key は JWK を Key に変換します。
+func (j *jwk) key() (*Key, error) {
+	if j.KeyID == "" {
+		return nil, fmt.Errorf("kid is required")
+	}
+	if j.Use != "" && j.Use != "sig" {
+		return nil, fmt.Errorf("unsupported use %q", j.Use)
+	}
+
+	switch j.KeyType {
+	case "oct":
+		if j.Algorithm != "" && j.Algorithm != AlgorithmHS256 {
+			return nil, fmt.Errorf("unsupported alg %q for kty oct", j.Algorithm)
+		}
+		secret, err := encoding.DecodeString(j.K)
+		if err != nil {
+			return nil, fmt.Errorf("k: %w", err)
+		}
+		if len(secret) < minSecretBytes {
+			return nil, fmt.Errorf("k must be at least %d bytes", minSecretBytes)
+		}
+		return &Key{ID: j.KeyID, Algorithm: AlgorithmHS256, secret: secret}, nil
+	case "OKP":
+		if j.Algorithm != "" && j.Algorithm != AlgorithmEdDSA {
+			return nil, fmt.Errorf("unsupported alg %q for kty OKP", j.Algorithm)
+		}
+		if j.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported crv %q", j.Curve)
+		}
+		x, err := encoding.DecodeString(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("x must be a %d bytes Ed25519 public key", ed25519.PublicKeySize)
+		}
+		key := &Key{ID: j.KeyID, Algorithm: AlgorithmEdDSA, public: ed25519.PublicKey(x)}
+		if j.D != "" {
+			d, err := encoding.DecodeString(j.D)
+			if err != nil || len(d) != ed25519.SeedSize {
+				return nil, fmt.Errorf("d must be a %d bytes Ed25519 seed", ed25519.SeedSize)
+			}
+			key.private = ed25519.NewKeyFromSeed(d)
+			if !key.private.Public().(ed25519.PublicKey).Equal(key.public) {
+				return nil, fmt.Errorf("d does not match x")
+			}
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported kty %q", j.KeyType)
+}
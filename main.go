@@ -2,18 +2,31 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/TechBowl-japan/go-stations/backup"
 	"github.com/TechBowl-japan/go-stations/db"
 	"github.com/TechBowl-japan/go-stations/handler/router"
+	"github.com/TechBowl-japan/go-stations/htpasswd"
+	"github.com/TechBowl-japan/go-stations/jwt"
+	"github.com/TechBowl-japan/go-stations/reminder"
+	"github.com/TechBowl-japan/go-stations/service"
+	"github.com/TechBowl-japan/go-stations/trash"
 )
 
+// driverMemory は、データベースを使わずにメモリ上にデータを保持する DB_DRIVER の値です。
+const driverMemory = "memory"
+
 func main() {
 	err := realMain()
 	if err != nil {
@@ -24,8 +37,14 @@ func main() {
 func realMain() error {
 	// config values
 	const (
-		defaultPort   = ":8080"
-		defaultDBPath = ".sqlite3/todo.db"
+		defaultPort             = ":8080"
+		defaultDBPath           = ".sqlite3/todo.db"
+		defaultReminderInterval = time.Minute
+		defaultTrashRetention   = 30 * 24 * time.Hour
+		defaultPurgeInterval    = time.Hour
+		defaultBackupDir        = ".sqlite3/backups"
+		defaultBackupKeep       = 7
+		defaultHtpasswdInterval = 5 * time.Second
 	)
 
 	port := os.Getenv("PORT")
@@ -38,31 +57,258 @@ func realMain() error {
 		dbPath = defaultDBPath
 	}
 
+	// 保存先のドライバー (sqlite3, postgres, memory) と接続先を取得
+	// sqlite3 の場合、DB_DSN を省略すると DB_PATH のファイルを使う。memory の場合は DB_DSN を使わない
+	dbDriver := os.Getenv("DB_DRIVER")
+	if dbDriver == "" {
+		dbDriver = db.DriverSQLite
+	}
+	dbDSN := os.Getenv("DB_DSN")
+	switch dbDriver {
+	case db.DriverSQLite:
+		if dbDSN == "" {
+			dbDSN = dbPath
+		}
+	case db.DriverPostgres:
+		if dbDSN == "" {
+			return fmt.Errorf("DB_DSN is required for DB_DRIVER=%s", dbDriver)
+		}
+	case driverMemory:
+	default:
+		return fmt.Errorf("DB_DRIVER must be %s, %s or %s, got %s", db.DriverSQLite, db.DriverPostgres, driverMemory, dbDriver)
+	}
+
+	// データベースの接続とコネクションプールの設定を取得
+	// 指定しない項目は db.DefaultOptions の値を使う
+	dbOptions, err := dbOptionsFromEnv()
+	if err != nil {
+		return err
+	}
+
 	// station4
 	// 環境変数からユーザーIDとパスワードを取得
 	userID := os.Getenv("BASIC_AUTH_USER_ID")
 	password := os.Getenv("BASIC_AUTH_PASSWORD")
 
-	// set time zone
-	var err error
-	time.Local, err = time.LoadLocation("Asia/Tokyo")
+	// リマインドの通知先 (log, webhook, file) と確認間隔を取得
+	// REMINDER_TARGET は webhook の場合は URL、file の場合はファイルのパス
+	notifier, err := reminder.NewNotifier(os.Getenv("REMINDER_NOTIFIER"), os.Getenv("REMINDER_TARGET"))
+	if err != nil {
+		return err
+	}
+	reminderInterval := defaultReminderInterval
+	if v := os.Getenv("REMINDER_INTERVAL"); v != "" {
+		reminderInterval, err = time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		if reminderInterval <= 0 {
+			return fmt.Errorf("REMINDER_INTERVAL must be positive, got %s", v)
+		}
+	}
+
+	// ゴミ箱の TODO を完全に削除するまでの保持期間と確認間隔を取得
+	trashRetention := defaultTrashRetention
+	if v := os.Getenv("TRASH_RETENTION"); v != "" {
+		trashRetention, err = time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		if trashRetention <= 0 {
+			return fmt.Errorf("TRASH_RETENTION must be positive, got %s", v)
+		}
+	}
+	purgeInterval := defaultPurgeInterval
+	if v := os.Getenv("TRASH_PURGE_INTERVAL"); v != "" {
+		purgeInterval, err = time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		if purgeInterval <= 0 {
+			return fmt.Errorf("TRASH_PURGE_INTERVAL must be positive, got %s", v)
+		}
+	}
+
+	// バックアップの保存先と残す数、定期的に作成する間隔を取得 (SQLite の場合のみ)
+	// BACKUP_INTERVAL を指定しない場合は定期的なバックアップを作成しない
+	backupDir := os.Getenv("BACKUP_DIR")
+	if backupDir == "" {
+		backupDir = defaultBackupDir
+	}
+	backupKeep := defaultBackupKeep
+	if v := os.Getenv("BACKUP_KEEP"); v != "" {
+		backupKeep, err = strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		if backupKeep < 0 {
+			return fmt.Errorf("BACKUP_KEEP must not be negative, got %s", v)
+		}
+	}
+	var backupInterval time.Duration
+	if v := os.Getenv("BACKUP_INTERVAL"); v != "" {
+		backupInterval, err = time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		if backupInterval <= 0 {
+			return fmt.Errorf("BACKUP_INTERVAL must be positive, got %s", v)
+		}
+		if dbDriver != db.DriverSQLite {
+			return fmt.Errorf("BACKUP_INTERVAL is only supported for DB_DRIVER=%s", db.DriverSQLite)
+		}
+	}
+	var backupManager *backup.Manager
+	if dbDriver == db.DriverSQLite {
+		backupManager = backup.NewManager(dbDSN, backupDir, backupKeep)
+	}
+
+	// アクセストークンに署名する鍵 (JWK Set のファイル) と、アクセストークン・セッションの有効期間を取得
+	// JWT_KEYS_FILE を指定しない場合は起動ごとに鍵を生成するため、再起動するとログインし直す必要がある
+	var jwtKeys *jwt.KeySet
+	if v := os.Getenv("JWT_KEYS_FILE"); v != "" {
+		jwtKeys, err = jwt.ReadKeySetFile(v)
+	} else {
+		log.Println("JWT_KEYS_FILE is not set, sessions are invalidated when the server restarts")
+		jwtKeys, err = jwt.GenerateKeySet()
+	}
 	if err != nil {
 		return err
 	}
+	accessTokenTTL := service.DefaultAccessTokenTTL
+	if v := os.Getenv("JWT_ACCESS_TTL"); v != "" {
+		accessTokenTTL, err = time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		if accessTokenTTL <= 0 {
+			return fmt.Errorf("JWT_ACCESS_TTL must be positive, got %s", v)
+		}
+	}
+	sessionTTL := service.DefaultSessionTTL
+	if v := os.Getenv("JWT_SESSION_TTL"); v != "" {
+		sessionTTL, err = time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		if sessionTTL < accessTokenTTL {
+			return fmt.Errorf("JWT_SESSION_TTL must not be shorter than JWT_ACCESS_TTL, got %s", v)
+		}
+	}
+
+	// Basic 認証で照合する htpasswd ファイルと、変更を確認する間隔を取得
+	// ファイルは変更されるか SIGHUP を受け取ると読み込み直すため、再起動せずに運用者を追加・削除できる
+	var credentials *htpasswd.File
+	if v := os.Getenv("BASIC_AUTH_HTPASSWD_FILE"); v != "" {
+		credentials, err = htpasswd.Open(v)
+		if err != nil {
+			return err
+		}
+		log.Printf("htpasswd: loaded %d users from %s", credentials.Len(), credentials.Path())
+	}
+	// htpasswd ファイルの運用者のうち、管理者にする名前を取得 (カンマ区切り)。指定しない場合、運用者は管理者にならない
+	htpasswdAdmins := map[string]bool{}
+	if v := os.Getenv("BASIC_AUTH_HTPASSWD_ADMINS"); v != "" {
+		if credentials == nil {
+			return errors.New("BASIC_AUTH_HTPASSWD_ADMINS requires BASIC_AUTH_HTPASSWD_FILE")
+		}
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				htpasswdAdmins[name] = true
+			}
+		}
+	}
+	htpasswdInterval := defaultHtpasswdInterval
+	if v := os.Getenv("BASIC_AUTH_HTPASSWD_INTERVAL"); v != "" {
+		htpasswdInterval, err = time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		if htpasswdInterval <= 0 {
+			return fmt.Errorf("BASIC_AUTH_HTPASSWD_INTERVAL must be positive, got %s", v)
+		}
+	}
 
-	// set up sqlite3
-	todoDB, err := db.NewDB(dbPath)
+	// set time zone
+	time.Local, err = time.LoadLocation("Asia/Tokyo")
 	if err != nil {
 		return err
 	}
-	defer todoDB.Close()
+
+	// migrate サブコマンドの場合は、マイグレーションだけを行って終了する
+	if isCommand("migrate") {
+		return runMigrate(context.Background(), dbDriver, dbDSN, os.Args[2:], os.Stdout)
+	}
+	// backup, restore サブコマンドの場合は、バックアップの作成・復元だけを行って終了する
+	if isCommand("backup") {
+		return runBackup(context.Background(), backupManager, os.Args[2:], os.Stdout)
+	}
+	if isCommand("restore") {
+		return runRestore(context.Background(), dbDriver, dbDSN, os.Args[2:], os.Stdout)
+	}
+
+	// 保存先を選ぶ。memory の場合はデータベースを使わず、終了するとデータは失われる
+	var services *router.Services
+	switch dbDriver {
+	case db.DriverSQLite, db.DriverPostgres:
+		// set up sqlite3 or postgres
+		// 複数のレプリカで 1 つのデータベースを共有する場合は postgres を使う
+		todoDB, err := db.NewDBWithOptions(dbDriver, dbDSN, dbOptions)
+		if err != nil {
+			return err
+		}
+		defer todoDB.Close()
+		// FTS5 なしでビルドすると、検索がすべての TODO を走査する部分一致になるため警告する
+		if dbDriver == db.DriverSQLite {
+			available, err := db.HasFTS5(todoDB)
+			if err != nil {
+				return err
+			}
+			if !available {
+				log.Printf("%s is built without FTS5, searching TODOs with LIKE; build the server with -tags sqlite_fts5 for full-text search", db.DriverSQLite)
+			}
+		}
+		services = &router.Services{
+			TODO:    service.NewTODOService(todoDB),
+			Tag:     service.NewTagService(todoDB),
+			List:    service.NewListService(todoDB),
+			User:    service.NewUserService(todoDB),
+			Token:   service.NewTokenService(todoDB),
+			Session: service.NewSessionService(todoDB, jwtKeys),
+			Backup:  backupManager,
+		}
+	case driverMemory:
+		store := service.NewMemoryStore()
+		services = &router.Services{
+			TODO:    service.NewTODOServiceWithRepository(store),
+			Tag:     service.NewTagServiceWithRepository(store),
+			List:    service.NewListServiceWithRepository(store),
+			User:    service.NewUserServiceWithRepository(store),
+			Token:   service.NewTokenServiceWithRepository(store),
+			Session: service.NewSessionServiceWithRepository(store, jwtKeys),
+		}
+	}
+	services.Session.AccessTokenTTL = accessTokenTTL
+	services.Session.SessionTTL = sessionTTL
+	services.Credentials = credentials
+	// htpasswd ファイルの運用者の名前は /signup で登録できないようにする
+	if credentials != nil {
+		services.User.Reserved = credentials.Has
+		services.User.Administrators = func(name string) bool { return htpasswdAdmins[name] }
+	}
 
 	// WaitGroupを作成
 	var wg sync.WaitGroup
 
 	// station4
-	// NOTE: 新しいエンドポイントの登録はrouter.NewRouterの内部で行うようにする
-	mux := router.NewRouter(todoDB, userID, password)
+	// NOTE: 新しいエンドポイントの登録はrouter.NewRouterWithServicesの内部で行うようにする
+	// BASIC_AUTH_USER_ID が指定された場合は、その利用者を用意して所有者のない TODO を引き継がせる
+	if userID != "" {
+		if err := router.Bootstrap(context.Background(), services, userID, password); err != nil {
+			return err
+		}
+	}
+	mux := router.NewRouterWithServices(services)
 
 	// HTTPサーバーを設定
 	srv := &http.Server{
@@ -83,6 +329,45 @@ func realMain() error {
 		}
 	}()
 
+	// リマインドのスケジューラを別のゴルーチンで起動
+	// シグナルを受け取ると ctx がキャンセルされ、スケジューラも停止する
+	scheduler := reminder.NewScheduler(services.TODO, notifier, reminderInterval)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scheduler.Run(ctx)
+	}()
+
+	// ゴミ箱の TODO を完全に削除するパージャを別のゴルーチンで起動
+	purger := trash.NewPurger(services.TODO, trashRetention, purgeInterval)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		purger.Run(ctx)
+	}()
+
+	// 定期的にバックアップを作成するスケジューラを別のゴルーチンで起動
+	if backupInterval > 0 {
+		backupScheduler := backup.NewScheduler(backupManager, backupInterval)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			backupScheduler.Run(ctx)
+		}()
+	}
+
+	// htpasswd ファイルの変更と SIGHUP を監視して読み込み直す
+	if credentials != nil {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer signal.Stop(hup)
+			credentials.Watch(ctx, htpasswdInterval, hup)
+		}()
+	}
+
 	// シグナルを待機
 	<-ctx.Done()
 	log.Println("Shutdown signal received")
@@ -102,3 +387,60 @@ func realMain() error {
 
 	return nil
 }
+
+// dbOptionsFromEnv は、db.DefaultOptions を環境変数で上書きした設定を返します。
+// DB_JOURNAL_MODE から DB_TX_LOCK までは SQLite の場合のみ使います。
+func dbOptionsFromEnv() (db.Options, error) {
+	opts := db.DefaultOptions()
+
+	if v := os.Getenv("DB_JOURNAL_MODE"); v != "" {
+		opts.JournalMode = v
+	}
+	if v := os.Getenv("DB_SYNCHRONOUS"); v != "" {
+		opts.Synchronous = v
+	}
+	if v := os.Getenv("DB_TX_LOCK"); v != "" {
+		opts.TxLock = v
+	}
+	if v := os.Getenv("DB_BUSY_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return db.Options{}, err
+		}
+		if d < 0 {
+			return db.Options{}, fmt.Errorf("DB_BUSY_TIMEOUT must not be negative, got %s", v)
+		}
+		opts.BusyTimeout = d
+	}
+	if v := os.Getenv("DB_FOREIGN_KEYS"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return db.Options{}, err
+		}
+		opts.ForeignKeys = b
+	}
+
+	if v := os.Getenv("DB_MAX_OPEN_CONNS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return db.Options{}, err
+		}
+		opts.MaxOpenConns = n
+	}
+	if v := os.Getenv("DB_MAX_IDLE_CONNS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return db.Options{}, err
+		}
+		opts.MaxIdleConns = n
+	}
+	if v := os.Getenv("DB_CONN_MAX_LIFETIME"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return db.Options{}, err
+		}
+		opts.ConnMaxLifetime = d
+	}
+
+	return opts, nil
+}
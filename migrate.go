@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/TechBowl-japan/go-stations/db"
+)
+
+// migrateUsage は migrate サブコマンドの使い方です。
+const migrateUsage = `usage: stations migrate <command> [flags]
+
+commands:
+  up     [-to version] [-dry-run]  apply pending migrations (all of them if -to is omitted)
+  down   [-steps n] [-dry-run]     roll back the last n applied migrations (default 1)
+  status                           show applied, pending and modified migrations
+`
+
+// runMigrate は migrate サブコマンドを実行します。args は "migrate" より後ろの引数です。
+func runMigrate(ctx context.Context, driver, dsn string, args []string, out io.Writer) error {
+	if driver == driverMemory {
+		return fmt.Errorf("migrate: DB_DRIVER=%s has no schema to migrate", driver)
+	}
+	if len(args) == 0 {
+		fmt.Fprint(out, migrateUsage)
+		return fmt.Errorf("migrate: command is required")
+	}
+
+	command := args[0]
+	flags := flag.NewFlagSet("migrate "+command, flag.ContinueOnError)
+	flags.SetOutput(out)
+	dryRun := flags.Bool("dry-run", false, "run the migrations in a transaction and roll it back")
+	to := flags.Int("to", 0, "apply migrations up to and including this version")
+	steps := flags.Int("steps", 1, "number of migrations to roll back")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	// マイグレーションを適用せずに開く
+	todoDB, err := db.OpenWithDriver(driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer todoDB.Close()
+
+	migrator, err := db.NewMigrator(todoDB)
+	if err != nil {
+		return err
+	}
+	migrator.DryRun = *dryRun
+	migrator.Log = out
+	if *dryRun {
+		fmt.Fprintln(out, "dry run: no changes will be made")
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx, *to)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%d migrations applied\n", len(applied))
+	case "down":
+		if *steps <= 0 {
+			return fmt.Errorf("migrate: -steps must be positive, got %d", *steps)
+		}
+		rolledBack, err := migrator.Down(ctx, *steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%d migrations rolled back\n", len(rolledBack))
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, appliedAt := "pending", ""
+			if s.Applied {
+				state, appliedAt = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Modified {
+				state = "modified"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		return w.Flush()
+	default:
+		fmt.Fprint(out, migrateUsage)
+		return fmt.Errorf("migrate: unknown command %q", command)
+	}
+	return nil
+}
+
+// isCommand は、コマンドライン引数が name のサブコマンドかを返します。
+func isCommand(name string) bool {
+	return len(os.Args) > 1 && os.Args[1] == name
+}
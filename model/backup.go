@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+)
+
+type (
+	// A Backup expresses a snapshot file of the database in the backup directory.
+	Backup struct {
+		Name      string    `json:"name"`
+		Size      int64     `json:"size"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// A CreateBackupResponse expresses ...
+	CreateBackupResponse struct {
+		Backup *Backup `json:"backup"`
+	}
+
+	// A ReadBackupResponse expresses ...
+	ReadBackupResponse struct {
+		Backups []*Backup `json:"backups"`
+	}
+)
@@ -12,7 +12,101 @@ func (e ErrNotFound) Error() string {
 	return "the requested resource was not found"
 }
 
+// IsErrNotFound は err が ErrNotFound または *ErrNotFound を含むかを返します。
 func IsErrNotFound(err error) bool {
 	var notFoundErr ErrNotFound
-	return errors.As(err, &notFoundErr)
-}
\ No newline at end of file
+	var notFoundPtr *ErrNotFound
+	return errors.As(err, &notFoundErr) || errors.As(err, &notFoundPtr)
+}
+
+// ErrConflict は、リソースの現在の状態と矛盾する操作を行った場合のエラーを表します。
+type ErrConflict struct {
+	Message string
+}
+
+// Error メソッドを実装し、error インターフェースを満たします。
+func (e ErrConflict) Error() string {
+	return e.Message
+}
+
+// IsErrConflict は err が ErrConflict または *ErrConflict を含むかを返します。
+func IsErrConflict(err error) bool {
+	var conflictErr ErrConflict
+	var conflictPtr *ErrConflict
+	return errors.As(err, &conflictErr) || errors.As(err, &conflictPtr)
+}
+
+// ErrValidation は、リクエストの値が不正な場合のエラーを表します。
+// Field は不正なフィールドやクエリパラメータの名前で、特定のフィールドによらない場合は空です。
+type ErrValidation struct {
+	Field   string
+	Message string
+}
+
+// Error メソッドを実装し、error インターフェースを満たします。
+func (e ErrValidation) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// AsErrValidation は err が ErrValidation または *ErrValidation を含む場合に、その ErrValidation を返します。
+func AsErrValidation(err error) (*ErrValidation, bool) {
+	var validationErr ErrValidation
+	if errors.As(err, &validationErr) {
+		return &validationErr, true
+	}
+	var validationPtr *ErrValidation
+	if errors.As(err, &validationPtr) {
+		return validationPtr, true
+	}
+	return nil, false
+}
+
+// IsErrValidation は err が ErrValidation または *ErrValidation を含むかを返します。
+func IsErrValidation(err error) bool {
+	var validationErr ErrValidation
+	var validationPtr *ErrValidation
+	return errors.As(err, &validationErr) || errors.As(err, &validationPtr)
+}
+
+// ErrUnauthorized は、認証情報がないか正しくない場合のエラーを表します。
+type ErrUnauthorized struct {
+	Message string
+}
+
+// Error メソッドを実装し、error インターフェースを満たします。
+func (e ErrUnauthorized) Error() string {
+	if e.Message == "" {
+		return "authentication is required"
+	}
+	return e.Message
+}
+
+// IsErrUnauthorized は err が ErrUnauthorized または *ErrUnauthorized を含むかを返します。
+func IsErrUnauthorized(err error) bool {
+	var unauthorizedErr ErrUnauthorized
+	var unauthorizedPtr *ErrUnauthorized
+	return errors.As(err, &unauthorizedErr) || errors.As(err, &unauthorizedPtr)
+}
+
+// ErrForbidden は、認証された利用者やトークンに操作の権限がない場合のエラーを表します。
+type ErrForbidden struct {
+	Message string
+}
+
+// Error メソッドを実装し、error インターフェースを満たします。
+func (e ErrForbidden) Error() string {
+	if e.Message == "" {
+		return "the operation is not permitted"
+	}
+	return e.Message
+}
+
+// IsErrForbidden は err が ErrForbidden または *ErrForbidden を含むかを返します。
+func IsErrForbidden(err error) bool {
+	var forbiddenErr ErrForbidden
+	var forbiddenPtr *ErrForbidden
+	return errors.As(err, &forbiddenErr) || errors.As(err, &forbiddenPtr)
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"time"
+)
+
+// リストでの利用者の役割です。
+// 所有者はリストとその TODO を変更・削除・共有でき、編集者はリストとその TODO を変更でき、閲覧者は読むことだけができます。
+const (
+	ListRoleOwner  = "owner"
+	ListRoleEditor = "editor"
+	ListRoleViewer = "viewer"
+)
+
+type (
+	// A List expresses a project that groups TODOs.
+	List struct {
+		ID          int64      `json:"id"`
+		Name        string     `json:"name"`
+		Description string     `json:"description"`
+		Archived    bool       `json:"archived"`
+		ArchivedAt  *time.Time `json:"archived_at"`
+		TODOCount   int64      `json:"todo_count"`
+		// Role はリクエストした利用者のリストでの役割です。
+		Role      string    `json:"role,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	// A ListMember expresses a user who can access a list and the role of the user.
+	ListMember struct {
+		UserID int64  `json:"user_id"`
+		Name   string `json:"name"`
+		Role   string `json:"role"`
+		// CreatedAt は共有した日時です。所有者の場合はリストを作成した日時です。
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// A CreateListRequest expresses ...
+	CreateListRequest struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	// A CreateListResponse expresses ...
+	CreateListResponse struct {
+		List *List `json:"list"`
+	}
+
+	// A ReadListResponse expresses ...
+	ReadListResponse struct {
+		Lists []*List `json:"lists"`
+	}
+
+	// A UpdateListRequest expresses ...
+	UpdateListRequest struct {
+		ID          int64  `json:"id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Archived    *bool  `json:"archived"`
+	}
+	// A UpdateListResponse expresses ...
+	UpdateListResponse struct {
+		List *List `json:"list"`
+	}
+
+	// A DeleteListRequest expresses ...
+	DeleteListRequest struct {
+		IDs []int64 `json:"ids"`
+	}
+	// A DeleteListResponse expresses ...
+	DeleteListResponse struct{}
+
+	// A ReadListMembersResponse expresses ...
+	ReadListMembersResponse struct {
+		Members []*ListMember `json:"members"`
+	}
+
+	// A ShareListRequest expresses a request to share a list with a user, or to change the role of the user.
+	ShareListRequest struct {
+		Name string `json:"name"`
+		Role string `json:"role"`
+	}
+	// A ShareListResponse expresses ...
+	ShareListResponse struct {
+		Member *ListMember `json:"member"`
+	}
+
+	// A UnshareListRequest expresses ...
+	UnshareListRequest struct {
+		Name string `json:"name"`
+	}
+	// A UnshareListResponse expresses ...
+	UnshareListResponse struct{}
+
+	// A MoveTODORequest expresses a request to move TODOs to a list.
+	// A null ListID moves them out of any list.
+	MoveTODORequest struct {
+		IDs    []int64   `json:"ids"`
+		ListID NullInt64 `json:"list_id"`
+	}
+	// A MoveTODOResponse expresses ...
+	MoveTODOResponse struct {
+		TODOs []*TODO `json:"todos"`
+	}
+)
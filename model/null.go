@@ -0,0 +1,85 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// A NullTime is a time.Time that may be null in JSON.
+// Set reports whether the field was present in the JSON object, so that an
+// omitted field ("unchanged") can be told apart from an explicit null ("clear").
+type NullTime struct {
+	Time  time.Time
+	Valid bool
+	Set   bool
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *NullTime) UnmarshalJSON(b []byte) error {
+	t.Set = true
+	if string(b) == "null" {
+		t.Time, t.Valid = time.Time{}, false
+		return nil
+	}
+	if err := json.Unmarshal(b, &t.Time); err != nil {
+		return err
+	}
+	t.Valid = true
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t NullTime) MarshalJSON() ([]byte, error) {
+	if !t.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.Time)
+}
+
+// Ptr は Valid な場合に時刻へのポインタを、そうでない場合に nil を返します。
+func (t NullTime) Ptr() *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	tm := t.Time
+	return &tm
+}
+
+// A NullInt64 is an int64 that may be null in JSON.
+// Set reports whether the field was present in the JSON object.
+type NullInt64 struct {
+	Int64 int64
+	Valid bool
+	Set   bool
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *NullInt64) UnmarshalJSON(b []byte) error {
+	n.Set = true
+	if string(b) == "null" {
+		n.Int64, n.Valid = 0, false
+		return nil
+	}
+	if err := json.Unmarshal(b, &n.Int64); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (n NullInt64) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(n.Int64)
+}
+
+// Ptr は Valid な場合に値へのポインタを、そうでない場合に nil を返します。
+func (n NullInt64) Ptr() *int64 {
+	if !n.Valid {
+		return nil
+	}
+	v := n.Int64
+	return &v
+}
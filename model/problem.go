@@ -0,0 +1,33 @@
+package model
+
+// エラーのレスポンスの problem の type です。
+// ドメインのエラーに対応しない problem は、HTTP のステータスコード以上の意味を持たない about:blank です。
+const (
+	ProblemTypeBlank              = "about:blank"
+	ProblemTypeValidation         = "/problems/validation"
+	ProblemTypeNotFound           = "/problems/not-found"
+	ProblemTypeConflict           = "/problems/conflict"
+	ProblemTypeUnauthorized       = "/problems/unauthorized"
+	ProblemTypeForbidden          = "/problems/forbidden"
+	ProblemTypePreconditionFailed = "/problems/precondition-failed"
+)
+
+type (
+	// A Problem expresses an error response in RFC 7807 Problem Details for HTTP APIs.
+	Problem struct {
+		Type   string `json:"type"`
+		Title  string `json:"title"`
+		Status int    `json:"status"`
+		Detail string `json:"detail,omitempty"`
+		// Errors は、リクエストの値が不正な場合の、フィールドごとの詳細です。
+		Errors []*FieldError `json:"errors,omitempty"`
+		// TODO は、TODO が指定された version から変更されていた場合の、現在の TODO です。
+		TODO *TODO `json:"todo,omitempty"`
+	}
+
+	// A FieldError expresses why the value of a field or a query parameter is invalid.
+	FieldError struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	}
+)
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+)
+
+// TODO の変更履歴の操作です。
+const (
+	RevisionActionCreate  = "create"
+	RevisionActionUpdate  = "update"
+	RevisionActionDelete  = "delete"
+	RevisionActionRestore = "restore"
+	RevisionActionRevert  = "revert"
+)
+
+type (
+	// A Revision expresses the state of a TODO right after a change.
+	// The state before the change is the previous revision.
+	Revision struct {
+		ID          int64      `json:"id"`
+		TODOID      int64      `json:"todo_id"`
+		Revision    int64      `json:"revision"`
+		Action      string     `json:"action"`
+		Actor       string     `json:"actor"`
+		Subject     string     `json:"subject"`
+		Description string     `json:"description"`
+		Done        bool       `json:"done"`
+		CompletedAt *time.Time `json:"completed_at"`
+		DueAt       *time.Time `json:"due_at"`
+		Priority    int        `json:"priority"`
+		CreatedAt   time.Time  `json:"created_at"`
+	}
+
+	// A RevisionChange expresses a field whose value differs between two revisions.
+	RevisionChange struct {
+		Field string      `json:"field"`
+		Old   interface{} `json:"old"`
+		New   interface{} `json:"new"`
+	}
+
+	// A ReadRevisionResponse expresses ...
+	ReadRevisionResponse struct {
+		Revisions []*Revision `json:"revisions"`
+	}
+
+	// A DiffRevisionResponse expresses ...
+	DiffRevisionResponse struct {
+		From    int64             `json:"from"`
+		To      int64             `json:"to"`
+		Changes []*RevisionChange `json:"changes"`
+	}
+
+	// A RevertTODORequest expresses ...
+	RevertTODORequest struct {
+		ID       int64 `json:"id"`
+		Revision int64 `json:"revision"`
+	}
+	// A RevertTODOResponse expresses ...
+	RevertTODOResponse struct {
+		TODO *TODO `json:"todo"`
+	}
+)
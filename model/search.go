@@ -0,0 +1,28 @@
+package model
+
+type (
+	// A SearchTODORequest expresses ...
+	SearchTODORequest struct {
+		Query  string `json:"q"`
+		Size   int64  `json:"size"`
+		Offset int64  `json:"offset"`
+	}
+	// A SearchTODOResponse expresses ...
+	SearchTODOResponse struct {
+		Results []*SearchTODOResult `json:"results"`
+	}
+
+	// A SearchTODOResult expresses a TODO that matched the query, ordered by Score.
+	SearchTODOResult struct {
+		TODO      *TODO         `json:"todo"`
+		Score     float64       `json:"score"`
+		Highlight TODOHighlight `json:"highlight"`
+	}
+
+	// A TODOHighlight holds HTML-escaped text in which the matched terms are
+	// wrapped with <mark> and </mark>. Description is a snippet around the matches.
+	TODOHighlight struct {
+		Subject     string `json:"subject"`
+		Description string `json:"description"`
+	}
+)
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+)
+
+// TokenTypeBearer は、アクセストークンを Authorization ヘッダーで送るときのスキームです。
+const TokenTypeBearer = "Bearer"
+
+type (
+	// A Session expresses a login of a user. Its refresh token is rotated on every refresh.
+	Session struct {
+		ID        int64      `json:"id"`
+		UserID    int64      `json:"-"`
+		ExpiresAt time.Time  `json:"expires_at"`
+		RevokedAt *time.Time `json:"revoked_at"`
+		CreatedAt time.Time  `json:"created_at"`
+	}
+
+	// A SessionTokens expresses the access token and the refresh token of a session.
+	SessionTokens struct {
+		// AccessToken は署名された JWT です。有効期間は短く、期限が切れたら RefreshToken で再発行します。
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		// ExpiresIn はアクセストークンの有効期間の秒数です。
+		ExpiresIn    int64  `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	// A RefreshRequest expresses ...
+	RefreshRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// A RefreshResponse expresses ...
+	RefreshResponse struct {
+		User *User `json:"user"`
+		SessionTokens
+	}
+
+	// A LogoutRequest expresses ...
+	LogoutRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// A LogoutResponse expresses ...
+	LogoutResponse struct{}
+)
@@ -0,0 +1,41 @@
+package model
+
+// TODO をタグで絞り込む際の条件の結合方法です。
+const (
+	TagModeAnd = "and"
+	TagModeOr  = "or"
+)
+
+type (
+	// A Tag expresses a label attached to TODOs.
+	Tag struct {
+		ID        int64  `json:"id"`
+		Name      string `json:"name"`
+		TODOCount int64  `json:"todo_count"`
+	}
+
+	// A ReadTagResponse expresses ...
+	ReadTagResponse struct {
+		Tags []*Tag `json:"tags"`
+	}
+
+	// A UpdateTagRequest expresses a request to rename a tag.
+	UpdateTagRequest struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+	// A UpdateTagResponse expresses ...
+	UpdateTagResponse struct {
+		Tag *Tag `json:"tag"`
+	}
+
+	// A MergeTagRequest expresses a request to merge tags into the target tag.
+	MergeTagRequest struct {
+		SourceIDs []int64 `json:"source_ids"`
+		TargetID  int64   `json:"target_id"`
+	}
+	// A MergeTagResponse expresses ...
+	MergeTagResponse struct {
+		Tag *Tag `json:"tag"`
+	}
+)
@@ -1,23 +1,101 @@
 package model
 
 import (
-    "time"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// TODO の完了状態による絞り込み条件です。
+const (
+	TODOStatusAll    = "all"
+	TODOStatusDone   = "done"
+	TODOStatusUndone = "undone"
+)
+
+// TODO の優先度です。
+const (
+	PriorityNone   = 0
+	PriorityLow    = 1
+	PriorityMedium = 2
+	PriorityHigh   = 3
+)
+
+// GET /todos のソートキーと並び順です。
+const (
+	TODOSortID        = "id"
+	TODOSortPriority  = "priority"
+	TODOSortDueAt     = "due_at"
+	TODOSortCreatedAt = "created_at"
+	TODOSortUpdatedAt = "updated_at"
+	TODOSortSubject   = "subject"
+
+	SortOrderAsc  = "asc"
+	SortOrderDesc = "desc"
+)
+
+// 子を持つ TODO を削除する際の子の扱いです。
+const (
+	// DeleteChildrenReparent は子を削除する TODO の親に付け替えます。
+	DeleteChildrenReparent = "reparent"
+	// DeleteChildrenCascade は子孫もすべて削除します。
+	DeleteChildrenCascade = "cascade"
+)
+
+// 一括作成・一括更新のモードです。
+const (
+	// BulkModeAtomic は、1 件でも失敗するとすべての項目を取り消します。
+	BulkModeAtomic = "atomic"
+	// BulkModeBestEffort は、失敗した項目だけを取り消して残りを適用します。
+	BulkModeBestEffort = "best_effort"
 )
 
 type (
 	// A TODO expresses ...
 	TODO struct {
-		ID          int64     `json:"id"`
-		Subject     string    `json:"subject"`
-		Description string    `json:"description"`
-		CreatedAt   time.Time `json:"created_at"`
-		UpdatedAt   time.Time `json:"updated_at"`
+		ID          int64        `json:"id"`
+		Subject     string       `json:"subject"`
+		Description string       `json:"description"`
+		Done        bool         `json:"done"`
+		CompletedAt *time.Time   `json:"completed_at"`
+		DueAt       *time.Time   `json:"due_at"`
+		Priority    int          `json:"priority"`
+		Tags        []string     `json:"tags"`
+		ParentID    *int64       `json:"parent_id"`
+		Progress    TODOProgress `json:"progress"`
+		Recurrence  string       `json:"recurrence"`
+		Occurrence  int          `json:"occurrence"`
+		PreviousID  *int64       `json:"previous_id"`
+		NextID      *int64       `json:"next_id"`
+		ListID      *int64       `json:"list_id"`
+		DeletedAt   *time.Time   `json:"deleted_at"`
+		Version     int64        `json:"version"`
+		CreatedAt   time.Time    `json:"created_at"`
+		UpdatedAt   time.Time    `json:"updated_at"`
+	}
+
+	// A TODOProgress expresses how many of the direct children of a TODO are done.
+	TODOProgress struct {
+		Done  int64 `json:"done"`
+		Total int64 `json:"total"`
+	}
+
+	// A TODONode expresses a TODO with its descendants.
+	TODONode struct {
+		*TODO
+		Children []*TODONode `json:"children"`
 	}
 
 	// A CreateTODORequest expresses ...
 	CreateTODORequest struct {
-		Subject     string `json:"subject"`
-		Description string `json:"description"`
+		Subject     string     `json:"subject"`
+		Description string     `json:"description"`
+		DueAt       *time.Time `json:"due_at"`
+		Priority    int        `json:"priority"`
+		Tags        []string   `json:"tags"`
+		ParentID    *int64     `json:"parent_id"`
+		Recurrence  string     `json:"recurrence"`
+		ListID      *int64     `json:"list_id"`
 	}
 	// A CreateTODOResponse expresses ...
 	CreateTODOResponse struct {
@@ -26,29 +104,156 @@ type (
 
 	// A ReadTODORequest expresses ...
 	ReadTODORequest struct {
-		PrevID int64 `json:"prev_id"`
-		Size   int64 `json:"size"`
+		PrevID    int64      `json:"prev_id"`
+		Size      int64      `json:"size"`
+		Status    string     `json:"status"`
+		Overdue   bool       `json:"overdue"`
+		DueBefore *time.Time `json:"due_before"`
+		DueAfter  *time.Time `json:"due_after"`
+		Sort      string     `json:"sort"`
+		Order     string     `json:"order"`
+		Cursor    string     `json:"cursor"`
+		Tags      []string   `json:"tags"`
+		TagMode   string     `json:"tag_mode"`
+		ListID    *int64     `json:"list_id"`
 	}
 	// A ReadTODOResponse expresses ...
 	ReadTODOResponse struct {
-		TODOs []*TODO `json:"todos"`
+		TODOs      []*TODO `json:"todos"`
+		NextCursor string  `json:"next_cursor,omitempty"`
+	}
+
+	// A ReadTODOByIDResponse expresses ...
+	ReadTODOByIDResponse struct {
+		TODO *TODO `json:"todo"`
 	}
 
 	// A UpdateTODORequest expresses ...
 	UpdateTODORequest struct {
-		ID          int64  `json:"id"`
-		Subject     string `json:"subject"`
-		Description string `json:"description"`
+		ID          int64     `json:"id"`
+		Subject     string    `json:"subject"`
+		Description string    `json:"description"`
+		Done        *bool     `json:"done"`
+		DueAt       NullTime  `json:"due_at"`
+		Priority    *int      `json:"priority"`
+		Tags        []string  `json:"tags"`
+		ParentID    NullInt64 `json:"parent_id"`
+		Recurrence  *string   `json:"recurrence"`
+		ListID      NullInt64 `json:"list_id"`
 	}
 	// A UpdateTODOResponse expresses ...
 	UpdateTODOResponse struct {
 		TODO *TODO `json:"todo"`
 	}
 
+	// A BulkCreateTODORequest expresses ...
+	BulkCreateTODORequest struct {
+		TODOs []*CreateTODORequest `json:"todos"`
+		Mode  string               `json:"mode"`
+	}
+	// A BulkUpdateTODORequest expresses ...
+	BulkUpdateTODORequest struct {
+		TODOs []*BulkUpdateTODOItem `json:"todos"`
+		Mode  string                `json:"mode"`
+	}
+	// A BulkUpdateTODOItem is an UpdateTODORequest with the version that the TODO must have,
+	// which replaces If-Match for each item.
+	BulkUpdateTODOItem struct {
+		UpdateTODORequest
+		Version *int64 `json:"version"`
+	}
+	// A BulkTODOResponse expresses ...
+	BulkTODOResponse struct {
+		Results []*BulkTODOResult `json:"results"`
+	}
+	// A BulkTODOResult is the result of one item of a bulk request, in the same order as the request.
+	// 失敗した項目は Error を持ちます。version が一致しなかった場合の TODO は現在の状態です。
+	BulkTODOResult struct {
+		Index  int    `json:"index"`
+		Status int    `json:"status"`
+		TODO   *TODO  `json:"todo,omitempty"`
+		Error  string `json:"error,omitempty"`
+	}
+
 	// A DeleteTODORequest expresses ...
 	DeleteTODORequest struct {
-		IDs []int64 `json:"ids"`
+		IDs      []int64 `json:"ids"`
+		Children string  `json:"children"`
 	}
 	// A DeleteTODOResponse expresses ...
 	DeleteTODOResponse struct{}
+
+	// A ReadTrashResponse expresses ...
+	ReadTrashResponse struct {
+		TODOs []*TODO `json:"todos"`
+	}
+
+	// A RestoreTODORequest expresses ...
+	RestoreTODORequest struct {
+		IDs []int64 `json:"ids"`
+	}
+	// A RestoreTODOResponse expresses ...
+	RestoreTODOResponse struct {
+		TODOs []*TODO `json:"todos"`
+	}
+
+	// A ReadTODOChildrenResponse expresses ...
+	ReadTODOChildrenResponse struct {
+		TODOs []*TODO `json:"todos"`
+	}
+
+	// A ReadTODOTreeResponse expresses ...
+	ReadTODOTreeResponse struct {
+		Tree *TODONode `json:"tree"`
+	}
 )
+
+// todoJSON は TODO と同じフィールドを持ち、MarshalJSON を持たない型です。
+type todoJSON TODO
+
+// jsonValue は、タグのない TODO の Tags を空スライスにした todoJSON を返します。
+func (t TODO) jsonValue() todoJSON {
+	v := todoJSON(t)
+	if v.Tags == nil {
+		v.Tags = []string{}
+	}
+	return v
+}
+
+// MarshalJSON implements json.Marshaler.
+// タグのない TODO の Tags は nil ですが、tags は null ではなく [] として書き込みます。
+func (t TODO) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.jsonValue())
+}
+
+// MarshalJSON implements json.Marshaler.
+// 埋め込んだ TODO の MarshalJSON は children を書き込まないため、TODO のフィールドと children を並べて書き込みます。
+func (n TODONode) MarshalJSON() ([]byte, error) {
+	var todo *todoJSON
+	if n.TODO != nil {
+		v := n.TODO.jsonValue()
+		todo = &v
+	}
+	return json.Marshal(struct {
+		*todoJSON
+		Children []*TODONode `json:"children"`
+	}{todo, n.Children})
+}
+
+// ErrPreconditionFailed は、TODO が指定された version から変更されていた場合のエラーを表します。
+type ErrPreconditionFailed struct {
+	// Current は TODO の現在の状態です。
+	Current *TODO
+}
+
+// Error メソッドを実装し、error インターフェースを満たします。
+func (e ErrPreconditionFailed) Error() string {
+	return "the TODO has been modified by someone else"
+}
+
+// IsErrPreconditionFailed は err が ErrPreconditionFailed または *ErrPreconditionFailed を含むかを返します。
+func IsErrPreconditionFailed(err error) bool {
+	var preconditionErr ErrPreconditionFailed
+	var preconditionPtr *ErrPreconditionFailed
+	return errors.As(err, &preconditionErr) || errors.As(err, &preconditionPtr)
+}
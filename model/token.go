@@ -0,0 +1,63 @@
+package model
+
+import (
+	"time"
+)
+
+// API トークンに付与できるスコープです。ScopeAdmin はすべてのスコープを含みます。
+const (
+	ScopeTODOsRead  = "todos:read"
+	ScopeTODOsWrite = "todos:write"
+	ScopeAdmin      = "admin"
+)
+
+// Scopes は、API トークンに付与できるスコープの一覧です。
+var Scopes = []string{ScopeTODOsRead, ScopeTODOsWrite, ScopeAdmin}
+
+type (
+	// An APIToken expresses a personal access token that authenticates requests as its user.
+	APIToken struct {
+		ID         int64      `json:"id"`
+		UserID     int64      `json:"-"`
+		Name       string     `json:"name"`
+		Scopes     []string   `json:"scopes"`
+		ExpiresAt  *time.Time `json:"expires_at"`
+		LastUsedAt *time.Time `json:"last_used_at"`
+		RevokedAt  *time.Time `json:"revoked_at"`
+		CreatedAt  time.Time  `json:"created_at"`
+	}
+
+	// A CreateTokenRequest expresses ...
+	CreateTokenRequest struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+		// ExpiresAt を省略した場合、トークンは取り消すまで有効です。
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	// A CreateTokenResponse expresses ...
+	CreateTokenResponse struct {
+		Token *APIToken `json:"token"`
+		// Secret は Bearer 認証に使うトークンです。保存していないため、作成時にしか返せません。
+		Secret string `json:"secret"`
+	}
+
+	// A ReadTokensResponse expresses ...
+	ReadTokensResponse struct {
+		Tokens []*APIToken `json:"tokens"`
+	}
+
+	// A RevokeTokenResponse expresses ...
+	RevokeTokenResponse struct {
+		Token *APIToken `json:"token"`
+	}
+)
+
+// HasScope は、トークンが scope を持つかを返します。ScopeAdmin を持つトークンはすべてのスコープを持ちます。
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
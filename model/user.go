@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+)
+
+type (
+	// A User expresses an account that owns TODOs.
+	User struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+		// PasswordHash はパスワードの bcrypt のハッシュです。レスポンスには含めません。
+		PasswordHash string `json:"-"`
+		// Admin は、バックアップなどの管理用のエンドポイントを使い、admin スコープの API トークンを発行できるかを表します。
+		Admin     bool      `json:"admin"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	// A SignupRequest expresses ...
+	SignupRequest struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	// A SignupResponse expresses ...
+	SignupResponse struct {
+		User *User `json:"user"`
+	}
+
+	// A LoginRequest expresses ...
+	LoginRequest struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	// A LoginResponse expresses ...
+	LoginResponse struct {
+		User *User `json:"user"`
+		SessionTokens
+	}
+)
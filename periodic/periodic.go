@@ -0,0 +1,34 @@
+// Package periodic は、一定の間隔で処理を繰り返すバックグラウンドのジョブを実行します。
+package periodic
+
+import (
+	"context"
+	"time"
+)
+
+// Run は ctx がキャンセルされるまで interval ごとに tick を呼び出します。
+// 最初の呼び出しは interval が経過してからです。tick は並行には呼び出さず、
+// tick の実行中に経過した interval は time.Ticker と同じく 1 回にまとめます。
+func Run(ctx context.Context, interval time.Duration, tick func(context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		tick(ctx)
+	}
+}
+
+// RunNow は tick をすぐに 1 回呼び出し、その後は Run と同じく interval ごとに呼び出します。
+func RunNow(ctx context.Context, interval time.Duration, tick func(context.Context)) {
+	tick(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+	Run(ctx, interval, tick)
+}
@@ -0,0 +1,74 @@
+package periodic_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/periodic"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		run       func(context.Context, time.Duration, func(context.Context))
+		immediate bool
+	}{
+		"Run":    {run: periodic.Run},
+		"RunNow": {run: periodic.RunNow, immediate: true},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var count int32
+			ticked := make(chan struct{}, 10)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				c.run(ctx, 50*time.Millisecond, func(context.Context) {
+					atomic.AddInt32(&count, 1)
+					ticked <- struct{}{}
+				})
+			}()
+
+			// RunNow は interval を待たずに呼び出す
+			select {
+			case <-ticked:
+				if !c.immediate {
+					t.Error("tick should not be called before the first interval")
+				}
+			case <-time.After(20 * time.Millisecond):
+				if c.immediate {
+					t.Error("tick should be called immediately")
+				}
+			}
+
+			for i := 0; i < 2; i++ {
+				select {
+				case <-ticked:
+				case <-time.After(time.Second):
+					t.Fatal("tick should be called every interval")
+				}
+			}
+
+			cancel()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("Run should return when ctx is canceled")
+			}
+			given := atomic.LoadInt32(&count)
+			time.Sleep(100 * time.Millisecond)
+			if atomic.LoadInt32(&count) != given {
+				t.Error("tick should not be called after Run returned")
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+// Package recurrence implements the subset of RFC 5545 recurrence rules (RRULE)
+// used by recurring TODOs.
+//
+// サポートする規則は次のとおりです。
+//
+//	FREQ=DAILY|WEEKLY|MONTHLY|YEARLY (必須)
+//	INTERVAL=n
+//	COUNT=n
+//	UNTIL=YYYYMMDD または YYYYMMDDTHHMMSSZ
+//	BYDAY=MO,TU,...  (DAILY, WEEKLY では曜日、MONTHLY では 1MO や -1FR のように第 n 曜日も指定可)
+//	BYMONTHDAY=1,15,-1 (MONTHLY のみ。負の値は月末から数えます)
+//
+// WKST は常に MO として扱います。また、"daily", "weekly", "monthly", "yearly" を
+// それぞれ FREQ のみの規則の省略形として受け付けます。
+package recurrence
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Frequency is the FREQ part of a rule.
+type Frequency string
+
+// サポートする FREQ です。
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods は、次の発生日時を探す際に調べる期間 (日、週、月、年) の上限です。
+// BYMONTHDAY=31 と INTERVAL の組み合わせなどで発生日時が見つからない規則でも終了するようにします。
+const maxPeriods = 1000
+
+// A WeekdayNum is a BYDAY entry. N is the ordinal within the month (0 means every such weekday).
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+// A Rule is a parsed recurrence rule.
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+var weekdayCodes = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Parse parses a rule such as "FREQ=WEEKLY;BYDAY=MO,WE" or one of the shorthands.
+// A leading "RRULE:" is ignored. UNTIL without a time is interpreted in loc.
+func Parse(s string, loc *time.Location) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(s) {
+	case "daily", "weekly", "monthly", "yearly":
+		return &Rule{Freq: Frequency(strings.ToUpper(s)), Interval: 1}, nil
+	}
+	s = strings.TrimPrefix(strings.ToUpper(s), "RRULE:")
+
+	r := &Rule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("recurrence: malformed part %q", part)
+		}
+		key, value := kv[0], kv[1]
+		if seen[key] {
+			return nil, fmt.Errorf("recurrence: duplicated %s", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch f := Frequency(value); f {
+			case Daily, Weekly, Monthly, Yearly:
+				r.Freq = f
+			default:
+				err = fmt.Errorf("recurrence: unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = parsePositive(key, value)
+		case "COUNT":
+			r.Count, err = parsePositive(key, value)
+		case "UNTIL":
+			r.Until, err = parseUntil(value, loc)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseByMonthDay(value)
+		case "WKST":
+			if value != "MO" {
+				err = fmt.Errorf("recurrence: only WKST=MO is supported")
+			}
+		default:
+			err = fmt.Errorf("recurrence: unsupported part %s", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("recurrence: FREQ is required")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, fmt.Errorf("recurrence: COUNT and UNTIL can't be used together")
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq != Monthly {
+		return nil, fmt.Errorf("recurrence: BYMONTHDAY is only supported with FREQ=MONTHLY")
+	}
+	if len(r.ByDay) > 0 {
+		if r.Freq == Yearly {
+			return nil, fmt.Errorf("recurrence: BYDAY is not supported with FREQ=YEARLY")
+		}
+		for _, wd := range r.ByDay {
+			if wd.N != 0 && r.Freq != Monthly {
+				return nil, fmt.Errorf("recurrence: ordinal BYDAY is only supported with FREQ=MONTHLY")
+			}
+		}
+	}
+
+	return r, nil
+}
+
+func parsePositive(key, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("recurrence: %s must be a positive integer", key)
+	}
+	return n, nil
+}
+
+func parseUntil(value string, loc *time.Location) (*time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("20060102", value, loc)
+	if err != nil {
+		return nil, fmt.Errorf("recurrence: UNTIL must be YYYYMMDD or YYYYMMDDTHHMMSSZ")
+	}
+	// 日付のみの場合はその日の終わりまでを含める
+	end := t.AddDate(0, 0, 1).Add(-time.Second)
+	return &end, nil
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("recurrence: malformed BYDAY %q", item)
+		}
+		day, ok := weekdayNames[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("recurrence: malformed BYDAY %q", item)
+		}
+		wd := WeekdayNum{Day: day}
+		if ord := item[:len(item)-2]; ord != "" {
+			n, err := strconv.Atoi(ord)
+			if err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("recurrence: malformed BYDAY %q", item)
+			}
+			wd.N = n
+		}
+		days = append(days, wd)
+	}
+	return days, nil
+}
+
+func parseByMonthDay(value string) ([]int, error) {
+	var days []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil || n == 0 || n < -31 || n > 31 {
+			return nil, fmt.Errorf("recurrence: malformed BYMONTHDAY %q", item)
+		}
+		days = append(days, n)
+	}
+	return days, nil
+}
+
+// String returns the canonical form of the rule without the "RRULE:" prefix.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, wd := range r.ByDay {
+			days[i] = weekdayCodes[wd.Day]
+			if wd.N != 0 {
+				days[i] = strconv.Itoa(wd.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, d := range r.ByMonthDay {
+			days[i] = strconv.Itoa(d)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence strictly after both start and after.
+//
+// start は規則の起点 (DTSTART に相当) で、発生日時の時刻や、BYDAY などが省略された場合の
+// 曜日・日付は start から引き継ぎます。計算は loc で行うため、夏時間の有無にかかわらず
+// 同じ現地時刻に発生します。COUNT の判定は呼び出し側で行ってください。
+// 発生日時がない場合 (UNTIL を過ぎた場合など) は false を返します。
+func (r *Rule) Next(start, after time.Time, loc *time.Location) (time.Time, bool) {
+	start = start.In(loc)
+	if start.After(after) {
+		after = start
+	}
+
+	for period := 0; period < maxPeriods; period++ {
+		for _, t := range r.candidates(start, period*r.Interval, loc) {
+			if !t.After(after) {
+				continue
+			}
+			if r.Until != nil && t.After(*r.Until) {
+				return time.Time{}, false
+			}
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// candidates は start から offset 期間後の期間に含まれる発生日時を昇順で返します。
+func (r *Rule) candidates(start time.Time, offset int, loc *time.Location) []time.Time {
+	hour, min, sec := start.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hour, min, sec, 0, loc)
+	}
+
+	var result []time.Time
+	switch r.Freq {
+	case Daily:
+		t := at(start.Year(), start.Month(), start.Day()+offset)
+		if len(r.ByDay) == 0 || r.hasWeekday(t.Weekday()) {
+			result = append(result, t)
+		}
+
+	case Weekly:
+		// 週の始まり (月曜日) からの日数
+		monday := start.Day() - (int(start.Weekday())+6)%7 + 7*offset
+		if len(r.ByDay) == 0 {
+			result = append(result, at(start.Year(), start.Month(), start.Day()+7*offset))
+			break
+		}
+		for _, wd := range r.ByDay {
+			result = append(result, at(start.Year(), start.Month(), monday+(int(wd.Day)+6)%7))
+		}
+
+	case Monthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(offset), 1, 0, 0, 0, 0, loc)
+		y, m := first.Year(), first.Month()
+		days := daysIn(y, m, loc)
+		var mdays []int
+		for _, d := range r.ByMonthDay {
+			if d < 0 {
+				d = days + d + 1
+			}
+			if d >= 1 && d <= days {
+				mdays = append(mdays, d)
+			}
+		}
+		for _, wd := range r.ByDay {
+			mdays = append(mdays, weekdaysInMonth(y, m, days, wd, loc)...)
+		}
+		if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 && start.Day() <= days {
+			// 該当する日がない月 (31 日に対する 2 月など) は RFC 5545 に従い飛ばす
+			mdays = append(mdays, start.Day())
+		}
+		for _, d := range mdays {
+			result = append(result, at(y, m, d))
+		}
+
+	case Yearly:
+		y := start.Year() + offset
+		if start.Day() <= daysIn(y, start.Month(), loc) {
+			result = append(result, at(y, start.Month(), start.Day()))
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Before(result[j]) })
+	return result
+}
+
+func (r *Rule) hasWeekday(day time.Weekday) bool {
+	for _, wd := range r.ByDay {
+		if wd.Day == day {
+			return true
+		}
+	}
+	return false
+}
+
+// daysIn は y 年 m 月の日数を返します。
+func daysIn(y int, m time.Month, loc *time.Location) int {
+	return time.Date(y, m+1, 0, 0, 0, 0, 0, loc).Day()
+}
+
+// weekdaysInMonth は y 年 m 月のうち wd に該当する日を返します。
+func weekdaysInMonth(y int, m time.Month, days int, wd WeekdayNum, loc *time.Location) []int {
+	var all []int
+	first := int(time.Date(y, m, 1, 0, 0, 0, 0, loc).Weekday())
+	for d := 1 + (int(wd.Day)-first+7)%7; d <= days; d += 7 {
+		all = append(all, d)
+	}
+	switch {
+	case wd.N == 0:
+		return all
+	case wd.N > 0 && wd.N <= len(all):
+		return []int{all[wd.N-1]}
+	case wd.N < 0 && -wd.N <= len(all):
+		return []int{all[len(all)+wd.N]}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,148 @@
+package recurrence_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/recurrence"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		rule    string
+		want    string
+		wantErr bool
+	}{
+		"Shorthand":           {rule: "weekly", want: "FREQ=WEEKLY"},
+		"RRULE prefix":        {rule: "RRULE:FREQ=DAILY;INTERVAL=2", want: "FREQ=DAILY;INTERVAL=2"},
+		"Monthly ordinal":     {rule: "FREQ=MONTHLY;BYDAY=-1FR", want: "FREQ=MONTHLY;BYDAY=-1FR"},
+		"Until date":          {rule: "FREQ=DAILY;UNTIL=20240131", want: "FREQ=DAILY;UNTIL=20240131T145959Z"},
+		"Missing FREQ":        {rule: "INTERVAL=2", wantErr: true},
+		"Unsupported FREQ":    {rule: "FREQ=HOURLY", wantErr: true},
+		"COUNT and UNTIL":     {rule: "FREQ=DAILY;COUNT=2;UNTIL=20240131", wantErr: true},
+		"Weekly ordinal":      {rule: "FREQ=WEEKLY;BYDAY=1MO", wantErr: true},
+		"Weekly BYMONTHDAY":   {rule: "FREQ=WEEKLY;BYMONTHDAY=1", wantErr: true},
+		"Unsupported part":    {rule: "FREQ=DAILY;BYHOUR=9", wantErr: true},
+		"Zero interval":       {rule: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+		"Duplicated part":     {rule: "FREQ=DAILY;FREQ=WEEKLY", wantErr: true},
+		"Malformed BYDAY":     {rule: "FREQ=WEEKLY;BYDAY=XX", wantErr: true},
+		"Malformed monthday":  {rule: "FREQ=MONTHLY;BYMONTHDAY=32", wantErr: true},
+		"Lower case accepted": {rule: "freq=monthly;bymonthday=1,-1", want: "FREQ=MONTHLY;BYMONTHDAY=1,-1"},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := recurrence.Parse(c.rule, tokyo)
+			if c.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got rule %s", r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := r.String(); got != c.want {
+				t.Errorf("unexpected value, given = %s, expected = %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRuleNext(t *testing.T) {
+	t.Parallel()
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(y int, m time.Month, d, hour int) time.Time {
+		return time.Date(y, m, d, hour, 0, 0, 0, tokyo)
+	}
+
+	cases := map[string]struct {
+		rule   string
+		start  time.Time
+		after  time.Time
+		want   time.Time
+		wantOK bool
+	}{
+		"Daily keeps local time": {
+			rule: "daily", start: at(2024, 1, 31, 9), after: at(2024, 1, 31, 9),
+			want: at(2024, 2, 1, 9), wantOK: true,
+		},
+		"Daily skips missed occurrences": {
+			rule: "FREQ=DAILY;INTERVAL=2", start: at(2024, 1, 1, 9), after: at(2024, 1, 4, 12),
+			want: at(2024, 1, 5, 9), wantOK: true,
+		},
+		"Weekly on the same weekday": {
+			rule: "weekly", start: at(2024, 1, 1, 9), after: at(2024, 1, 1, 9),
+			want: at(2024, 1, 8, 9), wantOK: true,
+		},
+		"Weekly BYDAY within the week": {
+			rule: "FREQ=WEEKLY;BYDAY=MO,FR", start: at(2024, 1, 1, 9), after: at(2024, 1, 1, 9),
+			want: at(2024, 1, 5, 9), wantOK: true,
+		},
+		"Biweekly BYDAY skips a week": {
+			rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", start: at(2024, 1, 1, 9), after: at(2024, 1, 1, 9),
+			want: at(2024, 1, 15, 9), wantOK: true,
+		},
+		"Monthly skips months without the day": {
+			rule: "monthly", start: at(2024, 1, 31, 9), after: at(2024, 1, 31, 9),
+			want: at(2024, 3, 31, 9), wantOK: true,
+		},
+		"Monthly last day": {
+			rule: "FREQ=MONTHLY;BYMONTHDAY=-1", start: at(2024, 1, 31, 9), after: at(2024, 1, 31, 9),
+			want: at(2024, 2, 29, 9), wantOK: true,
+		},
+		"Monthly last Friday": {
+			rule: "FREQ=MONTHLY;BYDAY=-1FR", start: at(2024, 1, 26, 9), after: at(2024, 1, 26, 9),
+			want: at(2024, 2, 23, 9), wantOK: true,
+		},
+		"Monthly second Tuesday": {
+			rule: "FREQ=MONTHLY;BYDAY=2TU", start: at(2024, 1, 1, 9), after: at(2024, 1, 1, 9),
+			want: at(2024, 1, 9, 9), wantOK: true,
+		},
+		"Yearly leap day": {
+			rule: "yearly", start: at(2024, 2, 29, 9), after: at(2024, 2, 29, 9),
+			want: at(2028, 2, 29, 9), wantOK: true,
+		},
+		"Until reached": {
+			rule: "FREQ=DAILY;UNTIL=20240102", start: at(2024, 1, 2, 9), after: at(2024, 1, 2, 9),
+			wantOK: false,
+		},
+		"Until inclusive": {
+			rule: "FREQ=DAILY;UNTIL=20240102", start: at(2024, 1, 1, 9), after: at(2024, 1, 1, 9),
+			want: at(2024, 1, 2, 9), wantOK: true,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := recurrence.Parse(c.rule, tokyo)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, ok := r.Next(c.start, c.after, tokyo)
+			if ok != c.wantOK {
+				t.Fatalf("unexpected ok, given = %t, expected = %t (next = %s)", ok, c.wantOK, got)
+			}
+			if ok && !got.Equal(c.want) {
+				t.Errorf("unexpected value, given = %s, expected = %s", got, c.want)
+			}
+		})
+	}
+}
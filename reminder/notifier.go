@@ -0,0 +1,145 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A Notifier is notified when a TODO becomes due.
+type Notifier interface {
+	Notify(ctx context.Context, todo *model.TODO) error
+}
+
+// A Message is the payload sent by WebhookNotifier and written by FileNotifier.
+type Message struct {
+	Event      string      `json:"event"`
+	TODO       *model.TODO `json:"todo"`
+	NotifiedAt time.Time   `json:"notified_at"`
+}
+
+func newMessage(todo *model.TODO) Message {
+	return Message{
+		Event:      "todo.due",
+		TODO:       todo,
+		NotifiedAt: time.Now(),
+	}
+}
+
+// LogNotifier は標準のロガーにリマインドを出力します。
+type LogNotifier struct{}
+
+// NewLogNotifier returns new LogNotifier.
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+// Notify implements Notifier.
+func (n *LogNotifier) Notify(ctx context.Context, todo *model.TODO) error {
+	log.Printf("reminder: TODO %d %q is due at %s", todo.ID, todo.Subject, todo.DueAt.Local().Format(time.RFC3339))
+	return nil
+}
+
+// WebhookNotifier は URL にリマインドを JSON で POST します。
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookNotifier returns new WebhookNotifier.
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify implements Notifier.
+func (n *WebhookNotifier) Notify(ctx context.Context, todo *model.TODO) error {
+	body, err := json.Marshal(newMessage(todo))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 2xx 以外は失敗として扱い、次回再送する
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("reminder: webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// FileNotifier はリマインドを JSON Lines 形式でファイルに追記します。
+type FileNotifier struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// NewFileNotifier returns new FileNotifier.
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{
+		Path: path,
+	}
+}
+
+// Notify implements Notifier.
+func (n *FileNotifier) Notify(ctx context.Context, todo *model.TODO) error {
+	line, err := json.Marshal(newMessage(todo))
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// NewNotifier は kind ("log", "webhook", "file") に応じた Notifier を返します。
+// target は webhook の場合は URL、file の場合はファイルのパスです。
+func NewNotifier(kind, target string) (Notifier, error) {
+	switch kind {
+	case "", "log":
+		return NewLogNotifier(), nil
+	case "webhook":
+		if target == "" {
+			return nil, fmt.Errorf("reminder: webhook notifier requires a URL")
+		}
+		return NewWebhookNotifier(target), nil
+	case "file":
+		if target == "" {
+			return nil, fmt.Errorf("reminder: file notifier requires a path")
+		}
+		return NewFileNotifier(target), nil
+	default:
+		return nil, fmt.Errorf("reminder: unknown notifier %q", kind)
+	}
+}
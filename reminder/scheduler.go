@@ -0,0 +1,60 @@
+package reminder
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/periodic"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// batchSize は 1 回の確認で通知する TODO の最大件数です。
+const batchSize = 100
+
+// A Scheduler periodically looks for TODOs that became due and notifies them.
+type Scheduler struct {
+	service  *service.TODOService
+	notifier Notifier
+	interval time.Duration
+}
+
+// NewScheduler returns new Scheduler.
+func NewScheduler(svc *service.TODOService, notifier Notifier, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		service:  svc,
+		notifier: notifier,
+		interval: interval,
+	}
+}
+
+// Run は ctx がキャンセルされるまで interval ごとに期限を迎えた TODO を通知します。
+// ctx がキャンセルされると、残りの TODO を通知せずに戻ります。
+func (s *Scheduler) Run(ctx context.Context) {
+	periodic.RunNow(ctx, s.interval, s.tick)
+}
+
+// tick は期限を迎えた未通知の TODO を通知し、通知済みとして記録します。
+// 通知に失敗した TODO は記録しないため、次回の確認で再送されます。
+func (s *Scheduler) tick(ctx context.Context) {
+	todos, err := s.service.ReadDueTODOs(ctx, time.Now(), batchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Println("reminder: failed to read due TODOs:", err)
+		}
+		return
+	}
+
+	for _, todo := range todos {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.notifier.Notify(ctx, todo); err != nil {
+			log.Printf("reminder: failed to notify TODO %d: %v", todo.ID, err)
+			continue
+		}
+		if err := s.service.MarkTODOReminded(ctx, todo.ID); err != nil {
+			log.Printf("reminder: failed to mark TODO %d as reminded: %v", todo.ID, err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package reminder_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/reminder"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// recordNotifier は通知した TODO の ID を記録します。fail に含まれる TODO の通知は失敗させます。
+type recordNotifier struct {
+	mu       sync.Mutex
+	notified []int64
+	fail     map[int64]bool
+}
+
+func (n *recordNotifier) Notify(ctx context.Context, todo *model.TODO) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notified = append(n.notified, todo.ID)
+	if n.fail[todo.ID] {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func (n *recordNotifier) count(id int64) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var c int
+	for _, notified := range n.notified {
+		if notified == id {
+			c++
+		}
+	}
+	return c
+}
+
+func TestScheduler_Run(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	svc := service.NewTODOServiceWithRepository(service.NewMemoryStore())
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	create := func(subject string, opts ...service.TODOOption) *model.TODO {
+		todo, err := svc.CreateTODO(ctx, subject, "", opts...)
+		if err != nil {
+			t.Fatal("failed to create, err =", err)
+		}
+		return todo
+	}
+	due := create("due", service.WithDueAt(&past))
+	failing := create("failing", service.WithDueAt(&past))
+	done := create("done", service.WithDueAt(&past), service.WithDone(true))
+	later := create("later", service.WithDueAt(&future))
+
+	notifier := &recordNotifier{fail: map[int64]bool{failing.ID: true}}
+	runCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	reminder.NewScheduler(svc, notifier, 10*time.Millisecond).Run(runCtx)
+
+	// 通知できた TODO は 1 回だけ通知し、失敗した TODO は次の確認で再送する
+	if c := notifier.count(due.ID); c != 1 {
+		t.Errorf("a due TODO should be notified once, given = %d times", c)
+	}
+	if c := notifier.count(failing.ID); c < 2 {
+		t.Errorf("a TODO whose notification failed should be retried, given = %d times", c)
+	}
+	if c := notifier.count(done.ID) + notifier.count(later.ID); c != 0 {
+		t.Errorf("done or not yet due TODOs should not be notified, given = %d times", c)
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// 利用者が読める TODO は、利用者自身の TODO と、所有しているか共有されたリストの TODO です。
+// 変更できる TODO は、利用者自身の TODO と、所有しているか編集者として共有されたリストの TODO です。
+// 読めない TODO やリストは存在しないものとして ErrNotFound を、読めるが変更できない場合は ErrForbidden を返します。
+
+// listIDs は ctx の利用者が読める (write が true の場合は変更できる) リストの id を返す副問い合わせと、その引数を返します。
+func listIDs(user *model.User, write bool) (string, []interface{}) {
+	query := `SELECT id FROM lists WHERE owner_id = ? UNION SELECT list_id FROM list_members WHERE user_id = ?`
+	if write {
+		query += ` AND role = '` + model.ListRoleEditor + `'`
+	}
+	return query, []interface{}{user.ID, user.ID}
+}
+
+// todoAccessFilter は、ctx の利用者が読める (write が true の場合は変更できる) TODO に絞り込む WHERE 句の条件とその引数を返します。
+// prefix は todos の別名 ("t." など) です。ownerFilter と同じく、利用者のない ctx ではすべての TODO が対象です。
+func todoAccessFilter(ctx context.Context, prefix string, write bool) (string, []interface{}) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return "1 = 1", nil
+	}
+	lists, args := listIDs(user, write)
+	return "(" + prefix + "owner_id = ? OR " + prefix + "list_id IN (" + lists + "))", append([]interface{}{user.ID}, args...)
+}
+
+// listAccessFilter は、ctx の利用者が読める (write が true の場合は変更できる) リストに絞り込む WHERE 句の条件とその引数を返します。
+func listAccessFilter(ctx context.Context, write bool) (string, []interface{}) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return "1 = 1", nil
+	}
+	lists, args := listIDs(user, write)
+	return "id IN (" + lists + ")", args
+}
+
+// listRole は、ctx の利用者のリストでの役割を SELECT する式とその引数を返します。利用者のない ctx や、役割のない利用者では空文字列です。
+func listRole(ctx context.Context) (string, []interface{}) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return "''", nil
+	}
+	return `COALESCE(CASE WHEN lists.owner_id = ? THEN '` + model.ListRoleOwner + `'
+		ELSE (SELECT m.role FROM list_members m WHERE m.list_id = lists.id AND m.user_id = ?) END, '')`, []interface{}{user.ID, user.ID}
+}
+
+// writableTODOIDs は ids のうち ctx の利用者が読める TODO の id を、重複を除いて ids の順序のまま返します。
+// trashed が true の場合はゴミ箱にある TODO を、false の場合はゴミ箱にない TODO を対象にします。
+// 読める TODO に変更できないものが含まれる場合は ErrForbidden を返します。
+func writableTODOIDs(ctx context.Context, q dbtx, ids []int64, trashed bool) ([]int64, error) {
+	read, readArgs := todoAccessFilter(ctx, "", false)
+	write, writeArgs := todoAccessFilter(ctx, "", true)
+	deleted := "deleted_at IS NULL"
+	if trashed {
+		deleted = "deleted_at IS NOT NULL"
+	}
+	// 所有者もリストもない TODO では条件が NULL になるため、COALESCE で偽にする
+	query := fmt.Sprintf(`SELECT id, COALESCE(%s, FALSE) FROM todos WHERE id IN (%s) AND %s AND %s`, write, placeholders(len(ids)), deleted, read)
+	args := append(append(writeArgs, int64Args(ids)...), readArgs...)
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := map[int64]bool{}
+	for rows.Next() {
+		var (
+			id       int64
+			writable bool
+		)
+		if err := rows.Scan(&id, &writable); err != nil {
+			return nil, err
+		}
+		if !writable {
+			return nil, &model.ErrForbidden{Message: fmt.Sprintf("TODO %d is read-only for you", id)}
+		}
+		found[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var writable []int64
+	for _, id := range uniqueInt64s(ids) {
+		if found[id] {
+			writable = append(writable, id)
+		}
+	}
+	return writable, nil
+}
+
+// checkWritable は id の TODO が存在しない、ゴミ箱にある、または ctx の利用者が読めない場合に ErrNotFound を、
+// 読めるが変更できない場合に ErrForbidden を返します。
+func checkWritable(ctx context.Context, q dbtx, id int64) error {
+	ids, err := writableTODOIDs(ctx, q, []int64{id}, false)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return &model.ErrNotFound{}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// ErrBulkAborted は、アトミックな一括操作で他の項目が失敗したため、項目が適用されなかったことを表します。
+var ErrBulkAborted = errors.New("not applied because another item failed")
+
+// A BulkTODO is one TODO to create with CreateTODOs or to update with UpdateTODOs.
+type BulkTODO struct {
+	// ID は更新する TODO の id です。CreateTODOs では使いません。
+	ID          int64
+	Subject     string
+	Description string
+	Options     []TODOOption
+}
+
+// A BulkResult is the result of one BulkTODO. Either TODO or Err is set.
+type BulkResult struct {
+	TODO *model.TODO
+	Err  error
+}
+
+// abortBulk は、成功した項目の結果を ErrBulkAborted に置き換えます。
+func abortBulk(results []*BulkResult) {
+	for _, r := range results {
+		if r.Err == nil {
+			r.TODO = nil
+			r.Err = ErrBulkAborted
+		}
+	}
+}
+
+// CreateTODOs creates TODOs in a single transaction and returns the result of each item in the same order.
+//
+// atomic が true の場合は、1 件でも失敗するとすべての作成を取り消し、成功していた項目の結果を ErrBulkAborted にします。
+// false の場合は、失敗した項目だけを取り消して残りを作成します。
+// どちらの場合も、項目の失敗は BulkResult.Err で返し、error はトランザクション自体が失敗した場合のみ返します。
+func (s *SQLTODORepository) CreateTODOs(ctx context.Context, items []*BulkTODO, atomic bool) ([]*BulkResult, error) {
+	return s.bulk(ctx, items, atomic, func(tx *sqlTx, item *BulkTODO) (*model.TODO, error) {
+		attrs, err := newTODOAttributes(item.Options)
+		if err != nil {
+			return nil, err
+		}
+		return createTODO(ctx, tx, item.Subject, item.Description, attrs)
+	})
+}
+
+// UpdateTODOs updates TODOs in a single transaction and returns the result of each item in the same order.
+// atomic の扱いは CreateTODOs と同じです。
+func (s *SQLTODORepository) UpdateTODOs(ctx context.Context, items []*BulkTODO, atomic bool) ([]*BulkResult, error) {
+	return s.bulk(ctx, items, atomic, func(tx *sqlTx, item *BulkTODO) (*model.TODO, error) {
+		attrs, err := newTODOAttributes(item.Options)
+		if err != nil {
+			return nil, err
+		}
+		return updateTODO(ctx, tx, item.ID, item.Subject, item.Description, attrs)
+	})
+}
+
+// bulk は items を 1 つのトランザクションで順に apply します。
+// 失敗した項目の変更だけを取り消せるよう、項目ごとに SAVEPOINT を作成します。
+// PostgreSQL ではエラーになった文があるとトランザクション全体が使えなくなるため、ROLLBACK TO で戻してから次の項目に進みます。
+func (s *SQLTODORepository) bulk(ctx context.Context, items []*BulkTODO, atomic bool, apply func(tx *sqlTx, item *BulkTODO) (*model.TODO, error)) ([]*BulkResult, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]*BulkResult, len(items))
+	failed := false
+	for i, item := range items {
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT bulk_item`); err != nil {
+			return nil, err
+		}
+		todo, err := apply(tx, item)
+		if err != nil {
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT bulk_item`); err != nil {
+				return nil, err
+			}
+			failed = true
+		}
+		results[i] = &BulkResult{TODO: todo, Err: err}
+		if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT bulk_item`); err != nil {
+			return nil, err
+		}
+	}
+
+	// アトミックな場合は、失敗した項目があればすべて取り消す
+	if atomic && failed {
+		abortBulk(results)
+		return results, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// CreateTODOs creates TODOs in memory. atomic の扱いは SQLTODORepository.CreateTODOs と同じです。
+func (s *MemoryStore) CreateTODOs(ctx context.Context, items []*BulkTODO, atomic bool) ([]*BulkResult, error) {
+	return s.bulk(items, atomic, func(item *BulkTODO) (*model.TODO, error) {
+		attrs, err := newTODOAttributes(item.Options)
+		if err != nil {
+			return nil, err
+		}
+		return s.createTODO(ctx, item.Subject, item.Description, attrs)
+	})
+}
+
+// UpdateTODOs updates TODOs in memory. atomic の扱いは SQLTODORepository.CreateTODOs と同じです。
+func (s *MemoryStore) UpdateTODOs(ctx context.Context, items []*BulkTODO, atomic bool) ([]*BulkResult, error) {
+	return s.bulk(items, atomic, func(item *BulkTODO) (*model.TODO, error) {
+		attrs, err := newTODOAttributes(item.Options)
+		if err != nil {
+			return nil, err
+		}
+		return s.updateTODO(ctx, item.ID, item.Subject, item.Description, attrs)
+	})
+}
+
+// bulk は、ロックを取ったまま items を順に apply します。
+// アトミックな場合は、失敗したときにトランザクションのロールバックと同じく元に戻せるよう、先に状態を複製しておきます。
+func (s *MemoryStore) bulk(items []*BulkTODO, atomic bool, apply func(item *BulkTODO) (*model.TODO, error)) ([]*BulkResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var saved *MemoryStore
+	if atomic {
+		saved = s.clone()
+	}
+
+	results := make([]*BulkResult, len(items))
+	failed := false
+	for i, item := range items {
+		todo, err := apply(item)
+		if err != nil {
+			failed = true
+		}
+		results[i] = &BulkResult{TODO: todo, Err: err}
+	}
+
+	if atomic && failed {
+		s.restore(saved)
+		abortBulk(results)
+	}
+	return results, nil
+}
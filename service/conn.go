@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/TechBowl-japan/go-stations/db"
+)
+
+// sqlDB は *sql.DB を包み、SQLite 向けに書いたクエリを接続先のドライバーに合わせて書き換えてから実行します。
+// クエリは SQLite と PostgreSQL のどちらでも同じ結果になるよう書いてください。
+type sqlDB struct {
+	db     *sql.DB
+	driver string
+}
+
+// newSQLDB は conn のドライバーを判定して sqlDB を返します。
+func newSQLDB(conn *sql.DB) *sqlDB {
+	return &sqlDB{
+		db:     conn,
+		driver: db.DriverName(conn),
+	}
+}
+
+// ExecContext は *sql.DB の ExecContext と同じです。
+func (d *sqlDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return d.db.ExecContext(ctx, db.Rebind(d.driver, query), args...)
+}
+
+// QueryContext は *sql.DB の QueryContext と同じです。
+func (d *sqlDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return d.db.QueryContext(ctx, db.Rebind(d.driver, query), args...)
+}
+
+// QueryRowContext は *sql.DB の QueryRowContext と同じです。
+func (d *sqlDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return d.db.QueryRowContext(ctx, db.Rebind(d.driver, query), args...)
+}
+
+// BeginTx は、クエリを同じように書き換えるトランザクションを開始します。
+func (d *sqlDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sqlTx, error) {
+	tx, err := d.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{tx: tx, driver: d.driver}, nil
+}
+
+// sqlTx は sqlDB のトランザクションです。
+type sqlTx struct {
+	tx     *sql.Tx
+	driver string
+}
+
+// ExecContext は *sql.Tx の ExecContext と同じです。
+func (t *sqlTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, db.Rebind(t.driver, query), args...)
+}
+
+// QueryContext は *sql.Tx の QueryContext と同じです。
+func (t *sqlTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, db.Rebind(t.driver, query), args...)
+}
+
+// QueryRowContext は *sql.Tx の QueryRowContext と同じです。
+func (t *sqlTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRowContext(ctx, db.Rebind(t.driver, query), args...)
+}
+
+// PrepareContext は *sql.Tx の PrepareContext と同じです。
+func (t *sqlTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return t.tx.PrepareContext(ctx, db.Rebind(t.driver, query))
+}
+
+// Commit はトランザクションをコミットします。
+func (t *sqlTx) Commit() error {
+	return t.tx.Commit()
+}
+
+// Rollback はトランザクションをロールバックします。
+func (t *sqlTx) Rollback() error {
+	return t.tx.Rollback()
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// noDueAt は期限が設定されていない TODO のソート値です。昇順では期限付きの TODO の後に並びます。
+const noDueAt = "9999-12-31 23:59:59"
+
+// sortExprs は、ソートキーごとの ORDER BY に使う式です。
+// いずれも NULL にならないため、キーセットページネーションの比較にそのまま使えます。
+var sortExprs = map[string]string{
+	model.TODOSortID:        "id",
+	model.TODOSortPriority:  "priority",
+	model.TODOSortDueAt:     "COALESCE(due_at, '" + noDueAt + "')",
+	model.TODOSortCreatedAt: "created_at",
+	model.TODOSortUpdatedAt: "updated_at",
+	model.TODOSortSubject:   "subject",
+}
+
+// IsValidSort は key がソートキーとして使えるかを返します。
+func IsValidSort(key string) bool {
+	_, ok := sortExprs[key]
+	return ok
+}
+
+// ErrInvalidCursor is returned by ParseCursor when the cursor is malformed.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// A Cursor points at the last TODO of a page and is used to read the next page.
+// It holds the sort key and order it was issued for, so that it can't be reused
+// with a different sort.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+// NewCursor returns the cursor that points at todo in the given sort.
+func NewCursor(sort, order string, todo *model.TODO) *Cursor {
+	c := &Cursor{
+		Sort:  sort,
+		Order: order,
+		ID:    todo.ID,
+	}
+	switch sort {
+	case model.TODOSortPriority:
+		c.Value = strconv.Itoa(todo.Priority)
+	case model.TODOSortDueAt:
+		c.Value = noDueAt
+		if todo.DueAt != nil {
+			c.Value = formatDBTime(*todo.DueAt)
+		}
+	case model.TODOSortCreatedAt:
+		c.Value = formatDBTime(todo.CreatedAt)
+	case model.TODOSortUpdatedAt:
+		c.Value = formatDBTime(todo.UpdatedAt)
+	case model.TODOSortSubject:
+		c.Value = todo.Subject
+	}
+	return c
+}
+
+// ParseCursor parses the string returned by Cursor.String.
+func ParseCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if !IsValidSort(c.Sort) || (c.Order != model.SortOrderAsc && c.Order != model.SortOrderDesc) {
+		return nil, ErrInvalidCursor
+	}
+	switch c.Sort {
+	case model.TODOSortPriority:
+		if _, err := strconv.Atoi(c.Value); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	case model.TODOSortDueAt, model.TODOSortCreatedAt, model.TODOSortUpdatedAt:
+		// PostgreSQL では日時として解釈できない値との比較がエラーになるため、ここで弾く
+		if _, err := time.Parse(dbTimeLayout, c.Value); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &c, nil
+}
+
+// String returns the opaque representation of the cursor.
+func (c *Cursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// arg は、カーソルの値を sortExprs の式と比較できる型に変換します。
+func (c *Cursor) arg() interface{} {
+	switch c.Sort {
+	case model.TODOSortID:
+		return c.ID
+	case model.TODOSortPriority:
+		p, _ := strconv.Atoi(c.Value)
+		return p
+	default:
+		return c.Value
+	}
+}
+
+// dbTimeLayout は、データベースに保存する時刻の形式です。
+const dbTimeLayout = "2006-01-02 15:04:05"
+
+// formatDBTime は dbTime の time.Time 版です。
+func formatDBTime(t time.Time) string {
+	return t.UTC().Format(dbTimeLayout)
+}
@@ -0,0 +1,445 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// listColumns は、リストを取得する際に SELECT するカラムです。scanList と順序を合わせてください。
+// TODO の件数は lists を別名なしで FROM に指定した場合にのみ正しく集計されます。
+const listColumns = `id, name, description, archived_at,
+	(SELECT COUNT(*) FROM todos t WHERE t.list_id = lists.id AND t.deleted_at IS NULL),
+	created_at, updated_at`
+
+// A SQLListRepository implements ListRepository on SQLite or PostgreSQL.
+type SQLListRepository struct {
+	db *sqlDB
+}
+
+// NewSQLListRepository returns new SQLListRepository for the driver that db was opened with.
+func NewSQLListRepository(db *sql.DB) *SQLListRepository {
+	return &SQLListRepository{
+		db: newSQLDB(db),
+	}
+}
+
+// scanList は listColumns と listRole の順に並んだ行を model.List に変換します。
+func scanList(row scanner) (*model.List, error) {
+	var (
+		list       model.List
+		archivedAt sql.NullTime
+	)
+	if err := row.Scan(&list.ID, &list.Name, &list.Description, &archivedAt, &list.TODOCount,
+		&list.CreatedAt, &list.UpdatedAt, &list.Role); err != nil {
+		return nil, err
+	}
+	if archivedAt.Valid {
+		list.Archived = true
+		list.ArchivedAt = &archivedAt.Time
+	}
+	return &list, nil
+}
+
+// readList は id のリストを、ctx の利用者の役割付きで取得します。存在しない場合は ErrNotFound を返します。
+func readList(ctx context.Context, q dbtx, id int64) (*model.List, error) {
+	role, args := listRole(ctx)
+	list, err := scanList(q.QueryRowContext(ctx, `SELECT `+listColumns+`, `+role+` FROM lists WHERE id = ?`, append(args, id)...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &model.ErrNotFound{}
+		}
+		return nil, err
+	}
+	return list, nil
+}
+
+// CreateList creates a list of the user of ctx on DB.
+func (s *SQLListRepository) CreateList(ctx context.Context, name, description string) (*model.List, error) {
+	const insert = `INSERT INTO lists(name, description, owner_id) VALUES(?, ?, ?) RETURNING id`
+
+	var id int64
+	if err := s.db.QueryRowContext(ctx, insert, name, description, ownerID(ctx)).Scan(&id); err != nil {
+		return nil, err
+	}
+	return readList(ctx, s.db, id)
+}
+
+// ReadLists reads lists that the user of ctx can read ordered by id. Archived lists are included only if includeArchived is true.
+func (s *SQLListRepository) ReadLists(ctx context.Context, includeArchived bool) ([]*model.List, error) {
+	role, args := listRole(ctx)
+	access, accessArgs := listAccessFilter(ctx, false)
+	query := `SELECT ` + listColumns + `, ` + role + ` FROM lists WHERE ` + access
+	if !includeArchived {
+		query += ` AND archived_at IS NULL`
+	}
+	query += ` ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query, append(args, accessArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []*model.List{}
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+// UpdateList updates the name and description of the list.
+// archived が nil でない場合は、リストをアーカイブ (true) またはアーカイブから戻し (false) ます。
+// リストを変更できるのは所有者と編集者です。
+func (s *SQLListRepository) UpdateList(ctx context.Context, id int64, name, description string, archived *bool) (*model.List, error) {
+	sets := "name = ?, description = ?"
+	args := []interface{}{name, description}
+	if archived != nil {
+		// 既にアーカイブ済みのリストを再度アーカイブしても archived_at は更新しない
+		sets += ", archived_at = CASE WHEN ? THEN COALESCE(archived_at, DATETIME('now')) ELSE NULL END"
+		args = append(args, *archived)
+	}
+	args = append(args, id)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkListRole(ctx, tx, id, model.ListRoleOwner, model.ListRoleEditor); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE lists SET `+sets+` WHERE id = ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, &model.ErrNotFound{}
+	}
+
+	list, err := readList(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// DeleteLists deletes lists on DB by ids. TODOs in the lists are kept and no longer belong to any list.
+// ctx の利用者が読めないリストは存在しないものとして除きます。リストを削除できるのは所有者だけです。
+func (s *SQLListRepository) DeleteLists(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 読めるが所有していないリストが含まれる場合は 1 件も削除しない。所有者のないリストでは条件が NULL になる
+	owner, ownerArgs := ownerFilter(ctx, "owner_id")
+	access, accessArgs := listAccessFilter(ctx, false)
+	query := fmt.Sprintf(`SELECT id, COALESCE(%s, FALSE) FROM lists WHERE id IN (%s) AND %s`, owner, placeholders(len(ids)), access)
+	rows, err := tx.QueryContext(ctx, query, append(append(ownerArgs, int64Args(ids)...), accessArgs...)...)
+	if err != nil {
+		return err
+	}
+	var deleted []int64
+	for rows.Next() {
+		var (
+			id    int64
+			owned bool
+		)
+		if err := rows.Scan(&id, &owned); err != nil {
+			rows.Close()
+			return err
+		}
+		if !owned {
+			rows.Close()
+			return &model.ErrForbidden{Message: fmt.Sprintf("only the owner can delete list %d", id)}
+		}
+		deleted = append(deleted, id)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(deleted) == 0 {
+		return &model.ErrNotFound{}
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM lists WHERE id IN (%s)`, placeholders(len(deleted))), int64Args(deleted)...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReadListMembers reads the owner and the members of the list. 所有者を先頭に、共有した順に返します。
+func (s *SQLListRepository) ReadListMembers(ctx context.Context, listID int64) ([]*model.ListMember, error) {
+	const (
+		readOwner = `SELECT users.id, users.name, '` + model.ListRoleOwner + `', lists.created_at
+			FROM lists JOIN users ON users.id = lists.owner_id WHERE lists.id = ?`
+		readMembers = `SELECT ` + listMemberColumns + ` WHERE m.list_id = ? ORDER BY m.created_at, m.user_id`
+	)
+
+	if err := checkListRole(ctx, s.db, listID, model.ListRoleOwner, model.ListRoleEditor, model.ListRoleViewer); err != nil {
+		return nil, err
+	}
+
+	members := []*model.ListMember{}
+	owner, err := scanListMember(s.db.QueryRowContext(ctx, readOwner, listID))
+	switch {
+	case err == nil:
+		members = append(members, owner)
+	case err != sql.ErrNoRows:
+		// 所有者のいないリストは、メンバーだけを返す
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, readMembers, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		member, err := scanListMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// PutListMember shares the list with the user named userName as role, or changes the role of the user.
+// 共有できるのはリストの所有者だけです。存在しない利用者や所有者自身を指定した場合は ErrValidation を返します。
+func (s *SQLListRepository) PutListMember(ctx context.Context, listID int64, userName, role string) (*model.ListMember, error) {
+	const (
+		upsert = `INSERT INTO list_members(list_id, user_id, role) VALUES(?, ?, ?)
+			ON CONFLICT(list_id, user_id) DO UPDATE SET role = excluded.role`
+		read = `SELECT ` + listMemberColumns + ` WHERE m.list_id = ? AND m.user_id = ?`
+	)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkListRole(ctx, tx, listID, model.ListRoleOwner); err != nil {
+		return nil, err
+	}
+	userID, err := listMemberID(ctx, tx, userName)
+	if err != nil {
+		if model.IsErrNotFound(err) {
+			return nil, &model.ErrValidation{Field: "name", Message: fmt.Sprintf("user %q does not exist", userName)}
+		}
+		return nil, err
+	}
+	var owner sql.NullInt64
+	if err := tx.QueryRowContext(ctx, `SELECT owner_id FROM lists WHERE id = ?`, listID).Scan(&owner); err != nil {
+		return nil, err
+	}
+	if owner.Valid && owner.Int64 == userID {
+		return nil, &model.ErrValidation{Field: "name", Message: fmt.Sprintf("user %q is the owner of list %d", userName, listID)}
+	}
+
+	if _, err := tx.ExecContext(ctx, upsert, listID, userID, role); err != nil {
+		return nil, err
+	}
+	member, err := scanListMember(tx.QueryRowContext(ctx, read, listID, userID))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// DeleteListMember stops sharing the list with the user named userName.
+// 共有をやめられるのはリストの所有者と、リストから抜ける利用者自身です。
+func (s *SQLListRepository) DeleteListMember(ctx context.Context, listID int64, userName string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	userID, err := listMemberID(ctx, tx, userName)
+	if err != nil {
+		return err
+	}
+	if err := checkListRole(ctx, tx, listID, model.ListRoleOwner); err != nil {
+		user, ok := UserFromContext(ctx)
+		if !model.IsErrForbidden(err) || !ok || user.ID != userID {
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM list_members WHERE list_id = ? AND user_id = ?`, listID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return &model.ErrNotFound{}
+	}
+
+	return tx.Commit()
+}
+
+// listMemberColumns は、メンバーを取得する際に SELECT するカラムと FROM 句です。scanListMember と順序を合わせてください。
+const listMemberColumns = `users.id, users.name, m.role, m.created_at FROM list_members m JOIN users ON users.id = m.user_id`
+
+// scanListMember は利用者の id、名前、役割、共有した日時の順に並んだ行を model.ListMember に変換します。
+func scanListMember(row scanner) (*model.ListMember, error) {
+	var member model.ListMember
+	if err := row.Scan(&member.UserID, &member.Name, &member.Role, &member.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// listMemberID は name の利用者の id を返します。存在しない場合は ErrNotFound を返します。
+func listMemberID(ctx context.Context, q dbtx, name string) (int64, error) {
+	var id int64
+	if err := q.QueryRowContext(ctx, `SELECT id FROM users WHERE name = ?`, name).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, &model.ErrNotFound{}
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+// MoveTODOs moves the TODOs to the list. A nil listID moves them out of any list.
+func (s *SQLTODORepository) MoveTODOs(ctx context.Context, ids []int64, listID *int64) ([]*model.TODO, error) {
+	if len(ids) == 0 {
+		return []*model.TODO{}, nil
+	}
+	ids = uniqueInt64s(ids)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if listID != nil {
+		if err := checkList(ctx, tx, *listID); err != nil {
+			return nil, err
+		}
+	}
+
+	// 存在しない TODO や ctx の利用者が読めない TODO が含まれる場合は 1 件も移動しない
+	writable, err := writableTODOIDs(ctx, tx, ids, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(writable) != len(ids) {
+		return nil, &model.ErrNotFound{}
+	}
+
+	update := fmt.Sprintf(`UPDATE todos SET list_id = ? WHERE id IN (%s)`, placeholders(len(ids)))
+	if _, err := tx.ExecContext(ctx, update, append([]interface{}{listID}, int64Args(ids)...)...); err != nil {
+		return nil, err
+	}
+
+	columns, columnArgs := todoColumns(ctx)
+	read := fmt.Sprintf(`SELECT `+columns+` FROM todos WHERE id IN (%s) ORDER BY id`, placeholders(len(ids)))
+	rows, err := tx.QueryContext(ctx, read, append(columnArgs, int64Args(ids)...)...)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := scanTODOs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := attachTags(ctx, tx, todos); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// checkList は TODO を listID のリストに入れられることを確認します。
+// リストが存在しないか ctx の利用者が読めない場合は ErrValidation を、閲覧者の場合は ErrForbidden を、
+// アーカイブされている場合は ErrConflict を返します。
+func checkList(ctx context.Context, q dbtx, listID int64) error {
+	if err := checkListRole(ctx, q, listID, model.ListRoleOwner, model.ListRoleEditor); err != nil {
+		if model.IsErrNotFound(err) {
+			return &model.ErrValidation{Field: "list_id", Message: fmt.Sprintf("list %d does not exist", listID)}
+		}
+		return err
+	}
+
+	var archived bool
+	if err := q.QueryRowContext(ctx, `SELECT archived_at IS NOT NULL FROM lists WHERE id = ?`, listID).Scan(&archived); err != nil {
+		return err
+	}
+	if archived {
+		return &model.ErrConflict{Message: fmt.Sprintf("list %d is archived", listID)}
+	}
+	return nil
+}
+
+// checkListRole は id のリストが存在しないか ctx の利用者が読めない場合に ErrNotFound を、
+// 利用者の役割が roles のいずれでもない場合に ErrForbidden を返します。利用者のない ctx ではすべてのリストを扱えます。
+func checkListRole(ctx context.Context, q dbtx, id int64, roles ...string) error {
+	role, args := listRole(ctx)
+	access, accessArgs := listAccessFilter(ctx, false)
+	query := `SELECT ` + role + ` FROM lists WHERE id = ? AND ` + access
+	var current string
+	if err := q.QueryRowContext(ctx, query, append(append(args, id), accessArgs...)...).Scan(&current); err != nil {
+		if err == sql.ErrNoRows {
+			return &model.ErrNotFound{}
+		}
+		return err
+	}
+	if _, ok := UserFromContext(ctx); !ok {
+		return nil
+	}
+	for _, r := range roles {
+		if current == r {
+			return nil
+		}
+	}
+	return &model.ErrForbidden{Message: fmt.Sprintf("the %s of list %d can't do this", current, id)}
+}
@@ -0,0 +1,1117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A MemoryStore implements TODORepository, TagRepository, ListRepository, UserRepository, TokenRepository and SessionRepository in memory.
+// It behaves like the SQLite implementation and is meant for tests and local demos;
+// its contents are lost when the process exits.
+//
+// SQLite と同じく、時刻は UTC の秒単位で保持し、id は削除後も再利用しません。
+type MemoryStore struct {
+	mu sync.Mutex
+
+	todos     map[int64]*memoryTODO
+	tags      map[int64]string
+	lists     map[int64]*memoryList
+	revisions map[int64][]*model.Revision
+	users     map[int64]*model.User
+	tokens    map[int64]*memoryToken
+	sessions  map[int64]*model.Session
+	// listMembers は、リストの id と利用者の id をキーとします。
+	listMembers map[int64]map[int64]*memoryListMember
+	// refreshTokens はリフレッシュトークンのハッシュをキーとします。
+	refreshTokens map[string]*memoryRefreshToken
+
+	lastTODOID     int64
+	lastTagID      int64
+	lastListID     int64
+	lastRevisionID int64
+	lastUserID     int64
+	lastTokenID    int64
+	lastSessionID  int64
+}
+
+// memoryTODO は todos テーブルの 1 行に相当します。
+type memoryTODO struct {
+	id          int64
+	subject     string
+	description string
+	completedAt *time.Time
+	dueAt       *time.Time
+	remindedAt  *time.Time
+	priority    int
+	parentID    *int64
+	recurrence  string
+	occurrence  int
+	previousID  *int64
+	listID      *int64
+	ownerID     *int64
+	deletedAt   *time.Time
+	version     int64
+	createdAt   time.Time
+	updatedAt   time.Time
+
+	tagIDs map[int64]bool
+}
+
+// memoryToken は api_tokens テーブルの 1 行に相当します。
+type memoryToken struct {
+	token model.APIToken
+	hash  string
+}
+
+// memoryRefreshToken は refresh_tokens テーブルの 1 行に相当します。
+type memoryRefreshToken struct {
+	sessionID int64
+	usedAt    *time.Time
+}
+
+// memoryList は lists テーブルの 1 行に相当します。
+type memoryList struct {
+	id          int64
+	name        string
+	description string
+	archivedAt  *time.Time
+	ownerID     *int64
+	createdAt   time.Time
+	updatedAt   time.Time
+}
+
+// memoryListMember は list_members テーブルの 1 行に相当します。
+type memoryListMember struct {
+	role      string
+	createdAt time.Time
+}
+
+// NewMemoryStore returns new empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		todos:         map[int64]*memoryTODO{},
+		tags:          map[int64]string{},
+		lists:         map[int64]*memoryList{},
+		listMembers:   map[int64]map[int64]*memoryListMember{},
+		revisions:     map[int64][]*model.Revision{},
+		users:         map[int64]*model.User{},
+		tokens:        map[int64]*memoryToken{},
+		sessions:      map[int64]*model.Session{},
+		refreshTokens: map[string]*memoryRefreshToken{},
+	}
+}
+
+// clone は s の状態を複製した MemoryStore を返します。呼び出し元は s.mu をロックしてください。
+func (s *MemoryStore) clone() *MemoryStore {
+	c := NewMemoryStore()
+	for id, t := range s.todos {
+		copied := *t
+		copied.tagIDs = make(map[int64]bool, len(t.tagIDs))
+		for tagID := range t.tagIDs {
+			copied.tagIDs[tagID] = true
+		}
+		c.todos[id] = &copied
+	}
+	for id, name := range s.tags {
+		c.tags[id] = name
+	}
+	for id, l := range s.lists {
+		copied := *l
+		c.lists[id] = &copied
+	}
+	for listID, members := range s.listMembers {
+		c.listMembers[listID] = make(map[int64]*memoryListMember, len(members))
+		for userID, m := range members {
+			copied := *m
+			c.listMembers[listID][userID] = &copied
+		}
+	}
+	for id, revisions := range s.revisions {
+		c.revisions[id] = append([]*model.Revision(nil), revisions...)
+	}
+	for id, u := range s.users {
+		copied := *u
+		c.users[id] = &copied
+	}
+	for id, t := range s.tokens {
+		copied := *t
+		c.tokens[id] = &copied
+	}
+	for id, session := range s.sessions {
+		copied := *session
+		c.sessions[id] = &copied
+	}
+	for hash, r := range s.refreshTokens {
+		copied := *r
+		c.refreshTokens[hash] = &copied
+	}
+	c.lastTODOID = s.lastTODOID
+	c.lastTagID = s.lastTagID
+	c.lastListID = s.lastListID
+	c.lastRevisionID = s.lastRevisionID
+	c.lastUserID = s.lastUserID
+	c.lastTokenID = s.lastTokenID
+	c.lastSessionID = s.lastSessionID
+	return c
+}
+
+// restore は s の状態を clone で複製した saved に戻します。呼び出し元は s.mu をロックしてください。
+func (s *MemoryStore) restore(saved *MemoryStore) {
+	s.todos = saved.todos
+	s.tags = saved.tags
+	s.lists = saved.lists
+	s.listMembers = saved.listMembers
+	s.revisions = saved.revisions
+	s.users = saved.users
+	s.tokens = saved.tokens
+	s.sessions = saved.sessions
+	s.refreshTokens = saved.refreshTokens
+	s.lastTODOID = saved.lastTODOID
+	s.lastTagID = saved.lastTagID
+	s.lastListID = saved.lastListID
+	s.lastRevisionID = saved.lastRevisionID
+	s.lastUserID = saved.lastUserID
+	s.lastTokenID = saved.lastTokenID
+	s.lastSessionID = saved.lastSessionID
+}
+
+// memoryNow は DATETIME('now') と同じく、現在時刻を UTC の秒単位で返します。
+func memoryNow() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// storeTime は dbTime と同じく、時刻を UTC の秒単位に丸めた複製を返します。
+func storeTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC().Truncate(time.Second)
+	return &u
+}
+
+// copyTime と copyInt64 は、呼び出し元が値を書き換えても保持している値が変わらないよう複製します。
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+func copyInt64(n *int64) *int64 {
+	if n == nil {
+		return nil
+	}
+	c := *n
+	return &c
+}
+
+// errCheckConstraint は、SQLite の CHECK 制約に違反した場合と同じエラーを返します。
+func errCheckConstraint(expr string) error {
+	return fmt.Errorf("CHECK constraint failed: %s", expr)
+}
+
+// touch は、トリガーと同じく updated_at を更新します。bump が true の場合は version も増やします。
+func (t *memoryTODO) touch(now time.Time, bump bool) {
+	t.updatedAt = now
+	if bump {
+		t.version++
+	}
+}
+
+// sortedTODOs は match を満たす TODO を id の昇順で返します。
+func (s *MemoryStore) sortedTODOs(match func(*memoryTODO) bool) []*memoryTODO {
+	var todos []*memoryTODO
+	for _, t := range s.todos {
+		if match(t) {
+			todos = append(todos, t)
+		}
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		return todos[i].id < todos[j].id
+	})
+	return todos
+}
+
+// canAccess は ctx の利用者が t を読める (write が true の場合は変更できる) かを返します。
+// todoAccessFilter と同じく、利用者のない ctx ではすべての TODO が対象です。
+func (s *MemoryStore) canAccess(ctx context.Context, t *memoryTODO, write bool) bool {
+	user, ok := UserFromContext(ctx)
+	if !ok || (t.ownerID != nil && *t.ownerID == user.ID) {
+		return true
+	}
+	if t.listID == nil {
+		return false
+	}
+	switch s.listRole(ctx, *t.listID) {
+	case model.ListRoleOwner, model.ListRoleEditor:
+		return true
+	case model.ListRoleViewer:
+		return !write
+	}
+	return false
+}
+
+// canRead は ctx の利用者が t を読めるかを返します。
+func (s *MemoryStore) canRead(ctx context.Context, t *memoryTODO) bool {
+	return s.canAccess(ctx, t, false)
+}
+
+// checkWritable は ctx の利用者が読める t を変更できない場合に ErrForbidden を返します。
+func (s *MemoryStore) checkWritable(ctx context.Context, t *memoryTODO) error {
+	if !s.canAccess(ctx, t, true) {
+		return &model.ErrForbidden{Message: fmt.Sprintf("TODO %d is read-only for you", t.id)}
+	}
+	return nil
+}
+
+// liveTODO は id の TODO がゴミ箱になく、ctx の利用者が読めれば返します。
+func (s *MemoryStore) liveTODO(ctx context.Context, id int64) (*memoryTODO, bool) {
+	t, ok := s.todos[id]
+	if !ok || t.deletedAt != nil || !s.canRead(ctx, t) {
+		return nil, false
+	}
+	return t, true
+}
+
+// archived は t がアーカイブされたリストにあるかを返します。
+func (s *MemoryStore) archived(t *memoryTODO) bool {
+	if t.listID == nil {
+		return false
+	}
+	l, ok := s.lists[*t.listID]
+	return ok && l.archivedAt != nil
+}
+
+// view は t を model.TODO に変換します。子の進捗、次の回の id、タグは todoColumns と同じく集計し、ctx の利用者が読めない TODO は数えません。
+func (s *MemoryStore) view(ctx context.Context, t *memoryTODO) *model.TODO {
+	todo := &model.TODO{
+		ID:          t.id,
+		Subject:     t.subject,
+		Description: t.description,
+		Done:        t.completedAt != nil,
+		CompletedAt: copyTime(t.completedAt),
+		DueAt:       copyTime(t.dueAt),
+		Priority:    t.priority,
+		Tags:        s.tagNames(t),
+		ParentID:    copyInt64(t.parentID),
+		Recurrence:  t.recurrence,
+		Occurrence:  t.occurrence,
+		PreviousID:  copyInt64(t.previousID),
+		ListID:      copyInt64(t.listID),
+		DeletedAt:   copyTime(t.deletedAt),
+		Version:     t.version,
+		CreatedAt:   t.createdAt,
+		UpdatedAt:   t.updatedAt,
+	}
+	for _, c := range s.todos {
+		if c.deletedAt != nil || !s.canRead(ctx, c) {
+			continue
+		}
+		if c.parentID != nil && *c.parentID == t.id {
+			todo.Progress.Total++
+			if c.completedAt != nil {
+				todo.Progress.Done++
+			}
+		}
+		if c.previousID != nil && *c.previousID == t.id && (todo.NextID == nil || c.id < *todo.NextID) {
+			id := c.id
+			todo.NextID = &id
+		}
+	}
+	return todo
+}
+
+// views は todos を順に model.TODO に変換します。結果が 0 件の場合は空スライスを返します。
+func (s *MemoryStore) views(ctx context.Context, todos []*memoryTODO) []*model.TODO {
+	result := make([]*model.TODO, len(todos))
+	for i, t := range todos {
+		result[i] = s.view(ctx, t)
+	}
+	return result
+}
+
+// tagNames は t のタグ名を名前順で返します。タグがない場合は nil を返します。
+func (s *MemoryStore) tagNames(t *memoryTODO) []string {
+	var names []string
+	for id := range t.tagIDs {
+		names = append(names, s.tags[id])
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tagID は name のタグの id を返します。
+func (s *MemoryStore) tagID(name string) (int64, bool) {
+	for id, n := range s.tags {
+		if n == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// setTags は t のタグを names で置き換えます。存在しないタグは作成します。
+func (s *MemoryStore) setTags(t *memoryTODO, names []string) {
+	t.tagIDs = map[int64]bool{}
+	for _, name := range uniqueStrings(names) {
+		id, ok := s.tagID(name)
+		if !ok {
+			s.lastTagID++
+			id = s.lastTagID
+			s.tags[id] = name
+		}
+		t.tagIDs[id] = true
+	}
+}
+
+// recordRevision は t の現在の状態を action の変更履歴として記録します。
+func (s *MemoryStore) recordRevision(ctx context.Context, action string, t *memoryTODO) {
+	s.lastRevisionID++
+	s.revisions[t.id] = append(s.revisions[t.id], &model.Revision{
+		ID:          s.lastRevisionID,
+		TODOID:      t.id,
+		Revision:    int64(len(s.revisions[t.id])) + 1,
+		Action:      action,
+		Actor:       ActorFromContext(ctx),
+		Subject:     t.subject,
+		Description: t.description,
+		Done:        t.completedAt != nil,
+		CompletedAt: copyTime(t.completedAt),
+		DueAt:       copyTime(t.dueAt),
+		Priority:    t.priority,
+		CreatedAt:   memoryNow(),
+	})
+}
+
+// ReadTags reads the tags visible to the user of ctx ordered by name with the number of TODOs they are attached to.
+func (s *MemoryStore) ReadTags(ctx context.Context) ([]*model.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tags := []*model.Tag{}
+	for id := range s.tags {
+		if s.tagVisible(ctx, id) {
+			tags = append(tags, s.tagView(ctx, id))
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+// RenameTag renames the tag. It returns *model.ErrConflict if another tag already has the name;
+// use MergeTags to combine them.
+//
+// タグが ctx の利用者の変更できない TODO にも付いている場合は、変更できる TODO だけを name のタグに付け替えます。
+func (s *MemoryStore) RenameTag(ctx context.Context, id int64, name string) (*model.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.tagVisible(ctx, id) {
+		return nil, &model.ErrNotFound{}
+	}
+	otherID, exists := s.tagID(name)
+	if exists && otherID == id {
+		return s.tagView(ctx, id), nil
+	}
+	// 利用者に見えないタグと名前が重なる場合は、重複を知らせずにそのタグへ付け替える
+	if exists && s.tagVisible(ctx, otherID) {
+		return nil, &model.ErrConflict{Message: "tag " + name + " already exists"}
+	}
+	if name == "" {
+		return nil, errCheckConstraint("name <> ''")
+	}
+
+	var writable, total int
+	for _, t := range s.todos {
+		if t.tagIDs[id] {
+			total++
+			if s.canAccess(ctx, t, true) {
+				writable++
+			}
+		}
+	}
+	if !exists && writable == total {
+		s.tags[id] = name
+		return s.tagView(ctx, id), nil
+	}
+	if writable == 0 {
+		return nil, &model.ErrForbidden{Message: fmt.Sprintf("tag %d is attached only to TODOs that are read-only for you", id)}
+	}
+	if !exists {
+		s.lastTagID++
+		otherID = s.lastTagID
+		s.tags[otherID] = name
+	}
+	s.moveTags(ctx, []int64{id}, otherID)
+	return s.tagView(ctx, otherID), nil
+}
+
+// MergeTags moves every TODO tagged with one of sourceIDs to targetID and deletes the source tags.
+//
+// ctx の利用者が変更できる TODO だけを付け替え、他の TODO に付いたままの統合元のタグは削除しません。
+func (s *MemoryStore) MergeTags(ctx context.Context, sourceIDs []int64, targetID int64) (*model.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 自分自身へのマージは何もしない
+	var sources []int64
+	for _, id := range uniqueInt64s(sourceIDs) {
+		if id != targetID {
+			sources = append(sources, id)
+		}
+	}
+
+	// 存在しないタグや利用者に見えないタグが含まれる場合は ErrNotFound を返す
+	for _, id := range append([]int64{targetID}, sources...) {
+		if !s.tagVisible(ctx, id) {
+			return nil, &model.ErrNotFound{}
+		}
+	}
+
+	s.moveTags(ctx, sources, targetID)
+	return s.tagView(ctx, targetID), nil
+}
+
+// moveTags は、sourceIDs のタグが付いた TODO のうち ctx の利用者が変更できるものを targetID のタグに付け替え、
+// どの TODO にも付いていない統合元のタグを削除します。
+func (s *MemoryStore) moveTags(ctx context.Context, sourceIDs []int64, targetID int64) {
+	for _, t := range s.todos {
+		if !s.canAccess(ctx, t, true) {
+			continue
+		}
+		for _, id := range sourceIDs {
+			if t.tagIDs[id] {
+				delete(t.tagIDs, id)
+				t.tagIDs[targetID] = true
+			}
+		}
+	}
+	for _, id := range sourceIDs {
+		attached := false
+		for _, t := range s.todos {
+			if t.tagIDs[id] {
+				attached = true
+				break
+			}
+		}
+		if !attached {
+			delete(s.tags, id)
+		}
+	}
+}
+
+// tagVisible は、id のタグが ctx の利用者の読める TODO に付いているかを返します。
+// 利用者のない ctx では、TODO の付いていないタグも含めてすべてのタグが見えます。
+func (s *MemoryStore) tagVisible(ctx context.Context, id int64) bool {
+	if _, ok := s.tags[id]; !ok {
+		return false
+	}
+	if _, ok := UserFromContext(ctx); !ok {
+		return true
+	}
+	for _, t := range s.todos {
+		if t.tagIDs[id] && s.canRead(ctx, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// tagView は id のタグを、ctx の利用者が読めるゴミ箱にない TODO の件数付きで返します。
+func (s *MemoryStore) tagView(ctx context.Context, id int64) *model.Tag {
+	tag := &model.Tag{ID: id, Name: s.tags[id]}
+	for _, t := range s.todos {
+		if t.deletedAt == nil && t.tagIDs[id] && s.canRead(ctx, t) {
+			tag.TODOCount++
+		}
+	}
+	return tag
+}
+
+// CreateList creates a list in memory.
+func (s *MemoryStore) CreateList(ctx context.Context, name, description string) (*model.List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if name == "" {
+		return nil, errCheckConstraint("name <> ''")
+	}
+
+	now := memoryNow()
+	s.lastListID++
+	l := &memoryList{
+		id:          s.lastListID,
+		name:        name,
+		description: description,
+		ownerID:     ownerID(ctx),
+		createdAt:   now,
+		updatedAt:   now,
+	}
+	s.lists[l.id] = l
+	return s.listView(ctx, l), nil
+}
+
+// ReadLists reads lists that the user of ctx can read ordered by id. Archived lists are included only if includeArchived is true.
+func (s *MemoryStore) ReadLists(ctx context.Context, includeArchived bool) ([]*model.List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lists := []*model.List{}
+	for _, l := range s.lists {
+		if (includeArchived || l.archivedAt == nil) && s.listReadable(ctx, l.id) {
+			lists = append(lists, s.listView(ctx, l))
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return lists[i].ID < lists[j].ID
+	})
+	return lists, nil
+}
+
+// UpdateList updates the name and description of the list.
+// archived が nil でない場合は、リストをアーカイブ (true) またはアーカイブから戻し (false) ます。
+// リストを変更できるのは所有者と編集者です。
+func (s *MemoryStore) UpdateList(ctx context.Context, id int64, name, description string, archived *bool) (*model.List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkListRole(ctx, id, model.ListRoleOwner, model.ListRoleEditor); err != nil {
+		return nil, err
+	}
+	l := s.lists[id]
+	if name == "" {
+		return nil, errCheckConstraint("name <> ''")
+	}
+
+	now := memoryNow()
+	l.name = name
+	l.description = description
+	if archived != nil {
+		// 既にアーカイブ済みのリストを再度アーカイブしても archived_at は更新しない
+		if !*archived {
+			l.archivedAt = nil
+		} else if l.archivedAt == nil {
+			l.archivedAt = &now
+		}
+	}
+	l.updatedAt = now
+	return s.listView(ctx, l), nil
+}
+
+// DeleteLists deletes lists by ids. TODOs in the lists are kept and no longer belong to any list.
+// ctx の利用者が読めないリストは存在しないものとして除きます。リストを削除できるのは所有者だけです。
+func (s *MemoryStore) DeleteLists(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 読めるが所有していないリストが含まれる場合は 1 件も削除しない
+	deleted := map[int64]bool{}
+	for _, id := range ids {
+		switch err := s.checkListRole(ctx, id, model.ListRoleOwner); {
+		case err == nil:
+			deleted[id] = true
+		case model.IsErrForbidden(err):
+			return &model.ErrForbidden{Message: fmt.Sprintf("only the owner can delete list %d", id)}
+		}
+	}
+	if len(deleted) == 0 {
+		return &model.ErrNotFound{}
+	}
+	for id := range deleted {
+		delete(s.lists, id)
+		delete(s.listMembers, id)
+	}
+
+	now := memoryNow()
+	for _, t := range s.todos {
+		if t.listID != nil && deleted[*t.listID] {
+			t.listID = nil
+			t.touch(now, true)
+		}
+	}
+	return nil
+}
+
+// listView は l を、ゴミ箱にない TODO の件数と ctx の利用者の役割付きで model.List に変換します。
+func (s *MemoryStore) listView(ctx context.Context, l *memoryList) *model.List {
+	list := &model.List{
+		ID:          l.id,
+		Name:        l.name,
+		Description: l.description,
+		Archived:    l.archivedAt != nil,
+		ArchivedAt:  copyTime(l.archivedAt),
+		Role:        s.listRole(ctx, l.id),
+		CreatedAt:   l.createdAt,
+		UpdatedAt:   l.updatedAt,
+	}
+	for _, t := range s.todos {
+		if t.deletedAt == nil && t.listID != nil && *t.listID == l.id {
+			list.TODOCount++
+		}
+	}
+	return list
+}
+
+// checkList は TODO を listID のリストに入れられることを確認します。
+// リストが存在しないか ctx の利用者が読めない場合は ErrValidation を、閲覧者の場合は ErrForbidden を、
+// アーカイブされている場合は ErrConflict を返します。
+func (s *MemoryStore) checkList(ctx context.Context, listID int64) error {
+	if err := s.checkListRole(ctx, listID, model.ListRoleOwner, model.ListRoleEditor); err != nil {
+		if model.IsErrNotFound(err) {
+			return &model.ErrValidation{Field: "list_id", Message: fmt.Sprintf("list %d does not exist", listID)}
+		}
+		return err
+	}
+	if l := s.lists[listID]; l.archivedAt != nil {
+		return &model.ErrConflict{Message: fmt.Sprintf("list %d is archived", listID)}
+	}
+	return nil
+}
+
+// listRole は ctx の利用者の id のリストでの役割を返します。利用者のない ctx や、役割のない利用者では空文字列です。
+func (s *MemoryStore) listRole(ctx context.Context, id int64) string {
+	user, ok := UserFromContext(ctx)
+	l, found := s.lists[id]
+	if !ok || !found {
+		return ""
+	}
+	if l.ownerID != nil && *l.ownerID == user.ID {
+		return model.ListRoleOwner
+	}
+	if m, ok := s.listMembers[id][user.ID]; ok {
+		return m.role
+	}
+	return ""
+}
+
+// listReadable は ctx の利用者が id のリストを読めるかを返します。
+func (s *MemoryStore) listReadable(ctx context.Context, id int64) bool {
+	return s.checkListRole(ctx, id, model.ListRoleOwner, model.ListRoleEditor, model.ListRoleViewer) == nil
+}
+
+// checkListRole は id のリストが存在しないか ctx の利用者が読めない場合に ErrNotFound を、
+// 利用者の役割が roles のいずれでもない場合に ErrForbidden を返します。利用者のない ctx ではすべてのリストを扱えます。
+func (s *MemoryStore) checkListRole(ctx context.Context, id int64, roles ...string) error {
+	if _, ok := s.lists[id]; !ok {
+		return &model.ErrNotFound{}
+	}
+	if _, ok := UserFromContext(ctx); !ok {
+		return nil
+	}
+	current := s.listRole(ctx, id)
+	if current == "" {
+		return &model.ErrNotFound{}
+	}
+	for _, r := range roles {
+		if current == r {
+			return nil
+		}
+	}
+	return &model.ErrForbidden{Message: fmt.Sprintf("the %s of list %d can't do this", current, id)}
+}
+
+// ReadListMembers reads the owner and the members of the list. 所有者を先頭に、共有した順に返します。
+func (s *MemoryStore) ReadListMembers(ctx context.Context, listID int64) ([]*model.ListMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkListRole(ctx, listID, model.ListRoleOwner, model.ListRoleEditor, model.ListRoleViewer); err != nil {
+		return nil, err
+	}
+
+	members := []*model.ListMember{}
+	l := s.lists[listID]
+	if l.ownerID != nil {
+		if u, ok := s.users[*l.ownerID]; ok {
+			members = append(members, &model.ListMember{UserID: u.ID, Name: u.Name, Role: model.ListRoleOwner, CreatedAt: l.createdAt})
+		}
+	}
+	var shared []*model.ListMember
+	for userID := range s.listMembers[listID] {
+		shared = append(shared, s.listMemberView(listID, userID))
+	}
+	sort.Slice(shared, func(i, j int) bool {
+		if !shared[i].CreatedAt.Equal(shared[j].CreatedAt) {
+			return shared[i].CreatedAt.Before(shared[j].CreatedAt)
+		}
+		return shared[i].UserID < shared[j].UserID
+	})
+	return append(members, shared...), nil
+}
+
+// PutListMember shares the list with the user named userName as role, or changes the role of the user.
+// 共有できるのはリストの所有者だけです。存在しない利用者や所有者自身を指定した場合は ErrValidation を返します。
+func (s *MemoryStore) PutListMember(ctx context.Context, listID int64, userName, role string) (*model.ListMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkListRole(ctx, listID, model.ListRoleOwner); err != nil {
+		return nil, err
+	}
+	u, ok := s.userByName(userName)
+	if !ok {
+		return nil, &model.ErrValidation{Field: "name", Message: fmt.Sprintf("user %q does not exist", userName)}
+	}
+	if l := s.lists[listID]; l.ownerID != nil && *l.ownerID == u.ID {
+		return nil, &model.ErrValidation{Field: "name", Message: fmt.Sprintf("user %q is the owner of list %d", userName, listID)}
+	}
+	if role != model.ListRoleEditor && role != model.ListRoleViewer {
+		return nil, errCheckConstraint("role IN ('editor', 'viewer')")
+	}
+
+	members, ok := s.listMembers[listID]
+	if !ok {
+		members = map[int64]*memoryListMember{}
+		s.listMembers[listID] = members
+	}
+	if m, ok := members[u.ID]; ok {
+		m.role = role
+	} else {
+		members[u.ID] = &memoryListMember{role: role, createdAt: memoryNow()}
+	}
+	return s.listMemberView(listID, u.ID), nil
+}
+
+// DeleteListMember stops sharing the list with the user named userName.
+// 共有をやめられるのはリストの所有者と、リストから抜ける利用者自身です。
+func (s *MemoryStore) DeleteListMember(ctx context.Context, listID int64, userName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.userByName(userName)
+	if !ok {
+		return &model.ErrNotFound{}
+	}
+	if err := s.checkListRole(ctx, listID, model.ListRoleOwner); err != nil {
+		user, ok := UserFromContext(ctx)
+		if !model.IsErrForbidden(err) || !ok || user.ID != u.ID {
+			return err
+		}
+	}
+
+	if _, ok := s.listMembers[listID][u.ID]; !ok {
+		return &model.ErrNotFound{}
+	}
+	delete(s.listMembers[listID], u.ID)
+	return nil
+}
+
+// listMemberView は listID のリストのメンバー userID を model.ListMember に変換します。
+func (s *MemoryStore) listMemberView(listID, userID int64) *model.ListMember {
+	m := s.listMembers[listID][userID]
+	return &model.ListMember{UserID: userID, Name: s.users[userID].Name, Role: m.role, CreatedAt: m.createdAt}
+}
+
+// CreateUser creates a user. 同じ名前の利用者が存在する場合は ErrConflict を返します。
+func (s *MemoryStore) CreateUser(ctx context.Context, name, passwordHash string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if name == "" {
+		return nil, errCheckConstraint("name <> ''")
+	}
+	for _, u := range s.users {
+		if u.Name == name {
+			return nil, &model.ErrConflict{Message: "user " + name + " already exists"}
+		}
+	}
+
+	now := memoryNow()
+	s.lastUserID++
+	u := &model.User{
+		ID:           s.lastUserID,
+		Name:         name,
+		PasswordHash: passwordHash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	s.users[u.ID] = u
+	copied := *u
+	return &copied, nil
+}
+
+// ReadUserByName reads the user of name.
+func (s *MemoryStore) ReadUserByName(ctx context.Context, name string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.userByName(name)
+	if !ok {
+		return nil, &model.ErrNotFound{}
+	}
+	copied := *u
+	return &copied, nil
+}
+
+// userByName は name の利用者を返します。
+func (s *MemoryStore) userByName(name string) (*model.User, bool) {
+	for _, u := range s.users {
+		if u.Name == name {
+			return u, true
+		}
+	}
+	return nil, false
+}
+
+// UpdateUserPassword replaces the password hash of the user.
+func (s *MemoryStore) UpdateUserPassword(ctx context.Context, id int64, passwordHash string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, &model.ErrNotFound{}
+	}
+	u.PasswordHash = passwordHash
+	u.UpdatedAt = memoryNow()
+	copied := *u
+	return &copied, nil
+}
+
+// UpdateUserAdmin sets whether the user is an administrator.
+func (s *MemoryStore) UpdateUserAdmin(ctx context.Context, id int64, admin bool) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, &model.ErrNotFound{}
+	}
+	u.Admin = admin
+	u.UpdatedAt = memoryNow()
+	copied := *u
+	return &copied, nil
+}
+
+// CreateToken creates a token of the user of ctx.
+func (s *MemoryStore) CreateToken(ctx context.Context, name, tokenHash string, scopes []string, expiresAt *time.Time) (*model.APIToken, error) {
+	userID := ownerID(ctx)
+	if userID == nil {
+		return nil, &model.ErrUnauthorized{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if name == "" {
+		return nil, errCheckConstraint("name <> ''")
+	}
+	for _, t := range s.tokens {
+		if t.hash == tokenHash {
+			return nil, fmt.Errorf("UNIQUE constraint failed: api_tokens.token_hash")
+		}
+	}
+
+	s.lastTokenID++
+	t := &memoryToken{
+		token: model.APIToken{
+			ID:        s.lastTokenID,
+			UserID:    *userID,
+			Name:      name,
+			Scopes:    append([]string(nil), scopes...),
+			ExpiresAt: storeTime(expiresAt),
+			CreatedAt: memoryNow(),
+		},
+		hash: tokenHash,
+	}
+	s.tokens[t.token.ID] = t
+	return t.view(), nil
+}
+
+// ReadTokens reads the tokens of the user of ctx ordered by id, including revoked and expired tokens.
+func (s *MemoryStore) ReadTokens(ctx context.Context) ([]*model.APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens := []*model.APIToken{}
+	for _, t := range s.tokens {
+		if ownsToken(ctx, t) {
+			tokens = append(tokens, t.view())
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ID < tokens[j].ID
+	})
+	return tokens, nil
+}
+
+// RevokeToken revokes the token of the user of ctx. 取り消し済みのトークンはそのまま返します。
+func (s *MemoryStore) RevokeToken(ctx context.Context, id int64) (*model.APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[id]
+	if !ok || !ownsToken(ctx, t) {
+		return nil, &model.ErrNotFound{}
+	}
+	if t.token.RevokedAt == nil {
+		now := memoryNow()
+		t.token.RevokedAt = &now
+	}
+	return t.view(), nil
+}
+
+// ReadTokenByHash reads the token whose hash is tokenHash and its user.
+func (s *MemoryStore) ReadTokenByHash(ctx context.Context, tokenHash string) (*model.APIToken, *model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tokens {
+		if t.hash != tokenHash {
+			continue
+		}
+		u, ok := s.users[t.token.UserID]
+		if !ok {
+			break
+		}
+		user := *u
+		return t.view(), &user, nil
+	}
+	return nil, nil, &model.ErrNotFound{}
+}
+
+// MarkTokenUsed records that the token was used at now.
+// 前回の記録から tokenUsedInterval が経っていない場合は記録しません。
+func (s *MemoryStore) MarkTokenUsed(ctx context.Context, id int64, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[id]
+	if !ok {
+		return nil
+	}
+	if t.token.LastUsedAt == nil || !t.token.LastUsedAt.After(now.Add(-tokenUsedInterval)) {
+		t.token.LastUsedAt = storeTime(&now)
+	}
+	return nil
+}
+
+// ownsToken は t が ctx の利用者のトークンかを返します。利用者のない ctx ではすべてのトークンが対象です。
+func ownsToken(ctx context.Context, t *memoryToken) bool {
+	user, ok := UserFromContext(ctx)
+	return !ok || t.token.UserID == user.ID
+}
+
+// view は、呼び出し元が書き換えても保持している値が変わらないよう t のトークンを複製します。
+func (t *memoryToken) view() *model.APIToken {
+	token := t.token
+	token.Scopes = append([]string(nil), t.token.Scopes...)
+	token.ExpiresAt = copyTime(t.token.ExpiresAt)
+	token.LastUsedAt = copyTime(t.token.LastUsedAt)
+	token.RevokedAt = copyTime(t.token.RevokedAt)
+	return &token
+}
+
+// CreateSession creates a session of the user of ctx.
+func (s *MemoryStore) CreateSession(ctx context.Context, refreshTokenHash string, expiresAt time.Time) (*model.Session, error) {
+	userID := ownerID(ctx)
+	if userID == nil {
+		return nil, &model.ErrUnauthorized{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.refreshTokens[refreshTokenHash]; ok {
+		return nil, fmt.Errorf("UNIQUE constraint failed: refresh_tokens.token_hash")
+	}
+
+	s.lastSessionID++
+	session := &model.Session{
+		ID:        s.lastSessionID,
+		UserID:    *userID,
+		ExpiresAt: *storeTime(&expiresAt),
+		CreatedAt: memoryNow(),
+	}
+	s.sessions[session.ID] = session
+	s.refreshTokens[refreshTokenHash] = &memoryRefreshToken{sessionID: session.ID}
+	return sessionView(session), nil
+}
+
+// ReadSession reads the session and its user.
+func (s *MemoryStore) ReadSession(ctx context.Context, id int64) (*model.Session, *model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sessionUser(id)
+}
+
+// RotateRefreshToken replaces the refresh token with a new one.
+func (s *MemoryStore) RotateRefreshToken(ctx context.Context, refreshTokenHash, newRefreshTokenHash string) (*model.Session, *model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.refreshTokens[refreshTokenHash]
+	if !ok {
+		return nil, nil, &model.ErrNotFound{}
+	}
+	session, user, err := s.sessionUser(r.sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.RevokedAt != nil || !session.ExpiresAt.After(time.Now()) {
+		return nil, nil, &model.ErrNotFound{}
+	}
+	now := memoryNow()
+	if r.usedAt != nil {
+		s.sessions[session.ID].RevokedAt = &now
+		return nil, nil, &model.ErrConflict{Message: "the refresh token has already been used"}
+	}
+	if _, ok := s.refreshTokens[newRefreshTokenHash]; ok {
+		return nil, nil, fmt.Errorf("UNIQUE constraint failed: refresh_tokens.token_hash")
+	}
+
+	r.usedAt = &now
+	s.refreshTokens[newRefreshTokenHash] = &memoryRefreshToken{sessionID: session.ID}
+	return session, user, nil
+}
+
+// RevokeSession revokes the session of the refresh token. 取り消し済みのセッションはそのまま返します。
+func (s *MemoryStore) RevokeSession(ctx context.Context, refreshTokenHash string) (*model.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.refreshTokens[refreshTokenHash]
+	if !ok {
+		return nil, &model.ErrNotFound{}
+	}
+	session := s.sessions[r.sessionID]
+	if session.RevokedAt == nil {
+		now := memoryNow()
+		session.RevokedAt = &now
+	}
+	return sessionView(session), nil
+}
+
+// sessionUser は id のセッションとその利用者を複製して返します。呼び出し元は s.mu をロックしてください。
+func (s *MemoryStore) sessionUser(id int64) (*model.Session, *model.User, error) {
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, nil, &model.ErrNotFound{}
+	}
+	u, ok := s.users[session.UserID]
+	if !ok {
+		return nil, nil, &model.ErrNotFound{}
+	}
+	user := *u
+	return sessionView(session), &user, nil
+}
+
+// sessionView は、呼び出し元が書き換えても保持している値が変わらないよう session を複製します。
+func sessionView(session *model.Session) *model.Session {
+	copied := *session
+	copied.RevokedAt = copyTime(session.RevokedAt)
+	return &copied
+}
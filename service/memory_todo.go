@@ -0,0 +1,897 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/recurrence"
+)
+
+// CreateTODO creates a TODO in memory.
+func (s *MemoryStore) CreateTODO(ctx context.Context, subject, description string, opts ...TODOOption) (*model.TODO, error) {
+	attrs, err := newTODOAttributes(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createTODO(ctx, subject, description, attrs)
+}
+
+// createTODO は TODO を作成します。呼び出し元は s.mu をロックしてください。
+func (s *MemoryStore) createTODO(ctx context.Context, subject, description string, attrs *todoAttributes) (*model.TODO, error) {
+	if attrs.parentID != nil {
+		if err := s.checkParent(ctx, 0, *attrs.parentID); err != nil {
+			return nil, err
+		}
+	}
+	if attrs.listID != nil {
+		if err := s.checkList(ctx, *attrs.listID); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkTODOConstraints(subject, attrs); err != nil {
+		return nil, err
+	}
+
+	now := memoryNow()
+	s.lastTODOID++
+	t := &memoryTODO{
+		id:          s.lastTODOID,
+		subject:     subject,
+		description: description,
+		dueAt:       storeTime(attrs.dueAt),
+		parentID:    copyInt64(attrs.parentID),
+		recurrence:  attrs.recurrence,
+		occurrence:  occurrenceFor(attrs.recurrence, 0),
+		listID:      copyInt64(attrs.listID),
+		ownerID:     ownerID(ctx),
+		version:     1,
+		createdAt:   now,
+		updatedAt:   now,
+		tagIDs:      map[int64]bool{},
+	}
+	if attrs.done != nil && *attrs.done {
+		t.completedAt = &now
+	}
+	if attrs.priority != nil {
+		t.priority = *attrs.priority
+	}
+	s.todos[t.id] = t
+
+	if attrs.tagsSet {
+		s.setTags(t, attrs.tags)
+	}
+	s.recordRevision(ctx, model.RevisionActionCreate, t)
+
+	return s.view(ctx, t), nil
+}
+
+// checkTODOConstraints は todos と tags の CHECK 制約を確認します。
+// SQLite ではトランザクションのロールバックで元に戻る変更を、変更の前に確認して防ぎます。
+func checkTODOConstraints(subject string, attrs *todoAttributes) error {
+	if subject == "" {
+		return errCheckConstraint("subject <> ''")
+	}
+	if attrs.priority != nil && (*attrs.priority < model.PriorityNone || *attrs.priority > model.PriorityHigh) {
+		return errCheckConstraint("priority BETWEEN 0 AND 3")
+	}
+	for _, tag := range attrs.tags {
+		if tag == "" {
+			return errCheckConstraint("name <> ''")
+		}
+	}
+	return nil
+}
+
+// ReadTODO reads TODOs in memory.
+func (s *MemoryStore) ReadTODO(ctx context.Context, prevID, size int64, opts ...ReadOption) ([]*model.TODO, error) {
+	// size が 0 以下の場合、空スライスを返す
+	if size <= 0 {
+		return []*model.TODO{}, nil
+	}
+
+	filter := newReadFilter(opts)
+
+	// SQLite の実装と同じ順序で条件を検証する
+	switch filter.status {
+	case "", model.TODOStatusAll, model.TODOStatusDone, model.TODOStatusUndone:
+	default:
+		return nil, fmt.Errorf("unknown status: %q", filter.status)
+	}
+	if len(filter.tags) > 0 {
+		switch filter.tagMode {
+		case "", model.TagModeAnd, model.TagModeOr:
+		default:
+			return nil, fmt.Errorf("unknown tag mode: %q", filter.tagMode)
+		}
+	}
+	if !IsValidSort(filter.sort) {
+		return nil, fmt.Errorf("unknown sort key: %q", filter.sort)
+	}
+	if filter.order != model.SortOrderAsc && filter.order != model.SortOrderDesc {
+		return nil, fmt.Errorf("unknown sort order: %q", filter.order)
+	}
+	if c := filter.cursor; c != nil && (c.Sort != filter.sort || c.Order != filter.order) {
+		return nil, ErrInvalidCursor
+	}
+	// 昇順の場合は比較結果を反転して、降順と同じく「後ろ」を負の値で表す
+	dir := 1
+	if filter.order == model.SortOrderAsc {
+		dir = -1
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := formatDBTime(memoryNow())
+	todos := s.sortedTODOs(func(t *memoryTODO) bool {
+		if t.deletedAt != nil || !s.canRead(ctx, t) {
+			return false
+		}
+		if prevID > 0 && t.id >= prevID {
+			return false
+		}
+		switch filter.status {
+		case model.TODOStatusDone:
+			if t.completedAt == nil {
+				return false
+			}
+		case model.TODOStatusUndone:
+			if t.completedAt != nil {
+				return false
+			}
+		}
+		if filter.overdue && (t.dueAt == nil || formatDBTime(*t.dueAt) >= now || t.completedAt != nil) {
+			return false
+		}
+		if filter.dueBefore != nil && (t.dueAt == nil || formatDBTime(*t.dueAt) >= formatDBTime(*filter.dueBefore)) {
+			return false
+		}
+		if filter.dueAfter != nil && (t.dueAt == nil || formatDBTime(*t.dueAt) <= formatDBTime(*filter.dueAfter)) {
+			return false
+		}
+		if len(filter.tags) > 0 && !s.hasTags(t, filter.tags, filter.tagMode) {
+			return false
+		}
+		if filter.listIDSet {
+			if filter.listID == nil {
+				if t.listID != nil {
+					return false
+				}
+			} else if t.listID == nil || *t.listID != *filter.listID {
+				return false
+			}
+		} else if !filter.includeArchived && s.archived(t) {
+			// リストを指定しない場合は、アーカイブされたリストの TODO を含めない
+			return false
+		}
+		if c := filter.cursor; c != nil {
+			cmp := compareSortValues(sortValue(t, filter.sort), c.arg())
+			if cmp == 0 {
+				cmp = compareSortValues(t.id, c.ID)
+			}
+			if cmp*dir >= 0 {
+				return false
+			}
+		}
+		return true
+	})
+
+	// 同じ値の TODO の順序が一意に定まるよう、id を第 2 キーにする
+	sort.SliceStable(todos, func(i, j int) bool {
+		cmp := compareSortValues(sortValue(todos[i], filter.sort), sortValue(todos[j], filter.sort))
+		if cmp == 0 {
+			cmp = compareSortValues(todos[i].id, todos[j].id)
+		}
+		return cmp*dir > 0
+	})
+	if int64(len(todos)) > size {
+		todos = todos[:size]
+	}
+	return s.views(ctx, todos), nil
+}
+
+// hasTags は、t に tags がすべて (model.TagModeAnd) またはいずれか (model.TagModeOr) 付いているかを返します。
+func (s *MemoryStore) hasTags(t *memoryTODO, tags []string, mode string) bool {
+	names := map[string]bool{}
+	for id := range t.tagIDs {
+		names[s.tags[id]] = true
+	}
+	for _, tag := range uniqueStrings(tags) {
+		if mode == model.TagModeOr && names[tag] {
+			return true
+		}
+		if mode != model.TagModeOr && !names[tag] {
+			return false
+		}
+	}
+	return mode != model.TagModeOr
+}
+
+// sortValue は、sortExprs の式が t に対して返す値です。Cursor.arg の値と比較できます。
+func sortValue(t *memoryTODO, key string) interface{} {
+	switch key {
+	case model.TODOSortPriority:
+		return t.priority
+	case model.TODOSortDueAt:
+		if t.dueAt == nil {
+			return noDueAt
+		}
+		return formatDBTime(*t.dueAt)
+	case model.TODOSortCreatedAt:
+		return formatDBTime(t.createdAt)
+	case model.TODOSortUpdatedAt:
+		return formatDBTime(t.updatedAt)
+	case model.TODOSortSubject:
+		return t.subject
+	default:
+		return t.id
+	}
+}
+
+// compareSortValues は sortValue の値を比較し、a が b より大きければ正、小さければ負、等しければ 0 を返します。
+// 文字列は SQLite の BINARY 照合順序と同じくバイト列として比較します。
+func compareSortValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int64:
+		b := b.(int64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case int:
+		b := b.(int)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
+
+// ReadTODOByID reads the TODO of id in memory. ゴミ箱の TODO は存在しないものとして ErrNotFound を返します。
+func (s *MemoryStore) ReadTODOByID(ctx context.Context, id int64) (*model.TODO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.liveTODO(ctx, id)
+	if !ok {
+		return nil, &model.ErrNotFound{}
+	}
+	return s.view(ctx, t), nil
+}
+
+// UpdateTODO updates the TODO in memory.
+func (s *MemoryStore) UpdateTODO(ctx context.Context, id int64, subject, description string, opts ...TODOOption) (*model.TODO, error) {
+	attrs, err := newTODOAttributes(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateTODO(ctx, id, subject, description, attrs)
+}
+
+// updateTODO は id の TODO を更新します。呼び出し元は s.mu をロックしてください。
+func (s *MemoryStore) updateTODO(ctx context.Context, id int64, subject, description string, attrs *todoAttributes) (*model.TODO, error) {
+	// ゴミ箱の TODO は更新できない
+	t, ok := s.liveTODO(ctx, id)
+	if !ok {
+		return nil, &model.ErrNotFound{}
+	}
+	if err := s.checkWritable(ctx, t); err != nil {
+		return nil, err
+	}
+	if attrs.expectedVersion != nil && *attrs.expectedVersion != t.version {
+		return nil, &model.ErrPreconditionFailed{Current: s.view(ctx, t)}
+	}
+	if attrs.parentIDSet && attrs.parentID != nil {
+		if err := s.checkParent(ctx, id, *attrs.parentID); err != nil {
+			return nil, err
+		}
+	}
+	if attrs.listIDSet && attrs.listID != nil {
+		if err := s.checkList(ctx, *attrs.listID); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkTODOConstraints(subject, attrs); err != nil {
+		return nil, err
+	}
+
+	now := memoryNow()
+	wasDone := t.completedAt != nil
+	t.subject = subject
+	t.description = description
+	if attrs.done != nil {
+		// 既に完了済みの TODO を再度完了にしても completed_at は更新しない
+		if !*attrs.done {
+			t.completedAt = nil
+		} else if t.completedAt == nil {
+			t.completedAt = &now
+		}
+	}
+	if attrs.dueAtSet {
+		// 期限が変わった場合はリマインドを再度送れるようにする
+		t.dueAt = storeTime(attrs.dueAt)
+		t.remindedAt = nil
+	}
+	if attrs.priority != nil {
+		t.priority = *attrs.priority
+	}
+	if attrs.parentIDSet {
+		t.parentID = copyInt64(attrs.parentID)
+	}
+	if attrs.recurrenceSet {
+		t.recurrence = attrs.recurrence
+		t.occurrence = occurrenceFor(attrs.recurrence, t.occurrence)
+	}
+	if attrs.listIDSet {
+		t.listID = copyInt64(attrs.listID)
+	}
+	t.touch(now, true)
+
+	if attrs.tagsSet {
+		s.setTags(t, attrs.tags)
+	}
+	s.recordRevision(ctx, model.RevisionActionUpdate, t)
+
+	// 繰り返しの TODO が完了した場合は、次の TODO を作成する
+	if !wasDone && attrs.done != nil && *attrs.done {
+		if err := s.scheduleNext(ctx, t, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.view(ctx, t), nil
+}
+
+// scheduleNext は、繰り返しの規則を持つ t の次の回を作成します。規則は scheduleNext 関数と同じです。
+func (s *MemoryStore) scheduleNext(ctx context.Context, t *memoryTODO, now time.Time) error {
+	if t.recurrence == "" {
+		return nil
+	}
+	for _, n := range s.todos {
+		if n.previousID != nil && *n.previousID == t.id {
+			return nil
+		}
+	}
+
+	r, err := recurrence.Parse(t.recurrence, time.Local)
+	if err != nil {
+		return err
+	}
+	if r.Count > 0 && t.occurrence >= r.Count {
+		return nil
+	}
+
+	start := now
+	if t.dueAt != nil {
+		start = *t.dueAt
+	}
+	next, ok := r.Next(start, now, time.Local)
+	if !ok {
+		return nil
+	}
+
+	created := memoryNow()
+	previousID := t.id
+	s.lastTODOID++
+	n := &memoryTODO{
+		id:          s.lastTODOID,
+		subject:     t.subject,
+		description: t.description,
+		dueAt:       storeTime(&next),
+		priority:    t.priority,
+		parentID:    copyInt64(t.parentID),
+		recurrence:  t.recurrence,
+		occurrence:  t.occurrence + 1,
+		previousID:  &previousID,
+		listID:      copyInt64(t.listID),
+		ownerID:     copyInt64(t.ownerID),
+		version:     1,
+		createdAt:   created,
+		updatedAt:   created,
+		tagIDs:      map[int64]bool{},
+	}
+	for id := range t.tagIDs {
+		n.tagIDs[id] = true
+	}
+	s.todos[n.id] = n
+	s.recordRevision(ctx, model.RevisionActionCreate, n)
+	return nil
+}
+
+// checkParent は id の TODO の親を parentID にできるかを確認します。新規作成の場合 id は 0 です。
+// 親が存在しない場合は ErrValidation を、親を変更できない場合は ErrForbidden を、
+// 親が id 自身またはその子孫の場合は ErrConflict を返します。
+func (s *MemoryStore) checkParent(ctx context.Context, id, parentID int64) error {
+	parent, ok := s.liveTODO(ctx, parentID)
+	if !ok {
+		return &model.ErrValidation{Field: "parent_id", Message: fmt.Sprintf("parent TODO %d does not exist", parentID)}
+	}
+	if err := s.checkWritable(ctx, parent); err != nil {
+		return err
+	}
+	if id == 0 {
+		return nil
+	}
+
+	seen := map[int64]bool{}
+	for ancestor := &parentID; ancestor != nil && !seen[*ancestor]; {
+		if *ancestor == id {
+			return &model.ErrConflict{Message: fmt.Sprintf("TODO %d can't be a child of itself or of its descendant %d", id, parentID)}
+		}
+		seen[*ancestor] = true
+		t, ok := s.todos[*ancestor]
+		if !ok {
+			break
+		}
+		ancestor = t.parentID
+	}
+	return nil
+}
+
+// DeleteTODO moves TODOs to the trash by ids. Use RestoreTODOs to bring them back;
+// PurgeTODOs permanently deletes them.
+// 削除する TODO の子は、既定では削除する TODO の親に付け替えます。WithCascade を指定すると子孫もすべてゴミ箱に移します。
+func (s *MemoryStore) DeleteTODO(ctx context.Context, ids []int64, opts ...DeleteOption) error {
+	// ids が空の場合、何もせずに nil を返す
+	if len(ids) == 0 {
+		return nil
+	}
+
+	mode := newDeleteMode(opts)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 削除対象が 1 件も存在しない (またはすべてゴミ箱にある) 場合、ErrNotFound を返す
+	// 読めるが変更できない TODO があれば 1 件も削除しない
+	var targets []*memoryTODO
+	for _, id := range uniqueInt64s(ids) {
+		if t, ok := s.liveTODO(ctx, id); ok {
+			if err := s.checkWritable(ctx, t); err != nil {
+				return err
+			}
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == 0 {
+		return &model.ErrNotFound{}
+	}
+
+	// version が指定された場合は、削除する TODO が変更されていないことを確認する
+	if mode.expectedVersion != nil {
+		for _, t := range targets {
+			if t.version != *mode.expectedVersion {
+				return &model.ErrPreconditionFailed{Current: s.view(ctx, t)}
+			}
+		}
+	}
+
+	now := memoryNow()
+	var deleted []*memoryTODO
+	if mode.cascade {
+		// すべて同じ deleted_at にして、RestoreTODOs でまとめて元に戻せるようにする
+		// ctx の利用者が変更できない子孫は、その子孫も含めてゴミ箱に移さない
+		deleted = s.descendants(targets, func(t *memoryTODO) bool { return s.canAccess(ctx, t, true) })
+		for _, t := range deleted {
+			t.deletedAt = &now
+			t.touch(now, true)
+		}
+	} else {
+		// 1 件ずつ処理するため、削除対象同士が親子関係にあっても、子は削除されない最も近い祖先に付け替えられる
+		// ctx の利用者が変更できない子は付け替えない
+		for _, t := range targets {
+			id, parentID := t.id, t.parentID
+			for _, c := range s.todos {
+				if c.deletedAt == nil && c.parentID != nil && *c.parentID == id && s.canAccess(ctx, c, true) {
+					c.parentID = copyInt64(parentID)
+					c.touch(now, true)
+				}
+			}
+			t.deletedAt = &now
+			t.touch(now, true)
+			deleted = append(deleted, t)
+		}
+	}
+	for _, t := range deleted {
+		s.recordRevision(ctx, model.RevisionActionDelete, t)
+	}
+
+	// 正常に削除された場合は nil を返す
+	return nil
+}
+
+// descendants は roots とそのゴミ箱にない子孫を id の昇順で返します。match を満たさない子とその子孫はたどりません。
+func (s *MemoryStore) descendants(roots []*memoryTODO, match func(*memoryTODO) bool) []*memoryTODO {
+	found := map[int64]bool{}
+	queue := append([]*memoryTODO{}, roots...)
+	for _, t := range roots {
+		found[t.id] = true
+	}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for _, c := range s.todos {
+			if c.deletedAt == nil && c.parentID != nil && *c.parentID == parent.id && !found[c.id] && match(c) {
+				found[c.id] = true
+				queue = append(queue, c)
+			}
+		}
+	}
+	return s.sortedTODOs(func(t *memoryTODO) bool {
+		return found[t.id]
+	})
+}
+
+// ReadDueTODOs reads undone TODOs whose due date has passed at now and that have not been reminded yet.
+func (s *MemoryStore) ReadDueTODOs(ctx context.Context, now time.Time, size int64) ([]*model.TODO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := formatDBTime(now)
+	todos := s.sortedTODOs(func(t *memoryTODO) bool {
+		return t.dueAt != nil && formatDBTime(*t.dueAt) <= cutoff && t.completedAt == nil && t.remindedAt == nil && t.deletedAt == nil
+	})
+	sort.SliceStable(todos, func(i, j int) bool {
+		return todos[i].dueAt.Before(*todos[j].dueAt)
+	})
+	if size >= 0 && int64(len(todos)) > size {
+		todos = todos[:size]
+	}
+	return s.views(ctx, todos), nil
+}
+
+// MarkTODOReminded records that the reminder of the TODO has been sent.
+func (s *MemoryStore) MarkTODOReminded(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.todos[id]
+	if !ok {
+		return &model.ErrNotFound{}
+	}
+	now := memoryNow()
+	t.remindedAt = &now
+	t.touch(now, false)
+	return nil
+}
+
+// ClaimTODOs makes the user the owner of every TODO and every list that has no owner, and returns the number of the TODOs.
+func (s *MemoryStore) ClaimTODOs(ctx context.Context, ownerID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := memoryNow()
+	var claimed int64
+	for _, t := range s.todos {
+		if t.ownerID == nil {
+			t.ownerID = copyInt64(&ownerID)
+			t.touch(now, false)
+			claimed++
+		}
+	}
+	for _, l := range s.lists {
+		if l.ownerID == nil {
+			l.ownerID = copyInt64(&ownerID)
+			l.updatedAt = now
+		}
+	}
+	return claimed, nil
+}
+
+// SearchTODO searches TODOs whose subject or description contains all terms of query,
+// ordered by relevance. 順位付けは SQLite で FTS5 が使えない場合と同じです。
+func (s *MemoryStore) SearchTODO(ctx context.Context, query string, size, offset int64) ([]*model.SearchTODOResult, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 || size <= 0 {
+		return []*model.SearchTODOResult{}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todos := s.sortedTODOs(func(t *memoryTODO) bool {
+		// ReadTODOs と同じく、アーカイブされたリストの TODO は検索しない
+		if t.deletedAt != nil || s.archived(t) || !s.canRead(ctx, t) {
+			return false
+		}
+		subject, description := likeFold(t.subject), likeFold(t.description)
+		for _, term := range terms {
+			term = likeFold(term)
+			if !strings.Contains(subject, term) && !strings.Contains(description, term) {
+				return false
+			}
+		}
+		return true
+	})
+	return rankMatches(s.views(ctx, todos), terms, size, offset), nil
+}
+
+// ReadTODOChildren reads the direct children of the TODO ordered by id.
+func (s *MemoryStore) ReadTODOChildren(ctx context.Context, id int64) ([]*model.TODO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.liveTODO(ctx, id); !ok {
+		return nil, &model.ErrNotFound{}
+	}
+	return s.views(ctx, s.sortedTODOs(func(t *memoryTODO) bool {
+		return t.deletedAt == nil && t.parentID != nil && *t.parentID == id && s.canRead(ctx, t)
+	})), nil
+}
+
+// ReadTODOTree reads the TODO and all of its descendants. Children of each node are ordered by id.
+func (s *MemoryStore) ReadTODOTree(ctx context.Context, id int64) (*model.TODONode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	root, ok := s.liveTODO(ctx, id)
+	if !ok {
+		return nil, &model.ErrNotFound{}
+	}
+
+	// 読めない子の子孫は、親のないノードにならないよう、読める子孫であってもたどらない
+	todos := s.descendants([]*memoryTODO{root}, func(t *memoryTODO) bool {
+		return s.canRead(ctx, t)
+	})
+	nodes := make(map[int64]*model.TODONode, len(todos))
+	for _, t := range todos {
+		nodes[t.id] = &model.TODONode{TODO: s.view(ctx, t), Children: []*model.TODONode{}}
+	}
+	for _, t := range todos {
+		if t.id == id || t.parentID == nil {
+			continue
+		}
+		parent, ok := nodes[*t.parentID]
+		if !ok {
+			continue
+		}
+		parent.Children = append(parent.Children, nodes[t.id])
+	}
+
+	return nodes[id], nil
+}
+
+// MoveTODOs moves the TODOs to the list. A nil listID moves them out of any list.
+func (s *MemoryStore) MoveTODOs(ctx context.Context, ids []int64, listID *int64) ([]*model.TODO, error) {
+	if len(ids) == 0 {
+		return []*model.TODO{}, nil
+	}
+	ids = uniqueInt64s(ids)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if listID != nil {
+		if err := s.checkList(ctx, *listID); err != nil {
+			return nil, err
+		}
+	}
+
+	// 存在しない TODO や ctx の利用者が読めない TODO が含まれる場合は 1 件も移動しない
+	missing := false
+	for _, id := range ids {
+		t, ok := s.liveTODO(ctx, id)
+		if !ok {
+			missing = true
+			continue
+		}
+		if err := s.checkWritable(ctx, t); err != nil {
+			return nil, err
+		}
+	}
+	if missing {
+		return nil, &model.ErrNotFound{}
+	}
+
+	now := memoryNow()
+	moved := map[int64]bool{}
+	for _, id := range ids {
+		t := s.todos[id]
+		t.listID = copyInt64(listID)
+		t.touch(now, true)
+		moved[id] = true
+	}
+	return s.views(ctx, s.sortedTODOs(func(t *memoryTODO) bool {
+		return moved[t.id]
+	})), nil
+}
+
+// ReadTrash reads TODOs in the trash in descending order of id.
+func (s *MemoryStore) ReadTrash(ctx context.Context, prevID, size int64) ([]*model.TODO, error) {
+	if size <= 0 {
+		return []*model.TODO{}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	todos := s.sortedTODOs(func(t *memoryTODO) bool {
+		return t.deletedAt != nil && s.canRead(ctx, t) && (prevID <= 0 || t.id < prevID)
+	})
+	sort.Slice(todos, func(i, j int) bool {
+		return todos[i].id > todos[j].id
+	})
+	if int64(len(todos)) > size {
+		todos = todos[:size]
+	}
+	return s.views(ctx, todos), nil
+}
+
+// RestoreTODOs takes the TODOs out of the trash and returns the restored TODOs ordered by id.
+//
+// 子孫ごとゴミ箱に移した TODO を元に戻すと、同時にゴミ箱に移した子孫も元に戻します。
+// 親がゴミ箱にある TODO は、どの親にも属さない状態で元に戻します。
+func (s *MemoryStore) RestoreTODOs(ctx context.Context, ids []int64) ([]*model.TODO, error) {
+	if len(ids) == 0 {
+		return []*model.TODO{}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 指定された TODO と、同じ deleted_at を持つ子孫を集める。ctx の利用者が読めない TODO は元に戻さず、
+	// 読めるが変更できない TODO があれば 1 件も元に戻さない
+	found := map[int64]bool{}
+	var queue []*memoryTODO
+	for _, id := range uniqueInt64s(ids) {
+		if t, ok := s.todos[id]; ok && t.deletedAt != nil && s.canRead(ctx, t) {
+			if err := s.checkWritable(ctx, t); err != nil {
+				return nil, err
+			}
+			found[id] = true
+			queue = append(queue, t)
+		}
+	}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for _, c := range s.todos {
+			if c.parentID != nil && *c.parentID == parent.id && equalTime(c.deletedAt, parent.deletedAt) && !found[c.id] {
+				found[c.id] = true
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	// ゴミ箱にある TODO が 1 件も指定されていない場合、ErrNotFound を返す
+	if len(found) == 0 {
+		return nil, &model.ErrNotFound{}
+	}
+
+	now := memoryNow()
+	restored := s.sortedTODOs(func(t *memoryTODO) bool {
+		return found[t.id]
+	})
+	for _, t := range restored {
+		t.deletedAt = nil
+		t.touch(now, true)
+	}
+	for _, t := range restored {
+		if t.parentID == nil {
+			continue
+		}
+		if parent, ok := s.todos[*t.parentID]; ok && parent.deletedAt != nil {
+			t.parentID = nil
+			t.touch(now, true)
+		}
+	}
+	for _, t := range restored {
+		s.recordRevision(ctx, model.RevisionActionRestore, t)
+	}
+
+	return s.views(ctx, restored), nil
+}
+
+// PurgeTODOs permanently deletes TODOs that were moved to the trash at or before the given time
+// and returns the number of deleted TODOs.
+func (s *MemoryStore) PurgeTODOs(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := formatDBTime(before)
+	purged := map[int64]bool{}
+	for _, t := range s.todos {
+		if t.deletedAt != nil && formatDBTime(*t.deletedAt) <= cutoff {
+			purged[t.id] = true
+		}
+	}
+
+	now := memoryNow()
+	// 完全に削除する TODO の子が残っている場合は、どの親にも属さない状態にする
+	for _, t := range s.todos {
+		if !purged[t.id] && t.parentID != nil && purged[*t.parentID] {
+			t.parentID = nil
+			t.touch(now, true)
+		}
+	}
+	for id := range purged {
+		delete(s.todos, id)
+		delete(s.revisions, id)
+	}
+	for _, t := range s.todos {
+		if t.previousID != nil && purged[*t.previousID] {
+			t.previousID = nil
+			t.touch(now, false)
+		}
+	}
+
+	return int64(len(purged)), nil
+}
+
+// ReadRevisions reads the revisions of the TODO, oldest first.
+// ゴミ箱にある TODO の変更履歴も読めます。
+func (s *MemoryStore) ReadRevisions(ctx context.Context, id int64) ([]*model.Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.todos[id]; !ok || !s.canRead(ctx, t) {
+		return nil, &model.ErrNotFound{}
+	}
+
+	revisions := make([]*model.Revision, len(s.revisions[id]))
+	for i, rev := range s.revisions[id] {
+		c := *rev
+		c.CompletedAt = copyTime(rev.CompletedAt)
+		c.DueAt = copyTime(rev.DueAt)
+		revisions[i] = &c
+	}
+	return revisions, nil
+}
+
+// RevertTODO sets the subject, description, done state, due date and priority of the TODO
+// back to those of the revision, and records the change as a new revision.
+// Tags, the parent and the list are not changed.
+func (s *MemoryStore) RevertTODO(ctx context.Context, id, revision int64) (*model.TODO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.liveTODO(ctx, id)
+	if !ok {
+		return nil, &model.ErrNotFound{}
+	}
+	if err := s.checkWritable(ctx, t); err != nil {
+		return nil, err
+	}
+	var rev *model.Revision
+	for _, r := range s.revisions[id] {
+		if r.Revision == revision {
+			rev = r
+		}
+	}
+	if rev == nil {
+		return nil, &model.ErrValidation{Field: "revision", Message: fmt.Sprintf("TODO %d has no revision %d", id, revision)}
+	}
+
+	t.subject = rev.Subject
+	t.description = rev.Description
+	t.completedAt = copyTime(rev.CompletedAt)
+	// 期限が変わった場合はリマインドを再度送れるようにする
+	if !equalTime(t.dueAt, rev.DueAt) {
+		t.remindedAt = nil
+	}
+	t.dueAt = copyTime(rev.DueAt)
+	t.priority = rev.Priority
+	t.touch(memoryNow(), true)
+	s.recordRevision(ctx, model.RevisionActionRevert, t)
+
+	return s.view(ctx, t), nil
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/recurrence"
+)
+
+// A TODOOption sets an optional attribute of a TODO on CreateTODO and UpdateTODO.
+type TODOOption func(*todoAttributes)
+
+// todoAttributes は、省略可能な TODO の属性を保持します。
+// オプションで指定されなかった属性は変更しないことを表します。
+type todoAttributes struct {
+	done     *bool
+	dueAtSet bool
+	dueAt    *time.Time
+	priority *int
+	tagsSet  bool
+	tags     []string
+
+	parentIDSet bool
+	parentID    *int64
+
+	recurrenceSet bool
+	recurrence    string
+
+	listIDSet bool
+	listID    *int64
+
+	// expectedVersion は、更新前の TODO が持つべき version です。
+	expectedVersion *int64
+
+	// err は、オプションの値が不正な場合のエラーです。
+	err error
+}
+
+// WithDone は TODO を完了 (true) または未完了 (false) にします。
+func WithDone(done bool) TODOOption {
+	return func(a *todoAttributes) {
+		a.done = &done
+	}
+}
+
+// WithDueAt は TODO の期限を設定します。nil の場合は期限を解除します。
+func WithDueAt(dueAt *time.Time) TODOOption {
+	return func(a *todoAttributes) {
+		a.dueAtSet = true
+		a.dueAt = dueAt
+	}
+}
+
+// WithPriority は TODO の優先度 (model.PriorityNone から model.PriorityHigh) を設定します。
+func WithPriority(priority int) TODOOption {
+	return func(a *todoAttributes) {
+		a.priority = &priority
+	}
+}
+
+// WithTags は TODO のタグを tags で置き換えます。空の場合はすべてのタグを外します。
+func WithTags(tags []string) TODOOption {
+	return func(a *todoAttributes) {
+		a.tagsSet = true
+		a.tags = tags
+	}
+}
+
+// WithParentID は TODO を parentID の子にします。nil の場合は親から外します。
+func WithParentID(parentID *int64) TODOOption {
+	return func(a *todoAttributes) {
+		a.parentIDSet = true
+		a.parentID = parentID
+	}
+}
+
+// WithRecurrence は TODO の繰り返しの規則 (recurrence.Parse が受け付ける形式) を設定します。
+// 空文字の場合は繰り返しを解除します。規則は正規化して保存します。
+func WithRecurrence(rule string) TODOOption {
+	return func(a *todoAttributes) {
+		a.recurrenceSet = true
+		a.recurrence = ""
+		if rule == "" {
+			return
+		}
+		r, err := recurrence.Parse(rule, time.Local)
+		if err != nil {
+			a.err = &model.ErrValidation{Field: "recurrence", Message: strings.TrimPrefix(err.Error(), "recurrence: ")}
+			return
+		}
+		a.recurrence = r.String()
+	}
+}
+
+// WithListID は TODO を listID のリストに移動します。nil の場合はリストから外します。
+func WithListID(listID *int64) TODOOption {
+	return func(a *todoAttributes) {
+		a.listIDSet = true
+		a.listID = listID
+	}
+}
+
+// WithExpectedVersion は、TODO の version が version と一致する場合にのみ更新します。
+// 一致しない場合、UpdateTODO は現在の TODO を持つ *model.ErrPreconditionFailed を返します。
+func WithExpectedVersion(version int64) TODOOption {
+	return func(a *todoAttributes) {
+		a.expectedVersion = &version
+	}
+}
+
+func newTODOAttributes(opts []TODOOption) (*todoAttributes, error) {
+	a := &todoAttributes{}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.err != nil {
+		return nil, a.err
+	}
+	return a, nil
+}
+
+// A ReadOption narrows down the TODOs returned by ReadTODO.
+type ReadOption func(*readFilter)
+
+// readFilter は、ReadTODO の絞り込み条件を保持します。
+type readFilter struct {
+	status    string
+	overdue   bool
+	dueBefore *time.Time
+	dueAfter  *time.Time
+	sort      string
+	order     string
+	cursor    *Cursor
+	tags      []string
+	tagMode   string
+
+	listIDSet       bool
+	listID          *int64
+	includeArchived bool
+}
+
+// WithStatus は完了状態 (model.TODOStatusDone, model.TODOStatusUndone) で絞り込みます。
+// model.TODOStatusAll または空文字の場合は絞り込みを行いません。
+func WithStatus(status string) ReadOption {
+	return func(f *readFilter) {
+		f.status = status
+	}
+}
+
+// WithOverdue は期限を過ぎた未完了の TODO に絞り込みます。
+func WithOverdue() ReadOption {
+	return func(f *readFilter) {
+		f.overdue = true
+	}
+}
+
+// WithDueBefore は期限が t より前の TODO に絞り込みます。
+func WithDueBefore(t time.Time) ReadOption {
+	return func(f *readFilter) {
+		f.dueBefore = &t
+	}
+}
+
+// WithDueAfter は期限が t より後の TODO に絞り込みます。
+func WithDueAfter(t time.Time) ReadOption {
+	return func(f *readFilter) {
+		f.dueAfter = &t
+	}
+}
+
+// WithSort は key (model.TODOSortID など) と order (model.SortOrderAsc, model.SortOrderDesc) で並び替えます。
+// 省略した場合は id の降順です。
+func WithSort(key, order string) ReadOption {
+	return func(f *readFilter) {
+		f.sort = key
+		f.order = order
+	}
+}
+
+// WithCursor は cursor が指す TODO より後ろの TODO を返します。
+// cursor は WithSort と同じソートキーと並び順で発行されたものである必要があります。
+func WithCursor(cursor *Cursor) ReadOption {
+	return func(f *readFilter) {
+		f.cursor = cursor
+	}
+}
+
+// WithTagFilter はタグで絞り込みます。mode が model.TagModeAnd の場合はすべてのタグが付いた TODO に、
+// model.TagModeOr の場合はいずれかのタグが付いた TODO に絞り込みます。
+func WithTagFilter(tags []string, mode string) ReadOption {
+	return func(f *readFilter) {
+		f.tags = tags
+		f.tagMode = mode
+	}
+}
+
+// WithListFilter は listID のリストに属する TODO に絞り込みます。nil の場合はどのリストにも属さない TODO に絞り込みます。
+// リストを指定した場合は、そのリストがアーカイブされていても TODO を返します。
+func WithListFilter(listID *int64) ReadOption {
+	return func(f *readFilter) {
+		f.listIDSet = true
+		f.listID = listID
+	}
+}
+
+// WithArchived はアーカイブされたリストに属する TODO も返します。
+// 省略した場合、アーカイブされたリストの TODO は返しません。
+func WithArchived() ReadOption {
+	return func(f *readFilter) {
+		f.includeArchived = true
+	}
+}
+
+func newReadFilter(opts []ReadOption) *readFilter {
+	f := &readFilter{
+		sort:  model.TODOSortID,
+		order: model.SortOrderDesc,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// A DeleteOption changes how DeleteTODO treats the children of deleted TODOs.
+type DeleteOption func(*deleteMode)
+
+// deleteMode は、DeleteTODO で子をどう扱うかを保持します。
+type deleteMode struct {
+	cascade         bool
+	expectedVersion *int64
+}
+
+// WithCascade は削除する TODO の子孫もすべて削除します。
+func WithCascade() DeleteOption {
+	return func(m *deleteMode) {
+		m.cascade = true
+	}
+}
+
+// WithDeleteExpectedVersion は、削除する TODO の version が version と一致する場合にのみ削除します。
+// 一致しない場合、DeleteTODO は現在の TODO を持つ *model.ErrPreconditionFailed を返します。
+// 1 件の TODO を削除する場合に使用してください。
+func WithDeleteExpectedVersion(version int64) DeleteOption {
+	return func(m *deleteMode) {
+		m.expectedVersion = &version
+	}
+}
+
+func newDeleteMode(opts []DeleteOption) *deleteMode {
+	m := &deleteMode{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
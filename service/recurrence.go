@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/recurrence"
+)
+
+// occurrenceFor は、繰り返しの規則が rule に変わった TODO の回数を返します。
+// 繰り返さない TODO は 0 で、繰り返す TODO は 1 から数えます。既に繰り返している TODO の回数は変えません。
+func occurrenceFor(rule string, current int) int {
+	if rule == "" {
+		return 0
+	}
+	if current == 0 {
+		return 1
+	}
+	return current
+}
+
+// scheduleNext は、繰り返しの規則を持つ id の TODO の次の回を作成します。
+//
+// 次の回の期限は、完了した回の期限 (期限がない場合は now) を起点に、規則に従って now より後で
+// 最初に訪れる日時です。期限を過ぎてから完了した場合、過ぎてしまった回は作成しません。
+// 日時の計算は time.Local (realMain で Asia/Tokyo に設定) で行います。
+// 既に次の回が作成済みの場合や、COUNT, UNTIL により次の回がない場合は何もしません。
+func scheduleNext(ctx context.Context, q dbtx, id int64, now time.Time) error {
+	const (
+		read = `SELECT subject, description, priority, parent_id, list_id, owner_id, recurrence, occurrence, due_at,
+				EXISTS (SELECT 1 FROM todos n WHERE n.previous_id = todos.id)
+			FROM todos WHERE id = ?`
+		// PostgreSQL でプレースホルダの型がカラムの型から決まるよう、INSERT ... SELECT ではなく VALUES で挿入する
+		insert = `INSERT INTO todos(subject, description, due_at, priority, parent_id, recurrence, occurrence, previous_id, list_id, owner_id)
+			VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`
+		copyTags = `INSERT INTO todo_tags(todo_id, tag_id) SELECT CAST(? AS BIGINT), tag_id FROM todo_tags WHERE todo_id = ?`
+	)
+
+	var (
+		subject, description string
+		priority             int
+		parentID, listID     sql.NullInt64
+		ownerID              sql.NullInt64
+		rule                 string
+		occurrence           int
+		dueAt                sql.NullTime
+		scheduled            bool
+	)
+	err := q.QueryRowContext(ctx, read, id).Scan(&subject, &description, &priority, &parentID, &listID, &ownerID,
+		&rule, &occurrence, &dueAt, &scheduled)
+	if err != nil {
+		return err
+	}
+	if rule == "" || scheduled {
+		return nil
+	}
+
+	r, err := recurrence.Parse(rule, time.Local)
+	if err != nil {
+		return err
+	}
+	if r.Count > 0 && occurrence >= r.Count {
+		return nil
+	}
+
+	start := now
+	if dueAt.Valid {
+		start = dueAt.Time
+	}
+	next, ok := r.Next(start, now, time.Local)
+	if !ok {
+		return nil
+	}
+
+	var nextID int64
+	err = q.QueryRowContext(ctx, insert, subject, description, dbTime(&next), priority, parentID, rule, occurrence+1, id, listID, ownerID).Scan(&nextID)
+	if err != nil {
+		return err
+	}
+	if _, err := q.ExecContext(ctx, copyTags, nextID, id); err != nil {
+		return err
+	}
+	return recordRevision(ctx, q, model.RevisionActionCreate, nextID)
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A TODORepository persists TODOs together with their tags and revisions.
+//
+// 実装は SQLTODORepository (SQLite, PostgreSQL) と MemoryStore です。どちらも同じ振る舞いをするよう、
+// 変更するときは両方の実装と repository_test.go のテストを合わせて更新してください。
+type TODORepository interface {
+	CreateTODO(ctx context.Context, subject, description string, opts ...TODOOption) (*model.TODO, error)
+	ReadTODO(ctx context.Context, prevID, size int64, opts ...ReadOption) ([]*model.TODO, error)
+	ReadTODOByID(ctx context.Context, id int64) (*model.TODO, error)
+	UpdateTODO(ctx context.Context, id int64, subject, description string, opts ...TODOOption) (*model.TODO, error)
+	DeleteTODO(ctx context.Context, ids []int64, opts ...DeleteOption) error
+
+	CreateTODOs(ctx context.Context, items []*BulkTODO, atomic bool) ([]*BulkResult, error)
+	UpdateTODOs(ctx context.Context, items []*BulkTODO, atomic bool) ([]*BulkResult, error)
+
+	ReadDueTODOs(ctx context.Context, now time.Time, size int64) ([]*model.TODO, error)
+	MarkTODOReminded(ctx context.Context, id int64) error
+
+	SearchTODO(ctx context.Context, query string, size, offset int64) ([]*model.SearchTODOResult, error)
+
+	ReadTODOChildren(ctx context.Context, id int64) ([]*model.TODO, error)
+	ReadTODOTree(ctx context.Context, id int64) (*model.TODONode, error)
+
+	MoveTODOs(ctx context.Context, ids []int64, listID *int64) ([]*model.TODO, error)
+
+	ReadTrash(ctx context.Context, prevID, size int64) ([]*model.TODO, error)
+	RestoreTODOs(ctx context.Context, ids []int64) ([]*model.TODO, error)
+	PurgeTODOs(ctx context.Context, before time.Time) (int64, error)
+
+	ReadRevisions(ctx context.Context, id int64) ([]*model.Revision, error)
+	RevertTODO(ctx context.Context, id, revision int64) (*model.TODO, error)
+
+	ClaimTODOs(ctx context.Context, ownerID int64) (int64, error)
+}
+
+// A TagRepository persists tags.
+type TagRepository interface {
+	ReadTags(ctx context.Context) ([]*model.Tag, error)
+	RenameTag(ctx context.Context, id int64, name string) (*model.Tag, error)
+	MergeTags(ctx context.Context, sourceIDs []int64, targetID int64) (*model.Tag, error)
+}
+
+// A ListRepository persists TODO lists and the users they are shared with.
+//
+// リストと TODO の読み書きは、ctx の利用者の役割で制限されます。制限は access.go を参照してください。
+type ListRepository interface {
+	CreateList(ctx context.Context, name, description string) (*model.List, error)
+	ReadLists(ctx context.Context, includeArchived bool) ([]*model.List, error)
+	UpdateList(ctx context.Context, id int64, name, description string, archived *bool) (*model.List, error)
+	DeleteLists(ctx context.Context, ids []int64) error
+
+	ReadListMembers(ctx context.Context, listID int64) ([]*model.ListMember, error)
+	PutListMember(ctx context.Context, listID int64, userName, role string) (*model.ListMember, error)
+	DeleteListMember(ctx context.Context, listID int64, userName string) error
+}
+
+var (
+	_ TODORepository    = (*SQLTODORepository)(nil)
+	_ TagRepository     = (*SQLTagRepository)(nil)
+	_ ListRepository    = (*SQLListRepository)(nil)
+	_ UserRepository    = (*SQLUserRepository)(nil)
+	_ TokenRepository   = (*SQLTokenRepository)(nil)
+	_ SessionRepository = (*SQLSessionRepository)(nil)
+	_ TODORepository    = (*MemoryStore)(nil)
+	_ TagRepository     = (*MemoryStore)(nil)
+	_ ListRepository    = (*MemoryStore)(nil)
+	_ UserRepository    = (*MemoryStore)(nil)
+	_ TokenRepository   = (*MemoryStore)(nil)
+	_ SessionRepository = (*MemoryStore)(nil)
+)
+
+// A TODOService implements CRUD of TODO entities on a TODORepository.
+type TODOService struct {
+	TODORepository
+}
+
+// NewTODOService returns new TODOService that stores TODOs in the SQLite or PostgreSQL database.
+func NewTODOService(db *sql.DB) *TODOService {
+	return NewTODOServiceWithRepository(NewSQLTODORepository(db))
+}
+
+// NewTODOServiceWithRepository returns new TODOService that stores TODOs in repo.
+func NewTODOServiceWithRepository(repo TODORepository) *TODOService {
+	return &TODOService{
+		TODORepository: repo,
+	}
+}
+
+// A TagService implements reading, renaming and merging of tags on a TagRepository.
+type TagService struct {
+	TagRepository
+}
+
+// NewTagService returns new TagService that stores tags in the SQLite or PostgreSQL database.
+func NewTagService(db *sql.DB) *TagService {
+	return NewTagServiceWithRepository(NewSQLTagRepository(db))
+}
+
+// NewTagServiceWithRepository returns new TagService that stores tags in repo.
+func NewTagServiceWithRepository(repo TagRepository) *TagService {
+	return &TagService{
+		TagRepository: repo,
+	}
+}
+
+// A ListService implements CRUD of TODO lists on a ListRepository.
+type ListService struct {
+	ListRepository
+}
+
+// NewListService returns new ListService that stores lists in the SQLite or PostgreSQL database.
+func NewListService(db *sql.DB) *ListService {
+	return NewListServiceWithRepository(NewSQLListRepository(db))
+}
+
+// NewListServiceWithRepository returns new ListService that stores lists in repo.
+func NewListServiceWithRepository(repo ListRepository) *ListService {
+	return &ListService{
+		ListRepository: repo,
+	}
+}
+
+// ShareList shares the list with the user named name as role, or changes the role of the user.
+// role は編集者 (editor) か閲覧者 (viewer) です。所有者はリストごとに 1 人で、変更できません。
+func (s *ListService) ShareList(ctx context.Context, listID int64, name, role string) (*model.ListMember, error) {
+	switch {
+	case name == "":
+		return nil, &model.ErrValidation{Field: "name", Message: "is required"}
+	case role != model.ListRoleEditor && role != model.ListRoleViewer:
+		return nil, &model.ErrValidation{Field: "role", Message: "must be " + model.ListRoleEditor + " or " + model.ListRoleViewer}
+	}
+	return s.PutListMember(ctx, listID, name, role)
+}
+
+// UnshareList stops sharing the list with the user named name.
+func (s *ListService) UnshareList(ctx context.Context, listID int64, name string) error {
+	if name == "" {
+		return &model.ErrValidation{Field: "name", Message: "is required"}
+	}
+	return s.DeleteListMember(ctx, listID, name)
+}
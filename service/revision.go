@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// revisionColumns は、変更履歴を取得する際に SELECT するカラムです。scanRevision と順序を合わせてください。
+const revisionColumns = `id, todo_id, revision, action, actor, subject, description, completed_at, due_at, priority, created_at`
+
+// actorKey は、変更した人を context に保持するためのキーです。
+type actorKey struct{}
+
+// ContextWithActor returns a copy of ctx that carries the name of who makes the changes.
+// The name is recorded in the revisions of the TODOs changed with the context.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the name set by ContextWithActor, or an empty string.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// recordRevision は ids の TODO の現在の状態を action の変更履歴として記録します。
+func recordRevision(ctx context.Context, q dbtx, action string, ids ...int64) error {
+	const insert = `INSERT INTO todo_revisions(todo_id, revision, action, actor, subject, description, completed_at, due_at, priority)
+		SELECT id, COALESCE((SELECT MAX(r.revision) FROM todo_revisions r WHERE r.todo_id = todos.id), 0) + 1,
+			CAST(? AS TEXT), CAST(? AS TEXT), subject, description, completed_at, due_at, priority
+		FROM todos WHERE id = ?`
+
+	actor := ActorFromContext(ctx)
+	for _, id := range ids {
+		if _, err := q.ExecContext(ctx, insert, action, actor, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanRevision は revisionColumns の順に並んだ行を model.Revision に変換します。
+func scanRevision(row scanner) (*model.Revision, error) {
+	var (
+		rev         model.Revision
+		completedAt sql.NullTime
+		dueAt       sql.NullTime
+	)
+	if err := row.Scan(&rev.ID, &rev.TODOID, &rev.Revision, &rev.Action, &rev.Actor, &rev.Subject, &rev.Description,
+		&completedAt, &dueAt, &rev.Priority, &rev.CreatedAt); err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		rev.Done = true
+		rev.CompletedAt = &completedAt.Time
+	}
+	if dueAt.Valid {
+		rev.DueAt = &dueAt.Time
+	}
+	return &rev, nil
+}
+
+// readRevision は id の TODO の revision 番目の変更履歴を取得します。存在しない場合は ErrNotFound を返します。
+func readRevision(ctx context.Context, q dbtx, id, revision int64) (*model.Revision, error) {
+	const read = `SELECT ` + revisionColumns + ` FROM todo_revisions WHERE todo_id = ? AND revision = ?`
+
+	rev, err := scanRevision(q.QueryRowContext(ctx, read, id, revision))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// ReadRevisions reads the revisions of the TODO, oldest first.
+// ゴミ箱にある TODO の変更履歴も読めます。
+func (s *SQLTODORepository) ReadRevisions(ctx context.Context, id int64) ([]*model.Revision, error) {
+	const read = `SELECT ` + revisionColumns + ` FROM todo_revisions WHERE todo_id = ? ORDER BY revision`
+
+	access, accessArgs := todoAccessFilter(ctx, "", false)
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM todos WHERE id = ? AND `+access+`)`, append([]interface{}{id}, accessArgs...)...).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, &model.ErrNotFound{}
+	}
+
+	rows, err := s.db.QueryContext(ctx, read, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*model.Revision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// DiffRevisions returns the fields that differ between the from and to revisions of the TODO.
+func (s *TODOService) DiffRevisions(ctx context.Context, id, from, to int64) ([]*model.RevisionChange, error) {
+	revisions, err := s.ReadRevisions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var old, rev *model.Revision
+	for _, r := range revisions {
+		if r.Revision == from {
+			old = r
+		}
+		if r.Revision == to {
+			rev = r
+		}
+	}
+	if old == nil || rev == nil {
+		return nil, &model.ErrNotFound{}
+	}
+
+	changes := []*model.RevisionChange{}
+	add := func(field string, o, n interface{}) {
+		changes = append(changes, &model.RevisionChange{Field: field, Old: o, New: n})
+	}
+	if old.Subject != rev.Subject {
+		add("subject", old.Subject, rev.Subject)
+	}
+	if old.Description != rev.Description {
+		add("description", old.Description, rev.Description)
+	}
+	if old.Done != rev.Done {
+		add("done", old.Done, rev.Done)
+	}
+	if !equalTime(old.DueAt, rev.DueAt) {
+		add("due_at", old.DueAt, rev.DueAt)
+	}
+	if old.Priority != rev.Priority {
+		add("priority", old.Priority, rev.Priority)
+	}
+	return changes, nil
+}
+
+// equalTime は、a と b がどちらも nil か、同じ時刻を指すかを返します。
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// RevertTODO sets the subject, description, done state, due date and priority of the TODO
+// back to those of the revision, and records the change as a new revision.
+// Tags, the parent and the list are not changed.
+func (s *SQLTODORepository) RevertTODO(ctx context.Context, id, revision int64) (*model.TODO, error) {
+	// 期限が変わった場合はリマインドを再度送れるようにする
+	// PostgreSQL には SQLite の IS による NULL 同士の比較がないため、NULL の場合を分けて比較する
+	const revert = `UPDATE todos SET subject = r.subject, description = r.description, completed_at = r.completed_at,
+			reminded_at = CASE WHEN todos.due_at = r.due_at OR (todos.due_at IS NULL AND r.due_at IS NULL)
+				THEN todos.reminded_at END,
+			due_at = r.due_at, priority = r.priority
+		FROM (SELECT * FROM todo_revisions WHERE todo_id = ? AND revision = ?) r
+		WHERE todos.id = ? AND todos.deleted_at IS NULL`
+	columns, columnArgs := todoColumns(ctx)
+	confirm := `SELECT ` + columns + ` FROM todos WHERE id = ?`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkWritable(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	if _, err := readRevision(ctx, tx, id, revision); err != nil {
+		if model.IsErrNotFound(err) {
+			return nil, &model.ErrValidation{Field: "revision", Message: fmt.Sprintf("TODO %d has no revision %d", id, revision)}
+		}
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, revert, id, revision, id); err != nil {
+		return nil, err
+	}
+	if err := recordRevision(ctx, tx, model.RevisionActionRevert, id); err != nil {
+		return nil, err
+	}
+
+	todo, err := scanTODO(tx.QueryRowContext(ctx, confirm, append(columnArgs, id)...))
+	if err != nil {
+		return nil, err
+	}
+	if err := attachTags(ctx, tx, []*model.TODO{todo}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return todo, nil
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+const (
+	// markOpen, markClose は一致箇所を囲む目印です。HTML エスケープの後で <mark> タグに置き換えます。
+	markOpen  = "\x01"
+	markClose = "\x02"
+
+	// snippetTokens は FTS5 の snippet() が返すトークン数の上限です。
+	snippetTokens = 16
+	// snippetRunes は FTS5 が使えない場合に、一致箇所の前後に含める文字数です。
+	snippetRunes = 24
+
+	// minFTSTermRunes は trigram トークナイザで検索できる語の最小文字数です。
+	minFTSTermRunes = 3
+	// subjectWeight は、スコアの計算で subject の一致を description の何倍に数えるかです。
+	subjectWeight = 10.0
+)
+
+// SearchTODO searches TODOs whose subject or description contains all terms of query,
+// ordered by relevance.
+//
+// FTS5 が使える場合は todos_fts を bm25 の順で検索します。FTS5 が使えない場合や、
+// trigram で検索できない短い語を含む場合は LIKE で検索し、一致した回数で順位を付けます。
+// アーカイブされたリストの TODO は、ReadTODOs と同じく含めません。
+func (s *SQLTODORepository) SearchTODO(ctx context.Context, query string, size, offset int64) ([]*model.SearchTODOResult, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 || size <= 0 {
+		return []*model.SearchTODOResult{}, nil
+	}
+
+	useFTS, err := s.hasFTS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < minFTSTermRunes {
+			useFTS = false
+		}
+	}
+
+	var results []*model.SearchTODOResult
+	if useFTS {
+		results, err = s.searchFTS(ctx, terms, size, offset)
+	} else {
+		results, err = s.searchLike(ctx, terms, size, offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	todos := make([]*model.TODO, len(results))
+	for i, result := range results {
+		todos[i] = result.TODO
+	}
+	if err := attachTags(ctx, s.db, todos); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// hasFTS は、FTS5 が使えて todos_fts が作成済みかを返します。
+func (s *SQLTODORepository) hasFTS(ctx context.Context) (bool, error) {
+	const check = `SELECT sqlite_compileoption_used('ENABLE_FTS5')
+		AND EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'todos_fts')`
+
+	// todos_fts は SQLite でのみ作成する
+	if s.db.driver != db.DriverSQLite {
+		return false, nil
+	}
+
+	var ok bool
+	if err := s.db.QueryRowContext(ctx, check).Scan(&ok); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// searchFTS は todos_fts を使って検索します。
+func (s *SQLTODORepository) searchFTS(ctx context.Context, terms []string, size, offset int64) ([]*model.SearchTODOResult, error) {
+	columns, columnArgs := todoColumns(ctx)
+	access, accessArgs := todoAccessFilter(ctx, "todos.", false)
+	search := `SELECT ` + columns + `, f.score, f.subject_highlight, f.description_snippet
+		FROM todos JOIN (
+			SELECT rowid,
+				-bm25(todos_fts, 10.0, 1.0) AS score,
+				highlight(todos_fts, 0, '` + markOpen + `', '` + markClose + `') AS subject_highlight,
+				snippet(todos_fts, 1, '` + markOpen + `', '` + markClose + `', '…', ?) AS description_snippet
+			FROM todos_fts WHERE todos_fts MATCH ?
+		) f ON f.rowid = todos.id
+		WHERE todos.deleted_at IS NULL AND ` + notArchived("todos.") + ` AND ` + access + `
+		ORDER BY f.score DESC, todos.id DESC
+		LIMIT ? OFFSET ?`
+
+	// 語をすべて含む行を探すよう、各語をフレーズとして AND で結合する
+	phrases := make([]string, len(terms))
+	for i, term := range terms {
+		phrases[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+
+	args := append(append(columnArgs, snippetTokens, strings.Join(phrases, " AND ")), accessArgs...)
+	rows, err := s.db.QueryContext(ctx, search, append(args, size, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*model.SearchTODOResult{}
+	for rows.Next() {
+		var (
+			result           model.SearchTODOResult
+			subject, snippet string
+		)
+		todo, err := scanTODO(scannerFunc(func(dest ...interface{}) error {
+			return rows.Scan(append(dest, &result.Score, &subject, &snippet)...)
+		}))
+		if err != nil {
+			return nil, err
+		}
+		result.TODO = todo
+		result.Highlight = model.TODOHighlight{
+			Subject:     renderMarks(subject),
+			Description: renderMarks(snippet),
+		}
+		results = append(results, &result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// searchLike は LIKE を使って検索します。一致した回数による順位付けと offset, size の切り出しも SQL で行います。
+func (s *SQLTODORepository) searchLike(ctx context.Context, terms []string, size, offset int64) ([]*model.SearchTODOResult, error) {
+	// 語ごとに、subject と description に含まれる回数を数える (置き換えで短くなった文字数を語の長さで割る)
+	columns, args := todoColumns(ctx)
+	var scores []string
+	for _, term := range terms {
+		for _, column := range []string{"subject", "description"} {
+			count := `(LENGTH(LOWER(` + column + `)) - LENGTH(REPLACE(LOWER(` + column + `), LOWER(?), ''))) / LENGTH(?)`
+			if column == "subject" {
+				count = strconv.FormatFloat(subjectWeight, 'f', -1, 64) + ` * ` + count
+			}
+			scores = append(scores, count)
+			args = append(args, term, term)
+		}
+	}
+
+	access, accessArgs := todoAccessFilter(ctx, "", false)
+	conds := []string{"deleted_at IS NULL", notArchived(""), access}
+	args = append(args, accessArgs...)
+	for _, term := range terms {
+		pattern := "%" + escapeLike(term) + "%"
+		// PostgreSQL の LIKE は大文字と小文字を区別するため、SQLite に合わせて LOWER で揃えて比較する
+		conds = append(conds, `(LOWER(subject) LIKE LOWER(?) ESCAPE '\' OR LOWER(description) LIKE LOWER(?) ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	query := `SELECT ` + columns + `, ` + strings.Join(scores, " + ") + ` AS score
+		FROM todos WHERE ` + strings.Join(conds, " AND ") + `
+		ORDER BY score DESC, id DESC
+		LIMIT ? OFFSET ?`
+	args = append(args, size, offset)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*model.SearchTODOResult{}
+	for rows.Next() {
+		var score float64
+		todo, err := scanTODO(scannerFunc(func(dest ...interface{}) error {
+			return rows.Scan(append(dest, &score)...)
+		}))
+		if err != nil {
+			return nil, err
+		}
+		result := likeResult(todo, terms)
+		result.Score = score
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// likeResult は、todo の terms に一致した箇所を目印で囲み、一致した回数をスコアにした検索結果を返します。
+// subject の一致は description の一致の subjectWeight 倍に数えます。
+func likeResult(todo *model.TODO, terms []string) *model.SearchTODOResult {
+	subject, subjectHits := markTerms(todo.Subject, terms)
+	description, descriptionHits := markTerms(todo.Description, terms)
+	return &model.SearchTODOResult{
+		TODO:  todo,
+		Score: subjectWeight*float64(subjectHits) + float64(descriptionHits),
+		Highlight: model.TODOHighlight{
+			Subject:     renderMarks(subject),
+			Description: renderMarks(snippetAround(description)),
+		},
+	}
+}
+
+// rankMatches は、terms をすべて含む todos を likeResult のスコアで順位付けし、offset から size 件を返します。
+// SQLite で FTS5 を使わない場合と同じ順になります。
+func rankMatches(todos []*model.TODO, terms []string, size, offset int64) []*model.SearchTODOResult {
+	results := make([]*model.SearchTODOResult, 0, len(todos))
+	for _, todo := range todos {
+		results = append(results, likeResult(todo, terms))
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].TODO.ID > results[j].TODO.ID
+	})
+
+	if offset >= int64(len(results)) {
+		return []*model.SearchTODOResult{}
+	}
+	results = results[offset:]
+	if size < int64(len(results)) {
+		results = results[:size]
+	}
+	return results
+}
+
+// scannerFunc は関数を scanner として扱うためのアダプタです。
+type scannerFunc func(dest ...interface{}) error
+
+func (f scannerFunc) Scan(dest ...interface{}) error {
+	return f(dest...)
+}
+
+// escapeLike は LIKE のワイルドカードをエスケープします。
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// markTerms は text 中の terms を、SQLite の LIKE と同じく ASCII の英字の大文字小文字を区別せずに目印で囲み、
+// 一致した回数とともに返します。
+func markTerms(text string, terms []string) (string, int) {
+	lower := likeFold(text)
+	// 一致した範囲をバイト単位で記録する
+	matched := make([]bool, len(text))
+	hits := 0
+	for _, term := range terms {
+		term = likeFold(term)
+		for from := 0; ; {
+			i := strings.Index(lower[from:], term)
+			if i < 0 {
+				break
+			}
+			for j := from + i; j < from+i+len(term); j++ {
+				matched[j] = true
+			}
+			hits++
+			from += i + len(term)
+		}
+	}
+	if hits == 0 {
+		return text, hits
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if matched[i] && (i == 0 || !matched[i-1]) {
+			b.WriteString(markOpen)
+		}
+		b.WriteByte(text[i])
+		if matched[i] && (i == len(text)-1 || !matched[i+1]) {
+			b.WriteString(markClose)
+		}
+	}
+	return b.String(), hits
+}
+
+// snippetAround は最初の目印の前後 snippetRunes 文字を切り出します。
+func snippetAround(marked string) string {
+	runes := []rune(marked)
+	first := -1
+	for i, r := range runes {
+		if string(r) == markOpen {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	start, end := first-snippetRunes, first+2*snippetRunes
+	prefix, suffix := "…", "…"
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	if end >= len(runes) {
+		end, suffix = len(runes), ""
+	}
+	// 切り出した範囲で目印の対応が崩れないよう補う
+	snippet := string(runes[start:end])
+	if strings.Count(snippet, markOpen) > strings.Count(snippet, markClose) {
+		snippet += markClose
+	}
+	if strings.Count(snippet, markClose) > strings.Count(snippet, markOpen) {
+		snippet = markOpen + snippet
+	}
+	return prefix + snippet + suffix
+}
+
+// renderMarks は text を HTML エスケープし、目印を <mark> タグに置き換えます。
+func renderMarks(text string) string {
+	return strings.NewReplacer(markOpen, "<mark>", markClose, "</mark>").Replace(html.EscapeString(text))
+}
+
+// likeFold は、SQLite の LIKE と LOWER と同じく ASCII の英字のみを小文字にします。
+func likeFold(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
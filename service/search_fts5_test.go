@@ -0,0 +1,115 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// FTS5 を使った検索は go test -tags sqlite_fts5 ./service/... で確認します。
+func TestSQLite_SearchFTS(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := storages()["SQLite"](t)
+
+	inDescription, err := s.todo.CreateTODO(ctx, "write docs", "review the golang style guide")
+	if err != nil {
+		t.Fatal("failed to create, err =", err)
+	}
+	inSubject := mustCreate(t, ctx, s, "learn golang")
+	both, err := s.todo.CreateTODO(ctx, "golang meetup", "bring golang stickers")
+	if err != nil {
+		t.Fatal("failed to create, err =", err)
+	}
+	mustCreate(t, ctx, s, "unrelated")
+
+	results, err := s.todo.SearchTODO(ctx, "GOLANG", 10, 0)
+	if err != nil {
+		t.Fatal("failed to search, err =", err)
+	}
+	// bm25 では subject の一致を重く数えるため、subject に含む TODO が description だけに含む TODO より上に並ぶ
+	if len(results) != 3 || results[2].TODO.ID != inDescription.ID {
+		t.Fatalf("unexpected results, given = %+v", results)
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i-1].Score < results[i].Score {
+			t.Errorf("results should be ordered by score, given = %v then %v", results[i-1].Score, results[i].Score)
+		}
+	}
+	for _, result := range results[:2] {
+		if result.TODO.ID != inSubject.ID && result.TODO.ID != both.ID {
+			t.Errorf("unexpected result, given = %+v", result.TODO)
+		}
+		if result.TODO.ID == inSubject.ID && result.Highlight.Subject != "learn <mark>golang</mark>" {
+			t.Errorf("unexpected highlight, given = %q", result.Highlight.Subject)
+		}
+	}
+	if !strings.Contains(results[2].Highlight.Description, "<mark>golang</mark>") {
+		t.Errorf("unexpected snippet, given = %q", results[2].Highlight.Description)
+	}
+
+	// すべての語を含む TODO だけを返し、offset と size で切り出す
+	if results, err := s.todo.SearchTODO(ctx, "golang stickers", 10, 0); err != nil || len(results) != 1 || results[0].TODO.ID != both.ID {
+		t.Errorf("unexpected results for two terms, given = %+v, err = %v", results, err)
+	}
+	if page, err := s.todo.SearchTODO(ctx, "golang", 1, 2); err != nil || len(page) != 1 || page[0].TODO.ID != inDescription.ID {
+		t.Errorf("unexpected page, given = %+v, err = %v", page, err)
+	}
+
+	// 更新とゴミ箱への移動は索引に反映される
+	if _, err := s.todo.UpdateTODO(ctx, inSubject.ID, "learn rust", ""); err != nil {
+		t.Fatal("failed to update, err =", err)
+	}
+	if err := s.todo.DeleteTODO(ctx, []int64{both.ID}); err != nil {
+		t.Fatal("failed to delete, err =", err)
+	}
+	results, err = s.todo.SearchTODO(ctx, "golang", 10, 0)
+	if err != nil || len(results) != 1 || results[0].TODO.ID != inDescription.ID {
+		t.Errorf("unexpected results after update, given = %+v, err = %v", results, err)
+	}
+	if results, err := s.todo.SearchTODO(ctx, "rust", 10, 0); err != nil || len(results) != 1 {
+		t.Errorf("unexpected results for the new subject, given = %+v, err = %v", results, err)
+	}
+
+	// アーカイブされたリストの TODO は検索されない
+	list, err := s.list.CreateList(ctx, "old", "")
+	if err != nil {
+		t.Fatal("failed to create list, err =", err)
+	}
+	if _, err := s.todo.MoveTODOs(ctx, []int64{inDescription.ID}, &list.ID); err != nil {
+		t.Fatal("failed to move, err =", err)
+	}
+	archived := true
+	if _, err := s.list.UpdateList(ctx, list.ID, "old", "", &archived); err != nil {
+		t.Fatal("failed to archive list, err =", err)
+	}
+	if results, err := s.todo.SearchTODO(ctx, "golang", 10, 0); err != nil || len(results) != 0 {
+		t.Errorf("unexpected results in archived list, given = %+v, err = %v", results, err)
+	}
+
+	// 他の利用者の TODO は検索されない
+	alice, err := s.user.Signup(ctx, "alice", "password1")
+	if err != nil {
+		t.Fatal("failed to sign up, err =", err)
+	}
+	aliceCtx := service.ContextWithUser(ctx, alice)
+	if _, err := s.todo.CreateTODO(aliceCtx, "golang secret", ""); err != nil {
+		t.Fatal("failed to create, err =", err)
+	}
+	bob, err := s.user.Signup(ctx, "bob", "password2")
+	if err != nil {
+		t.Fatal("failed to sign up, err =", err)
+	}
+	if results, err := s.todo.SearchTODO(service.ContextWithUser(ctx, bob), "golang", 10, 0); err != nil || len(results) != 0 {
+		t.Errorf("unexpected results for another user, given = %+v, err = %v", results, err)
+	}
+	if results, err := s.todo.SearchTODO(aliceCtx, "golang", 10, 0); err != nil || len(results) != 1 {
+		t.Errorf("unexpected results for the owner, given = %+v, err = %v", results, err)
+	}
+}
@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/jwt"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// refreshTokenPrefix はリフレッシュトークンの接頭辞です。
+const refreshTokenPrefix = "str_"
+
+// セッションの既定の有効期間です。
+const (
+	// DefaultAccessTokenTTL はアクセストークンの既定の有効期間です。
+	DefaultAccessTokenTTL = 15 * time.Minute
+	// DefaultSessionTTL は、ログインしてからリフレッシュトークンを使えなくなるまでの既定の期間です。
+	DefaultSessionTTL = 30 * 24 * time.Hour
+)
+
+// sessionKey は、認証に使ったセッションを context に保持するためのキーです。
+type sessionKey struct{}
+
+// ContextWithSession returns a copy of ctx that carries the session whose access token authenticated the request.
+func ContextWithSession(ctx context.Context, session *model.Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// SessionFromContext returns the session set by ContextWithSession.
+func SessionFromContext(ctx context.Context) (*model.Session, bool) {
+	session, ok := ctx.Value(sessionKey{}).(*model.Session)
+	return session, ok && session != nil
+}
+
+// A SessionRepository persists sessions and their refresh tokens.
+//
+// 取り消されたセッションや期限切れのセッションのリフレッシュトークンは ErrNotFound として扱います。
+type SessionRepository interface {
+	// CreateSession は ctx の利用者のセッションを、最初のリフレッシュトークンとともに作成します。
+	CreateSession(ctx context.Context, refreshTokenHash string, expiresAt time.Time) (*model.Session, error)
+	// ReadSession は id のセッションとその利用者を返します。取り消されたセッションや期限切れのセッションも返します。
+	ReadSession(ctx context.Context, id int64) (*model.Session, *model.User, error)
+	// RotateRefreshToken はリフレッシュトークンを使用済みにし、同じセッションに新しいリフレッシュトークンを追加します。
+	// 使用済みのリフレッシュトークンが再び使われた場合は、盗まれたものとしてセッションを取り消し、ErrConflict を返します。
+	RotateRefreshToken(ctx context.Context, refreshTokenHash, newRefreshTokenHash string) (*model.Session, *model.User, error)
+	// RevokeSession はリフレッシュトークンのセッションを取り消します。使用済みのリフレッシュトークンでも取り消せます。
+	RevokeSession(ctx context.Context, refreshTokenHash string) (*model.Session, error)
+}
+
+// sessionColumns は、セッションを取得する際に SELECT するカラムです。scanSession と順序を合わせてください。
+const sessionColumns = `sessions.id, sessions.user_id, sessions.expires_at, sessions.revoked_at, sessions.created_at`
+
+// sessionUserColumns は、セッションとその利用者を取得する際に sessionColumns に続けて SELECT するカラムです。
+const sessionUserColumns = `users.name, users.password_hash, users.admin, users.created_at, users.updated_at`
+
+// A SQLSessionRepository implements SessionRepository on SQLite or PostgreSQL.
+type SQLSessionRepository struct {
+	db *sqlDB
+}
+
+// NewSQLSessionRepository returns new SQLSessionRepository for the driver that db was opened with.
+func NewSQLSessionRepository(db *sql.DB) *SQLSessionRepository {
+	return &SQLSessionRepository{
+		db: newSQLDB(db),
+	}
+}
+
+// scanSession は sessionColumns の順に並んだ値を model.Session に変換します。dest は続けて読み込むカラムです。
+func scanSession(row scanner, dest ...interface{}) (*model.Session, error) {
+	var (
+		session   model.Session
+		revokedAt sql.NullTime
+	)
+	dest = append([]interface{}{&session.ID, &session.UserID, &session.ExpiresAt, &revokedAt, &session.CreatedAt}, dest...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return &session, nil
+}
+
+// scanSessionUser は sessionColumns と sessionUserColumns の順に並んだ行を読み込みます。存在しない場合は ErrNotFound を返します。
+func scanSessionUser(row scanner, dest ...interface{}) (*model.Session, *model.User, error) {
+	var user model.User
+	session, err := scanSession(row, append([]interface{}{&user.Name, &user.PasswordHash, &user.Admin, &user.CreatedAt, &user.UpdatedAt}, dest...)...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, &model.ErrNotFound{}
+		}
+		return nil, nil, err
+	}
+	user.ID = session.UserID
+	return session, &user, nil
+}
+
+// CreateSession creates a session of the user of ctx on DB.
+func (s *SQLSessionRepository) CreateSession(ctx context.Context, refreshTokenHash string, expiresAt time.Time) (*model.Session, error) {
+	const (
+		insert  = `INSERT INTO sessions(user_id, expires_at) VALUES(?, ?) RETURNING id`
+		refresh = `INSERT INTO refresh_tokens(session_id, token_hash) VALUES(?, ?)`
+		read    = `SELECT ` + sessionColumns + ` FROM sessions WHERE id = ?`
+	)
+
+	userID := ownerID(ctx)
+	if userID == nil {
+		return nil, &model.ErrUnauthorized{}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	if err := tx.QueryRowContext(ctx, insert, *userID, dbTime(&expiresAt)).Scan(&id); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, refresh, id, refreshTokenHash); err != nil {
+		return nil, err
+	}
+	session, err := scanSession(tx.QueryRowContext(ctx, read, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// ReadSession reads the session and its user on DB.
+func (s *SQLSessionRepository) ReadSession(ctx context.Context, id int64) (*model.Session, *model.User, error) {
+	const read = `SELECT ` + sessionColumns + `, ` + sessionUserColumns + `
+		FROM sessions JOIN users ON users.id = sessions.user_id WHERE sessions.id = ?`
+
+	return scanSessionUser(s.db.QueryRowContext(ctx, read, id))
+}
+
+// RotateRefreshToken replaces the refresh token with a new one on DB.
+func (s *SQLSessionRepository) RotateRefreshToken(ctx context.Context, refreshTokenHash, newRefreshTokenHash string) (*model.Session, *model.User, error) {
+	const (
+		read = `SELECT ` + sessionColumns + `, ` + sessionUserColumns + `, refresh_tokens.id, refresh_tokens.used_at
+			FROM refresh_tokens JOIN sessions ON sessions.id = refresh_tokens.session_id JOIN users ON users.id = sessions.user_id
+			WHERE refresh_tokens.token_hash = ?`
+		use     = `UPDATE refresh_tokens SET used_at = DATETIME('now') WHERE id = ? AND used_at IS NULL`
+		refresh = `INSERT INTO refresh_tokens(session_id, token_hash) VALUES(?, ?)`
+		revoke  = `UPDATE sessions SET revoked_at = DATETIME('now') WHERE id = ? AND revoked_at IS NULL`
+	)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		refreshTokenID int64
+		usedAt         sql.NullTime
+	)
+	session, user, err := scanSessionUser(tx.QueryRowContext(ctx, read, refreshTokenHash), &refreshTokenID, &usedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.RevokedAt != nil || !session.ExpiresAt.After(time.Now()) {
+		return nil, nil, &model.ErrNotFound{}
+	}
+
+	var used bool
+	if !usedAt.Valid {
+		result, err := tx.ExecContext(ctx, use, refreshTokenID)
+		if err != nil {
+			return nil, nil, err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return nil, nil, err
+		}
+		used = rowsAffected == 0
+	}
+	if usedAt.Valid || used {
+		// 再利用を検知した場合は、取り消しを確定させてからエラーを返す
+		if _, err := tx.ExecContext(ctx, revoke, session.ID); err != nil {
+			return nil, nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, &model.ErrConflict{Message: "the refresh token has already been used"}
+	}
+	if _, err := tx.ExecContext(ctx, refresh, session.ID, newRefreshTokenHash); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return session, user, nil
+}
+
+// RevokeSession revokes the session of the refresh token on DB. 取り消し済みのセッションはそのまま返します。
+func (s *SQLSessionRepository) RevokeSession(ctx context.Context, refreshTokenHash string) (*model.Session, error) {
+	const (
+		find   = `SELECT session_id FROM refresh_tokens WHERE token_hash = ?`
+		revoke = `UPDATE sessions SET revoked_at = COALESCE(revoked_at, DATETIME('now')) WHERE id = ?`
+		read   = `SELECT ` + sessionColumns + ` FROM sessions WHERE id = ?`
+	)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	if err := tx.QueryRowContext(ctx, find, refreshTokenHash).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &model.ErrNotFound{}
+		}
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, revoke, id); err != nil {
+		return nil, err
+	}
+	session, err := scanSession(tx.QueryRowContext(ctx, read, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// A SessionService issues signed JWT access tokens and rotating refresh tokens on a SessionRepository.
+type SessionService struct {
+	SessionRepository
+
+	// Keys はアクセストークンに署名し、検証する鍵です。
+	Keys *jwt.KeySet
+	// AccessTokenTTL はアクセストークンの有効期間です。
+	AccessTokenTTL time.Duration
+	// SessionTTL は、ログインしてからリフレッシュトークンを使えなくなるまでの期間です。
+	SessionTTL time.Duration
+}
+
+// NewSessionService returns new SessionService that stores sessions in the SQLite or PostgreSQL database.
+func NewSessionService(db *sql.DB, keys *jwt.KeySet) *SessionService {
+	return NewSessionServiceWithRepository(NewSQLSessionRepository(db), keys)
+}
+
+// NewSessionServiceWithRepository returns new SessionService that stores sessions in repo.
+// 有効期間は DefaultAccessTokenTTL と DefaultSessionTTL です。
+func NewSessionServiceWithRepository(repo SessionRepository, keys *jwt.KeySet) *SessionService {
+	return &SessionService{
+		SessionRepository: repo,
+		Keys:              keys,
+		AccessTokenTTL:    DefaultAccessTokenTTL,
+		SessionTTL:        DefaultSessionTTL,
+	}
+}
+
+// Login starts a session of the user and returns its tokens.
+func (s *SessionService) Login(ctx context.Context, user *model.User) (*model.SessionTokens, error) {
+	refreshToken, err := generateToken(refreshTokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session, err := s.CreateSession(ContextWithUser(ctx, user), hashToken(refreshToken), now.Add(s.SessionTTL))
+	if err != nil {
+		return nil, err
+	}
+	return s.sessionTokens(user, session, refreshToken, now)
+}
+
+// Refresh rotates the refresh token and returns the user and new tokens of the session.
+// リフレッシュトークンが使えない場合は ErrUnauthorized を返します。
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string) (*model.User, *model.SessionTokens, error) {
+	newRefreshToken, err := generateToken(refreshTokenPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	session, user, err := s.RotateRefreshToken(ctx, hashToken(refreshToken), hashToken(newRefreshToken))
+	if err != nil {
+		switch {
+		case model.IsErrNotFound(err):
+			return nil, nil, &model.ErrUnauthorized{Message: "the refresh token is invalid or the session has ended"}
+		case model.IsErrConflict(err):
+			return nil, nil, &model.ErrUnauthorized{Message: "the refresh token has already been used, the session has been revoked"}
+		}
+		return nil, nil, err
+	}
+	tokens, err := s.sessionTokens(user, session, newRefreshToken, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// Logout revokes the session of the refresh token. アクセストークンもすぐに使えなくなります。
+func (s *SessionService) Logout(ctx context.Context, refreshToken string) error {
+	if _, err := s.RevokeSession(ctx, hashToken(refreshToken)); err != nil {
+		if model.IsErrNotFound(err) {
+			return &model.ErrUnauthorized{Message: "the refresh token is invalid"}
+		}
+		return err
+	}
+	return nil
+}
+
+// Authenticate verifies the access token and returns its user and session.
+// 署名や有効期間が正しくない場合や、セッションが取り消された場合は ErrUnauthorized を返します。
+func (s *SessionService) Authenticate(ctx context.Context, accessToken string) (*model.User, *model.Session, error) {
+	claims, err := s.Keys.Verify(accessToken, time.Now())
+	if err != nil {
+		return nil, nil, &model.ErrUnauthorized{Message: "the access token is invalid or has expired"}
+	}
+	id, err := strconv.ParseInt(claims.SessionID, 10, 64)
+	if err != nil {
+		return nil, nil, &model.ErrUnauthorized{Message: "the access token has no session"}
+	}
+
+	session, user, err := s.ReadSession(ctx, id)
+	if err != nil {
+		if model.IsErrNotFound(err) {
+			return nil, nil, &model.ErrUnauthorized{Message: "the session has ended"}
+		}
+		return nil, nil, err
+	}
+	if session.RevokedAt != nil || !session.ExpiresAt.After(time.Now()) || strconv.FormatInt(user.ID, 10) != claims.Subject {
+		return nil, nil, &model.ErrUnauthorized{Message: "the session has ended"}
+	}
+	return user, session, nil
+}
+
+// sessionTokens は session のアクセストークンに署名し、refreshToken と組にして返します。
+func (s *SessionService) sessionTokens(user *model.User, session *model.Session, refreshToken string, now time.Time) (*model.SessionTokens, error) {
+	accessToken, err := s.Keys.Sign(&jwt.Claims{
+		Subject:   strconv.FormatInt(user.ID, 10),
+		Name:      user.Name,
+		SessionID: strconv.FormatInt(session.ID, 10),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.AccessTokenTTL).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.SessionTokens{
+		AccessToken:  accessToken,
+		TokenType:    model.TokenTypeBearer,
+		ExpiresIn:    int64(s.AccessTokenTTL / time.Second),
+		RefreshToken: refreshToken,
+	}, nil
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// ReadTODOChildren reads the direct children of the TODO ordered by id.
+func (s *SQLTODORepository) ReadTODOChildren(ctx context.Context, id int64) ([]*model.TODO, error) {
+	if err := checkExists(ctx, s.db, id); err != nil {
+		return nil, err
+	}
+
+	// 他の利用者が作成した子は、共有されたリストにある場合のみ返す
+	columns, columnArgs := todoColumns(ctx)
+	access, accessArgs := todoAccessFilter(ctx, "", false)
+	read := `SELECT ` + columns + ` FROM todos WHERE parent_id = ? AND deleted_at IS NULL AND ` + access + ` ORDER BY id`
+	rows, err := s.db.QueryContext(ctx, read, append(append(columnArgs, id), accessArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := scanTODOs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := attachTags(ctx, s.db, todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// ReadTODOTree reads the TODO and all of its descendants. Children of each node are ordered by id.
+func (s *SQLTODORepository) ReadTODOTree(ctx context.Context, id int64) (*model.TODONode, error) {
+	// ctx の利用者が読めない TODO を指定した場合は、読める子孫があっても ErrNotFound を返す
+	if err := checkExists(ctx, s.db, id); err != nil {
+		return nil, err
+	}
+
+	// 読めない子の子孫は、親のないノードにならないよう、読める子孫であってもたどらない
+	descend, descendArgs := todoAccessFilter(ctx, "t.", false)
+	columns, columnArgs := todoColumns(ctx)
+	access, accessArgs := todoAccessFilter(ctx, "", false)
+	read := `WITH RECURSIVE tree(id) AS (
+			SELECT CAST(? AS BIGINT)
+			UNION
+			SELECT t.id FROM todos t JOIN tree ON t.parent_id = tree.id WHERE t.deleted_at IS NULL AND ` + descend + `
+		)
+		SELECT ` + columns + ` FROM todos WHERE id IN (SELECT id FROM tree) AND deleted_at IS NULL AND ` + access + ` ORDER BY id`
+
+	args := append(append(append([]interface{}{id}, descendArgs...), columnArgs...), accessArgs...)
+	rows, err := s.db.QueryContext(ctx, read, args...)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := scanTODOs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(todos) == 0 {
+		return nil, &model.ErrNotFound{}
+	}
+	if err := attachTags(ctx, s.db, todos); err != nil {
+		return nil, err
+	}
+
+	// 親の付け替えで子の id が親より小さいこともあるため、先にすべてのノードを作ってからつなぐ
+	nodes := make(map[int64]*model.TODONode, len(todos))
+	for _, todo := range todos {
+		nodes[todo.ID] = &model.TODONode{TODO: todo, Children: []*model.TODONode{}}
+	}
+	for _, todo := range todos {
+		if todo.ID == id || todo.ParentID == nil {
+			continue
+		}
+		// 検索と同時に親が変更された場合などに備え、親のないノードはつながない
+		parent, ok := nodes[*todo.ParentID]
+		if !ok {
+			continue
+		}
+		parent.Children = append(parent.Children, nodes[todo.ID])
+	}
+
+	return nodes[id], nil
+}
+
+// checkExists は id の TODO が存在しない、ゴミ箱にある、または ctx の利用者が読めない場合に ErrNotFound を返します。
+func checkExists(ctx context.Context, q dbtx, id int64) error {
+	access, accessArgs := todoAccessFilter(ctx, "", false)
+	query := `SELECT EXISTS (SELECT 1 FROM todos WHERE id = ? AND deleted_at IS NULL AND ` + access + `)`
+	var exists bool
+	if err := q.QueryRowContext(ctx, query, append([]interface{}{id}, accessArgs...)...).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return &model.ErrNotFound{}
+	}
+	return nil
+}
+
+// checkParent は id の TODO の親を parentID にできるかを確認します。新規作成の場合 id は 0 です。
+// 親が存在しない場合は ErrValidation を、親を変更できない場合は ErrForbidden を、
+// 親が id 自身またはその子孫の場合は ErrConflict を返します。
+func checkParent(ctx context.Context, q dbtx, id, parentID int64) error {
+	const ancestors = `WITH RECURSIVE ancestors(id) AS (
+			SELECT CAST(? AS BIGINT)
+			UNION
+			SELECT t.parent_id FROM todos t JOIN ancestors ON t.id = ancestors.id WHERE t.parent_id IS NOT NULL
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = ?)`
+
+	if err := checkWritable(ctx, q, parentID); err != nil {
+		if model.IsErrNotFound(err) {
+			return &model.ErrValidation{Field: "parent_id", Message: fmt.Sprintf("parent TODO %d does not exist", parentID)}
+		}
+		return err
+	}
+	if id == 0 {
+		return nil
+	}
+
+	var cyclic bool
+	if err := q.QueryRowContext(ctx, ancestors, parentID, id).Scan(&cyclic); err != nil {
+		return err
+	}
+	if cyclic {
+		return &model.ErrConflict{Message: fmt.Sprintf("TODO %d can't be a child of itself or of its descendant %d", id, parentID)}
+	}
+	return nil
+}
+
+// deleteAndReparent は ids の TODO を 1 件ずつゴミ箱に移し、その子をゴミ箱に移した TODO の親に付け替えます。
+// 1 件ずつ処理するため、削除対象同士が親子関係にあっても、子は削除されない最も近い祖先に付け替えられます。
+// ctx の利用者が変更できない子は付け替えません。ゴミ箱に移した TODO の id を返します。
+func deleteAndReparent(ctx context.Context, q dbtx, ids []int64) ([]int64, error) {
+	const remove = `UPDATE todos SET deleted_at = DATETIME('now') WHERE id = ? AND deleted_at IS NULL`
+	write, writeArgs := todoAccessFilter(ctx, "", true)
+	reparent := `UPDATE todos SET parent_id = (SELECT p.parent_id FROM todos p WHERE p.id = ?) WHERE parent_id = ? AND deleted_at IS NULL AND ` + write
+
+	var deleted []int64
+	for _, id := range uniqueInt64s(ids) {
+		if _, err := q.ExecContext(ctx, reparent, append([]interface{}{id, id}, writeArgs...)...); err != nil {
+			return nil, err
+		}
+		result, err := q.ExecContext(ctx, remove, id)
+		if err != nil {
+			return nil, err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if rowsAffected > 0 {
+			deleted = append(deleted, id)
+		}
+	}
+	return deleted, nil
+}
+
+// deleteWithDescendants は ids の TODO とその子孫をすべてゴミ箱に移します。
+// 1 つの文で更新するため、すべて同じ deleted_at になり、RestoreTODOs でまとめて元に戻せます。
+// ctx の利用者が変更できない子孫は、その子孫も含めてゴミ箱に移しません。ゴミ箱に移した TODO の id を返します。
+func deleteWithDescendants(ctx context.Context, q dbtx, ids []int64) ([]int64, error) {
+	write, writeArgs := todoAccessFilter(ctx, "t.", true)
+	collect := fmt.Sprintf(`WITH RECURSIVE descendants(id) AS (
+			SELECT id FROM todos WHERE id IN (%s) AND deleted_at IS NULL
+			UNION
+			SELECT t.id FROM todos t JOIN descendants d ON t.parent_id = d.id WHERE t.deleted_at IS NULL AND %s
+		)
+		SELECT id FROM descendants ORDER BY id`, placeholders(len(ids)), write)
+
+	rows, err := q.QueryContext(ctx, collect, append(int64Args(ids), writeArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+
+	remove := fmt.Sprintf(`UPDATE todos SET deleted_at = DATETIME('now') WHERE id IN (%s)`, placeholders(len(deleted)))
+	if _, err := q.ExecContext(ctx, remove, int64Args(deleted)...); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// scanIDs は 1 列の id からなる rows をすべてスキャンして閉じます。
+func scanIDs(rows *sql.Rows) ([]int64, error) {
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A SQLTagRepository implements TagRepository on SQLite or PostgreSQL.
+type SQLTagRepository struct {
+	db *sqlDB
+}
+
+// NewSQLTagRepository returns new SQLTagRepository for the driver that db was opened with.
+func NewSQLTagRepository(db *sql.DB) *SQLTagRepository {
+	return &SQLTagRepository{
+		db: newSQLDB(db),
+	}
+}
+
+// タグの名前はすべての利用者で共有しますが、利用者に見えるのは読める TODO に付いたタグだけです。
+// 件数は読める TODO だけを数え、名前の変更と統合は変更できる TODO だけに適用します。
+// 他の利用者の TODO にも付いたタグの名前を変更する場合は、自分の TODO だけを新しい名前のタグに付け替えます。
+
+// ReadTags reads the tags visible to the user of ctx ordered by name with the number of TODOs they are attached to.
+func (s *SQLTagRepository) ReadTags(ctx context.Context) ([]*model.Tag, error) {
+	read, args := tagQuery(ctx, "1 = 1")
+	rows, err := s.db.QueryContext(ctx, read+` ORDER BY t.name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*model.Tag{}
+	for rows.Next() {
+		var tag model.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.TODOCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// RenameTag renames the tag. It returns *model.ErrConflict if another tag already has the name;
+// use MergeTags to combine them.
+//
+// タグが ctx の利用者の変更できない TODO にも付いている場合は、変更できる TODO だけを name のタグに付け替えます。
+// 変更できる TODO にタグが付いていない場合は ErrForbidden を返します。
+func (s *SQLTagRepository) RenameTag(ctx context.Context, id int64, name string) (*model.Tag, error) {
+	const (
+		exists = `SELECT id FROM tags WHERE name = ?`
+		update = `UPDATE tags SET name = ? WHERE id = ?`
+		insert = `INSERT INTO tags(name) VALUES(?) ON CONFLICT(name) DO NOTHING`
+	)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := readTag(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	var otherID int64
+	err = tx.QueryRowContext(ctx, exists, name).Scan(&otherID)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return nil, err
+	case otherID != id:
+		// 利用者に見えないタグと名前が重なる場合は、重複を知らせずにそのタグへ付け替える
+		if _, err := readTag(ctx, tx, otherID); !model.IsErrNotFound(err) {
+			if err != nil {
+				return nil, err
+			}
+			return nil, &model.ErrConflict{Message: "tag " + name + " already exists"}
+		}
+	}
+
+	if otherID != id {
+		writable, total, err := countTaggedTODOs(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if otherID == 0 && writable == total {
+			if _, err := tx.ExecContext(ctx, update, name, id); err != nil {
+				return nil, err
+			}
+		} else {
+			if writable == 0 {
+				return nil, &model.ErrForbidden{Message: fmt.Sprintf("tag %d is attached only to TODOs that are read-only for you", id)}
+			}
+			if _, err := tx.ExecContext(ctx, insert, name); err != nil {
+				return nil, err
+			}
+			var targetID int64
+			if err := tx.QueryRowContext(ctx, exists, name).Scan(&targetID); err != nil {
+				return nil, err
+			}
+			if err := moveTags(ctx, tx, []int64{id}, targetID); err != nil {
+				return nil, err
+			}
+			id = targetID
+		}
+	}
+
+	tag, err := readTag(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// MergeTags moves every TODO tagged with one of sourceIDs to targetID and deletes the source tags.
+//
+// ctx の利用者が変更できる TODO だけを付け替え、他の TODO に付いたままの統合元のタグは削除しません。
+func (s *SQLTagRepository) MergeTags(ctx context.Context, sourceIDs []int64, targetID int64) (*model.Tag, error) {
+	// 自分自身へのマージは何もしない
+	var sources []int64
+	for _, id := range uniqueInt64s(sourceIDs) {
+		if id != targetID {
+			sources = append(sources, id)
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// 存在しないタグや利用者に見えないタグが含まれる場合は ErrNotFound を返す
+	for _, id := range append([]int64{targetID}, sources...) {
+		if _, err := readTag(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(sources) > 0 {
+		if err := moveTags(ctx, tx, sources, targetID); err != nil {
+			return nil, err
+		}
+	}
+
+	tag, err := readTag(ctx, tx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// tagQuery は、where に一致して ctx の利用者に見えるタグを、読めるゴミ箱にない TODO の件数付きで SELECT する文とその引数を返します。
+// 利用者のない ctx では、TODO の付いていないタグも含めてすべてのタグが見えます。
+func tagQuery(ctx context.Context, where string, whereArgs ...interface{}) (string, []interface{}) {
+	access, args := todoAccessFilter(ctx, "todos.", false)
+	query := `SELECT t.id, t.name, COUNT(CASE WHEN todos.deleted_at IS NULL THEN todos.id END) FROM tags t
+		LEFT JOIN todo_tags tt ON tt.tag_id = t.id
+		LEFT JOIN todos ON todos.id = tt.todo_id AND ` + access + `
+		WHERE ` + where + ` GROUP BY t.id`
+	if _, ok := UserFromContext(ctx); ok {
+		query += ` HAVING COUNT(todos.id) > 0`
+	}
+	return query, append(args, whereArgs...)
+}
+
+// readTag は ctx の利用者に見える id のタグを件数付きで取得します。
+func readTag(ctx context.Context, q dbtx, id int64) (*model.Tag, error) {
+	read, args := tagQuery(ctx, "t.id = ?", id)
+
+	var tag model.Tag
+	err := q.QueryRowContext(ctx, read, args...).Scan(&tag.ID, &tag.Name, &tag.TODOCount)
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// countTaggedTODOs は、id のタグが付いた TODO のうち ctx の利用者が変更できるものの件数と、すべての件数を返します。
+func countTaggedTODOs(ctx context.Context, q dbtx, id int64) (writable, total int, err error) {
+	write, args := todoAccessFilter(ctx, "todos.", true)
+	query := `SELECT COUNT(CASE WHEN ` + write + ` THEN 1 END), COUNT(*) FROM todo_tags tt
+		JOIN todos ON todos.id = tt.todo_id WHERE tt.tag_id = ?`
+	err = q.QueryRowContext(ctx, query, append(args, id)...).Scan(&writable, &total)
+	return writable, total, err
+}
+
+// moveTags は、sourceIDs のタグが付いた TODO のうち ctx の利用者が変更できるものを targetID のタグに付け替え、
+// どの TODO にも付いていない統合元のタグを削除します。
+func moveTags(ctx context.Context, q dbtx, sourceIDs []int64, targetID int64) error {
+	write, writeArgs := todoAccessFilter(ctx, "todos.", true)
+	in := `(` + placeholders(len(sourceIDs)) + `)`
+	move := `INSERT INTO todo_tags(todo_id, tag_id) SELECT tt.todo_id, CAST(? AS BIGINT) FROM todo_tags tt
+		JOIN todos ON todos.id = tt.todo_id WHERE tt.tag_id IN ` + in + ` AND ` + write + ` ON CONFLICT DO NOTHING`
+	detach := `DELETE FROM todo_tags WHERE tag_id IN ` + in + ` AND todo_id IN (SELECT todos.id FROM todos WHERE ` + write + `)`
+	orphans := `DELETE FROM tags WHERE id IN ` + in + ` AND NOT EXISTS (SELECT 1 FROM todo_tags tt WHERE tt.tag_id = tags.id)`
+
+	args := append([]interface{}{targetID}, int64Args(sourceIDs)...)
+	if _, err := q.ExecContext(ctx, move, append(args, writeArgs...)...); err != nil {
+		return err
+	}
+	if _, err := q.ExecContext(ctx, detach, append(int64Args(sourceIDs), writeArgs...)...); err != nil {
+		return err
+	}
+	_, err := q.ExecContext(ctx, orphans, int64Args(sourceIDs)...)
+	return err
+}
+
+// setTags は TODO のタグを names で置き換えます。存在しないタグは作成します。
+func setTags(ctx context.Context, q dbtx, todoID int64, names []string) error {
+	const (
+		clear     = `DELETE FROM todo_tags WHERE todo_id = ?`
+		insertTag = `INSERT INTO tags(name) VALUES(?) ON CONFLICT(name) DO NOTHING`
+		attach    = `INSERT INTO todo_tags(todo_id, tag_id) SELECT CAST(? AS BIGINT), id FROM tags WHERE name = ? ON CONFLICT DO NOTHING`
+	)
+
+	if _, err := q.ExecContext(ctx, clear, todoID); err != nil {
+		return err
+	}
+	for _, name := range uniqueStrings(names) {
+		if _, err := q.ExecContext(ctx, insertTag, name); err != nil {
+			return err
+		}
+		if _, err := q.ExecContext(ctx, attach, todoID, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachTags は todos にタグ名を名前順で設定します。タグのない TODO の Tags は nil のままです。
+func attachTags(ctx context.Context, q dbtx, todos []*model.TODO) error {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*model.TODO, len(todos))
+	ids := make([]int64, 0, len(todos))
+	for _, todo := range todos {
+		byID[todo.ID] = todo
+		ids = append(ids, todo.ID)
+	}
+
+	query := `SELECT tt.todo_id, t.name FROM todo_tags tt JOIN tags t ON t.id = tt.tag_id
+		WHERE tt.todo_id IN (` + placeholders(len(ids)) + `) ORDER BY t.name`
+	rows, err := q.QueryContext(ctx, query, int64Args(ids)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			todoID int64
+			name   string
+		)
+		if err := rows.Scan(&todoID, &name); err != nil {
+			return err
+		}
+		byID[todoID].Tags = append(byID[todoID].Tags, name)
+	}
+	return rows.Err()
+}
+
+// placeholders は n 個の '?' をカンマで区切った文字列を返します。
+func placeholders(n int) string {
+	return strings.TrimLeft(strings.Repeat(",?", n), ",")
+}
+
+// int64Args は ids を []interface{} 型に変換します。
+func int64Args(ids []int64) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
+
+// uniqueStrings は順序を保ったまま重複を取り除きます。
+func uniqueStrings(ss []string) []string {
+	seen := make(map[string]bool, len(ss))
+	var result []string
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// uniqueInt64s は順序を保ったまま重複を取り除きます。
+func uniqueInt64s(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	var result []int64
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
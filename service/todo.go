@@ -4,210 +4,635 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-    "strings"
+	"strings"
 	"time"
+
 	"github.com/TechBowl-japan/go-stations/model"
 )
 
-// A TODOService implements CRUD of TODO entities.
-type TODOService struct {
-	db *sql.DB
+// todoColumns は、TODO を取得する際に SELECT するカラムとその引数を返します。scanTODO と順序を合わせてください。
+// 子の進捗と次の回の id は todos を別名なしで FROM に指定した場合にのみ正しく集計されます。
+// ゴミ箱の TODO と、ctx の利用者が読めない TODO は数えません。引数は WHERE 句の引数より前に渡してください。
+func todoColumns(ctx context.Context) (string, []interface{}) {
+	done, doneArgs := todoAccessFilter(ctx, "c.", false)
+	total, totalArgs := todoAccessFilter(ctx, "c.", false)
+	next, nextArgs := todoAccessFilter(ctx, "n.", false)
+	columns := `id, subject, description, completed_at, due_at, priority, parent_id,
+	(SELECT COUNT(*) FROM todos c WHERE c.parent_id = todos.id AND c.deleted_at IS NULL AND c.completed_at IS NOT NULL AND ` + done + `),
+	(SELECT COUNT(*) FROM todos c WHERE c.parent_id = todos.id AND c.deleted_at IS NULL AND ` + total + `),
+	recurrence, occurrence, previous_id, (SELECT MIN(n.id) FROM todos n WHERE n.previous_id = todos.id AND n.deleted_at IS NULL AND ` + next + `),
+	list_id, deleted_at, version, created_at, updated_at`
+	return columns, append(append(doneArgs, totalArgs...), nextArgs...)
+}
+
+// A SQLTODORepository implements TODORepository on SQLite or PostgreSQL.
+type SQLTODORepository struct {
+	db *sqlDB
 }
 
-// NewTODOService returns new TODOService.
-func NewTODOService(db *sql.DB) *TODOService {
-	return &TODOService{
-		db: db,
+// NewSQLTODORepository returns new SQLTODORepository for the driver that db was opened with.
+func NewSQLTODORepository(db *sql.DB) *SQLTODORepository {
+	return &SQLTODORepository{
+		db: newSQLDB(db),
 	}
 }
 
-// CreateTODO creates a TODO on DB.
-func (s *TODOService) CreateTODO(ctx context.Context, subject, description string) (*model.TODO, error) {
-	const (
-		insert  = `INSERT INTO todos(subject, description) VALUES(?, ?)`
-		confirm = `SELECT subject, description, created_at, updated_at FROM todos WHERE id = ?`
+// dbtx は *sql.DB と *sql.Tx の共通インターフェースです。
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// scanner は *sql.Row と *sql.Rows の共通インターフェースです。
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// notArchived は、アーカイブされたリストの TODO を除く WHERE 句の条件を返します。prefix は todos の別名 ("t." など) です。
+func notArchived(prefix string) string {
+	return "(" + prefix + "list_id IS NULL OR " + prefix + "list_id NOT IN (SELECT id FROM lists WHERE archived_at IS NOT NULL))"
+}
+
+// scanTODO は todoColumns の順に並んだ行を model.TODO に変換します。
+func scanTODO(row scanner) (*model.TODO, error) {
+	var (
+		todo        model.TODO
+		completedAt sql.NullTime
+		dueAt       sql.NullTime
+		parentID    sql.NullInt64
+		previousID  sql.NullInt64
+		nextID      sql.NullInt64
+		listID      sql.NullInt64
+		deletedAt   sql.NullTime
 	)
+	if err := row.Scan(&todo.ID, &todo.Subject, &todo.Description, &completedAt, &dueAt, &todo.Priority, &parentID,
+		&todo.Progress.Done, &todo.Progress.Total, &todo.Recurrence, &todo.Occurrence, &previousID, &nextID,
+		&listID, &deletedAt, &todo.Version, &todo.CreatedAt, &todo.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		todo.Done = true
+		todo.CompletedAt = &completedAt.Time
+	}
+	if dueAt.Valid {
+		todo.DueAt = &dueAt.Time
+	}
+	if parentID.Valid {
+		todo.ParentID = &parentID.Int64
+	}
+	if previousID.Valid {
+		todo.PreviousID = &previousID.Int64
+	}
+	if nextID.Valid {
+		todo.NextID = &nextID.Int64
+	}
+	if listID.Valid {
+		todo.ListID = &listID.Int64
+	}
+	if deletedAt.Valid {
+		todo.DeletedAt = &deletedAt.Time
+	}
+	return &todo, nil
+}
+
+// scanTODOs は rows をすべてスキャンして閉じます。結果が 0 件の場合は空スライスを返します。
+func scanTODOs(rows *sql.Rows) ([]*model.TODO, error) {
+	defer rows.Close()
+
+	todos := []*model.TODO{}
+
+	// 取得した行をスキャンしてスライスに追加
+	for rows.Next() {
+		todo, err := scanTODO(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
 
-	// TODO を DB に挿入
-	result, err := s.db.ExecContext(ctx, insert, subject, description)
+	// イテレーション中にエラーが発生したか確認
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// dbTime は DATETIME('now') と文字列として比較できるよう、時刻を UTC の "YYYY-MM-DD HH:MM:SS" 形式に変換します。
+// nil の場合は NULL として扱われるよう nil を返します。
+func dbTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return formatDBTime(*t)
+}
+
+// CreateTODO creates a TODO on DB.
+func (s *SQLTODORepository) CreateTODO(ctx context.Context, subject, description string, opts ...TODOOption) (*model.TODO, error) {
+	attrs, err := newTODOAttributes(opts)
 	if err != nil {
-		return nil, err // 挿入時のエラーをそのまま返す
+		return nil, err
 	}
 
-	// 挿入された TODO の ID を取得
-	id, err := result.LastInsertId()
+	// タグも同時に登録するため、トランザクション内で挿入する
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err // ID の取得時のエラーをそのまま返す
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	// 挿入された TODO を取得
-	row := s.db.QueryRowContext(ctx, confirm, id)
+	todo, err := createTODO(ctx, tx, subject, description, attrs)
+	if err != nil {
+		return nil, err
+	}
 
-	var fetchedSubject, fetchedDescription string
-	var createdAt, updatedAt time.Time
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
-	err = row.Scan(&fetchedSubject, &fetchedDescription, &createdAt, &updatedAt)
+	return todo, nil
+}
+
+// createTODO は tx の中で TODO を作成し、作成した TODO を返します。
+func createTODO(ctx context.Context, tx *sqlTx, subject, description string, attrs *todoAttributes) (*model.TODO, error) {
+	const insert = `INSERT INTO todos(subject, description, completed_at, due_at, priority, parent_id, recurrence, occurrence, list_id, owner_id)
+		VALUES(?, ?, CASE WHEN ? THEN DATETIME('now') END, ?, ?, ?, ?, ?, ?, ?) RETURNING id`
+	columns, columnArgs := todoColumns(ctx)
+	confirm := `SELECT ` + columns + ` FROM todos WHERE id = ?`
+
+	done := attrs.done != nil && *attrs.done
+	priority := model.PriorityNone
+	if attrs.priority != nil {
+		priority = *attrs.priority
+	}
+
+	if attrs.parentID != nil {
+		if err := checkParent(ctx, tx, 0, *attrs.parentID); err != nil {
+			return nil, err
+		}
+	}
+	if attrs.listID != nil {
+		if err := checkList(ctx, tx, *attrs.listID); err != nil {
+			return nil, err
+		}
+	}
+
+	// TODO を DB に挿入し、挿入された TODO の ID を取得
+	// PostgreSQL のドライバーは LastInsertId に対応していないため、RETURNING で受け取る
+	var id int64
+	err := tx.QueryRowContext(ctx, insert, subject, description, done, dbTime(attrs.dueAt), priority, attrs.parentID, attrs.recurrence, occurrenceFor(attrs.recurrence, 0), attrs.listID, ownerID(ctx)).Scan(&id)
 	if err != nil {
-		return nil, err // 取得時のエラーをそのまま返す
+		return nil, err // 挿入時のエラーをそのまま返す
 	}
 
-	// TODO 構造体を作成
-	todo := &model.TODO{
-		ID:          int64(id),
-		Subject:     fetchedSubject,
-		Description: fetchedDescription,
-		CreatedAt:   createdAt,
-		UpdatedAt:   updatedAt,
+	if attrs.tagsSet {
+		if err := setTags(ctx, tx, id, attrs.tags); err != nil {
+			return nil, err
+		}
+	}
+	if err := recordRevision(ctx, tx, model.RevisionActionCreate, id); err != nil {
+		return nil, err
 	}
 
+	// 挿入された TODO を取得
+	todo, err := scanTODO(tx.QueryRowContext(ctx, confirm, append(columnArgs, id)...))
+	if err != nil {
+		return nil, err
+	}
+	if err := attachTags(ctx, tx, []*model.TODO{todo}); err != nil {
+		return nil, err
+	}
 	return todo, nil
 }
 
 // ReadTODO reads TODOs on DB.
-func (s *TODOService) ReadTODO(ctx context.Context, prevID, size int64) ([]*model.TODO, error) {
-	const (
-			read       = `SELECT id, subject, description, created_at, updated_at FROM todos ORDER BY id DESC LIMIT ?`
-			readWithID = `SELECT id, subject, description, created_at, updated_at FROM todos WHERE id < ? ORDER BY id DESC LIMIT ?`
-	)
-
+func (s *SQLTODORepository) ReadTODO(ctx context.Context, prevID, size int64, opts ...ReadOption) ([]*model.TODO, error) {
 	// size が 0 以下の場合、空スライスを返す
 	if size <= 0 {
-			return []*model.TODO{}, nil
+		return []*model.TODO{}, nil
 	}
 
-	var rows *sql.Rows
-	var err error
+	filter := newReadFilter(opts)
 
-	// prevID の有無でクエリを選択
+	// 条件に応じて WHERE 句を組み立てる。ゴミ箱の TODO と、ctx の利用者が読めない TODO は返さない
+	access, args := todoAccessFilter(ctx, "", false)
+	conds := []string{"deleted_at IS NULL", access}
 	if prevID > 0 {
-			rows, err = s.db.QueryContext(ctx, readWithID, prevID, size)
-	} else {
-			rows, err = s.db.QueryContext(ctx, read, size)
+		conds = append(conds, "id < ?")
+		args = append(args, prevID)
 	}
-	if err != nil {
-			return nil, err
+	switch filter.status {
+	case "", model.TODOStatusAll:
+	case model.TODOStatusDone:
+		conds = append(conds, "completed_at IS NOT NULL")
+	case model.TODOStatusUndone:
+		conds = append(conds, "completed_at IS NULL")
+	default:
+		return nil, fmt.Errorf("unknown status: %q", filter.status)
 	}
-	defer rows.Close()
-
-	var todos []*model.TODO
-
-	// 取得した行をスキャンしてスライスに追加
-	for rows.Next() {
-			var todo model.TODO
-			if err := rows.Scan(&todo.ID, &todo.Subject, &todo.Description, &todo.CreatedAt, &todo.UpdatedAt); err != nil {
-					return nil, err
-			}
-			todos = append(todos, &todo)
+	if filter.overdue {
+		conds = append(conds, "due_at < DATETIME('now') AND completed_at IS NULL")
 	}
-
-	// イテレーション中にエラーが発生したか確認
-	if err := rows.Err(); err != nil {
-			return nil, err
+	if filter.dueBefore != nil {
+		conds = append(conds, "due_at < ?")
+		args = append(args, dbTime(filter.dueBefore))
+	}
+	if filter.dueAfter != nil {
+		conds = append(conds, "due_at > ?")
+		args = append(args, dbTime(filter.dueAfter))
+	}
+	if len(filter.tags) > 0 {
+		sub := `SELECT tt.todo_id FROM todo_tags tt JOIN tags t ON t.id = tt.tag_id WHERE t.name IN (` + placeholders(len(filter.tags)) + `)`
+		for _, tag := range filter.tags {
+			args = append(args, tag)
+		}
+		switch filter.tagMode {
+		case "", model.TagModeAnd:
+			// すべてのタグが付いている TODO のみ残す
+			sub += ` GROUP BY tt.todo_id HAVING COUNT(DISTINCT t.id) = ?`
+			args = append(args, len(uniqueStrings(filter.tags)))
+		case model.TagModeOr:
+		default:
+			return nil, fmt.Errorf("unknown tag mode: %q", filter.tagMode)
+		}
+		conds = append(conds, "id IN ("+sub+")")
+	}
+	if filter.listIDSet {
+		if filter.listID != nil {
+			conds = append(conds, "list_id = ?")
+			args = append(args, *filter.listID)
+		} else {
+			conds = append(conds, "list_id IS NULL")
+		}
+	} else if !filter.includeArchived {
+		// リストを指定しない場合は、アーカイブされたリストの TODO を含めない
+		conds = append(conds, notArchived(""))
 	}
 
-	// 空の結果の場合は空スライスを返す
-	if todos == nil {
-			todos = []*model.TODO{}
+	// ソート順を決め、カーソルがあればその位置より後ろに絞り込む
+	expr, ok := sortExprs[filter.sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort key: %q", filter.sort)
 	}
+	if filter.order != model.SortOrderAsc && filter.order != model.SortOrderDesc {
+		return nil, fmt.Errorf("unknown sort order: %q", filter.order)
+	}
+	dir, cmp := "DESC", "<"
+	if filter.order == model.SortOrderAsc {
+		dir, cmp = "ASC", ">"
+	}
+	if c := filter.cursor; c != nil {
+		if c.Sort != filter.sort || c.Order != filter.order {
+			return nil, ErrInvalidCursor
+		}
+		conds = append(conds, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", expr, cmp))
+		args = append(args, c.arg(), c.arg(), c.ID)
+	}
+
+	columns, columnArgs := todoColumns(ctx)
+	query := `SELECT ` + columns + ` FROM todos WHERE ` + strings.Join(conds, " AND ")
+	// 同じ値の TODO の順序が一意に定まるよう、id を第 2 キーにする
+	query += fmt.Sprintf(` ORDER BY %[1]s %[2]s, id %[2]s LIMIT ?`, expr, dir)
+	args = append(args, size)
 
+	rows, err := s.db.QueryContext(ctx, query, append(columnArgs, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := scanTODOs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := attachTags(ctx, s.db, todos); err != nil {
+		return nil, err
+	}
 	return todos, nil
 }
 
+// ReadTODOByID reads the TODO of id on DB. ゴミ箱の TODO は存在しないものとして ErrNotFound を返します。
+func (s *SQLTODORepository) ReadTODOByID(ctx context.Context, id int64) (*model.TODO, error) {
+	todo, err := readTODO(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if todo.DeletedAt != nil {
+		return nil, &model.ErrNotFound{}
+	}
+	return todo, nil
+}
+
 // UpdateTODO updates the TODO on DB.
-func (s *TODOService) UpdateTODO(ctx context.Context, id int64, subject, description string) (*model.TODO, error) {
-	const (
-			update  = `UPDATE todos SET subject = ?, description = ? WHERE id = ?`
-			confirm = `SELECT id, subject, description, created_at, updated_at FROM todos WHERE id = ?`
-	)
+func (s *SQLTODORepository) UpdateTODO(ctx context.Context, id int64, subject, description string, opts ...TODOOption) (*model.TODO, error) {
+	attrs, err := newTODOAttributes(opts)
+	if err != nil {
+		return nil, err
+	}
 
 	// トランザクションの開始
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-			return nil, err
+		return nil, err
 	}
 	defer tx.Rollback()
 
+	todo, err := updateTODO(ctx, tx, id, subject, description, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	// トランザクションのコミット
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// 更新された TODO を返す
+	return todo, nil
+}
+
+// updateTODO は tx の中で id の TODO を更新し、更新した TODO を返します。
+func updateTODO(ctx context.Context, tx *sqlTx, id int64, subject, description string, attrs *todoAttributes) (*model.TODO, error) {
+	columns, columnArgs := todoColumns(ctx)
+	confirm := `SELECT ` + columns + ` FROM todos WHERE id = ?`
+
+	// 指定された属性に応じて SET 句を組み立てる
+	sets := []string{"subject = ?", "description = ?"}
+	args := []interface{}{subject, description}
+	if attrs.done != nil {
+		// 既に完了済みの TODO を再度完了にしても completed_at は更新しない
+		sets = append(sets, "completed_at = CASE WHEN ? THEN COALESCE(completed_at, DATETIME('now')) ELSE NULL END")
+		args = append(args, *attrs.done)
+	}
+	if attrs.dueAtSet {
+		// 期限が変わった場合はリマインドを再度送れるようにする
+		sets = append(sets, "due_at = ?", "reminded_at = NULL")
+		args = append(args, dbTime(attrs.dueAt))
+	}
+	if attrs.priority != nil {
+		sets = append(sets, "priority = ?")
+		args = append(args, *attrs.priority)
+	}
+	if attrs.parentIDSet {
+		sets = append(sets, "parent_id = ?")
+		args = append(args, attrs.parentID)
+	}
+	if attrs.recurrenceSet {
+		// 繰り返しを解除した TODO は 0 回目に、繰り返さなかった TODO は 1 回目にする (occurrenceFor と同じ)
+		sets = append(sets, "recurrence = ?", "occurrence = CASE WHEN ? THEN 0 WHEN occurrence = 0 THEN 1 ELSE occurrence END")
+		args = append(args, attrs.recurrence, attrs.recurrence == "")
+	}
+	if attrs.listIDSet {
+		sets = append(sets, "list_id = ?")
+		args = append(args, attrs.listID)
+	}
+	update := `UPDATE todos SET ` + strings.Join(sets, ", ") + ` WHERE id = ? AND deleted_at IS NULL`
+	args = append(args, id)
+	if attrs.expectedVersion != nil {
+		update += ` AND version = ?`
+		args = append(args, *attrs.expectedVersion)
+	}
+
+	// 未完了から完了になったかを判定するため、更新前の状態を取得する。ゴミ箱の TODO は更新できない
+	// ctx の利用者が読めない TODO は存在しないものとして扱い、読めるが変更できない TODO は ErrForbidden とする
+	if err := checkWritable(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	// 同じ TODO を同時に更新するトランザクションが、更新前の同じ version や完了状態を読まないよう行をロックする
+	var (
+		wasDone bool
+		version int64
+	)
+	const current = `SELECT completed_at IS NOT NULL, version FROM todos WHERE id = ? FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, current, id).Scan(&wasDone, &version); err != nil {
+		return nil, err
+	}
+	if attrs.expectedVersion != nil && *attrs.expectedVersion != version {
+		return nil, preconditionFailed(ctx, tx, id)
+	}
+
+	// 親を付け替える場合は、親が存在し循環しないことを確認する
+	if attrs.parentIDSet && attrs.parentID != nil {
+		if err := checkParent(ctx, tx, id, *attrs.parentID); err != nil {
+			return nil, err
+		}
+	}
+	if attrs.listIDSet && attrs.listID != nil {
+		if err := checkList(ctx, tx, *attrs.listID); err != nil {
+			return nil, err
+		}
+	}
+
 	// Prepared Statement の作成 (UPDATE)
 	stmtUpdate, err := tx.PrepareContext(ctx, update)
 	if err != nil {
-			return nil, err
+		return nil, err
 	}
 	defer stmtUpdate.Close()
 
 	// UPDATE クエリの実行
-	result, err := stmtUpdate.ExecContext(ctx, subject, description, id)
+	result, err := stmtUpdate.ExecContext(ctx, args...)
 	if err != nil {
-			return nil, err
+		return nil, err
 	}
 
 	// 更新された行数の確認
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-			return nil, err
+		return nil, err
 	}
 	if rowsAffected == 0 {
-			return nil, &model.ErrNotFound{}
+		if attrs.expectedVersion != nil {
+			return nil, preconditionFailed(ctx, tx, id)
+		}
+		return nil, &model.ErrNotFound{}
+	}
+
+	if attrs.tagsSet {
+		if err := setTags(ctx, tx, id, attrs.tags); err != nil {
+			return nil, err
+		}
+	}
+	if err := recordRevision(ctx, tx, model.RevisionActionUpdate, id); err != nil {
+		return nil, err
+	}
+
+	// 繰り返しの TODO が完了した場合は、次の TODO を作成する
+	if !wasDone && attrs.done != nil && *attrs.done {
+		if err := scheduleNext(ctx, tx, id, time.Now()); err != nil {
+			return nil, err
+		}
 	}
 
 	// Prepared Statement の作成 (SELECT)
 	stmtSelect, err := tx.PrepareContext(ctx, confirm)
 	if err != nil {
-			return nil, err
+		return nil, err
 	}
 	defer stmtSelect.Close()
 
 	// 更新された TODO の取得
-	row := stmtSelect.QueryRowContext(ctx, id)
-	var todo model.TODO
-	if err := row.Scan(&todo.ID, &todo.Subject, &todo.Description, &todo.CreatedAt, &todo.UpdatedAt); err != nil {
-			return nil, err
+	todo, err := scanTODO(stmtSelect.QueryRowContext(ctx, append(columnArgs, id)...))
+	if err != nil {
+		return nil, err
+	}
+	if err := attachTags(ctx, tx, []*model.TODO{todo}); err != nil {
+		return nil, err
 	}
 
-	// トランザクションのコミット
-	if err := tx.Commit(); err != nil {
-			return nil, err
+	return todo, nil
+}
+
+// DeleteTODO moves TODOs to the trash by ids. Use RestoreTODOs to bring them back;
+// PurgeTODOs permanently deletes them.
+// 削除する TODO の子は、既定では削除する TODO の親に付け替えます。WithCascade を指定すると子孫もすべてゴミ箱に移します。
+func (s *SQLTODORepository) DeleteTODO(ctx context.Context, ids []int64, opts ...DeleteOption) error {
+	// ids が空の場合、何もせずに nil を返す
+	if len(ids) == 0 {
+		return nil
 	}
 
-	// 更新された TODO を返す
-	return &todo, nil
+	mode := newDeleteMode(opts)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// ctx の利用者が読めない TODO は存在しないものとして削除対象から除く。読めるが変更できない TODO があれば 1 件も削除しない
+	ids, err = writableTODOIDs(ctx, tx, ids, false)
+	if err != nil {
+		return err
+	}
+
+	// 削除対象が 1 件も存在しない (またはすべてゴミ箱にある) 場合、ErrNotFound を返す
+	if len(ids) == 0 {
+		return &model.ErrNotFound{}
+	}
+
+	// version が指定された場合は、削除する TODO が変更されていないことを確認する
+	if mode.expectedVersion != nil {
+		for _, id := range ids {
+			var version int64
+			err := tx.QueryRowContext(ctx, `SELECT version FROM todos WHERE id = ? AND deleted_at IS NULL FOR UPDATE`, id).Scan(&version)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if version != *mode.expectedVersion {
+				return preconditionFailed(ctx, tx, id)
+			}
+		}
+	}
+
+	var deleted []int64
+	if mode.cascade {
+		deleted, err = deleteWithDescendants(ctx, tx, ids)
+	} else {
+		deleted, err = deleteAndReparent(ctx, tx, ids)
+	}
+	if err != nil {
+		return err
+	}
+	if err := recordRevision(ctx, tx, model.RevisionActionDelete, deleted...); err != nil {
+		return err
+	}
+
+	// 正常に削除された場合は nil を返す
+	return tx.Commit()
+}
+
+// readTODO は id の TODO をタグ付きで取得します。存在しない場合や、ctx の利用者が読めない場合は ErrNotFound を返します。
+func readTODO(ctx context.Context, q dbtx, id int64) (*model.TODO, error) {
+	columns, columnArgs := todoColumns(ctx)
+	access, accessArgs := todoAccessFilter(ctx, "", false)
+	args := append(append(columnArgs, id), accessArgs...)
+	todo, err := scanTODO(q.QueryRowContext(ctx, `SELECT `+columns+` FROM todos WHERE id = ? AND `+access, args...))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := attachTags(ctx, q, []*model.TODO{todo}); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// preconditionFailed は id の TODO の現在の状態を持つ ErrPreconditionFailed を返します。
+func preconditionFailed(ctx context.Context, q dbtx, id int64) error {
+	current, err := readTODO(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	return &model.ErrPreconditionFailed{Current: current}
+}
+
+// ReadDueTODOs reads undone TODOs whose due date has passed at now and that have not been reminded yet.
+func (s *SQLTODORepository) ReadDueTODOs(ctx context.Context, now time.Time, size int64) ([]*model.TODO, error) {
+	columns, columnArgs := todoColumns(ctx)
+	read := `SELECT ` + columns + ` FROM todos
+		WHERE due_at <= ? AND completed_at IS NULL AND reminded_at IS NULL AND deleted_at IS NULL
+		ORDER BY due_at, id LIMIT ?`
+
+	rows, err := s.db.QueryContext(ctx, read, append(columnArgs, dbTime(&now), size)...)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := scanTODOs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := attachTags(ctx, s.db, todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// MarkTODOReminded records that the reminder of the TODO has been sent.
+func (s *SQLTODORepository) MarkTODOReminded(ctx context.Context, id int64) error {
+	const update = `UPDATE todos SET reminded_at = DATETIME('now') WHERE id = ?`
+
+	result, err := s.db.ExecContext(ctx, update, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return &model.ErrNotFound{}
+	}
+	return nil
 }
 
-// DeleteTODO deletes TODOs on DB by ids.
-func (s *TODOService) DeleteTODO(ctx context.Context, ids []int64) error {
-    // ids が空の場合、何もせずに nil を返す
-    if len(ids) == 0 {
-        return nil
-    }
-
-    // ids の数だけ '?' を作成し、カンマで区切る
-    placeholders := strings.TrimLeft(strings.Repeat(",?", len(ids)), ",")
-
-    // DELETE クエリを作成
-    query := fmt.Sprintf("DELETE FROM todos WHERE id IN (%s)", placeholders)
-
-    // ids を []interface{} 型に変換
-    args := make([]interface{}, len(ids))
-    for i, id := range ids {
-        args[i] = id
-    }
-
-    // クエリを実行
-    result, err := s.db.ExecContext(ctx, query, args...)
-    if err != nil {
-        return err
-    }
-
-    // 削除された行数を取得
-    rowsAffected, err := result.RowsAffected()
-    if err != nil {
-        return err
-    }
-
-    // 削除された行数が 0 の場合、ErrNotFound を返す
-    if rowsAffected == 0 {
-        return &model.ErrNotFound{}
-    }
-
-    // 正常に削除された場合は nil を返す
-    return nil
+// ClaimTODOs makes the user the owner of every TODO and every list that has no owner, and returns the number of the TODOs.
+// 利用者ごとの TODO や共有できるリストを導入する前に作成された TODO とリストを、BASIC_AUTH_USER_ID の利用者に引き継ぐために使います。
+func (s *SQLTODORepository) ClaimTODOs(ctx context.Context, ownerID int64) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE todos SET owner_id = ? WHERE owner_id IS NULL`, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE lists SET owner_id = ? WHERE owner_id IS NULL`, ownerID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return claimed, nil
 }
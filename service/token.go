@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// tokenPrefix は API トークンの接頭辞です。漏洩したトークンをシークレットスキャンで見つけやすくします。
+const tokenPrefix = "sta_"
+
+// maxTokenNameLength は API トークンの名前の最大の長さです。
+const maxTokenNameLength = 100
+
+// tokenUsedInterval は last_used_at を更新する最小の間隔です。リクエストのたびに書き込まないよう、間隔を空けて記録します。
+const tokenUsedInterval = time.Minute
+
+// tokenKey は、認証に使った API トークンを context に保持するためのキーです。
+type tokenKey struct{}
+
+// ContextWithToken returns a copy of ctx that carries the API token that authenticated the request.
+func ContextWithToken(ctx context.Context, token *model.APIToken) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// TokenFromContext returns the token set by ContextWithToken.
+// パスワードで認証されたリクエストの context にはトークンがありません。
+func TokenFromContext(ctx context.Context) (*model.APIToken, bool) {
+	token, ok := ctx.Value(tokenKey{}).(*model.APIToken)
+	return token, ok && token != nil
+}
+
+// HasScope reports whether the request authenticated in ctx may perform the operations of scope.
+//
+// パスワードやセッションで認証された利用者は todos:read と todos:write を持ち、admin は管理者だけが持ちます。
+// API トークンで認証された場合は、トークンのスコープのうち利用者が持つものだけを持ちます。
+// 管理者でなくなった利用者の admin スコープのトークンは、admin を持ちません。利用者のない ctx はスコープを持ちません。
+func HasScope(ctx context.Context, scope string) bool {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return false
+	}
+	if scope == model.ScopeAdmin && !user.Admin {
+		return false
+	}
+	if token, ok := TokenFromContext(ctx); ok {
+		return token.HasScope(scope)
+	}
+	return true
+}
+
+// A TokenRepository persists API tokens.
+//
+// CreateToken、ReadTokens と RevokeToken は ctx の利用者のトークンを扱います。
+type TokenRepository interface {
+	CreateToken(ctx context.Context, name, tokenHash string, scopes []string, expiresAt *time.Time) (*model.APIToken, error)
+	ReadTokens(ctx context.Context) ([]*model.APIToken, error)
+	RevokeToken(ctx context.Context, id int64) (*model.APIToken, error)
+	// ReadTokenByHash は、ハッシュが tokenHash のトークンとその利用者を返します。
+	ReadTokenByHash(ctx context.Context, tokenHash string) (*model.APIToken, *model.User, error)
+	MarkTokenUsed(ctx context.Context, id int64, now time.Time) error
+}
+
+// tokenColumns は、トークンを取得する際に SELECT するカラムです。scanToken と順序を合わせてください。
+const tokenColumns = `api_tokens.id, api_tokens.user_id, api_tokens.name, api_tokens.scopes,
+	api_tokens.expires_at, api_tokens.last_used_at, api_tokens.revoked_at, api_tokens.created_at`
+
+// A SQLTokenRepository implements TokenRepository on SQLite or PostgreSQL.
+type SQLTokenRepository struct {
+	db *sqlDB
+}
+
+// NewSQLTokenRepository returns new SQLTokenRepository for the driver that db was opened with.
+func NewSQLTokenRepository(db *sql.DB) *SQLTokenRepository {
+	return &SQLTokenRepository{
+		db: newSQLDB(db),
+	}
+}
+
+// scanToken は tokenColumns の順に並んだ値を model.APIToken に変換します。dest は続けて読み込むカラムです。
+func scanToken(row scanner, dest ...interface{}) (*model.APIToken, error) {
+	var (
+		token      model.APIToken
+		scopes     string
+		expiresAt  sql.NullTime
+		lastUsedAt sql.NullTime
+		revokedAt  sql.NullTime
+	)
+	dest = append([]interface{}{&token.ID, &token.UserID, &token.Name, &scopes, &expiresAt, &lastUsedAt, &revokedAt, &token.CreatedAt}, dest...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	token.Scopes = strings.Fields(scopes)
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return &token, nil
+}
+
+// CreateToken creates a token of the user of ctx on DB.
+func (s *SQLTokenRepository) CreateToken(ctx context.Context, name, tokenHash string, scopes []string, expiresAt *time.Time) (*model.APIToken, error) {
+	const (
+		insert = `INSERT INTO api_tokens(user_id, name, token_hash, scopes, expires_at) VALUES(?, ?, ?, ?, ?) RETURNING id`
+		read   = `SELECT ` + tokenColumns + ` FROM api_tokens WHERE id = ?`
+	)
+
+	userID := ownerID(ctx)
+	if userID == nil {
+		return nil, &model.ErrUnauthorized{}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	if err := tx.QueryRowContext(ctx, insert, *userID, name, tokenHash, strings.Join(scopes, " "), dbTime(expiresAt)).Scan(&id); err != nil {
+		return nil, err
+	}
+	token, err := scanToken(tx.QueryRowContext(ctx, read, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// ReadTokens reads the tokens of the user of ctx ordered by id, including revoked and expired tokens.
+func (s *SQLTokenRepository) ReadTokens(ctx context.Context) ([]*model.APIToken, error) {
+	owner, args := ownerFilter(ctx, "user_id")
+	rows, err := s.db.QueryContext(ctx, `SELECT `+tokenColumns+` FROM api_tokens WHERE `+owner+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*model.APIToken{}
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeToken revokes the token of the user of ctx on DB. 取り消し済みのトークンはそのまま返します。
+func (s *SQLTokenRepository) RevokeToken(ctx context.Context, id int64) (*model.APIToken, error) {
+	owner, ownerArgs := ownerFilter(ctx, "user_id")
+	update := `UPDATE api_tokens SET revoked_at = COALESCE(revoked_at, DATETIME('now')) WHERE id = ? AND ` + owner
+	const read = `SELECT ` + tokenColumns + ` FROM api_tokens WHERE id = ?`
+
+	result, err := s.db.ExecContext(ctx, update, append([]interface{}{id}, ownerArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, &model.ErrNotFound{}
+	}
+	return scanToken(s.db.QueryRowContext(ctx, read, id))
+}
+
+// ReadTokenByHash reads the token whose hash is tokenHash and its user on DB.
+func (s *SQLTokenRepository) ReadTokenByHash(ctx context.Context, tokenHash string) (*model.APIToken, *model.User, error) {
+	const read = `SELECT ` + tokenColumns + `, users.name, users.password_hash, users.admin, users.created_at, users.updated_at
+		FROM api_tokens JOIN users ON users.id = api_tokens.user_id WHERE api_tokens.token_hash = ?`
+
+	var user model.User
+	token, err := scanToken(s.db.QueryRowContext(ctx, read, tokenHash), &user.Name, &user.PasswordHash, &user.Admin, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, &model.ErrNotFound{}
+		}
+		return nil, nil, err
+	}
+	user.ID = token.UserID
+	return token, &user, nil
+}
+
+// MarkTokenUsed records that the token was used at now.
+// 前回の記録から tokenUsedInterval が経っていない場合は記録しません。
+func (s *SQLTokenRepository) MarkTokenUsed(ctx context.Context, id int64, now time.Time) error {
+	const update = `UPDATE api_tokens SET last_used_at = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at <= ?)`
+
+	before := now.Add(-tokenUsedInterval)
+	_, err := s.db.ExecContext(ctx, update, dbTime(&now), id, dbTime(&before))
+	return err
+}
+
+// A TokenService issues API tokens and authenticates requests with them on a TokenRepository.
+type TokenService struct {
+	TokenRepository
+}
+
+// NewTokenService returns new TokenService that stores tokens in the SQLite or PostgreSQL database.
+func NewTokenService(db *sql.DB) *TokenService {
+	return NewTokenServiceWithRepository(NewSQLTokenRepository(db))
+}
+
+// NewTokenServiceWithRepository returns new TokenService that stores tokens in repo.
+func NewTokenServiceWithRepository(repo TokenRepository) *TokenService {
+	return &TokenService{
+		TokenRepository: repo,
+	}
+}
+
+// IssueToken creates a token of the user of ctx and returns it with the secret to send as a Bearer token.
+// expiresAt が nil の場合、トークンは取り消すまで有効です。シークレットはハッシュだけを保存するため、後から取得できません。
+// admin スコープのトークンは管理者にしか発行せず、管理者でない利用者には ErrForbidden を返します。
+func (s *TokenService) IssueToken(ctx context.Context, name string, scopes []string, expiresAt *time.Time) (*model.APIToken, string, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, "", &model.ErrUnauthorized{}
+	}
+	switch {
+	case name == "":
+		return nil, "", &model.ErrValidation{Field: "name", Message: "is required"}
+	case utf8.RuneCountInString(name) > maxTokenNameLength:
+		return nil, "", &model.ErrValidation{Field: "name", Message: "must not be longer than 100 characters"}
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, scope := range scopes {
+		if scope == model.ScopeAdmin && !user.Admin {
+			return nil, "", &model.ErrForbidden{Message: "only administrators can issue tokens with the scope " + model.ScopeAdmin}
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", &model.ErrValidation{Field: "expires_at", Message: "must be in the future"}
+	}
+
+	secret, err := generateToken(tokenPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := s.CreateToken(ctx, name, hashToken(secret), scopes, expiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+	return token, secret, nil
+}
+
+// Authenticate returns the user and the token of secret, and records that the token was used.
+// トークンが存在しない、取り消された、または期限切れの場合は ErrUnauthorized を返します。
+func (s *TokenService) Authenticate(ctx context.Context, secret string) (*model.User, *model.APIToken, error) {
+	token, user, err := s.ReadTokenByHash(ctx, hashToken(secret))
+	if err != nil {
+		if model.IsErrNotFound(err) {
+			return nil, nil, &model.ErrUnauthorized{Message: "the token is invalid"}
+		}
+		return nil, nil, err
+	}
+	now := time.Now()
+	switch {
+	case token.RevokedAt != nil:
+		return nil, nil, &model.ErrUnauthorized{Message: "the token has been revoked"}
+	case token.ExpiresAt != nil && !token.ExpiresAt.After(now):
+		return nil, nil, &model.ErrUnauthorized{Message: "the token has expired"}
+	}
+	if err := s.MarkTokenUsed(ctx, token.ID, now); err != nil {
+		return nil, nil, err
+	}
+	return user, token, nil
+}
+
+// normalizeScopes はスコープを検証し、重複を除いて model.Scopes の順に並べ替えます。
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, &model.ErrValidation{Field: "scopes", Message: "is required"}
+	}
+	requested := map[string]bool{}
+	for _, scope := range scopes {
+		requested[scope] = true
+	}
+	var normalized []string
+	for _, scope := range model.Scopes {
+		if requested[scope] {
+			normalized = append(normalized, scope)
+			delete(requested, scope)
+		}
+	}
+	for scope := range requested {
+		return nil, &model.ErrValidation{Field: "scopes", Message: fmt.Sprintf("unknown scope %q, must be one of %s", scope, strings.Join(model.Scopes, ", "))}
+	}
+	return normalized, nil
+}
+
+// generateToken は、prefix で始まる推測できない新しいトークンを返します。
+func generateToken(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken はトークンを保存・照合するためのハッシュを返します。
+// トークンは十分に長い乱数のため、パスワードと異なり bcrypt のような遅いハッシュは使いません。
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// ReadTrash reads TODOs in the trash in descending order of id.
+func (s *SQLTODORepository) ReadTrash(ctx context.Context, prevID, size int64) ([]*model.TODO, error) {
+	if size <= 0 {
+		return []*model.TODO{}, nil
+	}
+
+	columns, args := todoColumns(ctx)
+	access, accessArgs := todoAccessFilter(ctx, "", false)
+	query := `SELECT ` + columns + ` FROM todos WHERE deleted_at IS NOT NULL AND ` + access
+	args = append(args, accessArgs...)
+	if prevID > 0 {
+		query += ` AND id < ?`
+		args = append(args, prevID)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, size)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := scanTODOs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := attachTags(ctx, s.db, todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// RestoreTODOs takes the TODOs out of the trash and returns the restored TODOs ordered by id.
+//
+// 子孫ごとゴミ箱に移した TODO を元に戻すと、同時にゴミ箱に移した子孫も元に戻します。
+// 親がゴミ箱にある TODO は、どの親にも属さない状態で元に戻します。
+func (s *SQLTODORepository) RestoreTODOs(ctx context.Context, ids []int64) ([]*model.TODO, error) {
+	if len(ids) == 0 {
+		return []*model.TODO{}, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// ctx の利用者が読めない TODO は元に戻さない。読めるが変更できない TODO があれば 1 件も元に戻さない
+	ids, err = writableTODOIDs(ctx, tx, ids, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// ゴミ箱にある TODO が 1 件も指定されていない場合、ErrNotFound を返す
+	if len(ids) == 0 {
+		return nil, &model.ErrNotFound{}
+	}
+
+	// 指定された TODO と、同じ deleted_at を持つ子孫を集める
+	collect := fmt.Sprintf(`WITH RECURSIVE restored(id, deleted_at) AS (
+			SELECT id, deleted_at FROM todos WHERE id IN (%s)
+			UNION
+			SELECT t.id, t.deleted_at FROM todos t JOIN restored r ON t.parent_id = r.id AND t.deleted_at = r.deleted_at
+		)
+		SELECT id FROM restored ORDER BY id`, placeholders(len(ids)))
+	rows, err := tx.QueryContext(ctx, collect, int64Args(ids)...)
+	if err != nil {
+		return nil, err
+	}
+	restored, err := scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	in := placeholders(len(restored))
+	restore := fmt.Sprintf(`UPDATE todos SET deleted_at = NULL WHERE id IN (%s)`, in)
+	if _, err := tx.ExecContext(ctx, restore, int64Args(restored)...); err != nil {
+		return nil, err
+	}
+	detach := fmt.Sprintf(`UPDATE todos SET parent_id = NULL
+		WHERE id IN (%s) AND parent_id IN (SELECT id FROM todos WHERE deleted_at IS NOT NULL)`, in)
+	if _, err := tx.ExecContext(ctx, detach, int64Args(restored)...); err != nil {
+		return nil, err
+	}
+	if err := recordRevision(ctx, tx, model.RevisionActionRestore, restored...); err != nil {
+		return nil, err
+	}
+
+	columns, columnArgs := todoColumns(ctx)
+	read := fmt.Sprintf(`SELECT `+columns+` FROM todos WHERE id IN (%s) ORDER BY id`, in)
+	rows, err = tx.QueryContext(ctx, read, append(columnArgs, int64Args(restored)...)...)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := scanTODOs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := attachTags(ctx, tx, todos); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+// PurgeTODOs permanently deletes TODOs that were moved to the trash at or before the given time
+// and returns the number of deleted TODOs.
+func (s *SQLTODORepository) PurgeTODOs(ctx context.Context, before time.Time) (int64, error) {
+	const (
+		// 完全に削除する TODO の子が残っている場合は、どの親にも属さない状態にする
+		detach = `UPDATE todos SET parent_id = NULL
+			WHERE parent_id IN (SELECT id FROM todos WHERE deleted_at <= ?) AND (deleted_at IS NULL OR deleted_at > ?)`
+		purge = `DELETE FROM todos WHERE deleted_at <= ?`
+	)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	cutoff := dbTime(&before)
+	if _, err := tx.ExecContext(ctx, detach, cutoff, cutoff); err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx, purge, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
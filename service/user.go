@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 利用者の名前とパスワードの長さの制限です。bcrypt は 72 バイトを超えるパスワードを扱えません。
+const (
+	maxUserNameLength = 64
+	minPasswordLength = 8
+	maxPasswordBytes  = 72
+)
+
+// userKey は、認証された利用者を context に保持するためのキーです。
+type userKey struct{}
+
+// ContextWithUser returns a copy of ctx that carries the authenticated user.
+// TODORepository reads and changes only the TODOs owned by the user of the context
+// and the TODOs in the lists shared with the user; see access.go.
+func ContextWithUser(ctx context.Context, user *model.User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext returns the user set by ContextWithUser.
+// 利用者のない context は、リマインドやゴミ箱のパージなど、すべての TODO を扱うバックグラウンドの処理に使います。
+func UserFromContext(ctx context.Context) (*model.User, bool) {
+	user, ok := ctx.Value(userKey{}).(*model.User)
+	return user, ok && user != nil
+}
+
+// ownerFilter は、column が ctx の利用者の id と一致する TODO に絞り込む WHERE 句の条件とその引数を返します。
+// 利用者のない ctx では絞り込みません。
+func ownerFilter(ctx context.Context, column string) (string, []interface{}) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return "1 = 1", nil
+	}
+	return column + " = ?", []interface{}{user.ID}
+}
+
+// ownerID は、作成する TODO やリストの owner_id として ctx の利用者の id を返します。利用者のない ctx では nil を返します。
+func ownerID(ctx context.Context) *int64 {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	id := user.ID
+	return &id
+}
+
+// A UserRepository persists users.
+type UserRepository interface {
+	CreateUser(ctx context.Context, name, passwordHash string) (*model.User, error)
+	ReadUserByName(ctx context.Context, name string) (*model.User, error)
+	UpdateUserPassword(ctx context.Context, id int64, passwordHash string) (*model.User, error)
+	UpdateUserAdmin(ctx context.Context, id int64, admin bool) (*model.User, error)
+}
+
+// userColumns は、利用者を取得する際に SELECT するカラムです。scanUser と順序を合わせてください。
+const userColumns = `id, name, password_hash, admin, created_at, updated_at`
+
+// A SQLUserRepository implements UserRepository on SQLite or PostgreSQL.
+type SQLUserRepository struct {
+	db *sqlDB
+}
+
+// NewSQLUserRepository returns new SQLUserRepository for the driver that db was opened with.
+func NewSQLUserRepository(db *sql.DB) *SQLUserRepository {
+	return &SQLUserRepository{
+		db: newSQLDB(db),
+	}
+}
+
+// scanUser は userColumns の順に並んだ行を model.User に変換します。存在しない場合は ErrNotFound を返します。
+func scanUser(row scanner) (*model.User, error) {
+	var user model.User
+	if err := row.Scan(&user.ID, &user.Name, &user.PasswordHash, &user.Admin, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &model.ErrNotFound{}
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateUser creates a user on DB. 同じ名前の利用者が存在する場合は ErrConflict を返します。
+func (s *SQLUserRepository) CreateUser(ctx context.Context, name, passwordHash string) (*model.User, error) {
+	const (
+		exists = `SELECT EXISTS (SELECT 1 FROM users WHERE name = ?)`
+		insert = `INSERT INTO users(name, password_hash) VALUES(?, ?) RETURNING id`
+		read   = `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+	)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var found bool
+	if err := tx.QueryRowContext(ctx, exists, name).Scan(&found); err != nil {
+		return nil, err
+	}
+	if found {
+		return nil, &model.ErrConflict{Message: "user " + name + " already exists"}
+	}
+
+	var id int64
+	if err := tx.QueryRowContext(ctx, insert, name, passwordHash).Scan(&id); err != nil {
+		return nil, err
+	}
+	user, err := scanUser(tx.QueryRowContext(ctx, read, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ReadUserByName reads the user of name on DB.
+func (s *SQLUserRepository) ReadUserByName(ctx context.Context, name string) (*model.User, error) {
+	return scanUser(s.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE name = ?`, name))
+}
+
+// UpdateUserPassword replaces the password hash of the user on DB.
+func (s *SQLUserRepository) UpdateUserPassword(ctx context.Context, id int64, passwordHash string) (*model.User, error) {
+	const (
+		update = `UPDATE users SET password_hash = ? WHERE id = ?`
+		read   = `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+	)
+
+	result, err := s.db.ExecContext(ctx, update, passwordHash, id)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, &model.ErrNotFound{}
+	}
+	return scanUser(s.db.QueryRowContext(ctx, read, id))
+}
+
+// UpdateUserAdmin sets whether the user is an administrator on DB.
+func (s *SQLUserRepository) UpdateUserAdmin(ctx context.Context, id int64, admin bool) (*model.User, error) {
+	const (
+		update = `UPDATE users SET admin = ? WHERE id = ?`
+		read   = `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+	)
+
+	result, err := s.db.ExecContext(ctx, update, admin, id)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, &model.ErrNotFound{}
+	}
+	return scanUser(s.db.QueryRowContext(ctx, read, id))
+}
+
+// A UserService implements signup and password authentication of users on a UserRepository.
+type UserService struct {
+	UserRepository
+	// Reserved は、Signup で登録できない名前かを返します。nil の場合はすべての名前を登録できます。
+	// htpasswd ファイルの運用者の名前を、/signup で先に登録されないよう予約するために使います。
+	Reserved func(name string) bool
+	// Administrators は、ProvisionUser で管理者にする運用者の名前かを返します。nil の場合、運用者は管理者になりません。
+	// 運用者を認証するたびに照合するため、名前を外すと次の認証から管理者でなくなります。
+	Administrators func(name string) bool
+}
+
+// NewUserService returns new UserService that stores users in the SQLite or PostgreSQL database.
+func NewUserService(db *sql.DB) *UserService {
+	return NewUserServiceWithRepository(NewSQLUserRepository(db))
+}
+
+// NewUserServiceWithRepository returns new UserService that stores users in repo.
+func NewUserServiceWithRepository(repo UserRepository) *UserService {
+	return &UserService{
+		UserRepository: repo,
+	}
+}
+
+// dummyPasswordHash は、存在しない利用者の認証にも同じ時間をかけるために比較するハッシュです。
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// Signup creates a user with the password.
+// 名前やパスワードが不正な場合は ErrValidation を、同じ名前の利用者が存在するか名前が予約されている場合は ErrConflict を返します。
+func (s *UserService) Signup(ctx context.Context, name, password string) (*model.User, error) {
+	if err := validateUserName(name); err != nil {
+		return nil, err
+	}
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+	if s.Reserved != nil && s.Reserved(name) {
+		return nil, &model.ErrConflict{Message: "user " + name + " is reserved"}
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return s.CreateUser(ctx, name, string(hash))
+}
+
+// Authenticate returns the user if the password is correct.
+// 利用者が存在しない場合もパスワードが違う場合も、区別せずに ErrUnauthorized を返します。
+func (s *UserService) Authenticate(ctx context.Context, name, password string) (*model.User, error) {
+	user, err := s.ReadUserByName(ctx, name)
+	if err != nil {
+		if !model.IsErrNotFound(err) {
+			return nil, err
+		}
+		// 利用者が存在するかを応答時間から推測されないよう、存在しない場合もハッシュを比較する
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, &model.ErrUnauthorized{Message: "the name or the password is incorrect"}
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, &model.ErrUnauthorized{Message: "the name or the password is incorrect"}
+	}
+	return user, nil
+}
+
+// EnsureUser creates the user with the password, or resets the password if the user exists
+// and the password is different. The user is made an administrator.
+//
+// BASIC_AUTH_USER_ID と BASIC_AUTH_PASSWORD の利用者を起動時に用意するために使います。
+// 運用者が指定するパスワードのため、Signup と異なりパスワードの長さは検証しません。
+func (s *UserService) EnsureUser(ctx context.Context, name, password string) (*model.User, error) {
+	if err := validateUserName(name); err != nil {
+		return nil, err
+	}
+	if len(password) > maxPasswordBytes {
+		return nil, &model.ErrValidation{Field: "password", Message: "must not be longer than 72 bytes"}
+	}
+
+	user, err := s.ReadUserByName(ctx, name)
+	if err != nil && !model.IsErrNotFound(err) {
+		return nil, err
+	}
+	if user == nil || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			user, err = s.CreateUser(ctx, name, string(hash))
+		} else {
+			user, err = s.UpdateUserPassword(ctx, user.ID, string(hash))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if user.Admin {
+		return user, nil
+	}
+	return s.UpdateUserAdmin(ctx, user.ID, true)
+}
+
+// ProvisionUser returns the user of name, and creates the user without a password if the user does not exist.
+//
+// htpasswd ファイルで認証された運用者を、TODO の所有者として扱うために使います。運用者は Administrators に含まれる場合だけ管理者になります。
+// パスワードのない利用者は、Basic 認証や /login ではパスワードで認証できません。
+// /signup などでパスワードを設定した利用者は運用者とは別の利用者のため、その TODO を運用者に渡さないよう ErrConflict を返します。
+func (s *UserService) ProvisionUser(ctx context.Context, name string) (*model.User, error) {
+	if err := validateUserName(name); err != nil {
+		return nil, err
+	}
+	user, err := s.ReadUserByName(ctx, name)
+	if err != nil {
+		if !model.IsErrNotFound(err) {
+			return nil, err
+		}
+		user, err = s.CreateUser(ctx, name, "")
+		if model.IsErrConflict(err) {
+			// 他のリクエストが同時に作成した場合は、その利用者を使う
+			user, err = s.ReadUserByName(ctx, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if user.PasswordHash != "" {
+		return nil, &model.ErrConflict{Message: "user " + name + " is registered with a password"}
+	}
+	admin := s.Administrators != nil && s.Administrators(name)
+	if user.Admin == admin {
+		return user, nil
+	}
+	return s.UpdateUserAdmin(ctx, user.ID, admin)
+}
+
+// validateUserName は利用者の名前を検証します。名前は Basic 認証で区切り文字として使う : を含められません。
+func validateUserName(name string) error {
+	switch {
+	case name == "":
+		return &model.ErrValidation{Field: "name", Message: "is required"}
+	case utf8.RuneCountInString(name) > maxUserNameLength:
+		return &model.ErrValidation{Field: "name", Message: "must not be longer than 64 characters"}
+	case strings.ContainsAny(name, ": \t\r\n"):
+		return &model.ErrValidation{Field: "name", Message: "must not contain a colon or a space"}
+	}
+	return nil
+}
+
+// validatePassword はパスワードを検証します。
+func validatePassword(password string) error {
+	switch {
+	case utf8.RuneCountInString(password) < minPasswordLength:
+		return &model.ErrValidation{Field: "password", Message: "must be at least 8 characters"}
+	case len(password) > maxPasswordBytes:
+		return &model.ErrValidation{Field: "password", Message: "must not be longer than 72 bytes"}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package trash
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/periodic"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A Purger periodically deletes TODOs that have been in the trash longer than the retention.
+type Purger struct {
+	service   *service.TODOService
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewPurger returns new Purger.
+func NewPurger(svc *service.TODOService, retention, interval time.Duration) *Purger {
+	return &Purger{
+		service:   svc,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run は ctx がキャンセルされるまで interval ごとに保持期間を過ぎた TODO を完全に削除します。
+func (p *Purger) Run(ctx context.Context) {
+	periodic.RunNow(ctx, p.interval, p.tick)
+}
+
+// tick は retention より前にゴミ箱に移された TODO を完全に削除します。
+func (p *Purger) tick(ctx context.Context) {
+	purged, err := p.service.PurgeTODOs(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Println("trash: failed to purge TODOs:", err)
+		}
+		return
+	}
+	if purged > 0 {
+		log.Printf("trash: purged %d TODOs", purged)
+	}
+}
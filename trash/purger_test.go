@@ -0,0 +1,51 @@
+package trash_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/service"
+	"github.com/TechBowl-japan/go-stations/trash"
+)
+
+func TestPurger_Run(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	svc := service.NewTODOServiceWithRepository(service.NewMemoryStore())
+	trashed, err := svc.CreateTODO(ctx, "trashed", "")
+	if err != nil {
+		t.Fatal("failed to create, err =", err)
+	}
+	kept, err := svc.CreateTODO(ctx, "kept", "")
+	if err != nil {
+		t.Fatal("failed to create, err =", err)
+	}
+	if err := svc.DeleteTODO(ctx, []int64{trashed.ID}); err != nil {
+		t.Fatal("failed to delete, err =", err)
+	}
+
+	// run は purger を d の間だけ動かし、ゴミ箱に残っている TODO の数を返す
+	run := func(retention, d time.Duration) int {
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		trash.NewPurger(svc, retention, 10*time.Millisecond).Run(ctx)
+		todos, err := svc.ReadTrash(context.Background(), 0, 10)
+		if err != nil {
+			t.Fatal("failed to read the trash, err =", err)
+		}
+		return len(todos)
+	}
+
+	// 保持期間内の TODO は削除しない
+	if n := run(time.Hour, 50*time.Millisecond); n != 1 {
+		t.Errorf("TODOs within the retention should not be purged, given = %d in the trash", n)
+	}
+	if n := run(0, 50*time.Millisecond); n != 0 {
+		t.Errorf("TODOs past the retention should be purged, given = %d in the trash", n)
+	}
+	if _, err := svc.ReadTODOByID(ctx, kept.ID); err != nil {
+		t.Error("TODOs not in the trash should be kept, err =", err)
+	}
+}